@@ -5,15 +5,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net"
 	"net/http"
 	"time"
+
+	"github.com/igomonov88/users/internal/platform/logger"
 )
 
 // Datadog provides the ability to publish metrics to Datadog.
 type Datadog struct {
-	log    *log.Logger
+	log    *logger.Logger
 	apiKey string
 	host   string
 	tr     *http.Transport
@@ -21,7 +22,7 @@ type Datadog struct {
 }
 
 // New initializes Datadog access for publishing metrics.
-func New(log *log.Logger, apiKey string, host string) *Datadog {
+func New(log *logger.Logger, apiKey string, host string) *Datadog {
 	tr := http.Transport{
 		Proxy: http.ProxyFromEnvironment,
 		DialContext: (&net.Dialer{
@@ -54,20 +55,20 @@ func New(log *log.Logger, apiKey string, host string) *Datadog {
 func (d *Datadog) Publish(data map[string]interface{}) {
 	doc, err := marshalDatadog(d.log, data)
 	if err != nil {
-		d.log.Println("datadog.publish :", err)
+		d.log.Error("datadog.publish : marshaling", "error", err)
 		return
 	}
 
 	if err := sendDatadog(d, doc); err != nil {
-		d.log.Println("datadog.publish :", err)
+		d.log.Error("datadog.publish : sending", "error", err)
 		return
 	}
 
-	log.Println("datadog.publish : published :", string(doc))
+	d.log.Debug("datadog.publish : published", "doc", json.RawMessage(doc))
 }
 
 // marshalDatadog converts the data map to datadog JSON document.
-func marshalDatadog(log *log.Logger, data map[string]interface{}) ([]byte, error) {
+func marshalDatadog(log *logger.Logger, data map[string]interface{}) ([]byte, error) {
 	/*
 		{ "series" : [
 				{
@@ -129,7 +130,7 @@ func marshalDatadog(log *log.Logger, data map[string]interface{}) ([]byte, error
 	// Convert the data into JSON.
 	out, err := json.MarshalIndent(doc, "", "    ")
 	if err != nil {
-		log.Println("datadog.publish : marshaling :", err)
+		log.Error("datadog.publish : marshaling", "error", err)
 		return nil, err
 	}
 
@@ -3,24 +3,25 @@ package expvar
 import (
 	"context"
 	"encoding/json"
-	"log"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/dimfeld/httptreemux/v5"
+
+	"github.com/igomonov88/users/internal/platform/logger"
 )
 
 // Expvar provide our basic publishing.
 type Expvar struct {
-	log    *log.Logger
+	log    *logger.Logger
 	server http.Server
 	data   map[string]interface{}
 	mu     sync.Mutex
 }
 
 // New starts a service for consuming the raw expvar stats.
-func New(log *log.Logger, host string, route string, readTimeout, writeTimeout time.Duration) *Expvar {
+func New(log *logger.Logger, host string, route string, readTimeout, writeTimeout time.Duration) *Expvar {
 	mux := httptreemux.New()
 	exp := Expvar{
 		log: log,
@@ -30,15 +31,16 @@ func New(log *log.Logger, host string, route string, readTimeout, writeTimeout t
 			ReadTimeout:    readTimeout,
 			WriteTimeout:   writeTimeout,
 			MaxHeaderBytes: 1 << 20,
+			ErrorLog:       log.Std(logger.Error),
 		},
 	}
 
 	mux.Handle("GET", route, exp.handler)
 
 	go func() {
-		log.Println("expvar : API Listening", host)
+		log.Info("expvar : API Listening", "host", host)
 		if err := exp.server.ListenAndServe(); err != nil {
-			log.Println("expvar : ERROR :", err)
+			log.Error("expvar : API Listener closed", "error", err)
 		}
 	}()
 
@@ -47,8 +49,8 @@ func New(log *log.Logger, host string, route string, readTimeout, writeTimeout t
 
 // Stop shuts down the service.
 func (exp *Expvar) Stop(shutdownTimeout time.Duration) {
-	exp.log.Println("expvar : Start shutdown...")
-	defer exp.log.Println("expvar : Completed")
+	exp.log.Info("expvar : Start shutdown")
+	defer exp.log.Info("expvar : Completed")
 
 	// Create context for Shutdown call.
 	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
@@ -56,9 +58,9 @@ func (exp *Expvar) Stop(shutdownTimeout time.Duration) {
 
 	// Asking listener to shutdown and load shed.
 	if err := exp.server.Shutdown(ctx); err != nil {
-		exp.log.Printf("expvar : Graceful shutdown did not complete in %v : %v", shutdownTimeout, err)
+		exp.log.Error("expvar : Graceful shutdown did not complete", "timeout", shutdownTimeout, "error", err)
 		if err := exp.server.Close(); err != nil {
-			exp.log.Fatalf("expvar : Could not stop http server: %v", err)
+			exp.log.Fatal("expvar : Could not stop http server", "error", err)
 		}
 	}
 }
@@ -85,12 +87,13 @@ func (exp *Expvar) handler(w http.ResponseWriter, r *http.Request, params map[st
 	exp.mu.Unlock()
 
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		exp.log.Println("expvar : ERROR :", err)
+		exp.log.Error("expvar : encoding metrics", "error", err)
 	}
 
-	log.Printf("expvar : (%d) : %s %s -> %s",
-		http.StatusOK,
-		r.Method, r.URL.Path,
-		r.RemoteAddr,
+	exp.log.Info("request completed",
+		"method", r.Method,
+		"path", r.URL.Path,
+		"status", http.StatusOK,
+		"remote_ip", r.RemoteAddr,
 	)
 }
@@ -2,9 +2,10 @@ package publisher
 
 import (
 	"encoding/json"
-	"log"
 	"sync"
 	"time"
+
+	"github.com/igomonov88/users/internal/platform/logger"
 )
 
 // Set of possible publisher types.
@@ -30,7 +31,7 @@ type Publisher func(map[string]interface{})
 // Publish provides the ability to receive metrics
 // on an interval.
 type Publish struct {
-	log       *log.Logger
+	log       *logger.Logger
 	collector Collector
 	publisher []Publisher
 	wg        sync.WaitGroup
@@ -39,7 +40,7 @@ type Publish struct {
 }
 
 // New creates a Publish for consuming and publishing metrics.
-func New(log *log.Logger, collector Collector, interval time.Duration, publisher ...Publisher) (*Publish, error) {
+func New(log *logger.Logger, collector Collector, interval time.Duration, publisher ...Publisher) (*Publish, error) {
 	p := Publish{
 		log:       log,
 		collector: collector,
@@ -75,7 +76,7 @@ func (p *Publish) Stop() {
 func (p *Publish) update() {
 	data, err := p.collector.Collect()
 	if err != nil {
-		p.log.Println(err)
+		p.log.Error("publisher : collecting metrics", "error", err)
 		return
 	}
 
@@ -88,11 +89,11 @@ func (p *Publish) update() {
 
 // Stdout provide our basic publishing.
 type Stdout struct {
-	log *log.Logger
+	log *logger.Logger
 }
 
 // NewStdout initializes stdout for publishing metrics.
-func NewStdout(log *log.Logger) *Stdout {
+func NewStdout(log *logger.Logger) *Stdout {
 	return &Stdout{log}
 }
 
@@ -100,13 +101,13 @@ func NewStdout(log *log.Logger) *Stdout {
 func (s *Stdout) Publish(data map[string]interface{}) {
 	rawJSON, err := json.Marshal(data)
 	if err != nil {
-		s.log.Println("Stdout : Marshal ERROR :", err)
+		s.log.Error("stdout : marshaling metrics", "error", err)
 		return
 	}
 
 	var d map[string]interface{}
 	if err := json.Unmarshal(rawJSON, &d); err != nil {
-		s.log.Println("Stdout : Unmarshal ERROR :", err)
+		s.log.Error("stdout : unmarshaling metrics", "error", err)
 		return
 	}
 
@@ -120,9 +121,5 @@ func (s *Stdout) Publish(data map[string]interface{}) {
 	delete(d, "memstats")
 	delete(d, "cmdline")
 
-	out, err := json.MarshalIndent(d, "", "    ")
-	if err != nil {
-		return
-	}
-	s.log.Println("Stdout :\n", string(out))
+	s.log.Info("stdout : metrics", "metrics", d)
 }
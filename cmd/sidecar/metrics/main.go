@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -14,6 +13,7 @@ import (
 	"github.com/igomonov88/users/cmd/sidecar/metrics/internal/collector"
 	"github.com/igomonov88/users/cmd/sidecar/metrics/internal/publisher"
 	"github.com/igomonov88/users/cmd/sidecar/metrics/internal/publisher/expvar"
+	"github.com/igomonov88/users/internal/platform/logger"
 )
 
 func main() {
@@ -21,8 +21,8 @@ func main() {
 	// =========================================================================
 	// Logging
 
-	log := log.New(os.Stdout, "METRICS : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
-	defer log.Println("main : Completed")
+	log := logger.New(os.Stdout, logger.Info).With("service", "metrics")
+	defer log.Info("main : Completed")
 
 	// =========================================================================
 	// Configuration
@@ -34,6 +34,9 @@ func main() {
 			WriteTimeout    time.Duration `conf:"default:5s"`
 			ShutdownTimeout time.Duration `conf:"default:5s"`
 		}
+		Log struct {
+			Level string `conf:"default:info"`
+		}
 		Expvar struct {
 			Host            string        `conf:"default:0.0.0.0:3001"`
 			Route           string        `conf:"default:/metrics"`
@@ -54,28 +57,37 @@ func main() {
 		if err == conf.ErrHelpWanted {
 			usage, err := conf.Usage("METRICS", &cfg)
 			if err != nil {
-				log.Fatalf("main : Parsing Config : %v", err)
+				log.Fatal("main : Parsing Config", "error", err)
 			}
 			fmt.Println(usage)
 			return
 		}
-		log.Fatalf("main : Parsing Config : %v", err)
+		log.Fatal("main : Parsing Config", "error", err)
 	}
 
+	level, err := logger.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		log.Fatal("main : Parsing Log Level", "error", err)
+	}
+	log.SetLevel(level)
+
 	out, err := conf.String(&cfg)
 	if err != nil {
-		log.Fatalf("main : Marshalling Config for output : %v", err)
+		log.Fatal("main : Marshalling Config for output", "error", err)
 	}
-	log.Printf("main : Config :\n%v\n", out)
+	log.Info("main : Config", "config", out)
 
 	// =========================================================================
 	// Start Debug Service. Not concerned with shutting this down when the
 	// application is being shutdown.
 	//
 	// /debug/pprof - Added to the default mux by the net/http/pprof package.
+	// /debug/log/level - Reports and changes the level of the logs.
+	http.Handle("/debug/log/level", log.LevelHandler())
+
 	go func() {
-		log.Printf("main : Debug Listening %s", cfg.Web.DebugHost)
-		log.Printf("main : Debug Listener closed : %v", http.ListenAndServe(cfg.Web.DebugHost, http.DefaultServeMux))
+		log.Info("main : Debug Listening", "host", cfg.Web.DebugHost)
+		log.Info("main : Debug Listener closed", "error", http.ListenAndServe(cfg.Web.DebugHost, http.DefaultServeMux))
 	}()
 
 	// =========================================================================
@@ -90,7 +102,7 @@ func main() {
 	// Initialize to allow for the collection of metrics.
 	collector, err := collector.New(cfg.Collect.From)
 	if err != nil {
-		log.Fatalf("main : Starting collector : %v", err)
+		log.Fatal("main : Starting collector", "error", err)
 	}
 
 	// Create a stdout publisher.
@@ -100,7 +112,7 @@ func main() {
 	// Start the publisher to collect/publish metrics.
 	publish, err := publisher.New(log, collector, cfg.Publish.Interval, exp.Publish, stdout.Publish)
 	if err != nil {
-		log.Fatalf("main : Starting publisher : %v", err)
+		log.Fatal("main : Starting publisher", "error", err)
 	}
 	defer publish.Stop()
 
@@ -113,5 +125,5 @@ func main() {
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 	<-shutdown
 
-	log.Println("main : Start shutdown...")
+	log.Info("main : Start shutdown")
 }
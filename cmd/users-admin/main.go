@@ -2,19 +2,30 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/ardanlabs/conf"
 	"github.com/pkg/errors"
 
+	"github.com/igomonov88/users/internal/avatar"
+	"github.com/igomonov88/users/internal/email"
+	"github.com/igomonov88/users/internal/password"
+	"github.com/igomonov88/users/internal/platform/content_uploader"
 	"github.com/igomonov88/users/internal/platform/database"
+	"github.com/igomonov88/users/internal/platform/logger"
 	schema2 "github.com/igomonov88/users/internal/schema"
+	"github.com/igomonov88/users/internal/storage"
 )
 
 func main() {
@@ -37,6 +48,35 @@ func run() error {
 			Name       string `conf:"default:users"`
 			DisableTLS bool   `conf:"default:true"`
 		}
+		Blob struct {
+			Backend         string `conf:"default:s3"`
+			Dir             string `conf:"default:/tmp/users-blobs"`
+			Region          string `conf:"default:eu-central-1"`
+			Bucket          string `conf:"default:users-avatars"`
+			Endpoint        string
+			PathStyle       bool
+			AccessKeyID     string
+			SecretAccessKey string `conf:"noprint"`
+			BaseURL         string
+		}
+		Avatar struct {
+			Sizes          []int         `conf:"default:64;128;256"`
+			UploadTTL      time.Duration `conf:"default:5m"`
+			ReconcileGrace time.Duration `conf:"default:24h"`
+			DryRun         bool
+		}
+		Password struct {
+			MinLength    int     `conf:"default:8"`
+			MinEntropy   float64 `conf:"default:30"`
+			BreachedFile string
+		}
+		Breached struct {
+			FalsePositiveRate float64 `conf:"default:0.001"`
+			MinCount          int     `conf:"default:1"`
+		}
+		Disposable struct {
+			Timeout time.Duration `conf:"default:30s"`
+		}
 		Args conf.Args
 	}
 
@@ -69,6 +109,45 @@ func run() error {
 		err = seed(dbConfig)
 	case "keygen":
 		err = keygen(cfg.Args.Num(1))
+	case "unlock":
+		err = unlock(dbConfig, cfg.Args.Num(1))
+	case "reset-password":
+		passwordConfig := password.Config{
+			MinLength:    cfg.Password.MinLength,
+			MinEntropy:   cfg.Password.MinEntropy,
+			BreachedFile: cfg.Password.BreachedFile,
+		}
+		err = resetPassword(dbConfig, passwordConfig, cfg.Args.Num(1), cfg.Args.Num(2))
+	case "breached":
+		err = breached(cfg.Args.Num(1), cfg.Args.Num(2), cfg.Breached.FalsePositiveRate, cfg.Breached.MinCount)
+	case "disposable":
+		err = disposable(cfg.Args.Num(1), cfg.Args.Num(2), cfg.Disposable.Timeout)
+	case "reconcile":
+		var blobs content_uploader.BlobStore
+		switch cfg.Blob.Backend {
+		case "s3":
+			blobs, err = content_uploader.New(content_uploader.Config{
+				Region:          cfg.Blob.Region,
+				Bucket:          cfg.Blob.Bucket,
+				Endpoint:        cfg.Blob.Endpoint,
+				PathStyle:       cfg.Blob.PathStyle,
+				AccessKeyID:     cfg.Blob.AccessKeyID,
+				SecretAccessKey: cfg.Blob.SecretAccessKey,
+				BaseURL:         cfg.Blob.BaseURL,
+			})
+		case "filesystem":
+			signer := content_uploader.NewSigner(cfg.Blob.BaseURL, nil)
+			blobs, err = content_uploader.NewFilesystem(cfg.Blob.Dir, signer)
+		default:
+			err = errors.Errorf("blob backend %q can not be reconciled", cfg.Blob.Backend)
+		}
+		if err == nil {
+			avatarConfig := avatar.Config{
+				Sizes:     cfg.Avatar.Sizes,
+				UploadTTL: cfg.Avatar.UploadTTL,
+			}
+			err = reconcile(dbConfig, avatarConfig, blobs, cfg.Avatar.ReconcileGrace, cfg.Avatar.DryRun)
+		}
 	default:
 		err = errors.New("Must specify a command")
 	}
@@ -91,6 +170,15 @@ func migrate(cfg database.Config) error {
 		return err
 	}
 
+	// Users created before skeletons were kept need them to be compared.
+	n, err := storage.BackfillUserNameSkeletons(context.Background(), db)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		fmt.Printf("Saved user name skeletons of %d users\n", n)
+	}
+
 	fmt.Println("Migrations complete")
 	return nil
 }
@@ -106,6 +194,10 @@ func seed(cfg database.Config) error {
 		return err
 	}
 
+	if _, err := storage.BackfillUserNameSkeletons(context.Background(), db); err != nil {
+		return err
+	}
+
 	fmt.Println("Seed data complete")
 	return nil
 }
@@ -137,6 +229,177 @@ func useradd(cfg database.Config, email, password string) error {
 	return nil
 }
 
+// unlock removes the failed login attempts recorded for the account with the
+// given email so it can be used again.
+func unlock(cfg database.Config, email string) error {
+	if email == "" {
+		return errors.New("unlock command must be called with an additional argument for email")
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := storage.ClearLoginFailures(context.Background(), db, strings.ToLower(strings.TrimSpace(email))); err != nil {
+		return err
+	}
+
+	fmt.Printf("Account %q unlocked\n", email)
+	return nil
+}
+
+// resetPassword replaces the password of the account with the given email
+// and signs out all of its sessions. The password has to follow the policy.
+func resetPassword(cfg database.Config, passwordConfig password.Config, email, pass string) error {
+	if email == "" || pass == "" {
+		return errors.New("reset-password command must be called with two additional arguments for email and password")
+	}
+
+	policy, err := password.New(passwordConfig)
+	if err != nil {
+		return err
+	}
+
+	db, err := database.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	usr, err := storage.RetrieveByEmail(ctx, db, strings.ToLower(strings.TrimSpace(email)))
+	if err != nil {
+		return err
+	}
+
+	if err := policy.Check(pass, usr.Email, usr.Name); err != nil {
+		return err
+	}
+
+	if err := storage.UpdatePassword(ctx, db, usr.ID, pass); err != nil {
+		return err
+	}
+
+	if _, err := storage.RevokeOtherSessions(ctx, db, usr.ID, "", time.Now()); err != nil {
+		return err
+	}
+
+	fmt.Printf("Password of %q reset\n", email)
+	return nil
+}
+
+// breached builds the bloom filter of breached passwords the password policy
+// checks against from a downloaded list, like the SHA-1 hashes of the Pwned
+// Passwords downloads.
+func breached(list, output string, rate float64, minCount int) error {
+	if list == "" || output == "" {
+		return errors.New("breached command must be called with two additional arguments for the list and the filter file")
+	}
+
+	b, n, err := password.BuildBloom(list, rate, minCount)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return errors.Wrap(err, "creating filter file")
+	}
+	defer file.Close()
+
+	if _, err := b.WriteTo(file); err != nil {
+		return errors.Wrap(err, "writing filter file")
+	}
+
+	if err := file.Close(); err != nil {
+		return errors.Wrap(err, "closing filter file")
+	}
+
+	fmt.Printf("Filter of %d breached passwords written to %q\n", n, output)
+	return nil
+}
+
+// disposable downloads a list of disposable email providers with one domain
+// per line and writes the valid domains to the file the email policy reads
+// with DisposableFile.
+func disposable(url, output string, timeout time.Duration) error {
+	if url == "" || output == "" {
+		return errors.New("disposable command must be called with two additional arguments for the list url and the output file")
+	}
+
+	client := http.Client{Timeout: timeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return errors.Wrap(err, "downloading disposable domains")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("downloading disposable domains : status %d", resp.StatusCode)
+	}
+
+	domains, err := email.ReadDomains(resp.Body)
+	if err != nil {
+		return errors.Wrap(err, "reading disposable domains")
+	}
+
+	var valid []string
+	for _, d := range domains {
+		a, err := email.Parse("user@" + d)
+		if err != nil {
+			fmt.Printf("Skipping invalid domain %q\n", d)
+			continue
+		}
+		valid = append(valid, a.Domain)
+	}
+	sort.Strings(valid)
+
+	file, err := os.Create(output)
+	if err != nil {
+		return errors.Wrap(err, "creating disposable domains file")
+	}
+	defer file.Close()
+
+	fmt.Fprintf(file, "# Disposable email providers downloaded from %s\n", url)
+	for _, d := range valid {
+		fmt.Fprintln(file, d)
+	}
+
+	if err := file.Close(); err != nil {
+		return errors.Wrap(err, "closing disposable domains file")
+	}
+
+	fmt.Printf("%d disposable domains written to %q\n", len(valid), output)
+	return nil
+}
+
+// reconcile deletes the avatar objects in the blob store which no user
+// references and which are older than the grace period.
+func reconcile(cfg database.Config, avatarConfig avatar.Config, blobs content_uploader.BlobStore, grace time.Duration, dryRun bool) error {
+	db, err := database.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	avatars := avatar.New(avatarConfig, db, blobs, logger.New(os.Stdout, logger.Info).With("service", "users-admin"))
+
+	rep, err := avatars.Reconcile(context.Background(), time.Now(), grace, dryRun)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		fmt.Printf("Scanned %d objects, %d would be deleted\n", rep.Scanned, rep.Orphaned)
+		return nil
+	}
+
+	fmt.Printf("Scanned %d objects, deleted %d\n", rep.Scanned, rep.Deleted)
+	return nil
+}
+
 // keygen creates an x509 private key for signing auth tokens.
 func keygen(path string) error {
 	if path == "" {
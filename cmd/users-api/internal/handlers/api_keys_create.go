@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// defaultAPIKeyDays is the lifetime of an API key when none is requested.
+const defaultAPIKeyDays = 90
+
+// CreateAPIKey creates a named, scoped and expiring API key for the
+// authenticated user. The key is part of the response only once. API keys
+// can't be used to create other API keys.
+func (u *User) CreateAPIKey(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.CreateAPIKey")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("create api key", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	if len(claims.Scopes) > 0 {
+		err := errors.New("api keys can't be created with an api key")
+		return web.NewRequestError(err, http.StatusForbidden)
+	}
+
+	var req CreateAPIKeyRequest
+	if err := web.Decode(r, &req); err != nil {
+		return errors.Wrap(err, "decoding api key")
+	}
+
+	days := req.ExpiresInDays
+	if days == 0 {
+		days = defaultAPIKeyDays
+	}
+	expires := v.Now.Add(time.Duration(days) * 24 * time.Hour)
+
+	k, key, err := storage.CreateAPIKey(ctx, u.db, claims.Subject, req.Name, req.Scopes, v.Now, expires)
+	if err != nil {
+		return errors.Wrapf(err, "creating api key for %q", claims.Subject)
+	}
+
+	resp := CreateAPIKeyResponse{
+		APIKey: toAPIKey(k),
+		Key:    key,
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusCreated)
+}
+
+// toAPIKey converts a stored key into its response form.
+func toAPIKey(k *storage.APIKey) APIKey {
+	ak := APIKey{
+		ID:         k.ID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.Scopes,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedIP: k.LastUsedIP,
+		CreatedAt:  k.CreatedAt,
+	}
+	if k.LastUsedAt.Valid {
+		ak.LastUsedAt = &k.LastUsedAt.Time
+	}
+	return ak
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// ListAPIKeys returns the API keys of the authenticated user which are not
+// revoked. Secrets are never part of the response.
+func (u *User) ListAPIKeys(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.ListAPIKeys")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("list api keys", w, r)
+	defer txn.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	keys, err := storage.ListAPIKeys(ctx, u.db, claims.Subject)
+	if err != nil {
+		return errors.Wrapf(err, "listing api keys for %q", claims.Subject)
+	}
+
+	resp := ListAPIKeysResponse{APIKeys: make([]APIKey, len(keys))}
+	for i := range keys {
+		resp.APIKeys[i] = toAPIKey(&keys[i])
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
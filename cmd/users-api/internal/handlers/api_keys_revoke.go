@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// RevokeAPIKey revokes an API key of the authenticated user. A revoked key
+// is rejected immediately.
+func (u *User) RevokeAPIKey(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.RevokeAPIKey")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("revoke api key", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	if err := storage.RevokeAPIKey(ctx, u.db, claims.Subject, params["api_key_id"], v.Now); err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			return web.NewCodedError(err, http.StatusNotFound, codeAPIKeyNotFound)
+		default:
+			return errors.Wrapf(err, "revoking api key %q", params["api_key_id"])
+		}
+	}
+
+	return web.Respond(ctx, w, RevokeAPIKeyResponse{}, http.StatusOK)
+}
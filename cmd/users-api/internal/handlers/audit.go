@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+	"github.com/igomonov88/users/internal/webhook"
+)
+
+// audit records the action on the account of the user, done by the actor,
+// and publishes it to the webhooks subscribed to it.
+func (u *User) audit(ctx context.Context, userID, actorID, action string) error {
+	now := time.Now()
+	if v, ok := ctx.Value(web.KeyValues).(*web.Values); ok {
+		now = v.Now
+	}
+
+	if err := storage.CreateAuditEntry(ctx, u.db, userID, actorID, action, now); err != nil {
+		return errors.Wrapf(err, "recording %s of %q", action, userID)
+	}
+
+	data := webhook.Data{UserID: userID, ActorID: actorID}
+	if err := u.hooks.Publish(ctx, action, data, now); err != nil {
+		return errors.Wrapf(err, "publishing %s of %q", action, userID)
+	}
+
+	return nil
+}
+
+// subject returns the id of the authenticated user or an empty string for
+// anonymous requests.
+func subject(ctx context.Context) string {
+	claims, _ := ctx.Value(auth.Key).(auth.Claims)
+	return claims.Subject
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/identicon"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// DefaultAvatar serves the generated avatar of a user. The query selects the
+// format, png or svg, the size in pixels and the style, a pattern or the
+// initials of the user. Pattern avatars never change, so they are cached
+// forever. Initials change with the user name.
+func (u *User) DefaultAvatar(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.DefaultAvatar")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("default avatar", w, r)
+	defer txn.End()
+
+	userID := params["user_id"]
+	if _, err := uuid.Parse(userID); err != nil {
+		return web.NewRequestError(storage.ErrInvalidUserID, http.StatusBadRequest)
+	}
+
+	q := r.URL.Query()
+
+	size := identicon.DefaultSize
+	if s := q.Get("size"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < identicon.MinSize || n > identicon.MaxSize {
+			err := errors.Errorf("size must be between %d and %d", identicon.MinSize, identicon.MaxSize)
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		size = n
+	}
+
+	av := identicon.New(userID)
+	cacheControl := "public, max-age=31536000, immutable"
+	switch q.Get("style") {
+	case "", "identicon":
+	case "initials":
+		usr, err := storage.Retrieve(ctx, u.db, userID)
+		if err != nil {
+			switch err {
+			case storage.ErrNotFound:
+				return web.NewRequestError(err, http.StatusNotFound)
+			default:
+				return errors.Wrapf(err, "retrieving user %q", userID)
+			}
+		}
+		av = identicon.NewInitials(userID, usr.Name)
+		cacheControl = "public, max-age=86400"
+	default:
+		err := errors.New("style must be identicon or initials")
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	format := q.Get("format")
+	if format == "" {
+		format = "png"
+	}
+	if format != "png" && format != "svg" {
+		err := errors.New("format must be png or svg")
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	contentType := "image/png"
+	if format == "svg" {
+		contentType = "image/svg+xml"
+	}
+
+	etag := av.ETag(format, size)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if r.Header.Get("If-None-Match") == etag {
+		return web.RespondRaw(ctx, w, nil, contentType, http.StatusNotModified)
+	}
+
+	if format == "svg" {
+		return web.RespondRaw(ctx, w, av.SVG(size), contentType, http.StatusOK)
+	}
+
+	b, err := av.PNG(size)
+	if err != nil {
+		return errors.Wrapf(err, "drawing avatar of %q", userID)
+	}
+
+	return web.RespondRaw(ctx, w, b, contentType, http.StatusOK)
+}
+
+// avatarURL returns the avatar of the user, or the generated one for users
+// who have none.
+func avatarURL(usr *storage.User) string {
+	if usr.Avatar != "" {
+		return usr.Avatar
+	}
+	return "/v1/users/" + usr.ID + "/avatar"
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// DeleteAvatar clears the avatar of the user of the route and deletes its
+// objects. The generated avatar is shown instead.
+func (u *User) DeleteAvatar(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.DeleteAvatar")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("delete avatar", w, r)
+	defer txn.End()
+
+	userID := params["user_id"]
+	if err := u.avatars.Remove(ctx, userID); err != nil {
+		switch errors.Cause(err) {
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case storage.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "removing avatar of %q", userID)
+		}
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
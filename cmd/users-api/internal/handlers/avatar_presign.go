@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/avatar"
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// PresignAvatar returns a short-lived URL the authenticated user can upload
+// an avatar to directly, without sending it through this service. The upload
+// has to be completed with CompleteAvatar.
+func (u *User) PresignAvatar(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.PresignAvatar")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("presign avatar", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var req PresignAvatarRequest
+	if err := web.Decode(r, &req); err != nil {
+		return errors.Wrap(err, "decoding presign request")
+	}
+
+	p, err := u.avatars.Presign(ctx, claims.Subject, req.ContentType, v.Now)
+	if err != nil {
+		switch err {
+		case avatar.ErrUnsupportedType:
+			return web.NewRequestError(err, http.StatusUnsupportedMediaType)
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "presigning avatar of %q", claims.Subject)
+		}
+	}
+
+	resp := PresignAvatarResponse{
+		URL:         p.URL,
+		Key:         p.Key,
+		ContentType: p.ContentType,
+		ExpiresAt:   p.ExpiresAt,
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusCreated)
+}
+
+// CompleteAvatar makes a direct upload the avatar of the authenticated user
+// once the image is checked and processed.
+func (u *User) CompleteAvatar(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.CompleteAvatar")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("complete avatar", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var req CompleteAvatarRequest
+	if err := web.Decode(r, &req); err != nil {
+		return errors.Wrap(err, "decoding complete request")
+	}
+
+	av, err := u.avatars.Complete(ctx, claims.Subject, req.Key, v.Now)
+	if err != nil {
+		switch errors.Cause(err) {
+		case avatar.ErrUploadNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case avatar.ErrTooLarge:
+			return web.NewRequestError(err, http.StatusRequestEntityTooLarge)
+		case avatar.ErrUnsupportedType:
+			return web.NewRequestError(err, http.StatusUnsupportedMediaType)
+		case avatar.ErrDimensions:
+			return web.NewRequestError(err, http.StatusUnprocessableEntity)
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "completing avatar of %q", claims.Subject)
+		}
+	}
+
+	resp := UploadAvatarResponse{
+		Avatar:     av.URL,
+		Thumbnails: av.Thumbnails,
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
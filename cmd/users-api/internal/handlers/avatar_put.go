@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/avatar"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// PutAvatar replaces the avatar of the user of the route with the image in
+// the body of the request. It responds with the URLs of the stored avatar
+// and its thumbnails.
+func (u *User) PutAvatar(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.PutAvatar")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("put avatar", w, r)
+	defer txn.End()
+
+	userID := params["user_id"]
+	av, err := u.avatars.Upload(ctx, userID, r.Body)
+	if err != nil {
+		switch errors.Cause(err) {
+		case avatar.ErrTooLarge:
+			return web.NewRequestError(err, http.StatusRequestEntityTooLarge)
+		case avatar.ErrUnsupportedType:
+			return web.NewRequestError(err, http.StatusUnsupportedMediaType)
+		case avatar.ErrDimensions:
+			return web.NewRequestError(err, http.StatusUnprocessableEntity)
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case storage.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "uploading avatar of %q", userID)
+		}
+	}
+
+	resp := UploadAvatarResponse{
+		Avatar:     av.URL,
+		Thumbnails: av.Thumbnails,
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
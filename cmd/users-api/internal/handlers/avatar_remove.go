@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// RemoveAvatar clears the avatar of the authenticated user and deletes its
+// objects. It responds with the URL of the generated avatar shown instead.
+func (u *User) RemoveAvatar(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.RemoveAvatar")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("remove avatar", w, r)
+	defer txn.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	if err := u.avatars.Remove(ctx, claims.Subject); err != nil {
+		switch errors.Cause(err) {
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case storage.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "removing avatar of %q", claims.Subject)
+		}
+	}
+
+	resp := RemoveAvatarResponse{
+		Avatar: avatarURL(&storage.User{ID: claims.Subject}),
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
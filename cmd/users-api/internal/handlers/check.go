@@ -22,10 +22,7 @@ func (c *Check) Health(ctx context.Context, w http.ResponseWriter, r *http.Reque
 	ctx, span := trace.StartSpan(ctx, "handlers.Check.Health")
 	defer span.End()
 
-	health := struct {
-		Version string `json:"version"`
-		Status  string `json:"status"`
-	}{
+	health := HealthResponse{
 		Version: c.build,
 	}
 
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"github.com/igomonov88/users/internal/avatar"
+	"github.com/igomonov88/users/internal/federation"
+	"github.com/igomonov88/users/internal/mfa"
+	"github.com/igomonov88/users/internal/mid"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+	"github.com/igomonov88/users/internal/webhook"
+)
+
+// Codes of errors which share the error they are caused by with others, so
+// handlers set them where they happen.
+var (
+	codeSessionNotFound  = web.ErrorCode{Code: "session.not_found", Title: "Session not found"}
+	codeAPIKeyNotFound   = web.ErrorCode{Code: "api_key.not_found", Title: "API key not found"}
+	codeIdentityNotFound = web.ErrorCode{Code: "identity.not_found", Title: "Identity not found"}
+	codeAccountLocked    = web.ErrorCode{Code: "auth.account_locked", Title: "Account temporarily locked"}
+	codeUserNameRejected = web.ErrorCode{Code: "user.name_rejected", Title: "User name not allowed"}
+	codeEmailRejected    = web.ErrorCode{Code: "user.email_rejected", Title: "Email address not allowed"}
+	codePasswordRejected = web.ErrorCode{Code: "user.password_rejected", Title: "Password not allowed"}
+	codeWebhookNotFound  = web.ErrorCode{Code: "webhook.not_found", Title: "Webhook not found"}
+	codeDeliveryNotFound = web.ErrorCode{Code: "webhook.delivery_not_found", Title: "Webhook delivery not found"}
+)
+
+// catalog are the codes of the errors handlers respond with. Clients branch
+// on the codes, so they must never change.
+var catalog = map[error]web.ErrorCode{
+	storage.ErrNotFound:              {Code: "user.not_found", Title: "User not found"},
+	storage.ErrInvalidUserID:         {Code: "user.invalid_id", Title: "Invalid user id"},
+	storage.ErrEmailAlreadyExist:     {Code: "user.email_taken", Title: "Email already taken"},
+	storage.ErrUserNameAlreadyExist:  {Code: "user.name_taken", Title: "User name already taken"},
+	storage.ErrAuthenticationFailure: {Code: "auth.invalid_credentials", Title: "Invalid credentials"},
+	storage.ErrTokenReused:           {Code: "auth.token_reused", Title: "Token already used"},
+	storage.ErrTOTPAlreadyConfirmed:  {Code: "mfa.already_enabled", Title: "Two-factor authentication already enabled"},
+	storage.ErrStepAlreadyUsed:       {Code: "mfa.code_reused", Title: "One-time password already used"},
+	storage.ErrIdentityAlreadyLinked: {Code: "identity.already_linked", Title: "Identity already linked"},
+
+	mid.ErrForbidden:       {Code: "auth.forbidden", Title: "Forbidden"},
+	mid.ErrSessionRevoked:  {Code: "auth.session_revoked", Title: "Session revoked"},
+	mid.ErrMFARequired:     {Code: "auth.mfa_required", Title: "Two-factor authentication required"},
+	mid.ErrTooManyRequests: {Code: "request.rate_limited", Title: "Too many requests"},
+
+	mfa.ErrInvalidCode:       {Code: "mfa.invalid_code", Title: "Invalid two-factor authentication code"},
+	mfa.ErrNotEnrolled:       {Code: "mfa.not_enrolled", Title: "Two-factor authentication not enabled"},
+	mfa.ErrChallengeNotFound: {Code: "mfa.challenge_not_found", Title: "Two-factor challenge not found"},
+
+	federation.ErrUnknownProvider: {Code: "federation.unknown_provider", Title: "Unknown identity provider"},
+	federation.ErrInvalidState:    {Code: "federation.invalid_state", Title: "Invalid login state"},
+	federation.ErrExchange:        {Code: "federation.exchange_failed", Title: "Login at the provider failed"},
+	federation.ErrInvalidIDToken:  {Code: "federation.invalid_id_token", Title: "Invalid id token"},
+	federation.ErrNoAccount:       {Code: "federation.no_account", Title: "No linked account"},
+
+	avatar.ErrUploadNotFound:  {Code: "avatar.upload_not_found", Title: "Avatar upload not found"},
+	avatar.ErrTooLarge:        {Code: "avatar.too_large", Title: "Avatar too large"},
+	avatar.ErrUnsupportedType: {Code: "avatar.unsupported_type", Title: "Avatar type not supported"},
+	avatar.ErrDimensions:      {Code: "avatar.invalid_dimensions", Title: "Avatar dimensions out of bounds"},
+
+	webhook.ErrUnknownEvent: {Code: "webhook.unknown_event", Title: "Unknown webhook event"},
+}
+
+func init() {
+	for err, code := range catalog {
+		web.RegisterErrorCode(err, code)
+	}
+}
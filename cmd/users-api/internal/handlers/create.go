@@ -23,17 +23,42 @@ func (u *User) Create(ctx context.Context, w http.ResponseWriter, r *http.Reques
 		return errors.Wrap(err, "")
 	}
 
+	usr, err := u.create(ctx, cur)
+	if err != nil {
+		return err
+	}
+
+	resp := CreateUserResponse{UserID:usr.ID}
+
+	return web.Respond(ctx, w, &resp, http.StatusOK)
+}
+
+// create checks the request against the policies for names, addresses and
+// passwords and stores the new user.
+func (u *User) create(ctx context.Context, cur CreateUserRequest) (*storage.User, error) {
+	if err := u.checkUserName(ctx, "name", cur.Name, ""); err != nil {
+		return nil, err
+	}
+	if err := u.checkEmail("email", cur.Email); err != nil {
+		return nil, err
+	}
+	if err := u.passwords.Check(cur.Password, cur.Email, cur.Name); err != nil {
+		return nil, passwordError("password", err)
+	}
+
 	usr, err := storage.Create(ctx, u.db, cur.Email, cur.Name, cur.Avatar, cur.Password)
 	if err != nil {
-		switch err {
+		switch errors.Cause(err) {
 		case storage.ErrEmailAlreadyExist, storage.ErrUserNameAlreadyExist:
-			return web.ResponseError(ctx, w, err)
+			return nil, web.NewRequestError(err, http.StatusConflict)
 		default:
-			return web.ResponseError(ctx, w, err)
+			return nil, errors.Wrap(err, "creating user")
 		}
 	}
 
-	resp := CreateUserResponse{UserID:usr.ID}
+	if err := u.audit(ctx, usr.ID, subject(ctx), storage.AuditUserCreated); err != nil {
+		return nil, err
+	}
 
-	return web.Respond(ctx, w, &resp, http.StatusOK)
+	return usr, nil
 }
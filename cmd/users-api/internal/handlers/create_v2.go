@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// CreateV2 creates a user like Create does but responds with 201, the
+// location of the new user and its representation.
+func (u *User) CreateV2(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.CreateV2")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("create user v2", w, r)
+	defer txn.End()
+
+	req := CreateUserRequest{}
+	if err := web.Decode(r, &req); err != nil {
+		return err
+	}
+
+	usr, err := u.create(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", "/v2/users/"+usr.ID)
+
+	return web.Respond(ctx, w, userResponse(usr), http.StatusCreated)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 
 	"github.com/igomonov88/users/internal/platform/web"
@@ -19,11 +20,20 @@ func (u *User) Delete(ctx context.Context, w http.ResponseWriter, r *http.Reques
 
 	req := DeleteUserRequest{}
 	if err := web.Decode(r, &req); err != nil {
-		return web.Respond(ctx, w, DeleteUserResponse{}, http.StatusBadRequest)
+		return err
 	}
 
-	if err := storage.Delete(ctx, u.db, req.UserID); err!= nil {
-		return web.Respond(ctx, w, DeleteUserResponse{}, http.StatusInternalServerError)
+	if err := storage.Delete(ctx, u.db, req.UserID); err != nil {
+		switch err {
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "deleting user %q", req.UserID)
+		}
+	}
+
+	if err := u.audit(ctx, req.UserID, subject(ctx), storage.AuditUserDeleted); err != nil {
+		return err
 	}
 
 	return web.Respond(ctx, w, DeleteUserResponse{}, http.StatusOK)
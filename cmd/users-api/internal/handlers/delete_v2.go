@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// DeleteV2 deletes the user of the route and responds without a body.
+// Deleting a user which does not exist succeeds as well.
+func (u *User) DeleteV2(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.DeleteV2")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("delete user v2", w, r)
+	defer txn.End()
+
+	userID := params["user_id"]
+	if err := storage.Delete(ctx, u.db, userID); err != nil {
+		switch err {
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "deleting user %q", userID)
+		}
+	}
+
+	if err := u.audit(ctx, userID, subject(ctx), storage.AuditUserDeleted); err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusNoContent)
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/igomonov88/users/internal/email"
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+func init() {
+
+	// Validate the syntax of addresses while decoding requests.
+	if err := web.RegisterValidation("email_address", email.Valid); err != nil {
+		panic(err)
+	}
+}
+
+// checkEmail applies the email policy to an address. Violations are reported
+// for the field.
+func (u *User) checkEmail(field, addr string) error {
+	err := u.emails.Check(addr)
+	v, ok := err.(*email.Violation)
+	if !ok {
+		return err
+	}
+
+	return &web.Error{
+		Err:    err,
+		Status: http.StatusBadRequest,
+		Code:   codeEmailRejected.Code,
+		Title:  codeEmailRejected.Title,
+		Fields: []web.FieldError{{Field: field, Error: v.Reason}},
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 
 	"github.com/igomonov88/users/internal/platform/web"
@@ -19,13 +20,12 @@ func (u *User) EmailExist(ctx context.Context, w http.ResponseWriter, r *http.Re
 
 	email, ok := params["email"]
 	if !ok {
-		return web.Respond(ctx, w, EmailExistResponse{}, http.StatusBadRequest)
+		return web.NewRequestError(errors.New("email is missing"), http.StatusBadRequest)
 	}
 
 	exist, err := storage.DoesEmailExist(ctx, u.db, email)
 	if err != nil {
-		return web.Respond(ctx, w, web.ErrorResponse{
-			Error: "Internal Server Error",}, http.StatusInternalServerError)
+		return errors.Wrapf(err, "checking email %q", email)
 	}
 
 	resp := EmailExistResponse{Exist: exist}
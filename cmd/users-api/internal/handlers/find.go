@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// Find lists the users matching the email or user_name query parameter. As
+// both are unique the list holds one user at most. Emails are private, users
+// are only found by their own email unless the authenticated user is an
+// admin, so the route can't be used to probe for addresses.
+func (u *User) Find(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.Find")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("find users", w, r)
+	defer txn.End()
+
+	field, value, err := userQuery(r)
+	if err != nil {
+		return err
+	}
+
+	var usr *storage.User
+	switch field {
+	case "email":
+		usr, err = storage.RetrieveByEmail(ctx, u.db, value)
+	default:
+		usr, err = storage.RetrieveByUserName(ctx, u.db, value)
+	}
+
+	if err == nil && field == "email" && selfOrAdmin(ctx, usr.ID) != nil {
+		err = storage.ErrNotFound
+	}
+
+	resp := ListUsersResponse{Users: []*RetrieveUserResponse{}}
+	switch err {
+	case nil:
+		found := visibleUserResponse(ctx, usr)
+		resp.Users = append(resp.Users, &found)
+	case storage.ErrNotFound:
+	default:
+		return errors.Wrapf(err, "finding user by %s %q", field, value)
+	}
+
+	return web.Respond(ctx, w, &resp, http.StatusOK)
+}
+
+// Exists responds with 200 when a user matches the email or user_name query
+// parameter and with 404 otherwise. It is meant for HEAD requests, so
+// clients can tell if an address or name is taken before signing up.
+func (u *User) Exists(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.Exists")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("users exist", w, r)
+	defer txn.End()
+
+	field, value, err := userQuery(r)
+	if err != nil {
+		return err
+	}
+
+	var exist bool
+	switch field {
+	case "email":
+		exist, err = storage.DoesEmailExist(ctx, u.db, value)
+	default:
+		exist, err = storage.DoesUserNameExist(ctx, u.db, value)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "checking %s %q", field, value)
+	}
+	if !exist {
+		return web.NewRequestError(storage.ErrNotFound, http.StatusNotFound)
+	}
+
+	return web.Respond(ctx, w, nil, http.StatusOK)
+}
+
+// userQuery returns the field and value of the query the users are looked
+// up by. Exactly one of email and user_name must be given.
+func userQuery(r *http.Request) (string, string, error) {
+	q := r.URL.Query()
+	email, name := q.Get("email"), q.Get("user_name")
+
+	switch {
+	case email != "" && name == "":
+		return "email", email, nil
+	case name != "" && email == "":
+		return "user_name", name, nil
+	}
+
+	err := errors.New("expected exactly one of the email and user_name query parameters")
+	return "", "", web.NewRequestError(err, http.StatusBadRequest)
+}
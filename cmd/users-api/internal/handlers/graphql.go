@@ -0,0 +1,359 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/jmoiron/sqlx"
+	newrelic "github.com/newrelic/go-agent"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/dataloader"
+	"github.com/igomonov88/users/internal/platform/graph"
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// Bounds of the lists of the GraphQL schema.
+const (
+	maxGraphQLUsers   = 100
+	maxAuditEntries   = 100
+	defaultAuditLimit = 20
+)
+
+// GraphQL represents the GraphQL API over users, their sessions and their
+// audit entries.
+type GraphQL struct {
+	db     *sqlx.DB
+	relict newrelic.Application
+	log    *logger.Logger
+	limits graph.Limits
+}
+
+// Query executes a GraphQL query. Fields are resolved through loaders of
+// the request, so a field of many users costs one query.
+func (g *GraphQL) Query(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.GraphQL.Query")
+	defer span.End()
+
+	txn := g.relict.StartTransaction("graphql query", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var req GraphQLRequest
+	if err := web.Decode(r, &req); err != nil {
+		return err
+	}
+
+	ctx = context.WithValue(ctx, keyLoaders, newLoaders(g.db, v.Now))
+	resp, status := graph.Execute(ctx, g.log, &usersSchema, graph.Request(req), g.limits)
+
+	return web.Respond(ctx, w, GraphQLResponse(resp), status)
+}
+
+// ctxKey represents the type of value for the context key.
+type ctxKey int
+
+// keyLoaders is how the loaders of a request are stored in its context.
+const keyLoaders ctxKey = 1
+
+// loaders batch the queries of the resolvers of a request.
+type loaders struct {
+	db       *sqlx.DB
+	users    *dataloader.Loader
+	sessions *dataloader.Loader
+
+	mu    sync.Mutex
+	audit map[int]*dataloader.Loader
+}
+
+// newLoaders constructs the loaders of a request made at the time.
+func newLoaders(db *sqlx.DB, now time.Time) *loaders {
+	l := loaders{
+		db:    db,
+		audit: make(map[int]*dataloader.Loader),
+	}
+
+	l.users = dataloader.New(func(ctx context.Context, ids []string) (map[string]interface{}, error) {
+		users, err := storage.RetrieveMany(ctx, db, ids)
+		if err != nil {
+			return nil, errors.Wrap(err, "retrieving users")
+		}
+		values := make(map[string]interface{}, len(users))
+		for i := range users {
+			values[users[i].ID] = &users[i]
+		}
+		return values, nil
+	})
+
+	l.sessions = dataloader.New(func(ctx context.Context, ids []string) (map[string]interface{}, error) {
+		sessions, err := storage.ListSessionsOfUsers(ctx, db, ids, now)
+		if err != nil {
+			return nil, errors.Wrap(err, "listing sessions")
+		}
+		values := make(map[string]interface{}, len(ids))
+		for _, id := range ids {
+			values[id] = []storage.Session{}
+		}
+		for _, s := range sessions {
+			values[s.UserID] = append(values[s.UserID].([]storage.Session), s)
+		}
+		return values, nil
+	})
+
+	return &l
+}
+
+// auditEntries returns the loader of the latest audit entries of users, up
+// to the limit per user.
+func (l *loaders) auditEntries(limit int) *dataloader.Loader {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if loader, ok := l.audit[limit]; ok {
+		return loader
+	}
+
+	loader := dataloader.New(func(ctx context.Context, ids []string) (map[string]interface{}, error) {
+		entries, err := storage.ListAuditEntries(ctx, l.db, ids, limit)
+		if err != nil {
+			return nil, errors.Wrap(err, "listing audit entries")
+		}
+		values := make(map[string]interface{}, len(ids))
+		for _, id := range ids {
+			values[id] = []storage.AuditEntry{}
+		}
+		for _, e := range entries {
+			values[e.UserID] = append(values[e.UserID].([]storage.AuditEntry), e)
+		}
+		return values, nil
+	})
+	l.audit[limit] = loader
+
+	return loader
+}
+
+// requestLoaders returns the loaders of the request of the context.
+func requestLoaders(ctx context.Context) (*loaders, error) {
+	l, ok := ctx.Value(keyLoaders).(*loaders)
+	if !ok {
+		return nil, errors.New("loaders missing from context")
+	}
+	return l, nil
+}
+
+// loadUser returns a thunk resolving the user with the id, nil when there is
+// none.
+func loadUser(ctx context.Context, userID string) (interface{}, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, web.NewRequestError(storage.ErrInvalidUserID, http.StatusBadRequest)
+	}
+
+	l, err := requestLoaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.users.Load(ctx, userID), nil
+}
+
+// private resolves a field only visible to the user it belongs to and to
+// admins.
+func private(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		usr := p.Source.(*storage.User)
+		if err := selfOrAdmin(p.Context, usr.ID); err != nil {
+			return nil, err
+		}
+		return resolve(p)
+	}
+}
+
+// usersSchema is the schema of the GraphQL API.
+var usersSchema graphql.Schema
+
+func init() {
+	var err error
+	if usersSchema, err = graphQLSchema(); err != nil {
+		panic(err)
+	}
+}
+
+// graphQLSchema builds the schema of the GraphQL API.
+func graphQLSchema() (graphql.Schema, error) {
+	user := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "User",
+		Description: "Someone with access to the system. Private fields are visible to the user and to admins only.",
+		Fields: graphql.Fields{
+			"id": &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"userName": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*storage.User).Name, nil
+				},
+			},
+			"avatar": &graphql.Field{
+				Type: graphql.NewNonNull(graphql.String),
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return avatarURL(p.Source.(*storage.User)), nil
+				},
+			},
+			"locale":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"email": &graphql.Field{
+				Type:        graphql.String,
+				Description: "Private.",
+				Resolve: private(func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*storage.User).Email, nil
+				}),
+			},
+			"roles": &graphql.Field{
+				Type:        graphql.NewList(graphql.NewNonNull(graphql.String)),
+				Description: "Private.",
+				Resolve: private(func(p graphql.ResolveParams) (interface{}, error) {
+					return []string(p.Source.(*storage.User).Roles), nil
+				}),
+			},
+		},
+	})
+
+	session := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "Session",
+		Description: "A login of a user on a device which is neither revoked nor expired.",
+		Fields: graphql.Fields{
+			"id":         &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"userAgent":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"ip":         &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"createdAt":  &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"lastSeenAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"expiresAt":  &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"current": &graphql.Field{
+				Type:        graphql.NewNonNull(graphql.Boolean),
+				Description: "If the session is the one of the token used for the request.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					claims, _ := p.Context.Value(auth.Key).(auth.Claims)
+					return p.Source.(storage.Session).ID == claims.SessionID, nil
+				},
+			},
+		},
+	})
+
+	auditEntry := graphql.NewObject(graphql.ObjectConfig{
+		Name:        "AuditEntry",
+		Description: "Something which happened to the account of a user.",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"action":    &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime)},
+			"actor": &graphql.Field{
+				Type:        user,
+				Description: "The user who did it, null for anonymous requests and deleted users.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					e := p.Source.(storage.AuditEntry)
+					if !e.ActorID.Valid {
+						return nil, nil
+					}
+					return loadUser(p.Context, e.ActorID.String)
+				},
+			},
+		},
+	})
+
+	user.AddFieldConfig("sessions", &graphql.Field{
+		Type:        graphql.NewList(graphql.NewNonNull(session)),
+		Description: "Private. The active sessions, the most recently used first.",
+		Resolve: private(func(p graphql.ResolveParams) (interface{}, error) {
+			l, err := requestLoaders(p.Context)
+			if err != nil {
+				return nil, err
+			}
+			return l.sessions.Load(p.Context, p.Source.(*storage.User).ID), nil
+		}),
+	})
+	user.AddFieldConfig("auditEntries", &graphql.Field{
+		Type:        graphql.NewList(graphql.NewNonNull(auditEntry)),
+		Description: "Private. The latest audit entries, the most recent first.",
+		Args: graphql.FieldConfigArgument{
+			"last": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: defaultAuditLimit},
+		},
+		Resolve: private(func(p graphql.ResolveParams) (interface{}, error) {
+			last, _ := p.Args["last"].(int)
+			if last < 1 || last > maxAuditEntries {
+				err := errors.Errorf("last must be between 1 and %d", maxAuditEntries)
+				return nil, web.NewRequestError(err, http.StatusBadRequest)
+			}
+			l, err := requestLoaders(p.Context)
+			if err != nil {
+				return nil, err
+			}
+			return l.auditEntries(last).Load(p.Context, p.Source.(*storage.User).ID), nil
+		}),
+	})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"me": &graphql.Field{
+				Type:        user,
+				Description: "The authenticated user.",
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					claims, ok := p.Context.Value(auth.Key).(auth.Claims)
+					if !ok {
+						return nil, errors.New("claims missing from context")
+					}
+					return loadUser(p.Context, claims.Subject)
+				},
+			},
+			"user": &graphql.Field{
+				Type:        user,
+				Description: "The user with the id, null when there is none.",
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					id, _ := p.Args["id"].(string)
+					return loadUser(p.Context, id)
+				},
+			},
+			"users": &graphql.Field{
+				Type:        graphql.NewList(user),
+				Description: "The users with the ids in their order, null for ids without a user.",
+				Args: graphql.FieldConfigArgument{
+					"ids": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.ID)))},
+				},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					args, _ := p.Args["ids"].([]interface{})
+					if len(args) > maxGraphQLUsers {
+						err := errors.Errorf("at most %d ids are allowed", maxGraphQLUsers)
+						return nil, web.NewRequestError(err, http.StatusBadRequest)
+					}
+					ids := make([]string, len(args))
+					for i, arg := range args {
+						ids[i], _ = arg.(string)
+						if _, err := uuid.Parse(ids[i]); err != nil {
+							return nil, web.NewRequestError(storage.ErrInvalidUserID, http.StatusBadRequest)
+						}
+					}
+					l, err := requestLoaders(p.Context)
+					if err != nil {
+						return nil, err
+					}
+					return l.users.LoadMany(p.Context, ids), nil
+				},
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: query})
+}
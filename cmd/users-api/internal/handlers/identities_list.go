@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// ListIdentities returns the external identities linked to the
+// authenticated user.
+func (u *User) ListIdentities(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.ListIdentities")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("list identities", w, r)
+	defer txn.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	ids, err := storage.ListIdentities(ctx, u.db, claims.Subject)
+	if err != nil {
+		switch err {
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "listing identities of %q", claims.Subject)
+		}
+	}
+
+	resp := ListIdentitiesResponse{
+		Identities: make([]Identity, len(ids)),
+	}
+	for i, id := range ids {
+		resp.Identities[i] = Identity{
+			Provider:    id.Provider,
+			Email:       id.Email,
+			CreatedAt:   id.CreatedAt,
+			LastLoginAt: id.LastLoginAt,
+		}
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
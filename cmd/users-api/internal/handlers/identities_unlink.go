@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// UnlinkIdentity removes the identity of a provider from the authenticated
+// user, so it can no longer be used to log in.
+func (u *User) UnlinkIdentity(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.UnlinkIdentity")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("unlink identity", w, r)
+	defer txn.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	if err := storage.UnlinkIdentity(ctx, u.db, claims.Subject, params["provider"]); err != nil {
+		switch err {
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case storage.ErrNotFound:
+			return web.NewCodedError(err, http.StatusNotFound, codeIdentityNotFound)
+		default:
+			return errors.Wrapf(err, "unlinking identity of %q", claims.Subject)
+		}
+	}
+
+	return web.Respond(ctx, w, UnlinkIdentityResponse{}, http.StatusOK)
+}
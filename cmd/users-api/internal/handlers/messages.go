@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"github.com/igomonov88/users/internal/email"
+	"github.com/igomonov88/users/internal/password"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/username"
+)
+
+// messages are the texts of fields rejected by the policies and of the codes
+// of the catalog, by locale. Titles of codes in the default locale are the
+// ones of the catalog.
+var messages = map[string]map[string]string{
+	"en": {
+		"validation.email_address": "{field} must be a valid email address",
+
+		"reason." + username.ReasonRequired:   "{field} is a required field",
+		"reason." + username.ReasonTooShort:   "{field} is too short",
+		"reason." + username.ReasonTooLong:    "{field} is too long",
+		"reason." + username.ReasonCharacters: "{field} contains characters which are not allowed",
+		"reason." + username.ReasonReserved:   "{field} is reserved",
+		"reason." + username.ReasonProfane:    "{field} contains words which are not allowed",
+		"reason." + username.ReasonDenied:     "{field} is not allowed",
+		"reason." + username.ReasonConfusable: "{field} is too similar to one which is taken",
+		"reason." + username.ReasonTaken:      "{field} is already taken",
+
+		"reason." + password.ReasonTooWeak:  "{field} is too easy to guess",
+		"reason." + password.ReasonPersonal: "{field} must not contain the user name or email address",
+		"reason." + password.ReasonBreached: "{field} appeared in a data breach",
+
+		"reason." + email.ReasonInvalid:    "{field} must be a valid email address",
+		"reason." + email.ReasonDenied:     "{field} uses a domain which is not allowed",
+		"reason." + email.ReasonDisposable: "{field} uses a disposable email provider",
+		"reason." + email.ReasonNotInvited: "{field} uses a domain which was not invited",
+	},
+	"ru": {
+		"validation.email_address": "{field} должно быть корректным адресом электронной почты",
+
+		"reason." + username.ReasonRequired:   "{field} обязательное поле",
+		"reason." + username.ReasonTooShort:   "{field} слишком короткое",
+		"reason." + username.ReasonTooLong:    "{field} слишком длинное",
+		"reason." + username.ReasonCharacters: "{field} содержит недопустимые символы",
+		"reason." + username.ReasonReserved:   "{field} зарезервировано",
+		"reason." + username.ReasonProfane:    "{field} содержит недопустимые слова",
+		"reason." + username.ReasonDenied:     "{field} не разрешено",
+		"reason." + username.ReasonConfusable: "{field} слишком похоже на уже занятое",
+		"reason." + username.ReasonTaken:      "{field} уже занято",
+
+		"reason." + password.ReasonTooWeak:  "{field} слишком легко подобрать",
+		"reason." + password.ReasonPersonal: "{field} не должно содержать имя пользователя или адрес электронной почты",
+		"reason." + password.ReasonBreached: "{field} встречается в утечках данных",
+
+		"reason." + email.ReasonInvalid:    "{field} должно быть корректным адресом электронной почты",
+		"reason." + email.ReasonDenied:     "{field} использует запрещённый домен",
+		"reason." + email.ReasonDisposable: "{field} использует сервис одноразовой почты",
+		"reason." + email.ReasonNotInvited: "{field} использует домен, который не был приглашён",
+
+		"code." + codeSessionNotFound.Code:  "Сессия не найдена",
+		"code." + codeAPIKeyNotFound.Code:   "API-ключ не найден",
+		"code." + codeIdentityNotFound.Code: "Учётная запись провайдера не найдена",
+		"code." + codeAccountLocked.Code:    "Учётная запись временно заблокирована",
+		"code." + codeUserNameRejected.Code: "Имя пользователя не разрешено",
+		"code." + codeEmailRejected.Code:    "Адрес электронной почты не разрешён",
+		"code." + codePasswordRejected.Code: "Пароль не разрешён",
+
+		"code.user.not_found":           "Пользователь не найден",
+		"code.user.invalid_id":          "Некорректный идентификатор пользователя",
+		"code.user.email_taken":         "Адрес электронной почты уже занят",
+		"code.user.name_taken":          "Имя пользователя уже занято",
+		"code.auth.invalid_credentials": "Неверные учётные данные",
+		"code.auth.token_reused":        "Токен уже использован",
+		"code.mfa.already_enabled":      "Двухфакторная аутентификация уже включена",
+		"code.mfa.code_reused":          "Одноразовый пароль уже использован",
+		"code.identity.already_linked":  "Учётная запись провайдера уже привязана",
+
+		"code.auth.session_revoked": "Сессия отозвана",
+		"code.auth.mfa_required":    "Требуется двухфакторная аутентификация",
+
+		"code.mfa.invalid_code":        "Неверный код двухфакторной аутентификации",
+		"code.mfa.not_enrolled":        "Двухфакторная аутентификация не включена",
+		"code.mfa.challenge_not_found": "Запрос двухфакторной аутентификации не найден",
+
+		"code.federation.unknown_provider": "Неизвестный провайдер учётных записей",
+		"code.federation.invalid_state":    "Некорректное состояние входа",
+		"code.federation.exchange_failed":  "Не удалось войти через провайдера",
+		"code.federation.invalid_id_token": "Некорректный ID-токен",
+		"code.federation.no_account":       "Нет привязанной учётной записи",
+
+		"code.avatar.upload_not_found":   "Загрузка аватара не найдена",
+		"code.avatar.too_large":          "Аватар слишком большой",
+		"code.avatar.unsupported_type":   "Тип аватара не поддерживается",
+		"code.avatar.invalid_dimensions": "Размеры аватара вне допустимых пределов",
+	},
+}
+
+func init() {
+	for locale, msgs := range messages {
+		web.RegisterMessages(locale, msgs)
+	}
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/mfa"
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// ConfirmTOTP enables two-factor authentication for the authenticated user
+// with the first code generated from the enrolled secret. It responds with
+// the recovery codes which are never shown again.
+func (u *User) ConfirmTOTP(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.ConfirmTOTP")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("confirm totp", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var req TOTPCodeRequest
+	if err := web.Decode(r, &req); err != nil {
+		return errors.Wrap(err, "decoding code")
+	}
+
+	codes, err := u.mfa.Confirm(ctx, claims.Subject, req.Code, v.Now)
+	if err != nil {
+		return mfaError(err)
+	}
+
+	return web.Respond(ctx, w, RecoveryCodesResponse{RecoveryCodes: codes}, http.StatusOK)
+}
+
+// mfaError converts the expected errors of the mfa package to request errors.
+func mfaError(err error) error {
+	switch err {
+	case mfa.ErrInvalidCode:
+		return web.NewRequestError(err, http.StatusForbidden)
+	case mfa.ErrNotEnrolled:
+		return web.NewRequestError(err, http.StatusBadRequest)
+	case storage.ErrTOTPAlreadyConfirmed:
+		return web.NewRequestError(err, http.StatusConflict)
+	default:
+		return errors.Wrap(err, "two-factor authentication")
+	}
+}
@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// DisableTOTP turns two-factor authentication off for the authenticated
+// user. It requires a current one-time password.
+func (u *User) DisableTOTP(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.DisableTOTP")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("disable totp", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var req TOTPCodeRequest
+	if err := web.Decode(r, &req); err != nil {
+		return errors.Wrap(err, "decoding code")
+	}
+
+	if err := u.mfa.Disable(ctx, claims.Subject, req.Code, v.Now); err != nil {
+		return mfaError(err)
+	}
+
+	return web.Respond(ctx, w, DisableTOTPResponse{}, http.StatusOK)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// EnrollTOTP generates a new two-factor secret for the authenticated user.
+// It responds with the secret, the otpauth:// URI and a QR code PNG of the
+// URI. The secret is used only after it is confirmed with ConfirmTOTP.
+func (u *User) EnrollTOTP(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.EnrollTOTP")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("enroll totp", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	usr, err := storage.Retrieve(ctx, u.db, claims.Subject)
+	if err != nil {
+		return errors.Wrapf(err, "retrieving user %q", claims.Subject)
+	}
+
+	e, err := u.mfa.Enroll(ctx, usr, v.Now)
+	if err != nil {
+		switch err {
+		case storage.ErrTOTPAlreadyConfirmed:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return errors.Wrapf(err, "enrolling user %q", usr.ID)
+		}
+	}
+
+	resp := EnrollTOTPResponse{
+		Secret: e.Secret,
+		URI:    e.URI,
+		QRCode: e.QRCode,
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
@@ -1,60 +1,294 @@
 package handlers
 
+import (
+	"time"
+
+	"github.com/igomonov88/users/internal/platform/graph"
+)
+
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=users:read users:write"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+type CreateAPIKeyResponse struct {
+	APIKey APIKey `json:"api_key"`
+	Key    string `json:"key"`
+}
+
+type APIKey struct {
+	ID         string     `json:"api_key_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type ListAPIKeysResponse struct {
+	APIKeys []APIKey `json:"api_keys"`
+}
+
+type RevokeAPIKeyResponse struct{}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required" protobuf:"bytes,1,opt,name=current_password,json=currentPassword,proto3"`
+	NewPassword     string `json:"new_password" validate:"required" protobuf:"bytes,2,opt,name=new_password,json=newPassword,proto3"`
+}
+
+type ChangePasswordResponse struct{}
+
 type CreateUserRequest struct {
-	Name     string `json:"name" validate:"required"`
-	Email    string `json:"email" validate:"required"`
-	Avatar   string `json:"avatar"`
-	Password string `json:"password" validate:"required"`
+	Name     string `json:"name" validate:"required" protobuf:"bytes,1,opt,name=name,proto3"`
+	Email    string `json:"email" validate:"required,email_address" protobuf:"bytes,2,opt,name=email,proto3"`
+	Avatar   string `json:"avatar" protobuf:"bytes,3,opt,name=avatar,proto3"`
+	Password string `json:"password" validate:"required" protobuf:"bytes,4,opt,name=password,proto3"`
 }
 
 type CreateUserResponse struct {
-	UserID string `json:"user_id"`
+	UserID string `json:"user_id" protobuf:"bytes,1,opt,name=user_id,json=userId,proto3"`
 }
 
 type DeleteUserRequest struct {
-	UserID string `json:"user_id"`
+	UserID string `json:"user_id" protobuf:"bytes,1,opt,name=user_id,json=userId,proto3"`
 }
 
 type DeleteUserResponse struct {
 }
 
-type EmailExistRequest struct{}
+type EmailExistRequest struct {
+	Email string `json:"email" validate:"required" protobuf:"bytes,1,opt,name=email,proto3"`
+}
 
 type EmailExistResponse struct {
-	Exist bool `json:"exist"`
+	Exist bool `json:"exist" protobuf:"varint,1,opt,name=exist,proto3"`
+}
+
+type HealthResponse struct {
+	Version string `json:"version" protobuf:"bytes,1,opt,name=version,proto3"`
+	Status  string `json:"status" protobuf:"bytes,2,opt,name=status,proto3"`
+}
+
+type Identity struct {
+	Provider    string    `json:"provider"`
+	Email       string    `json:"email"`
+	CreatedAt   time.Time `json:"created_at"`
+	LastLoginAt time.Time `json:"last_login_at"`
+}
+
+type ListIdentitiesResponse struct {
+	Identities []Identity `json:"identities"`
+}
+
+type UnlinkIdentityResponse struct{}
+
+type PresignAvatarRequest struct {
+	ContentType string `json:"content_type" validate:"required" protobuf:"bytes,1,opt,name=content_type,json=contentType,proto3"`
+}
+
+type PresignAvatarResponse struct {
+	URL         string    `json:"url"`
+	Key         string    `json:"key"`
+	ContentType string    `json:"content_type"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+type CompleteAvatarRequest struct {
+	Key string `json:"key" validate:"required" protobuf:"bytes,1,opt,name=key,proto3"`
+}
+
+type RegisterOAuthClientRequest struct {
+	Name         string   `json:"name" validate:"required,max=100"`
+	RedirectURIs []string `json:"redirect_uris" validate:"dive,url"`
+	GrantTypes   []string `json:"grant_types" validate:"required,min=1"`
+	Scopes       []string `json:"scopes" validate:"required,min=1"`
+	Confidential bool     `json:"confidential"`
+}
+
+type RegisterOAuthClientResponse struct {
+	ClientID     string    `json:"client_id"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	GrantTypes   []string  `json:"grant_types"`
+	Scopes       []string  `json:"scopes"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ListUsersResponse struct {
+	Users []*RetrieveUserResponse `json:"users" protobuf:"bytes,1,rep,name=users,proto3"`
+}
+
+type PatchUserRequest struct {
+	Name   string `json:"name" validate:"required"`
+	Email  string `json:"email" validate:"required,email_address"`
+	Locale string `json:"locale,omitempty" validate:"omitempty,locale"`
+}
+
+type RemoveAvatarResponse struct {
+	Avatar string `json:"avatar" protobuf:"bytes,1,opt,name=avatar,proto3"`
 }
 
 type RetrieveUserRequest struct {
+	UserID string `json:"user_id" validate:"required" protobuf:"bytes,1,opt,name=user_id,json=userId,proto3"`
+}
+
+type RetrieveUserByEmailRequest struct {
+	Email string `json:"email" validate:"required" protobuf:"bytes,1,opt,name=email,proto3"`
+}
+
+type RetrieveUserByNameRequest struct {
+	UserName string `json:"user_name" validate:"required" protobuf:"bytes,1,opt,name=user_name,json=userName,proto3"`
 }
 
 type RetrieveUserResponse struct {
-	UserID   string `json:"user_id"`
-	UserName string `json:"user_name"`
-	Email    string `json:"email"`
-	Avatar   string `json:"avatar"`
+	UserID   string `json:"user_id" protobuf:"bytes,1,opt,name=user_id,json=userId,proto3"`
+	UserName string `json:"user_name" protobuf:"bytes,2,opt,name=user_name,json=userName,proto3"`
+	Email    string `json:"email,omitempty" protobuf:"bytes,3,opt,name=email,proto3"`
+	Avatar   string `json:"avatar" protobuf:"bytes,4,opt,name=avatar,proto3"`
+	Locale   string `json:"locale,omitempty" protobuf:"bytes,5,opt,name=locale,proto3"`
+}
+
+type Session struct {
+	ID         string    `json:"session_id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+type ListSessionsResponse struct {
+	Sessions []Session `json:"sessions"`
+}
+
+type RevokeSessionResponse struct{}
+
+type RevokeOtherSessionsResponse struct {
+	Revoked int `json:"revoked"`
+}
+
+// GraphQLRequest is the body of GraphQL queries.
+type GraphQLRequest graph.Request
+
+// GraphQLResponse is the body of the results of GraphQL queries.
+type GraphQLResponse graph.Response
+
+type TokenRequest struct {
+	Email    string `json:"email" validate:"required" protobuf:"bytes,1,opt,name=email,proto3"`
+	Password string `json:"password" validate:"required" protobuf:"bytes,2,opt,name=password,proto3"`
 }
 
 type TokenResponse struct {
-	Token string `json:"token"`
+	Token       string `json:"token,omitempty" protobuf:"bytes,1,opt,name=token,proto3"`
+	MFARequired bool   `json:"mfa_required,omitempty" protobuf:"varint,2,opt,name=mfa_required,json=mfaRequired,proto3"`
+	Challenge   string `json:"challenge,omitempty" protobuf:"bytes,3,opt,name=challenge,proto3"`
 }
 
+type TokenOTPRequest struct {
+	Challenge    string `json:"challenge" validate:"required" protobuf:"bytes,1,opt,name=challenge,proto3"`
+	Code         string `json:"code" validate:"required_without=RecoveryCode" protobuf:"bytes,2,opt,name=code,proto3"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code" protobuf:"bytes,3,opt,name=recovery_code,json=recoveryCode,proto3"`
+}
+
+type EnrollTOTPResponse struct {
+	Secret string `json:"secret" protobuf:"bytes,1,opt,name=secret,proto3"`
+	URI    string `json:"uri" protobuf:"bytes,2,opt,name=uri,proto3"`
+	QRCode []byte `json:"qr_code_png" protobuf:"bytes,3,opt,name=qr_code_png,json=qrCodePng,proto3"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code" validate:"required" protobuf:"bytes,1,opt,name=code,proto3"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes" protobuf:"bytes,1,rep,name=recovery_codes,json=recoveryCodes,proto3"`
+}
+
+type DisableTOTPResponse struct{}
+
+type UnlockUserResponse struct{}
+
 type UpdateAvatarRequest struct {
-	UserID string `json:"user_id"`
-	Avatar string `json:"avatar"`
+	UserID string `json:"user_id" protobuf:"bytes,1,opt,name=user_id,json=userId,proto3"`
+	Avatar string `json:"avatar" protobuf:"bytes,2,opt,name=avatar,proto3"`
 }
 
 type UpdateAvatarResponse struct{}
 
+type UploadAvatarResponse struct {
+	Avatar     string         `json:"avatar"`
+	Thumbnails map[int]string `json:"thumbnails"`
+}
+
 type UpdateUserRequest struct {
-	UserID string `json:"user_id"`
-	Name   string `json:"name"`
-	Email  string `json:"email"`
+	UserID string `json:"user_id" protobuf:"bytes,1,opt,name=user_id,json=userId,proto3"`
+	Name   string `json:"name" protobuf:"bytes,2,opt,name=name,proto3"`
+	Email  string `json:"email" validate:"required,email_address" protobuf:"bytes,3,opt,name=email,proto3"`
+	Locale string `json:"locale" validate:"omitempty,locale" protobuf:"bytes,4,opt,name=locale,proto3"`
 }
 
 type UpdateUserResponse struct{}
 
-type UserNameExistRequest struct{}
+type UserNameExistRequest struct {
+	UserName string `json:"user_name" validate:"required" protobuf:"bytes,1,opt,name=user_name,json=userName,proto3"`
+}
 
 type UserNameExistResponse struct {
-	Exist bool `json:"exist"`
+	Exist bool `json:"exist" protobuf:"varint,1,opt,name=exist,proto3"`
+}
+
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" validate:"required,url,max=2048"`
+	Events []string `json:"events" validate:"required,min=1"`
+	Secret string   `json:"secret" validate:"omitempty,min=16,max=256"`
+}
+
+type CreateWebhookResponse struct {
+	Webhook Webhook `json:"webhook"`
+	Secret  string  `json:"secret"`
+}
+
+type Webhook struct {
+	ID         string     `json:"webhook_id"`
+	URL        string     `json:"url"`
+	Events     []string   `json:"events"`
+	Failures   int        `json:"failures"`
+	CreatedAt  time.Time  `json:"created_at"`
+	DisabledAt *time.Time `json:"disabled_at"`
+}
+
+type ListWebhooksResponse struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+type DeleteWebhookResponse struct{}
+
+type EnableWebhookResponse struct{}
+
+type WebhookDelivery struct {
+	ID            string           `json:"delivery_id"`
+	Event         string           `json:"event"`
+	State         string           `json:"state"`
+	Attempts      []WebhookAttempt `json:"attempts"`
+	NextAttemptAt *time.Time       `json:"next_attempt_at"`
+	CreatedAt     time.Time        `json:"created_at"`
+	DeliveredAt   *time.Time       `json:"delivered_at"`
 }
+
+type WebhookAttempt struct {
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error"`
+	DurationMS int64     `json:"duration_ms"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ListWebhookDeliveriesResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
+
+type RedeliverWebhookResponse struct{}
@@ -0,0 +1,143 @@
+package handlers
+
+import "github.com/golang/protobuf/proto"
+
+// The models below are the protobuf messages of users.proto, so they can be
+// sent and received as application/x-protobuf. Models holding times or
+// counts are not messages yet.
+
+func (m *HealthResponse) Reset()         { *m = HealthResponse{} }
+func (m *HealthResponse) String() string { return proto.CompactTextString(m) }
+func (*HealthResponse) ProtoMessage()    {}
+
+func (m *CreateUserRequest) Reset()         { *m = CreateUserRequest{} }
+func (m *CreateUserRequest) String() string { return proto.CompactTextString(m) }
+func (*CreateUserRequest) ProtoMessage()    {}
+
+func (m *CreateUserResponse) Reset()         { *m = CreateUserResponse{} }
+func (m *CreateUserResponse) String() string { return proto.CompactTextString(m) }
+func (*CreateUserResponse) ProtoMessage()    {}
+
+func (m *RetrieveUserRequest) Reset()         { *m = RetrieveUserRequest{} }
+func (m *RetrieveUserRequest) String() string { return proto.CompactTextString(m) }
+func (*RetrieveUserRequest) ProtoMessage()    {}
+
+func (m *RetrieveUserByEmailRequest) Reset()         { *m = RetrieveUserByEmailRequest{} }
+func (m *RetrieveUserByEmailRequest) String() string { return proto.CompactTextString(m) }
+func (*RetrieveUserByEmailRequest) ProtoMessage()    {}
+
+func (m *RetrieveUserByNameRequest) Reset()         { *m = RetrieveUserByNameRequest{} }
+func (m *RetrieveUserByNameRequest) String() string { return proto.CompactTextString(m) }
+func (*RetrieveUserByNameRequest) ProtoMessage()    {}
+
+func (m *RetrieveUserResponse) Reset()         { *m = RetrieveUserResponse{} }
+func (m *RetrieveUserResponse) String() string { return proto.CompactTextString(m) }
+func (*RetrieveUserResponse) ProtoMessage()    {}
+
+func (m *ListUsersResponse) Reset()         { *m = ListUsersResponse{} }
+func (m *ListUsersResponse) String() string { return proto.CompactTextString(m) }
+func (*ListUsersResponse) ProtoMessage()    {}
+
+func (m *UpdateUserRequest) Reset()         { *m = UpdateUserRequest{} }
+func (m *UpdateUserRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdateUserRequest) ProtoMessage()    {}
+
+func (m *UpdateUserResponse) Reset()         { *m = UpdateUserResponse{} }
+func (m *UpdateUserResponse) String() string { return proto.CompactTextString(m) }
+func (*UpdateUserResponse) ProtoMessage()    {}
+
+func (m *DeleteUserRequest) Reset()         { *m = DeleteUserRequest{} }
+func (m *DeleteUserRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteUserRequest) ProtoMessage()    {}
+
+func (m *DeleteUserResponse) Reset()         { *m = DeleteUserResponse{} }
+func (m *DeleteUserResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteUserResponse) ProtoMessage()    {}
+
+func (m *EmailExistRequest) Reset()         { *m = EmailExistRequest{} }
+func (m *EmailExistRequest) String() string { return proto.CompactTextString(m) }
+func (*EmailExistRequest) ProtoMessage()    {}
+
+func (m *EmailExistResponse) Reset()         { *m = EmailExistResponse{} }
+func (m *EmailExistResponse) String() string { return proto.CompactTextString(m) }
+func (*EmailExistResponse) ProtoMessage()    {}
+
+func (m *UserNameExistRequest) Reset()         { *m = UserNameExistRequest{} }
+func (m *UserNameExistRequest) String() string { return proto.CompactTextString(m) }
+func (*UserNameExistRequest) ProtoMessage()    {}
+
+func (m *UserNameExistResponse) Reset()         { *m = UserNameExistResponse{} }
+func (m *UserNameExistResponse) String() string { return proto.CompactTextString(m) }
+func (*UserNameExistResponse) ProtoMessage()    {}
+
+func (m *ChangePasswordRequest) Reset()         { *m = ChangePasswordRequest{} }
+func (m *ChangePasswordRequest) String() string { return proto.CompactTextString(m) }
+func (*ChangePasswordRequest) ProtoMessage()    {}
+
+func (m *ChangePasswordResponse) Reset()         { *m = ChangePasswordResponse{} }
+func (m *ChangePasswordResponse) String() string { return proto.CompactTextString(m) }
+func (*ChangePasswordResponse) ProtoMessage()    {}
+
+func (m *UpdateAvatarRequest) Reset()         { *m = UpdateAvatarRequest{} }
+func (m *UpdateAvatarRequest) String() string { return proto.CompactTextString(m) }
+func (*UpdateAvatarRequest) ProtoMessage()    {}
+
+func (m *UpdateAvatarResponse) Reset()         { *m = UpdateAvatarResponse{} }
+func (m *UpdateAvatarResponse) String() string { return proto.CompactTextString(m) }
+func (*UpdateAvatarResponse) ProtoMessage()    {}
+
+func (m *RemoveAvatarResponse) Reset()         { *m = RemoveAvatarResponse{} }
+func (m *RemoveAvatarResponse) String() string { return proto.CompactTextString(m) }
+func (*RemoveAvatarResponse) ProtoMessage()    {}
+
+func (m *PresignAvatarRequest) Reset()         { *m = PresignAvatarRequest{} }
+func (m *PresignAvatarRequest) String() string { return proto.CompactTextString(m) }
+func (*PresignAvatarRequest) ProtoMessage()    {}
+
+func (m *CompleteAvatarRequest) Reset()         { *m = CompleteAvatarRequest{} }
+func (m *CompleteAvatarRequest) String() string { return proto.CompactTextString(m) }
+func (*CompleteAvatarRequest) ProtoMessage()    {}
+
+func (m *TokenRequest) Reset()         { *m = TokenRequest{} }
+func (m *TokenRequest) String() string { return proto.CompactTextString(m) }
+func (*TokenRequest) ProtoMessage()    {}
+
+func (m *TokenResponse) Reset()         { *m = TokenResponse{} }
+func (m *TokenResponse) String() string { return proto.CompactTextString(m) }
+func (*TokenResponse) ProtoMessage()    {}
+
+func (m *TokenOTPRequest) Reset()         { *m = TokenOTPRequest{} }
+func (m *TokenOTPRequest) String() string { return proto.CompactTextString(m) }
+func (*TokenOTPRequest) ProtoMessage()    {}
+
+func (m *EnrollTOTPResponse) Reset()         { *m = EnrollTOTPResponse{} }
+func (m *EnrollTOTPResponse) String() string { return proto.CompactTextString(m) }
+func (*EnrollTOTPResponse) ProtoMessage()    {}
+
+func (m *TOTPCodeRequest) Reset()         { *m = TOTPCodeRequest{} }
+func (m *TOTPCodeRequest) String() string { return proto.CompactTextString(m) }
+func (*TOTPCodeRequest) ProtoMessage()    {}
+
+func (m *RecoveryCodesResponse) Reset()         { *m = RecoveryCodesResponse{} }
+func (m *RecoveryCodesResponse) String() string { return proto.CompactTextString(m) }
+func (*RecoveryCodesResponse) ProtoMessage()    {}
+
+func (m *DisableTOTPResponse) Reset()         { *m = DisableTOTPResponse{} }
+func (m *DisableTOTPResponse) String() string { return proto.CompactTextString(m) }
+func (*DisableTOTPResponse) ProtoMessage()    {}
+
+func (m *UnlockUserResponse) Reset()         { *m = UnlockUserResponse{} }
+func (m *UnlockUserResponse) String() string { return proto.CompactTextString(m) }
+func (*UnlockUserResponse) ProtoMessage()    {}
+
+func (m *RevokeAPIKeyResponse) Reset()         { *m = RevokeAPIKeyResponse{} }
+func (m *RevokeAPIKeyResponse) String() string { return proto.CompactTextString(m) }
+func (*RevokeAPIKeyResponse) ProtoMessage()    {}
+
+func (m *RevokeSessionResponse) Reset()         { *m = RevokeSessionResponse{} }
+func (m *RevokeSessionResponse) String() string { return proto.CompactTextString(m) }
+func (*RevokeSessionResponse) ProtoMessage()    {}
+
+func (m *UnlinkIdentityResponse) Reset()         { *m = UnlinkIdentityResponse{} }
+func (m *UnlinkIdentityResponse) String() string { return proto.CompactTextString(m) }
+func (*UnlinkIdentityResponse) ProtoMessage()    {}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+	newrelic "github.com/newrelic/go-agent"
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/users/internal/lockout"
+	"github.com/igomonov88/users/internal/mfa"
+	"github.com/igomonov88/users/internal/oauth"
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// OAuth represents the OAuth authorization server and OpenID Connect
+// provider handler set.
+type OAuth struct {
+	db       *sqlx.DB
+	provider *oauth.Provider
+	relict   newrelic.Application
+	guard    *lockout.Guard
+	mfa      *mfa.MFA
+	trusted  []*net.IPNet
+}
+
+// oauthError sends an *oauth.Error in the form OAuth clients expect instead
+// of the usual error response. Any other error is returned as is.
+func oauthError(ctx context.Context, w http.ResponseWriter, err error) error {
+	oe, ok := errors.Cause(err).(*oauth.Error)
+	if !ok {
+		return err
+	}
+
+	switch oe.Code {
+	case "invalid_client":
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	case "invalid_token", "insufficient_scope":
+		w.Header().Set("WWW-Authenticate", `Bearer error="`+oe.Code+`"`)
+	}
+
+	return web.Respond(ctx, w, oe, oe.Status)
+}
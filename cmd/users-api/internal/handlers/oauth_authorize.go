@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/lockout"
+	"github.com/igomonov88/users/internal/mfa"
+	"github.com/igomonov88/users/internal/oauth"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// loginPage is the form users sign in with during an authorization request.
+// The parameters of the request are carried in hidden fields.
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign in</title></head>
+<body>
+<h1>Sign in to {{.Client}}</h1>
+{{if .Error}}<p role="alert">{{.Error}}</p>{{end}}
+<form method="post" action="{{.Action}}">
+{{range $k, $v := .Params}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">
+{{end}}<label>Email <input type="email" name="email" value="{{.Email}}" required></label>
+<label>Password <input type="password" name="password" required></label>
+{{if .OTP}}<label>One-time password <input type="text" name="otp" autocomplete="one-time-code" required></label>
+{{end}}<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`))
+
+// loginForm is the data rendered by loginPage.
+type loginForm struct {
+	Client string
+	Action string
+	Params url.Values
+	Email  string
+	OTP    bool
+	Error  string
+}
+
+// Authorize starts an authorization request. It validates the request and
+// shows the login form. Errors about the client or the redirect uri are shown
+// to the user, all other errors are sent to the client.
+func (o *OAuth) Authorize(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.OAuth.Authorize")
+	defer span.End()
+
+	txn := o.relict.StartTransaction("oauth authorize", w, r)
+	defer txn.End()
+
+	ar := oauth.ParseAuthorizeRequest(r.URL.Query())
+
+	c, err := o.provider.Client(ctx, ar)
+	if err != nil {
+		return clientError(err)
+	}
+
+	if err := o.provider.Validate(c, &ar); err != nil {
+		return o.redirectError(ctx, w, r, ar, err)
+	}
+
+	f := loginForm{
+		Client: c.Name,
+		Params: ar.Values(),
+	}
+
+	return o.login(ctx, w, f, http.StatusOK)
+}
+
+// AuthorizeLogin handles the login form of an authorization request. Users
+// with two-factor authentication enabled have to provide a one-time password
+// as well. On success the user is redirected to the client with a code.
+// Clients registered here are trusted, so there is no consent screen.
+func (o *OAuth) AuthorizeLogin(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.OAuth.AuthorizeLogin")
+	defer span.End()
+
+	txn := o.relict.StartTransaction("oauth authorize login", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	ar := oauth.ParseAuthorizeRequest(r.PostForm)
+
+	c, err := o.provider.Client(ctx, ar)
+	if err != nil {
+		return clientError(err)
+	}
+
+	if err := o.provider.Validate(c, &ar); err != nil {
+		return o.redirectError(ctx, w, r, ar, err)
+	}
+
+	email := r.PostForm.Get("email")
+	f := loginForm{
+		Client: c.Name,
+		Params: ar.Values(),
+		Email:  email,
+	}
+
+	ip := web.ClientIP(r, o.trusted)
+	if err := o.guard.Check(ctx, email, ip, v.Now); err != nil {
+		if _, ok := errors.Cause(err).(*lockout.LockedError); !ok {
+			return errors.Wrap(err, "checking lockout")
+		}
+		f.Error = err.Error()
+		return o.login(ctx, w, f, http.StatusTooManyRequests)
+	}
+
+	claims, err := storage.Authenticate(ctx, o.db, v.Now, email, r.PostForm.Get("password"))
+	if err != nil {
+		switch err {
+		case storage.ErrAuthenticationFailure:
+			if err := o.guard.Fail(ctx, email, ip, v.Now); err != nil {
+				return errors.Wrap(err, "recording login failure")
+			}
+			f.Error = "Invalid email or password."
+			return o.login(ctx, w, f, http.StatusUnauthorized)
+		default:
+			return errors.Wrap(err, "authenticating")
+		}
+	}
+
+	enrolled, err := o.mfa.Enrolled(ctx, claims.Subject)
+	if err != nil {
+		return errors.Wrap(err, "checking two-factor enrollment")
+	}
+	if enrolled {
+		f.OTP = true
+
+		code := r.PostForm.Get("otp")
+		if code == "" {
+			return o.login(ctx, w, f, http.StatusOK)
+		}
+
+		if err := o.mfa.Verify(ctx, claims.Subject, code, v.Now); err != nil {
+			if err != mfa.ErrInvalidCode {
+				return errors.Wrap(err, "verifying second factor")
+			}
+			if err := o.guard.Fail(ctx, email, ip, v.Now); err != nil {
+				return errors.Wrap(err, "recording login failure")
+			}
+			f.Error = "Invalid one-time password."
+			return o.login(ctx, w, f, http.StatusUnauthorized)
+		}
+	}
+
+	if err := o.guard.Succeed(ctx, email); err != nil {
+		return errors.Wrap(err, "resetting login failures")
+	}
+
+	uri, err := o.provider.Grant(ctx, ar, claims.Subject, v.Now, v.Now)
+	if err != nil {
+		return errors.Wrap(err, "granting authorization code")
+	}
+
+	return web.Redirect(ctx, w, r, uri, http.StatusSeeOther)
+}
+
+// login renders the login form.
+func (o *OAuth) login(ctx context.Context, w http.ResponseWriter, f loginForm, status int) error {
+	f.Action = oauth.PathAuthorize
+
+	var b bytes.Buffer
+	if err := loginPage.Execute(&b, f); err != nil {
+		return errors.Wrap(err, "rendering login page")
+	}
+
+	// The page must not be cached or framed by other sites.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+
+	return web.RespondRaw(ctx, w, b.Bytes(), "text/html; charset=utf-8", status)
+}
+
+// redirectError sends an *oauth.Error to the client with a redirect. Any
+// other error is returned as is.
+func (o *OAuth) redirectError(ctx context.Context, w http.ResponseWriter, r *http.Request, ar oauth.AuthorizeRequest, err error) error {
+	oe, ok := errors.Cause(err).(*oauth.Error)
+	if !ok {
+		return err
+	}
+
+	return web.Redirect(ctx, w, r, o.provider.ErrorRedirect(ar, oe), http.StatusFound)
+}
+
+// clientError shows an *oauth.Error about the client or the redirect uri to
+// the user. Any other error is returned as is.
+func clientError(err error) error {
+	if _, ok := errors.Cause(err).(*oauth.Error); !ok {
+		return errors.Wrap(err, "finding client")
+	}
+
+	return web.NewRequestError(err, http.StatusBadRequest)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/oauth"
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// RegisterClient registers an application which signs users in with this
+// service. The secret of confidential clients is part of the response only
+// once. It is available for admins only.
+func (o *OAuth) RegisterClient(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.OAuth.RegisterClient")
+	defer span.End()
+
+	txn := o.relict.StartTransaction("register oauth client", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var req RegisterOAuthClientRequest
+	if err := web.Decode(r, &req); err != nil {
+		return errors.Wrap(err, "decoding oauth client")
+	}
+
+	nc := oauth.NewClient{
+		Name:         req.Name,
+		RedirectURIs: req.RedirectURIs,
+		GrantTypes:   req.GrantTypes,
+		Scopes:       req.Scopes,
+		Confidential: req.Confidential,
+	}
+
+	c, secret, err := o.provider.RegisterClient(ctx, nc, v.Now)
+	if err != nil {
+		if _, ok := errors.Cause(err).(*oauth.Error); ok {
+			return web.NewRequestError(err, http.StatusBadRequest)
+		}
+		return errors.Wrap(err, "registering oauth client")
+	}
+
+	resp := RegisterOAuthClientResponse{
+		ClientID:     c.ID,
+		ClientSecret: secret,
+		Name:         c.Name,
+		RedirectURIs: c.RedirectURIs,
+		GrantTypes:   c.GrantTypes,
+		Scopes:       c.Scopes,
+		CreatedAt:    c.CreatedAt,
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusCreated)
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// Discovery responds with the OpenID Connect discovery document so clients
+// can configure themselves from the issuer alone.
+func (o *OAuth) Discovery(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.OAuth.Discovery")
+	defer span.End()
+
+	txn := o.relict.StartTransaction("oauth discovery", w, r)
+	defer txn.End()
+
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+
+	return web.Respond(ctx, w, o.provider.Discovery(), http.StatusOK)
+}
+
+// JWKS responds with the public keys tokens are signed with.
+func (o *OAuth) JWKS(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.OAuth.JWKS")
+	defer span.End()
+
+	txn := o.relict.StartTransaction("oauth jwks", w, r)
+	defer txn.End()
+
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+
+	return web.Respond(ctx, w, o.provider.JWKS(), http.StatusOK)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/oauth"
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// Token handles requests of clients to the token endpoint. It expects a form
+// and responds with tokens or an OAuth error. Confidential clients
+// authenticate with Basic auth or the client_secret field.
+func (o *OAuth) Token(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.OAuth.Token")
+	defer span.End()
+
+	txn := o.relict.StartTransaction("oauth token", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	// Responses contain credentials and must never be cached.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		return oauthError(ctx, w, &oauth.Error{Code: "invalid_request", Description: "malformed form", Status: http.StatusBadRequest})
+	}
+
+	tr := oauth.TokenRequest{
+		GrantType:    r.PostForm.Get("grant_type"),
+		ClientID:     r.PostForm.Get("client_id"),
+		ClientSecret: r.PostForm.Get("client_secret"),
+		Code:         r.PostForm.Get("code"),
+		RedirectURI:  r.PostForm.Get("redirect_uri"),
+		CodeVerifier: r.PostForm.Get("code_verifier"),
+		RefreshToken: r.PostForm.Get("refresh_token"),
+		Scope:        r.PostForm.Get("scope"),
+	}
+
+	// The credentials in Basic auth are form encoded (RFC 6749 section 2.3.1).
+	if id, secret, ok := r.BasicAuth(); ok {
+		if tr.ClientSecret != "" {
+			return oauthError(ctx, w, &oauth.Error{Code: "invalid_request", Description: "multiple client authentication methods", Status: http.StatusBadRequest})
+		}
+		id, errID := url.QueryUnescape(id)
+		secret, errSecret := url.QueryUnescape(secret)
+		if errID != nil || errSecret != nil {
+			return oauthError(ctx, w, &oauth.Error{Code: "invalid_client", Description: "malformed client credentials", Status: http.StatusUnauthorized})
+		}
+		tr.ClientID = id
+		tr.ClientSecret = secret
+	}
+
+	tkn, err := o.provider.Token(ctx, tr, v.Now)
+	if err != nil {
+		if err := oauthError(ctx, w, err); err != nil {
+			return errors.Wrap(err, "issuing token")
+		}
+		return nil
+	}
+
+	return web.Respond(ctx, w, tkn, http.StatusOK)
+}
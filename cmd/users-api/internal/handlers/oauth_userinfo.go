@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/oauth"
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// UserInfo responds with the claims about the user an access token with the
+// openid scope was issued for.
+func (o *OAuth) UserInfo(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.OAuth.UserInfo")
+	defer span.End()
+
+	txn := o.relict.StartTransaction("oauth userinfo", w, r)
+	defer txn.End()
+
+	parts := strings.Split(r.Header.Get("Authorization"), " ")
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return oauthError(ctx, w, &oauth.Error{Code: "invalid_token", Description: "expected authorization header format: Bearer <token>", Status: http.StatusUnauthorized})
+	}
+
+	ui, err := o.provider.UserInfo(ctx, parts[1])
+	if err != nil {
+		if err := oauthError(ctx, w, err); err != nil {
+			return errors.Wrap(err, "retrieving user info")
+		}
+		return nil
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+
+	return web.Respond(ctx, w, ui, http.StatusOK)
+}
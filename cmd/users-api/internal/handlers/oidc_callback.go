@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/federation"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// OIDCCallback finishes a login with an external identity provider. It
+// expects the code the provider redirected the user back with and responds
+// with a JWT for the linked local user.
+func (u *User) OIDCCallback(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.OIDCCallback")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("oidc callback", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	provider := params["provider"]
+
+	// The state is single use, so the cookie is removed in any case.
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Path:     "/v1/users/oidc/" + provider,
+		HttpOnly: true,
+		MaxAge:   -1,
+	})
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		err := errors.Errorf("identity provider returned %s", e)
+		return web.NewRequestError(err, http.StatusUnauthorized)
+	}
+
+	c, err := r.Cookie(oidcStateCookie)
+	if err != nil {
+		return web.NewRequestError(federation.ErrInvalidState, http.StatusBadRequest)
+	}
+
+	usr, tok, err := u.federation.Finish(ctx, provider, c.Value, q.Get("state"), q.Get("code"), v.Now)
+	if err != nil {
+		switch errors.Cause(err) {
+		case federation.ErrUnknownProvider:
+			return web.NewRequestError(err, http.StatusNotFound)
+		case federation.ErrInvalidState:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case federation.ErrExchange, federation.ErrInvalidIDToken:
+			return web.NewRequestError(federation.ErrInvalidIDToken, http.StatusUnauthorized)
+		case federation.ErrNoAccount:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrapf(err, "finishing login with %q", provider)
+		}
+	}
+
+	// A second factor at the provider counts as one here.
+	claims := storage.NewClaims(usr, v.Now)
+	claims.MFA = tok.MFA()
+
+	tkn := TokenResponse{}
+
+	tkn.Token, err = u.sessionToken(ctx, claims, r.UserAgent(), web.ClientIP(r, u.trusted), v.Now)
+	if err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, tkn, http.StatusOK)
+}
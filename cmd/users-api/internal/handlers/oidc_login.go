@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/federation"
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// oidcStateCookie holds the encrypted state of a login with an identity
+// provider between the redirect to the provider and the callback.
+const oidcStateCookie = "oidc_state"
+
+// OIDCLogin starts a login with an external identity provider. It redirects
+// the user to the provider.
+func (u *User) OIDCLogin(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.OIDCLogin")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("oidc login", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	provider := params["provider"]
+	uri, state, err := u.federation.Begin(ctx, provider, v.Now)
+	if err != nil {
+		switch err {
+		case federation.ErrUnknownProvider:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "starting login with %q", provider)
+		}
+	}
+
+	// Lax lets the cookie be sent on the top level redirect back from the
+	// provider.
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/v1/users/oidc/" + provider,
+		HttpOnly: true,
+		Secure:   u.federation.Secure(provider),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return web.Redirect(ctx, w, r, uri, http.StatusFound)
+}
@@ -0,0 +1,639 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/identicon"
+	"github.com/igomonov88/users/internal/oauth"
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/openapi"
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// specVersion is the version of the API the document describes.
+const specVersion = "1.0.0"
+
+// Security schemes of the API.
+const (
+	securityBearer = "bearerAuth"
+	securityAPIKey = "apiKeyAuth"
+	securityBasic  = "basicAuth"
+)
+
+// authenticated are the schemes accepted by routes behind mid.Authenticate.
+var authenticated = []string{securityBearer, securityAPIKey}
+
+// endpoints describe the routes of the API by their method and path as they
+// are registered. Every route needs an entry, see openapi.Document.Check.
+var endpoints = map[string]openapi.Endpoint{
+	"GET /v1/health": {
+		Summary:  "Report the health of the service",
+		Tags:     []string{"health"},
+		Response: HealthResponse{},
+	},
+	"GET /v1/openapi.json": {
+		Summary:  "Retrieve this specification",
+		Tags:     []string{"health"},
+		Response: &openapi.Schema{Type: "object"},
+	},
+
+	"GET /v1/users/token": {
+		Summary:     "Get a token for the email and password of a user",
+		Description: "Users with two-factor authentication get a challenge instead, which is answered with the token/otp route.",
+		Tags:        []string{"auth"},
+		Security:    []string{securityBasic},
+		Response:    TokenResponse{},
+		Errors:      []int{http.StatusTooManyRequests},
+	},
+	"POST /v1/users/token/otp": {
+		Summary:  "Answer a two-factor challenge to get a token",
+		Tags:     []string{"auth"},
+		Request:  TokenOTPRequest{},
+		Response: TokenResponse{},
+		Errors:   []int{http.StatusUnauthorized, http.StatusTooManyRequests},
+	},
+	"GET /v1/users/oidc/:provider/login": {
+		Summary: "Sign in with an external identity provider",
+		Tags:    []string{"auth"},
+		Status:  http.StatusFound,
+		Errors:  []int{http.StatusNotFound, http.StatusTooManyRequests},
+	},
+	"GET /v1/users/oidc/:provider/callback": {
+		Summary: "Finish signing in with an external identity provider",
+		Tags:    []string{"auth"},
+		Query: []openapi.Parameter{
+			query("state", "The state of the login.", true),
+			query("code", "The authorization code of the provider.", false),
+			query("error", "The error the provider reported.", false),
+		},
+		Response: TokenResponse{},
+		Errors:   []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests},
+	},
+
+	"POST /v1/users": {
+		Summary:    "Create a user",
+		Tags:       []string{"users"},
+		Request:    CreateUserRequest{},
+		Response:   CreateUserResponse{},
+		Errors:     []int{http.StatusConflict, http.StatusTooManyRequests},
+		Deprecated: true,
+	},
+	"GET /v1/users/email/:email": {
+		Summary:    "Check if an email address is taken",
+		Tags:       []string{"users"},
+		Response:   EmailExistResponse{},
+		Errors:     []int{http.StatusTooManyRequests},
+		Deprecated: true,
+	},
+	"GET /v1/users/user_name/:user_name": {
+		Summary:    "Check if a user name is taken",
+		Tags:       []string{"users"},
+		Response:   UserNameExistResponse{},
+		Errors:     []int{http.StatusTooManyRequests},
+		Deprecated: true,
+	},
+	"GET /v1/users/:user_id": {
+		Summary:     "Retrieve a user",
+		Description: "The email is left out unless the user is the authenticated one or that is an admin.",
+		Tags:        []string{"users"},
+		Security:    authenticated,
+		Response:    RetrieveUserResponse{},
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+		Deprecated:  true,
+	},
+	"GET /v1/users/by_email/:email": {
+		Summary:    "Retrieve a user by email address",
+		Tags:       []string{"users"},
+		Security:   authenticated,
+		Response:   RetrieveUserResponse{},
+		Errors:     []int{http.StatusForbidden, http.StatusNotFound},
+		Deprecated: true,
+	},
+	"GET /v1/users/by_user_name/:user_name": {
+		Summary:    "Retrieve a user by user name",
+		Tags:       []string{"users"},
+		Security:   authenticated,
+		Response:   RetrieveUserResponse{},
+		Errors:     []int{http.StatusForbidden, http.StatusNotFound},
+		Deprecated: true,
+	},
+	"POST /v1/users/update": {
+		Summary:    "Update a user",
+		Tags:       []string{"users"},
+		Security:   authenticated,
+		Request:    UpdateUserRequest{},
+		Response:   UpdateUserResponse{},
+		Errors:     []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusTooManyRequests},
+		Deprecated: true,
+	},
+	"POST /v1/users/delete": {
+		Summary:    "Delete a user",
+		Tags:       []string{"users"},
+		Security:   authenticated,
+		Request:    DeleteUserRequest{},
+		Response:   DeleteUserResponse{},
+		Errors:     []int{http.StatusForbidden, http.StatusTooManyRequests},
+		Deprecated: true,
+	},
+	"POST /v1/users/password": {
+		Summary:  "Change the password of the authenticated user",
+		Tags:     []string{"users"},
+		Security: authenticated,
+		Request:  ChangePasswordRequest{},
+		Response: ChangePasswordResponse{},
+		Errors:   []int{http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests},
+	},
+	"POST /v1/users/:user_id/unlock": {
+		Summary:     "Unlock a user locked out after failed logins",
+		Description: "Requires the admin role.",
+		Tags:        []string{"admin"},
+		Security:    authenticated,
+		Response:    UnlockUserResponse{},
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	},
+
+	"GET /v1/users/:user_id/avatar": {
+		Summary: "Retrieve the generated avatar of a user",
+		Tags:    []string{"avatars"},
+		Query: []openapi.Parameter{{
+			Name:        "size",
+			In:          "query",
+			Description: "The width and height in pixels.",
+			Schema:      &openapi.Schema{Type: "integer", Minimum: float(identicon.MinSize), Maximum: float(identicon.MaxSize)},
+		}, {
+			Name:        "style",
+			In:          "query",
+			Description: "The kind of avatar, identicon unless set.",
+			Schema:      &openapi.Schema{Type: "string", Enum: []string{"identicon", "initials"}},
+		}, {
+			Name:        "format",
+			In:          "query",
+			Description: "The image format, PNG unless set. SVG images are image/svg+xml.",
+			Schema:      &openapi.Schema{Type: "string", Enum: []string{"png", "svg"}},
+		}},
+		Response:     binary,
+		ResponseType: "image/png",
+		Errors:       []int{http.StatusNotFound},
+	},
+	"POST /v1/users/update_avatar": {
+		Summary:    "Set the avatar URL of a user",
+		Tags:       []string{"avatars"},
+		Security:   authenticated,
+		Request:    UpdateAvatarRequest{},
+		Response:   UpdateAvatarResponse{},
+		Errors:     []int{http.StatusForbidden, http.StatusTooManyRequests},
+		Deprecated: true,
+	},
+	"POST /v1/users/avatar": {
+		Summary:  "Upload the avatar of the authenticated user",
+		Tags:     []string{"avatars"},
+		Security: authenticated,
+		Request: &openapi.Schema{
+			Type:       "object",
+			Properties: map[string]*openapi.Schema{avatarField: binary},
+			Required:   []string{avatarField},
+		},
+		RequestType: "multipart/form-data",
+		Response:    UploadAvatarResponse{},
+		Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusRequestEntityTooLarge,
+			http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity, http.StatusTooManyRequests},
+		Deprecated: true,
+	},
+	"DELETE /v1/users/avatar": {
+		Summary:    "Remove the avatar of the authenticated user",
+		Tags:       []string{"avatars"},
+		Security:   authenticated,
+		Response:   RemoveAvatarResponse{},
+		Errors:     []int{http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests},
+		Deprecated: true,
+	},
+	"POST /v1/users/avatar/presign": {
+		Summary:  "Get a URL to upload the avatar of the authenticated user to directly",
+		Tags:     []string{"avatars"},
+		Security: authenticated,
+		Request:  PresignAvatarRequest{},
+		Status:   http.StatusCreated,
+		Response: PresignAvatarResponse{},
+		Errors:   []int{http.StatusForbidden, http.StatusUnsupportedMediaType, http.StatusTooManyRequests},
+	},
+	"POST /v1/users/avatar/complete": {
+		Summary:  "Make a direct upload the avatar of the authenticated user",
+		Tags:     []string{"avatars"},
+		Security: authenticated,
+		Request:  CompleteAvatarRequest{},
+		Response: UploadAvatarResponse{},
+		Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusRequestEntityTooLarge,
+			http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity, http.StatusTooManyRequests},
+	},
+
+	"POST /v2/users": {
+		Summary:     "Create a user",
+		Description: "Responds with the Location of the new user.",
+		Tags:        []string{"users"},
+		Request:     CreateUserRequest{},
+		Status:      http.StatusCreated,
+		Response:    RetrieveUserResponse{},
+		Errors:      []int{http.StatusConflict, http.StatusTooManyRequests},
+	},
+	"HEAD /v2/users": {
+		Summary:     "Check if a user with the email address or user name exists",
+		Description: "Exactly one of the parameters must be given.",
+		Tags:        []string{"users"},
+		Query:       userQueryParams,
+		Errors:      []int{http.StatusNotFound, http.StatusTooManyRequests},
+	},
+	"GET /v2/users": {
+		Summary:     "Find the user with the email address or user name",
+		Description: "Exactly one of the parameters must be given. Users are found by email only by themselves and by admins. Emails are left out unless the user is the authenticated one or that is an admin.",
+		Tags:        []string{"users"},
+		Security:    authenticated,
+		Query:       userQueryParams,
+		Response:    ListUsersResponse{},
+		Errors:      []int{http.StatusForbidden},
+	},
+	"GET /v2/users/:user_id": {
+		Summary:     "Retrieve a user",
+		Description: "The email is left out unless the user is the authenticated one or that is an admin.",
+		Tags:        []string{"users"},
+		Security:    authenticated,
+		Response:    RetrieveUserResponse{},
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	"PATCH /v2/users/:user_id": {
+		Summary:     "Update a user",
+		Description: "Members left out are kept. Users may update themselves only unless they are admins.",
+		Tags:        []string{"users"},
+		Security:    authenticated,
+		Request:     PatchUserRequest{},
+		RequestType: web.ContentTypeMergePatch,
+		Response:    RetrieveUserResponse{},
+		Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusConflict, http.StatusUnsupportedMediaType,
+			http.StatusTooManyRequests},
+	},
+	"DELETE /v2/users/:user_id": {
+		Summary:     "Delete a user",
+		Description: "Users may delete themselves only unless they are admins.",
+		Tags:        []string{"users"},
+		Security:    authenticated,
+		Status:      http.StatusNoContent,
+		Errors:      []int{http.StatusForbidden, http.StatusTooManyRequests},
+	},
+	"PUT /v2/users/:user_id/avatar": {
+		Summary:     "Upload the avatar of a user",
+		Description: "The body is the image. Users may change their own avatar only unless they are admins.",
+		Tags:        []string{"avatars"},
+		Security:    authenticated,
+		Request:     binary,
+		RequestType: "image/*",
+		Response:    UploadAvatarResponse{},
+		Errors: []int{http.StatusForbidden, http.StatusNotFound, http.StatusRequestEntityTooLarge,
+			http.StatusUnsupportedMediaType, http.StatusUnprocessableEntity, http.StatusTooManyRequests},
+	},
+	"DELETE /v2/users/:user_id/avatar": {
+		Summary:     "Remove the avatar of a user",
+		Description: "The generated avatar is shown instead. Users may change their own avatar only unless they are admins.",
+		Tags:        []string{"avatars"},
+		Security:    authenticated,
+		Status:      http.StatusNoContent,
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound, http.StatusTooManyRequests},
+	},
+
+	"POST /v1/users/api_keys": {
+		Summary:  "Create an API key for the authenticated user",
+		Tags:     []string{"api keys"},
+		Security: authenticated,
+		Request:  CreateAPIKeyRequest{},
+		Status:   http.StatusCreated,
+		Response: CreateAPIKeyResponse{},
+		Errors:   []int{http.StatusForbidden},
+	},
+	"GET /v1/users/api_keys": {
+		Summary:  "List the API keys of the authenticated user",
+		Tags:     []string{"api keys"},
+		Security: authenticated,
+		Response: ListAPIKeysResponse{},
+		Errors:   []int{http.StatusForbidden},
+	},
+	"DELETE /v1/users/api_keys/:api_key_id": {
+		Summary:  "Revoke an API key of the authenticated user",
+		Tags:     []string{"api keys"},
+		Security: authenticated,
+		Response: RevokeAPIKeyResponse{},
+		Errors:   []int{http.StatusForbidden, http.StatusNotFound},
+	},
+
+	"GET /v1/users/sessions": {
+		Summary:  "List the sessions of the authenticated user",
+		Tags:     []string{"sessions"},
+		Security: authenticated,
+		Response: ListSessionsResponse{},
+		Errors:   []int{http.StatusForbidden},
+	},
+	"DELETE /v1/users/sessions": {
+		Summary:  "Revoke every session of the authenticated user but the current one",
+		Tags:     []string{"sessions"},
+		Security: authenticated,
+		Response: RevokeOtherSessionsResponse{},
+		Errors:   []int{http.StatusForbidden},
+	},
+	"DELETE /v1/users/sessions/:session_id": {
+		Summary:  "Revoke a session of the authenticated user",
+		Tags:     []string{"sessions"},
+		Security: authenticated,
+		Response: RevokeSessionResponse{},
+		Errors:   []int{http.StatusForbidden, http.StatusNotFound},
+	},
+
+	"GET /v1/users/identities": {
+		Summary:  "List the external identities of the authenticated user",
+		Tags:     []string{"identities"},
+		Security: authenticated,
+		Response: ListIdentitiesResponse{},
+		Errors:   []int{http.StatusForbidden},
+	},
+	"DELETE /v1/users/identities/:provider": {
+		Summary:  "Unlink an external identity of the authenticated user",
+		Tags:     []string{"identities"},
+		Security: authenticated,
+		Response: UnlinkIdentityResponse{},
+		Errors:   []int{http.StatusForbidden, http.StatusNotFound},
+	},
+
+	"POST /v1/users/mfa/totp": {
+		Summary:  "Start enrolling the authenticated user in two-factor authentication",
+		Tags:     []string{"mfa"},
+		Security: authenticated,
+		Response: EnrollTOTPResponse{},
+		Errors:   []int{http.StatusForbidden, http.StatusConflict},
+	},
+	"POST /v1/users/mfa/totp/confirm": {
+		Summary:  "Confirm the enrollment with a first code",
+		Tags:     []string{"mfa"},
+		Security: authenticated,
+		Request:  TOTPCodeRequest{},
+		Response: RecoveryCodesResponse{},
+		Errors:   []int{http.StatusForbidden, http.StatusConflict},
+	},
+	"POST /v1/users/mfa/totp/disable": {
+		Summary:  "Disable two-factor authentication of the authenticated user",
+		Tags:     []string{"mfa"},
+		Security: authenticated,
+		Request:  TOTPCodeRequest{},
+		Response: DisableTOTPResponse{},
+		Errors:   []int{http.StatusForbidden},
+	},
+	"POST /v1/users/mfa/recovery_codes": {
+		Summary:  "Replace the recovery codes of the authenticated user",
+		Tags:     []string{"mfa"},
+		Security: authenticated,
+		Request:  TOTPCodeRequest{},
+		Response: RecoveryCodesResponse{},
+		Errors:   []int{http.StatusForbidden},
+	},
+
+	"POST /v1/graphql": {
+		Summary:     "Execute a GraphQL query over users, their sessions and audit entries",
+		Description: "Emails, roles, sessions and audit entries are visible to the user and to admins only. Queries which are not valid or exceed the depth or complexity limits get 400 with the errors in the GraphQL response.",
+		Tags:        []string{"graphql"},
+		Security:    authenticated,
+		Request:     GraphQLRequest{},
+		Response:    GraphQLResponse{},
+		Errors:      []int{http.StatusForbidden},
+	},
+
+	"GET /.well-known/openid-configuration": {
+		Summary:  "Retrieve the OpenID Connect discovery document",
+		Tags:     []string{"oauth"},
+		Response: oauth.Discovery{},
+	},
+	"GET " + oauth.PathJWKS: {
+		Summary:  "Retrieve the keys tokens are signed with",
+		Tags:     []string{"oauth"},
+		Response: auth.JWKS{},
+	},
+	"GET " + oauth.PathAuthorize: {
+		Summary:      "Start an authorization request",
+		Description:  "Shows the login form. Errors are shown to the user or redirected to the client.",
+		Tags:         []string{"oauth"},
+		Query:        authorizeParams,
+		Response:     &openapi.Schema{Type: "string"},
+		ResponseType: "text/html",
+		ErrorBody:    oauth.Error{},
+	},
+	"POST " + oauth.PathAuthorize: {
+		Summary:     "Sign in to an authorization request",
+		Description: "Redirects to the client with an authorization code.",
+		Tags:        []string{"oauth"},
+		Request:     form([]string{"email", "password"}, "email", "password", "otp"),
+		RequestType: "application/x-www-form-urlencoded",
+		Status:      http.StatusSeeOther,
+		ErrorBody:   oauth.Error{},
+		Errors:      []int{http.StatusUnauthorized, http.StatusTooManyRequests},
+	},
+	"POST " + oauth.PathToken: {
+		Summary: "Get tokens for a grant",
+		Tags:    []string{"oauth"},
+		Request: form([]string{"grant_type"}, "grant_type", "client_id", "client_secret", "code",
+			"redirect_uri", "code_verifier", "refresh_token", "scope"),
+		RequestType: "application/x-www-form-urlencoded",
+		Response:    oauth.TokenResponse{},
+		ErrorBody:   oauth.Error{},
+		Errors:      []int{http.StatusUnauthorized, http.StatusTooManyRequests},
+	},
+	"GET " + oauth.PathUserInfo: {
+		Summary:   "Retrieve the claims about the user of an access token",
+		Tags:      []string{"oauth"},
+		Security:  []string{securityBearer},
+		Response:  oauth.UserInfo{},
+		ErrorBody: oauth.Error{},
+	},
+	"POST " + oauth.PathUserInfo: {
+		Summary:   "Retrieve the claims about the user of an access token",
+		Tags:      []string{"oauth"},
+		Security:  []string{securityBearer},
+		Response:  oauth.UserInfo{},
+		ErrorBody: oauth.Error{},
+	},
+	"POST /v1/oauth/clients": {
+		Summary:     "Register an OAuth client",
+		Description: "Requires the admin role.",
+		Tags:        []string{"admin"},
+		Security:    authenticated,
+		Request:     RegisterOAuthClientRequest{},
+		Status:      http.StatusCreated,
+		Response:    RegisterOAuthClientResponse{},
+		Errors:      []int{http.StatusForbidden},
+	},
+
+	"POST /v1/webhooks": {
+		Summary:     "Subscribe a URL to events",
+		Description: "Requires the admin role. Payloads are signed with HMAC-SHA256 of the Webhook-Timestamp header, a dot and the body. The secret is generated unless provided and is part of the response only once.",
+		Tags:        []string{"webhooks"},
+		Security:    authenticated,
+		Request:     CreateWebhookRequest{},
+		Status:      http.StatusCreated,
+		Response:    CreateWebhookResponse{},
+		Errors:      []int{http.StatusForbidden},
+	},
+	"GET /v1/webhooks": {
+		Summary:     "List the webhooks",
+		Description: "Requires the admin role.",
+		Tags:        []string{"webhooks"},
+		Security:    authenticated,
+		Response:    ListWebhooksResponse{},
+		Errors:      []int{http.StatusForbidden},
+	},
+	"GET /v1/webhooks/:webhook_id": {
+		Summary:     "Retrieve a webhook",
+		Description: "Requires the admin role.",
+		Tags:        []string{"webhooks"},
+		Security:    authenticated,
+		Response:    Webhook{},
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	"DELETE /v1/webhooks/:webhook_id": {
+		Summary:     "Delete a webhook with its deliveries",
+		Description: "Requires the admin role.",
+		Tags:        []string{"webhooks"},
+		Security:    authenticated,
+		Response:    DeleteWebhookResponse{},
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	"POST /v1/webhooks/:webhook_id/enable": {
+		Summary:     "Enable a webhook disabled after repeated failures",
+		Description: "Requires the admin role.",
+		Tags:        []string{"webhooks"},
+		Security:    authenticated,
+		Response:    EnableWebhookResponse{},
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	"GET /v1/webhooks/:webhook_id/deliveries": {
+		Summary:     "List the latest deliveries of a webhook with their attempts",
+		Description: "Requires the admin role.",
+		Tags:        []string{"webhooks"},
+		Security:    authenticated,
+		Response:    ListWebhookDeliveriesResponse{},
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	},
+	"POST /v1/webhooks/:webhook_id/deliveries/:delivery_id/redeliver": {
+		Summary:     "Queue a delivery of a webhook again",
+		Description: "Requires the admin role. Dead and delivered deliveries are queued again as well.",
+		Tags:        []string{"webhooks"},
+		Security:    authenticated,
+		Status:      http.StatusAccepted,
+		Response:    RedeliverWebhookResponse{},
+		Errors:      []int{http.StatusForbidden, http.StatusNotFound},
+	},
+}
+
+// binary is the schema of files.
+var binary = &openapi.Schema{Type: "string", Format: "binary"}
+
+// userQueryParams are the parameters users are looked up by.
+var userQueryParams = []openapi.Parameter{
+	query("email", "The email address of the user.", false),
+	query("user_name", "The name of the user.", false),
+}
+
+// authorizeParams are the parameters of an authorization request.
+var authorizeParams = []openapi.Parameter{
+	query("response_type", "Must be code.", true),
+	query("client_id", "The id of the client.", true),
+	query("redirect_uri", "One of the registered redirect URIs of the client.", false),
+	query("scope", "The scopes separated by spaces.", false),
+	query("state", "Sent back to the client unchanged.", false),
+	query("nonce", "Added to the ID token.", false),
+	query("code_challenge", "The PKCE code challenge.", false),
+	query("code_challenge_method", "The PKCE code challenge method.", false),
+}
+
+// Spec builds the OpenAPI document of the API from the endpoints.
+func Spec() *openapi.Document {
+	schemas := openapi.NewSchemas()
+	schemas.Tag("email_address", func(s *openapi.Schema, param string) { s.Format = "email" })
+	schemas.Tag("locale", func(s *openapi.Schema, param string) { s.Enum = web.Locales() })
+
+	doc := openapi.New(openapi.Info{
+		Title:       "Users API",
+		Description: "Manages users, their credentials and the ways they sign in.",
+		Version:     specVersion,
+	}, schemas)
+
+	doc.AddSecurityScheme(securityBearer, openapi.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+	})
+	doc.AddSecurityScheme(securityAPIKey, openapi.SecurityScheme{
+		Type: "apiKey",
+		Name: "X-API-Key",
+		In:   "header",
+	})
+	doc.AddSecurityScheme(securityBasic, openapi.SecurityScheme{
+		Type:        "http",
+		Scheme:      "basic",
+		Description: "The email address and password of the user.",
+	})
+
+	// Add the endpoints in a fixed order, so the schemas of types sharing a
+	// name are named the same every time.
+	routes := make([]string, 0, len(endpoints))
+	for route := range endpoints {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		parts := strings.SplitN(route, " ", 2)
+		doc.Add(parts[0], parts[1], endpoints[route])
+	}
+
+	return doc
+}
+
+// OpenAPI serves the OpenAPI document of the API.
+type OpenAPI struct {
+	doc *openapi.Document
+}
+
+// Spec responds with the document.
+func (o *OpenAPI) Spec(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.OpenAPI.Spec")
+	defer span.End()
+
+	return web.Respond(ctx, w, o.doc, http.StatusOK)
+}
+
+// query describes a string query parameter.
+func query(name, description string, required bool) openapi.Parameter {
+	return openapi.Parameter{
+		Name:        name,
+		In:          "query",
+		Description: description,
+		Required:    required,
+		Schema:      &openapi.Schema{Type: "string"},
+	}
+}
+
+// form describes a form of string fields.
+func form(required []string, fields ...string) *openapi.Schema {
+	s := openapi.Schema{
+		Type:       "object",
+		Properties: make(map[string]*openapi.Schema),
+		Required:   required,
+	}
+	for _, f := range fields {
+		s.Properties[f] = &openapi.Schema{Type: "string"}
+	}
+	return &s
+}
+
+// float returns a pointer to the number for the limits of schemas.
+func float(n int) *float64 {
+	f := float64(n)
+	return &f
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/password"
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// ChangePassword replaces the password of the authenticated user. It
+// requires the current password and signs out all other sessions.
+func (u *User) ChangePassword(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.ChangePassword")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("change password", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	var req ChangePasswordRequest
+	if err := web.Decode(r, &req); err != nil {
+		return errors.Wrap(err, "decoding passwords")
+	}
+
+	usr, err := storage.Retrieve(ctx, u.db, claims.Subject)
+	if err != nil {
+		switch err {
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case storage.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "retrieving user %q", claims.Subject)
+		}
+	}
+
+	if _, err := storage.Authenticate(ctx, u.db, v.Now, usr.Email, req.CurrentPassword); err != nil {
+		switch err {
+		case storage.ErrAuthenticationFailure:
+			return web.NewRequestError(err, http.StatusForbidden)
+		default:
+			return errors.Wrap(err, "verifying current password")
+		}
+	}
+
+	if err := u.passwords.Check(req.NewPassword, usr.Email, usr.Name); err != nil {
+		return passwordError("new_password", err)
+	}
+
+	if err := storage.UpdatePassword(ctx, u.db, usr.ID, req.NewPassword); err != nil {
+		return errors.Wrapf(err, "updating password of %q", usr.ID)
+	}
+
+	if _, err := storage.RevokeOtherSessions(ctx, u.db, usr.ID, claims.SessionID, v.Now); err != nil {
+		return errors.Wrapf(err, "revoking sessions of %q", usr.ID)
+	}
+
+	if err := u.audit(ctx, usr.ID, usr.ID, storage.AuditPasswordChanged); err != nil {
+		return err
+	}
+
+	return web.Respond(ctx, w, ChangePasswordResponse{}, http.StatusOK)
+}
+
+// passwordError turns a violation of the password policy into an error
+// naming the reason for the field.
+func passwordError(field string, err error) error {
+	v, ok := err.(*password.Violation)
+	if !ok {
+		return err
+	}
+
+	return &web.Error{
+		Err:    err,
+		Status: http.StatusBadRequest,
+		Code:   codePasswordRejected.Code,
+		Title:  codePasswordRejected.Title,
+		Fields: []web.FieldError{{Field: field, Error: v.Reason}},
+	}
+}
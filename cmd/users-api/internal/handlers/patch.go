@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// Patch applies a JSON Merge Patch to the name, email address and locale of
+// a user and responds with the updated user. Removing the locale keeps the
+// chosen one like Update does.
+func (u *User) Patch(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.Patch")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("patch user", w, r)
+	defer txn.End()
+
+	userID := params["user_id"]
+	usr, err := storage.Retrieve(ctx, u.db, userID)
+	if err != nil {
+		switch err {
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case storage.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "retrieving user %q", userID)
+		}
+	}
+
+	req := PatchUserRequest{
+		Name:   usr.Name,
+		Email:  usr.Email,
+		Locale: usr.Locale,
+	}
+	if err := web.DecodePatch(r, &req); err != nil {
+		return err
+	}
+
+	if err := u.update(ctx, usr, req.Name, req.Email, req.Locale); err != nil {
+		return err
+	}
+
+	usr.Name, usr.Email = req.Name, req.Email
+	if req.Locale != "" {
+		usr.Locale = req.Locale
+	}
+
+	return web.Respond(ctx, w, userResponse(usr), http.StatusOK)
+}
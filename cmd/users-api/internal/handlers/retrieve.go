@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 
 	"github.com/igomonov88/users/internal/platform/web"
@@ -21,27 +22,44 @@ func (u *User) Retrieve(ctx context.Context, w http.ResponseWriter, r *http.Requ
 
 	userID, ok := params["user_id"]
 	if !ok {
-		return web.Respond(ctx, w, RetrieveUserResponse{}, http.StatusBadRequest)
+		return web.NewRequestError(errors.New("user_id is missing"), http.StatusBadRequest)
 	}
 
 	usr, err := storage.Retrieve(ctx, u.db, userID)
 	if err != nil {
 		switch err {
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
 		case storage.ErrNotFound:
-			return web.Respond(ctx, w, web.ErrorResponse{
-				Error: "User Not Found",}, http.StatusNotFound)
+			return web.NewRequestError(err, http.StatusNotFound)
 		default:
-			return web.Respond(ctx, w, web.ErrorResponse{
-				Error: "Internal Server Error",}, http.StatusInternalServerError)
+			return errors.Wrapf(err, "retrieving user %q", userID)
 		}
 	}
 
-	resp := RetrieveUserResponse{
+	resp := visibleUserResponse(ctx, usr)
+
+	return web.Respond(ctx, w, &resp, http.StatusOK)
+}
+
+// visibleUserResponse returns the representation of a user in responses to
+// the authenticated user. The email is private like in the GraphQL API, it is
+// left out unless the user is the authenticated one or that is an admin.
+func visibleUserResponse(ctx context.Context, usr *storage.User) RetrieveUserResponse {
+	resp := userResponse(usr)
+	if err := selfOrAdmin(ctx, usr.ID); err != nil {
+		resp.Email = ""
+	}
+	return resp
+}
+
+// userResponse returns the representation of a user in responses.
+func userResponse(usr *storage.User) RetrieveUserResponse {
+	return RetrieveUserResponse{
 		UserID:   usr.ID,
 		UserName: usr.Name,
 		Email:    usr.Email,
-		Avatar:   usr.Avatar,
+		Avatar:   avatarURL(usr),
+		Locale:   usr.Locale,
 	}
-
-	return web.Respond(ctx, w, &resp, http.StatusOK)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 
 	"github.com/igomonov88/users/internal/platform/web"
@@ -21,7 +22,7 @@ func (u *User) RetrieveByUserName(ctx context.Context, w http.ResponseWriter, r
 
 	userName, ok := params["user_name"]
 	if !ok {
-		return web.Respond(ctx, w, RetrieveUserResponse{}, http.StatusBadRequest)
+		return web.NewRequestError(errors.New("user_name is missing"), http.StatusBadRequest)
 	}
 
 	usr, err := storage.RetrieveByUserName(ctx, u.db, userName)
@@ -29,20 +30,13 @@ func (u *User) RetrieveByUserName(ctx context.Context, w http.ResponseWriter, r
 	if err != nil {
 		switch err {
 		case storage.ErrNotFound:
-			return web.Respond(ctx, w, web.ErrorResponse{
-				Error: "User Not Found",}, http.StatusNotFound)
+			return web.NewRequestError(err, http.StatusNotFound)
 		default:
-			return web.Respond(ctx, w, web.ErrorResponse{
-				Error: "Internal Server Error",}, http.StatusInternalServerError)
+			return errors.Wrapf(err, "retrieving user by name %q", userName)
 		}
 	}
 
-	resp := RetrieveUserResponse{
-		UserID:   usr.ID,
-		UserName: usr.Name,
-		Email:    usr.Email,
-		Avatar:   usr.Avatar,
-	}
+	resp := userResponse(usr)
 
 	return web.Respond(ctx, w, &resp, http.StatusOK)
 }
@@ -1,16 +1,32 @@
 package handlers
 
 import (
-	"log"
+	"context"
+	"net"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	newrelic "github.com/newrelic/go-agent"
 
+	"github.com/igomonov88/users/internal/avatar"
+	"github.com/igomonov88/users/internal/email"
+	"github.com/igomonov88/users/internal/federation"
+	"github.com/igomonov88/users/internal/lockout"
+	"github.com/igomonov88/users/internal/mfa"
 	"github.com/igomonov88/users/internal/mid"
+	"github.com/igomonov88/users/internal/oauth"
+	"github.com/igomonov88/users/internal/password"
 	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/graph"
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/platform/openapi"
+	"github.com/igomonov88/users/internal/platform/ratelimit"
 	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+	"github.com/igomonov88/users/internal/username"
+	"github.com/igomonov88/users/internal/webhook"
 )
 
 // User  represents the user API method handler set.
@@ -18,13 +34,45 @@ type User struct {
 	db            *sqlx.DB
 	authenticator *auth.Authenticator
 	relict        newrelic.Application
+	guard         *lockout.Guard
+	mfa           *mfa.MFA
+	federation    *federation.Federation
+	avatars       *avatar.Avatars
+	names         *username.Policy
+	passwords     *password.Policy
+	emails        *email.Policy
+	hooks         *webhook.Webhooks
+	trusted       []*net.IPNet
+}
+
+// RateLimits holds the limits applied to the routes which are not
+// authenticated and so are the easiest to abuse. Write limits the routes
+// changing users per authenticated user.
+type RateLimits struct {
+	Token  ratelimit.Limit
+	Signup ratelimit.Limit
+	Exist  ratelimit.Limit
+	Write  ratelimit.Limit
 }
 
 // API constructs an http.Handler with all application routes defined.
-func API(build string, shutdown chan os.Signal, log *log.Logger, db *sqlx.DB,
-	relic newrelic.Application, authenticator *auth.Authenticator) http.Handler {
+func API(build string, shutdown chan os.Signal, log *logger.Logger, db *sqlx.DB,
+	relic newrelic.Application, authenticator *auth.Authenticator, guard *lockout.Guard,
+	limiter ratelimit.Store, limits RateLimits, trusted []*net.IPNet, m *mfa.MFA, provider *oauth.Provider,
+	fed *federation.Federation, avatars *avatar.Avatars, names *username.Policy,
+	passwords *password.Policy, emails *email.Policy, hooks *webhook.Webhooks, v1 mid.Deprecation, graphQL graph.Limits,
+	validateRequests bool) http.Handler {
+
+	// The specification is built first so requests can be validated against
+	// it. Routes are checked against it once they are all registered.
+	spec := Spec()
+
 	// Construct the web.App which holds all routes as well as common Middleware.
-	app := web.NewApp(shutdown, mid.Logger(log), mid.Errors(log), mid.Metrics(), mid.Panics(log))
+	mw := []web.Middleware{mid.Logger(log, trusted), mid.Errors(log), mid.Metrics(), mid.Panics(log)}
+	if validateRequests {
+		mw = append(mw, openapi.Validate(spec))
+	}
+	app := web.NewApp(shutdown, log, mw...)
 
 	// Register health check endpoint. This route is not authenticated.
 	check := Check{
@@ -37,21 +85,156 @@ func API(build string, shutdown chan os.Signal, log *log.Logger, db *sqlx.DB,
 		db:            db,
 		authenticator: authenticator,
 		relict:        relic,
+		guard:         guard,
+		mfa:           m,
+		federation:    fed,
+		avatars:       avatars,
+		names:         names,
+		passwords:     passwords,
+		emails:        emails,
+		hooks:         hooks,
+		trusted:       trusted,
 	}
 	app.Handle("GET", "/v1/health", check.Health)
 
-	// This route is not authenticated
-	app.Handle(http.MethodGet, "/v1/users/token", u.Token)
-	app.Handle(http.MethodPost, "/v1/users", u.Create)
-	app.Handle(http.MethodGet, "/v1/users/email/:email", u.EmailExist)
-	app.Handle(http.MethodGet, "/v1/users/user_name/:user_name", u.UserNameExists)
-
-	app.Handle(http.MethodPost, "/v1/users/update", u.Update, mid.Authenticate(authenticator))
-	app.Handle(http.MethodPost, "/v1/users/delete", u.Delete, mid.Authenticate(authenticator))
-	app.Handle(http.MethodGet, "/v1/users/:user_id", u.Retrieve, mid.Authenticate(authenticator))
-	app.Handle(http.MethodGet, "/v1/users/by_email/:email", u.RetrieveByEmail, mid.Authenticate(authenticator))
-	app.Handle(http.MethodGet, "/v1/users/by_user_name/:user_name", u.RetrieveByUserName, mid.Authenticate(authenticator))
-	app.Handle(http.MethodPost, "/v1/users/update_avatar", u.UpdateAvatar, mid.Authenticate(authenticator))
+	// Register the specification of the API. This route is not authenticated.
+	docs := OpenAPI{doc: spec}
+	app.Handle(http.MethodGet, "/v1/openapi.json", docs.Spec)
+
+	// API keys are accepted wherever a token is. Tokens are accepted while
+	// their session is active.
+	keys := func(ctx context.Context, r *http.Request, key string) (auth.Claims, error) {
+		return storage.AuthenticateAPIKey(ctx, db, key, web.ClientIP(r, trusted), time.Now())
+	}
+	sessions := func(ctx context.Context, r *http.Request, sessionID string) (bool, error) {
+		return storage.TouchSession(ctx, db, sessionID, time.Now())
+	}
+	authenticate := mid.Authenticate(authenticator, keys, sessions)
+
+	// The routes of v1 which have a successor in v2 announce when they go
+	// away.
+	deprecated := func(successor string) web.Middleware {
+		return mid.Deprecated(v1, successor)
+	}
+
+	// This route is not authenticated so the requests are limited per client ip.
+	byIP := mid.KeyByIP(trusted)
+	app.Handle(http.MethodGet, "/v1/users/token", u.Token, mid.RateLimit(limiter, limits.Token, byIP))
+	app.Handle(http.MethodPost, "/v1/users/token/otp", u.TokenOTP, mid.RateLimit(limiter, limits.Token, byIP))
+	app.Handle(http.MethodPost, "/v1/users", u.Create, deprecated("/v2/users"), mid.RateLimit(limiter, limits.Signup, byIP))
+	app.Handle(http.MethodGet, "/v1/users/email/:email", u.EmailExist, deprecated("/v2/users"), mid.RateLimit(limiter, limits.Exist, byIP))
+	app.Handle(http.MethodGet, "/v1/users/user_name/:user_name", u.UserNameExists, deprecated("/v2/users"), mid.RateLimit(limiter, limits.Exist, byIP))
+	app.Handle(http.MethodGet, "/v1/users/oidc/:provider/login", u.OIDCLogin, mid.RateLimit(limiter, limits.Token, byIP))
+	app.Handle(http.MethodGet, "/v1/users/oidc/:provider/callback", u.OIDCCallback, mid.RateLimit(limiter, limits.Token, byIP))
+
+	// Generated avatars are public, so they can be shown in image tags.
+	app.Handle(http.MethodGet, "/v1/users/:user_id/avatar", u.DefaultAvatar)
+
+	// This routes change the authenticated user, so the requests are limited
+	// per user no matter where they come from.
+	write := mid.RateLimit(limiter, limits.Write, mid.KeyBySubject(byIP))
+	app.Handle(http.MethodPost, "/v1/users/update", u.Update, deprecated("/v2/users"), authenticate, write)
+	app.Handle(http.MethodPost, "/v1/users/password", u.ChangePassword, authenticate, write)
+	app.Handle(http.MethodPost, "/v1/users/delete", u.Delete, deprecated("/v2/users"), authenticate, write)
+	app.Handle(http.MethodGet, "/v1/users/:user_id", u.Retrieve, deprecated("/v2/users/:user_id"), authenticate)
+	app.Handle(http.MethodGet, "/v1/users/by_email/:email", u.RetrieveByEmail, deprecated("/v2/users"), authenticate)
+	app.Handle(http.MethodGet, "/v1/users/by_user_name/:user_name", u.RetrieveByUserName, deprecated("/v2/users"), authenticate)
+	app.Handle(http.MethodPost, "/v1/users/update_avatar", u.UpdateAvatar, deprecated("/v2/users"), authenticate, write)
+	app.Handle(http.MethodPost, "/v1/users/avatar", u.UploadAvatar, deprecated("/v2/users"), authenticate, write)
+	app.Handle(http.MethodDelete, "/v1/users/avatar", u.RemoveAvatar, deprecated("/v2/users"), authenticate, write)
+	app.Handle(http.MethodPost, "/v1/users/avatar/presign", u.PresignAvatar, authenticate, write)
+	app.Handle(http.MethodPost, "/v1/users/avatar/complete", u.CompleteAvatar, authenticate, write)
+
+	// This routes are the users as resources. Users may change themselves
+	// only, admins may change anyone. Changes are limited like the ones
+	// above. Admins may be forced to use a second factor to change other
+	// users.
+	self := []web.Middleware{authenticate, write, mid.SelfOrRole("user_id", auth.RoleAdmin)}
+	if m.RequireForAdmin() {
+		self = append(self, mid.RequireMFAForOthers("user_id", auth.RoleAdmin))
+	}
+	app.Handle(http.MethodPost, "/v2/users", u.CreateV2, mid.RateLimit(limiter, limits.Signup, byIP))
+	app.Handle(http.MethodHead, "/v2/users", u.Exists, mid.RateLimit(limiter, limits.Exist, byIP))
+	app.Handle(http.MethodGet, "/v2/users", u.Find, authenticate)
+	app.Handle(http.MethodGet, "/v2/users/:user_id", u.Retrieve, authenticate)
+	app.Handle(http.MethodPatch, "/v2/users/:user_id", u.Patch, self...)
+	app.Handle(http.MethodDelete, "/v2/users/:user_id", u.DeleteV2, self...)
+	app.Handle(http.MethodPut, "/v2/users/:user_id/avatar", u.PutAvatar, self...)
+	app.Handle(http.MethodDelete, "/v2/users/:user_id/avatar", u.DeleteAvatar, self...)
+
+	// This routes manage the API keys of the authenticated user.
+	app.Handle(http.MethodPost, "/v1/users/api_keys", u.CreateAPIKey, authenticate)
+	app.Handle(http.MethodGet, "/v1/users/api_keys", u.ListAPIKeys, authenticate)
+	app.Handle(http.MethodDelete, "/v1/users/api_keys/:api_key_id", u.RevokeAPIKey, authenticate)
+
+	// This routes manage the sessions of the authenticated user.
+	app.Handle(http.MethodGet, "/v1/users/sessions", u.ListSessions, authenticate)
+	app.Handle(http.MethodDelete, "/v1/users/sessions", u.RevokeOtherSessions, authenticate)
+	app.Handle(http.MethodDelete, "/v1/users/sessions/:session_id", u.RevokeSession, authenticate)
+
+	// This routes manage the external identities of the authenticated user.
+	app.Handle(http.MethodGet, "/v1/users/identities", u.ListIdentities, authenticate)
+	app.Handle(http.MethodDelete, "/v1/users/identities/:provider", u.UnlinkIdentity, authenticate)
+
+	// This routes manage two-factor authentication of the authenticated user.
+	app.Handle(http.MethodPost, "/v1/users/mfa/totp", u.EnrollTOTP, authenticate)
+	app.Handle(http.MethodPost, "/v1/users/mfa/totp/confirm", u.ConfirmTOTP, authenticate)
+	app.Handle(http.MethodPost, "/v1/users/mfa/totp/disable", u.DisableTOTP, authenticate)
+	app.Handle(http.MethodPost, "/v1/users/mfa/recovery_codes", u.RegenerateRecoveryCodes, authenticate)
+
+	// Register the GraphQL API. Private fields are authorized by the
+	// resolvers.
+	gql := GraphQL{
+		db:     db,
+		relict: relic,
+		log:    log,
+		limits: graphQL,
+	}
+	app.Handle(http.MethodPost, "/v1/graphql", gql.Query, authenticate)
+
+	// This routes are available for admins only. Admins may be forced to use
+	// a second factor to get a token accepted here.
+	admin := []web.Middleware{authenticate, mid.HasRole(auth.RoleAdmin)}
+	if m.RequireForAdmin() {
+		admin = append(admin, mid.RequireMFA(auth.RoleAdmin))
+	}
+	app.Handle(http.MethodPost, "/v1/users/:user_id/unlock", u.Unlock, admin...)
+
+	// Register the webhook management endpoints for admins.
+	wh := Webhooks{
+		db:     db,
+		relict: relic,
+		hooks:  hooks,
+	}
+	app.Handle(http.MethodPost, "/v1/webhooks", wh.Create, admin...)
+	app.Handle(http.MethodGet, "/v1/webhooks", wh.List, admin...)
+	app.Handle(http.MethodGet, "/v1/webhooks/:webhook_id", wh.Retrieve, admin...)
+	app.Handle(http.MethodDelete, "/v1/webhooks/:webhook_id", wh.Delete, admin...)
+	app.Handle(http.MethodPost, "/v1/webhooks/:webhook_id/enable", wh.Enable, admin...)
+	app.Handle(http.MethodGet, "/v1/webhooks/:webhook_id/deliveries", wh.ListDeliveries, admin...)
+	app.Handle(http.MethodPost, "/v1/webhooks/:webhook_id/deliveries/:delivery_id/redeliver", wh.Redeliver, admin...)
+
+	// Register the OAuth authorization server and OpenID Connect provider.
+	o := OAuth{
+		db:       db,
+		provider: provider,
+		relict:   relic,
+		guard:    guard,
+		mfa:      m,
+		trusted:  trusted,
+	}
+	app.Handle(http.MethodGet, "/.well-known/openid-configuration", o.Discovery)
+	app.Handle(http.MethodGet, oauth.PathJWKS, o.JWKS)
+	app.Handle(http.MethodGet, oauth.PathAuthorize, o.Authorize)
+	app.Handle(http.MethodPost, oauth.PathAuthorize, o.AuthorizeLogin, mid.RateLimit(limiter, limits.Token, byIP))
+	app.Handle(http.MethodPost, oauth.PathToken, o.Token, mid.RateLimit(limiter, limits.Token, byIP))
+	app.Handle(http.MethodGet, oauth.PathUserInfo, o.UserInfo)
+	app.Handle(http.MethodPost, oauth.PathUserInfo, o.UserInfo)
+	app.Handle(http.MethodPost, "/v1/oauth/clients", o.RegisterClient, admin...)
+
+	if err := spec.Check(app.Routes()); err != nil {
+		log.Error("openapi : specification out of date", "error", err)
+	}
 
 	return app
 }
@@ -0,0 +1,316 @@
+package handlers
+
+import (
+	"context"
+	"net"
+	"os"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	newrelic "github.com/newrelic/go-agent"
+	"google.golang.org/grpc"
+
+	"github.com/igomonov88/users/internal/avatar"
+	"github.com/igomonov88/users/internal/email"
+	"github.com/igomonov88/users/internal/lockout"
+	"github.com/igomonov88/users/internal/mfa"
+	"github.com/igomonov88/users/internal/mid"
+	"github.com/igomonov88/users/internal/password"
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/platform/ratelimit"
+	"github.com/igomonov88/users/internal/platform/rpc"
+	"github.com/igomonov88/users/internal/storage"
+	"github.com/igomonov88/users/internal/username"
+	"github.com/igomonov88/users/internal/webhook"
+)
+
+// RPC constructs a gRPC server with the Users service of users.proto, next
+// to the health checking and reflection services.
+func RPC(shutdown chan os.Signal, log *logger.Logger, db *sqlx.DB, relic newrelic.Application,
+	authenticator *auth.Authenticator, guard *lockout.Guard, limiter ratelimit.Store, limits RateLimits,
+	trusted []*net.IPNet, m *mfa.MFA, avatars *avatar.Avatars, names *username.Policy,
+	passwords *password.Policy, emails *email.Policy, hooks *webhook.Webhooks) *rpc.Server {
+
+	// Construct the rpc.Server which runs the common middleware for every
+	// call.
+	srv := rpc.NewServer(shutdown, log, mid.RPCLogger(log, trusted), mid.RPCErrors(log), mid.RPCMetrics(), mid.RPCPanics(log))
+
+	s := UserRPC{
+		u: &User{
+			db:            db,
+			authenticator: authenticator,
+			relict:        relic,
+			guard:         guard,
+			mfa:           m,
+			avatars:       avatars,
+			names:         names,
+			passwords:     passwords,
+			emails:        emails,
+			hooks:         hooks,
+			trusted:       trusted,
+		},
+	}
+	RegisterUsersServer(srv, &s)
+
+	// API keys are accepted wherever a token is. Tokens are accepted while
+	// their session is active.
+	keys := func(ctx context.Context, key string) (auth.Claims, error) {
+		return storage.AuthenticateAPIKey(ctx, db, key, rpc.ClientIP(ctx, trusted), time.Now())
+	}
+	sessions := func(ctx context.Context, sessionID string) (bool, error) {
+		return storage.TouchSession(ctx, db, sessionID, time.Now())
+	}
+	read := mid.RPCAuthenticate(authenticator, keys, sessions, auth.ScopeRead)
+	write := mid.RPCAuthenticate(authenticator, keys, sessions, auth.ScopeWrite)
+
+	// This methods are not authenticated so the calls are limited per client ip.
+	srv.Use(usersMethod("CreateToken"), mid.RPCRateLimit(limiter, limits.Token, trusted))
+	srv.Use(usersMethod("TokenOTP"), mid.RPCRateLimit(limiter, limits.Token, trusted))
+	srv.Use(usersMethod("CreateUser"), mid.RPCRateLimit(limiter, limits.Signup, trusted))
+	srv.Use(usersMethod("EmailExists"), mid.RPCRateLimit(limiter, limits.Exist, trusted))
+	srv.Use(usersMethod("UserNameExists"), mid.RPCRateLimit(limiter, limits.Exist, trusted))
+
+	srv.Use(usersMethod("RetrieveUser"), read)
+	srv.Use(usersMethod("RetrieveUserByEmail"), read)
+	srv.Use(usersMethod("RetrieveUserByName"), read)
+	srv.Use(usersMethod("RetrieveUsers"), read)
+	srv.Use(usersMethod("UpdateUser"), write)
+	srv.Use(usersMethod("DeleteUser"), write)
+
+	return srv
+}
+
+// UsersServer is the server API of the Users service.
+type UsersServer interface {
+	CreateUser(context.Context, *CreateUserRequest) (*CreateUserResponse, error)
+	RetrieveUser(context.Context, *RetrieveUserRequest) (*RetrieveUserResponse, error)
+	RetrieveUserByEmail(context.Context, *RetrieveUserByEmailRequest) (*RetrieveUserResponse, error)
+	RetrieveUserByName(context.Context, *RetrieveUserByNameRequest) (*RetrieveUserResponse, error)
+	RetrieveUsers(Users_RetrieveUsersServer) error
+	UpdateUser(context.Context, *UpdateUserRequest) (*UpdateUserResponse, error)
+	DeleteUser(context.Context, *DeleteUserRequest) (*DeleteUserResponse, error)
+	CreateToken(context.Context, *TokenRequest) (*TokenResponse, error)
+	TokenOTP(context.Context, *TokenOTPRequest) (*TokenResponse, error)
+	EmailExists(context.Context, *EmailExistRequest) (*EmailExistResponse, error)
+	UserNameExists(context.Context, *UserNameExistRequest) (*UserNameExistResponse, error)
+}
+
+// Users_RetrieveUsersServer is the stream of a RetrieveUsers call.
+type Users_RetrieveUsersServer interface {
+	Send(*RetrieveUserResponse) error
+	Recv() (*RetrieveUserRequest, error)
+	grpc.ServerStream
+}
+
+// RegisterUsersServer registers the implementation of the Users service.
+func RegisterUsersServer(s *rpc.Server, srv UsersServer) {
+	s.RegisterService(&usersServiceDesc, srv)
+}
+
+// usersServiceName is the full name of the Users service.
+const usersServiceName = "users.v1.Users"
+
+// usersMethod returns the full name of a method of the Users service, the
+// form middleware is registered for.
+func usersMethod(name string) string {
+	return "/" + usersServiceName + "/" + name
+}
+
+// usersFile describes users.proto for the reflection service.
+var usersFile = rpc.File{
+	Name:    "users.proto",
+	Package: "users.v1",
+	Services: []rpc.Service{
+		{
+			Name: "Users",
+			Methods: []rpc.Method{
+				{Name: "CreateUser", Input: &CreateUserRequest{}, Output: &CreateUserResponse{}},
+				{Name: "RetrieveUser", Input: &RetrieveUserRequest{}, Output: &RetrieveUserResponse{}},
+				{Name: "RetrieveUserByEmail", Input: &RetrieveUserByEmailRequest{}, Output: &RetrieveUserResponse{}},
+				{Name: "RetrieveUserByName", Input: &RetrieveUserByNameRequest{}, Output: &RetrieveUserResponse{}},
+				{Name: "RetrieveUsers", Input: &RetrieveUserRequest{}, Output: &RetrieveUserResponse{}, ClientStreams: true, ServerStreams: true},
+				{Name: "UpdateUser", Input: &UpdateUserRequest{}, Output: &UpdateUserResponse{}},
+				{Name: "DeleteUser", Input: &DeleteUserRequest{}, Output: &DeleteUserResponse{}},
+				{Name: "CreateToken", Input: &TokenRequest{}, Output: &TokenResponse{}},
+				{Name: "TokenOTP", Input: &TokenOTPRequest{}, Output: &TokenResponse{}},
+				{Name: "EmailExists", Input: &EmailExistRequest{}, Output: &EmailExistResponse{}},
+				{Name: "UserNameExists", Input: &UserNameExistRequest{}, Output: &UserNameExistResponse{}},
+			},
+		},
+	},
+}
+
+func init() {
+	if err := rpc.RegisterFile(usersFile); err != nil {
+		panic(err)
+	}
+}
+
+var usersServiceDesc = grpc.ServiceDesc{
+	ServiceName: usersServiceName,
+	HandlerType: (*UsersServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "CreateUser", Handler: usersCreateUserHandler},
+		{MethodName: "RetrieveUser", Handler: usersRetrieveUserHandler},
+		{MethodName: "RetrieveUserByEmail", Handler: usersRetrieveUserByEmailHandler},
+		{MethodName: "RetrieveUserByName", Handler: usersRetrieveUserByNameHandler},
+		{MethodName: "UpdateUser", Handler: usersUpdateUserHandler},
+		{MethodName: "DeleteUser", Handler: usersDeleteUserHandler},
+		{MethodName: "CreateToken", Handler: usersCreateTokenHandler},
+		{MethodName: "TokenOTP", Handler: usersTokenOTPHandler},
+		{MethodName: "EmailExists", Handler: usersEmailExistsHandler},
+		{MethodName: "UserNameExists", Handler: usersUserNameExistsHandler},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "RetrieveUsers",
+			Handler:       usersRetrieveUsersHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "users.proto",
+}
+
+func usersCreateUserHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: usersMethod("CreateUser")}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).CreateUser(ctx, req.(*CreateUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func usersRetrieveUserHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RetrieveUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: usersMethod("RetrieveUser")}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).RetrieveUser(ctx, req.(*RetrieveUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func usersRetrieveUserByEmailHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RetrieveUserByEmailRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: usersMethod("RetrieveUserByEmail")}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).RetrieveUserByEmail(ctx, req.(*RetrieveUserByEmailRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func usersRetrieveUserByNameHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RetrieveUserByNameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: usersMethod("RetrieveUserByName")}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).RetrieveUserByName(ctx, req.(*RetrieveUserByNameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func usersRetrieveUsersHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(UsersServer).RetrieveUsers(&usersRetrieveUsersServer{stream})
+}
+
+// usersRetrieveUsersServer implements Users_RetrieveUsersServer over the
+// stream of a call.
+type usersRetrieveUsersServer struct {
+	grpc.ServerStream
+}
+
+func (x *usersRetrieveUsersServer) Send(m *RetrieveUserResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *usersRetrieveUsersServer) Recv() (*RetrieveUserRequest, error) {
+	m := new(RetrieveUserRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func usersUpdateUserHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: usersMethod("UpdateUser")}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).UpdateUser(ctx, req.(*UpdateUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func usersDeleteUserHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: usersMethod("DeleteUser")}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).DeleteUser(ctx, req.(*DeleteUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func usersCreateTokenHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: usersMethod("CreateToken")}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).CreateToken(ctx, req.(*TokenRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func usersTokenOTPHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TokenOTPRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: usersMethod("TokenOTP")}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).TokenOTP(ctx, req.(*TokenOTPRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func usersEmailExistsHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EmailExistRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: usersMethod("EmailExists")}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).EmailExists(ctx, req.(*EmailExistRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func usersUserNameExistsHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UserNameExistRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: usersMethod("UserNameExists")}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(UsersServer).UserNameExists(ctx, req.(*UserNameExistRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
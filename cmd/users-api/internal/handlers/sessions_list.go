@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// ListSessions returns the active sessions of the authenticated user. The
+// session of the token used for the request is marked as current.
+func (u *User) ListSessions(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.ListSessions")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("list sessions", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	sessions, err := storage.ListSessions(ctx, u.db, claims.Subject, v.Now)
+	if err != nil {
+		switch err {
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "listing sessions of %q", claims.Subject)
+		}
+	}
+
+	resp := ListSessionsResponse{
+		Sessions: make([]Session, len(sessions)),
+	}
+	for i, s := range sessions {
+		resp.Sessions[i] = Session{
+			ID:         s.ID,
+			UserAgent:  s.UserAgent,
+			IP:         s.IP,
+			CreatedAt:  s.CreatedAt,
+			LastSeenAt: s.LastSeenAt,
+			ExpiresAt:  s.ExpiresAt,
+			Current:    s.ID == claims.SessionID,
+		}
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
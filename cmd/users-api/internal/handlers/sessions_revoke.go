@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// RevokeSession revokes a session of the authenticated user, so tokens
+// issued for it are rejected from then on.
+func (u *User) RevokeSession(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.RevokeSession")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("revoke session", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	if err := storage.RevokeSession(ctx, u.db, claims.Subject, params["session_id"], v.Now); err != nil {
+		switch err {
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case storage.ErrNotFound:
+			return web.NewCodedError(err, http.StatusNotFound, codeSessionNotFound)
+		default:
+			return errors.Wrapf(err, "revoking session of %q", claims.Subject)
+		}
+	}
+
+	return web.Respond(ctx, w, RevokeSessionResponse{}, http.StatusOK)
+}
+
+// RevokeOtherSessions revokes all sessions of the authenticated user except
+// the one of the token used for the request. Requests authenticated without
+// a session revoke all sessions.
+func (u *User) RevokeOtherSessions(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.RevokeOtherSessions")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("revoke other sessions", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	n, err := storage.RevokeOtherSessions(ctx, u.db, claims.Subject, claims.SessionID, v.Now)
+	if err != nil {
+		switch err {
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "revoking sessions of %q", claims.Subject)
+		}
+	}
+
+	return web.Respond(ctx, w, RevokeOtherSessionsResponse{Revoked: n}, http.StatusOK)
+}
@@ -2,11 +2,16 @@ package handlers
 
 import (
 	"context"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 
+	"github.com/igomonov88/users/internal/lockout"
+	"github.com/igomonov88/users/internal/platform/auth"
 	"github.com/igomonov88/users/internal/platform/web"
 	"github.com/igomonov88/users/internal/storage"
 )
@@ -20,8 +25,6 @@ func (u *User) Token(ctx context.Context, w http.ResponseWriter, r *http.Request
 	txn := u.relict.StartTransaction("get token", w, r)
 	defer txn.End()
 
-
-
 	v, ok := ctx.Value(web.KeyValues).(*web.Values)
 	if !ok {
 		return web.NewShutdownError("web value missing from context")
@@ -33,21 +36,118 @@ func (u *User) Token(ctx context.Context, w http.ResponseWriter, r *http.Request
 		return web.NewRequestError(err, http.StatusUnauthorized)
 	}
 
-	claims, err := storage.Authenticate(ctx, u.db, v.Now, email, pass)
+	tkn, err := u.token(ctx, email, pass, r.UserAgent(), web.ClientIP(r, u.trusted), v.Now)
+	if err != nil {
+		retryAfter(w, err)
+		return err
+	}
+
+	return web.Respond(ctx, w, tkn, http.StatusOK)
+}
+
+// token authenticates a user by email and password. Users with two-factor
+// authentication enabled get a challenge instead of a token.
+func (u *User) token(ctx context.Context, email, pass, userAgent, ip string, now time.Time) (TokenResponse, error) {
+	if err := u.guard.Check(ctx, email, ip, now); err != nil {
+		return TokenResponse{}, lockedError(err)
+	}
+
+	claims, err := storage.Authenticate(ctx, u.db, now, email, pass)
 	if err != nil {
 		switch err {
 		case storage.ErrAuthenticationFailure:
-			return web.NewRequestError(err, http.StatusUnauthorized)
+			if err := u.guard.Fail(ctx, email, ip, now); err != nil {
+				return TokenResponse{}, errors.Wrap(err, "recording login failure")
+			}
+			return TokenResponse{}, web.NewRequestError(err, http.StatusUnauthorized)
 		default:
-			return errors.Wrap(err, "authenticating")
+			return TokenResponse{}, errors.Wrap(err, "authenticating")
 		}
 	}
+
+	// Users with two-factor authentication enabled have to finish logging in
+	// with a one-time password or a recovery code. Their failures are only
+	// reset once the second factor passed, otherwise the password alone
+	// would lift the lockout guarding the codes.
+	enrolled, err := u.mfa.Enrolled(ctx, claims.Subject)
+	if err != nil {
+		return TokenResponse{}, errors.Wrap(err, "checking two-factor enrollment")
+	}
+	if enrolled {
+		id, err := u.mfa.Challenge(ctx, claims.Subject, now)
+		if err != nil {
+			return TokenResponse{}, errors.Wrap(err, "creating two-factor challenge")
+		}
+		return TokenResponse{MFARequired: true, Challenge: id}, nil
+	}
+
+	if err := u.guard.Succeed(ctx, email); err != nil {
+		return TokenResponse{}, errors.Wrap(err, "resetting login failures")
+	}
+
 	tkn := TokenResponse{}
 
-	tkn.Token, err = u.authenticator.GenerateToken(claims)
+	tkn.Token, err = u.sessionToken(ctx, claims, userAgent, ip, now)
 	if err != nil {
-		return errors.Wrap(err, "generating token")
+		return TokenResponse{}, err
 	}
 
-	return web.Respond(ctx, w, tkn, http.StatusOK)
+	return tkn, nil
+}
+
+// sessionToken records a session for the device the request came from and
+// generates a token bound to it, so the token can be revoked with the session.
+func (u *User) sessionToken(ctx context.Context, claims auth.Claims, userAgent, ip string, now time.Time) (string, error) {
+	expires := time.Unix(claims.ExpiresAt, 0)
+
+	s, err := storage.CreateSession(ctx, u.db, claims.Subject, userAgent, ip, now, expires)
+	if err != nil {
+		return "", errors.Wrap(err, "creating session")
+	}
+	claims.SessionID = s.ID
+
+	if err := u.audit(ctx, claims.Subject, claims.Subject, storage.AuditLogin); err != nil {
+		return "", err
+	}
+
+	tkn, err := u.authenticator.GenerateToken(claims)
+	if err != nil {
+		return "", errors.Wrap(err, "generating token")
+	}
+
+	return tkn, nil
+}
+
+// lockedError converts an error returned by the lockout guard into a 429
+// error. Any other error is returned wrapped.
+func lockedError(err error) error {
+	le, ok := errors.Cause(err).(*lockout.LockedError)
+	if !ok {
+		return errors.Wrap(err, "checking lockout")
+	}
+
+	return web.NewCodedError(le, http.StatusTooManyRequests, codeAccountLocked)
+}
+
+// retryAfter sets the Retry-After header for errors of locked accounts, so
+// clients know when to try again.
+func retryAfter(w http.ResponseWriter, err error) {
+	if secs, ok := lockedSeconds(err); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(secs))
+	}
+}
+
+// lockedSeconds returns the seconds until a locked account may try again
+// for errors returned by lockedError.
+func lockedSeconds(err error) (int, bool) {
+	webErr, ok := errors.Cause(err).(*web.Error)
+	if !ok {
+		return 0, false
+	}
+	le, ok := webErr.Err.(*lockout.LockedError)
+	if !ok {
+		return 0, false
+	}
+
+	return int(math.Ceil(le.RetryAfter.Seconds())), true
 }
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/mfa"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// TokenOTP handles the second step of authenticating a user with two-factor
+// authentication enabled. It expects the challenge returned by Token with a
+// one-time password or a recovery code. It responds with a JWT.
+func (u *User) TokenOTP(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.TokenOTP")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("get token otp", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var req TokenOTPRequest
+	if err := web.Decode(r, &req); err != nil {
+		return errors.Wrap(err, "decoding otp request")
+	}
+
+	tkn, err := u.tokenOTP(ctx, req, r.UserAgent(), web.ClientIP(r, u.trusted), v.Now)
+	if err != nil {
+		retryAfter(w, err)
+		return err
+	}
+
+	return web.Respond(ctx, w, tkn, http.StatusOK)
+}
+
+// tokenOTP answers the challenge of a user with a one-time password or a
+// recovery code and generates a token.
+func (u *User) tokenOTP(ctx context.Context, req TokenOTPRequest, userAgent, ip string, now time.Time) (TokenResponse, error) {
+	userID, err := u.mfa.ChallengeUser(ctx, req.Challenge, now)
+	if err != nil {
+		switch err {
+		case mfa.ErrChallengeNotFound:
+			return TokenResponse{}, web.NewRequestError(err, http.StatusUnauthorized)
+		default:
+			return TokenResponse{}, errors.Wrap(err, "retrieving challenge")
+		}
+	}
+
+	usr, err := storage.Retrieve(ctx, u.db, userID)
+	if err != nil {
+		return TokenResponse{}, errors.Wrapf(err, "retrieving user %q", userID)
+	}
+
+	// One-time passwords are short, so failures count against the same
+	// lockout as failed passwords.
+	if err := u.guard.Check(ctx, usr.Email, ip, now); err != nil {
+		return TokenResponse{}, lockedError(err)
+	}
+
+	if req.RecoveryCode != "" {
+		err = u.mfa.Recover(ctx, usr.ID, req.RecoveryCode, now)
+	} else {
+		err = u.mfa.Verify(ctx, usr.ID, req.Code, now)
+	}
+	if err != nil {
+		switch err {
+		case mfa.ErrInvalidCode, mfa.ErrNotEnrolled:
+			if err := u.guard.Fail(ctx, usr.Email, ip, now); err != nil {
+				return TokenResponse{}, errors.Wrap(err, "recording login failure")
+			}
+			if err := u.mfa.FailChallenge(ctx, req.Challenge); err != nil {
+				return TokenResponse{}, errors.Wrap(err, "recording challenge failure")
+			}
+			return TokenResponse{}, web.NewRequestError(err, http.StatusUnauthorized)
+		default:
+			return TokenResponse{}, errors.Wrap(err, "verifying second factor")
+		}
+	}
+
+	if err := u.guard.Succeed(ctx, usr.Email); err != nil {
+		return TokenResponse{}, errors.Wrap(err, "resetting login failures")
+	}
+
+	if err := u.mfa.CloseChallenge(ctx, req.Challenge, now); err != nil {
+		return TokenResponse{}, errors.Wrap(err, "closing challenge")
+	}
+
+	claims := storage.NewClaims(usr, now)
+	claims.MFA = true
+
+	tkn := TokenResponse{}
+
+	tkn.Token, err = u.sessionToken(ctx, claims, userAgent, ip, now)
+	if err != nil {
+		return TokenResponse{}, err
+	}
+
+	return tkn, nil
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// Unlock removes the failed login attempts recorded for a user so a locked
+// account can be used again. It is available for admins only.
+func (u *User) Unlock(ctx context.Context, w http.ResponseWriter, r *http.Request,
+	params map[string]string) error {
+
+	ctx, span := trace.StartSpan(ctx, "handlers.User.Unlock")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("unlock user", w, r)
+	defer txn.End()
+
+	usr, err := storage.Retrieve(ctx, u.db, params["user_id"])
+	if err != nil {
+		switch err {
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case storage.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "retrieving user %q", params["user_id"])
+		}
+	}
+
+	if err := u.guard.Unlock(ctx, usr.Email); err != nil {
+		return errors.Wrapf(err, "unlocking user %q", usr.ID)
+	}
+
+	return web.Respond(ctx, w, UnlockUserResponse{}, http.StatusOK)
+}
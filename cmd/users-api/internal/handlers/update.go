@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 
 	"github.com/igomonov88/users/internal/platform/web"
@@ -19,12 +20,59 @@ func (u *User) Update(ctx context.Context, w http.ResponseWriter, r *http.Reques
 
 	req := UpdateUserRequest{}
 	if err := web.Decode(r, &req); err != nil {
-		return web.Respond(ctx, w, nil, http.StatusBadRequest)
+		return err
 	}
 
-	if err := storage.Update(ctx, u.db, req.UserID, req.Name, req.Email); err != nil {
-		return web.Respond(ctx, w, nil, http.StatusInternalServerError)
+	// Names and addresses taken before the policies existed are kept as they
+	// are.
+	usr, err := storage.Retrieve(ctx, u.db, req.UserID)
+	if err != nil {
+		switch err {
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case storage.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "retrieving user %q", req.UserID)
+		}
+	}
+	if err := u.update(ctx, usr, req.Name, req.Email, req.Locale); err != nil {
+		return err
 	}
 
 	return web.Respond(ctx, w, UpdateUserResponse{}, http.StatusOK)
 }
+
+// update changes the name, email address and locale of the user, checking
+// only the values which changed against the policies. An empty locale keeps
+// the chosen one.
+func (u *User) update(ctx context.Context, usr *storage.User, name, email, locale string) error {
+	if name != usr.Name {
+		if err := u.checkUserName(ctx, "name", name, usr.ID); err != nil {
+			return err
+		}
+	}
+	if email != usr.Email {
+		if err := u.checkEmail("email", email); err != nil {
+			return err
+		}
+	}
+
+	if err := storage.Update(ctx, u.db, usr.ID, name, email); err != nil {
+		switch errors.Cause(err) {
+		case storage.ErrEmailAlreadyExist, storage.ErrUserNameAlreadyExist:
+			return web.NewRequestError(err, http.StatusConflict)
+		default:
+			return errors.Wrapf(err, "updating user %q", usr.ID)
+		}
+	}
+
+	// Clients which do not know about locales leave the chosen one alone.
+	if locale != "" && locale != usr.Locale {
+		if err := storage.UpdateLocale(ctx, u.db, usr.ID, locale); err != nil {
+			return errors.Wrapf(err, "updating locale of user %q", usr.ID)
+		}
+	}
+
+	return u.audit(ctx, usr.ID, subject(ctx), storage.AuditUserUpdated)
+}
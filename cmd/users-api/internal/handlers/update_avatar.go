@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 
 	"github.com/igomonov88/users/internal/platform/web"
@@ -21,13 +22,16 @@ func (u *User) UpdateAvatar(ctx context.Context, w http.ResponseWriter, r *http.
 
 	req := UpdateAvatarRequest{}
 	if err := web.Decode(r, &req); err != nil {
-		return web.Respond(ctx, w, web.ErrorResponse{
-			Error: "Bad Request",}, http.StatusBadRequest)
+		return err
 	}
 
 	if err := storage.UpdateAvatar(ctx, u.db, req.UserID, req.Avatar); err != nil {
-		return web.Respond(ctx, w, web.ErrorResponse{
-			Error: "Internal Server Error",}, http.StatusInternalServerError)
+		switch err {
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "updating avatar of user %q", req.UserID)
+		}
 	}
 
 	return web.Respond(ctx, w, UpdateAvatarResponse{}, http.StatusOK)
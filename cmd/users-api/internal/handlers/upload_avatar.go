@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/avatar"
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// avatarField is the name of the multipart field holding the image.
+const avatarField = "avatar"
+
+// UploadAvatar replaces the avatar of the authenticated user with an image.
+// It expects a multipart form whose first part is the image and responds with
+// the URLs of the stored avatar and its thumbnails.
+func (u *User) UploadAvatar(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.User.UploadAvatar")
+	defer span.End()
+
+	txn := u.relict.StartTransaction("upload avatar", w, r)
+	defer txn.End()
+
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context")
+	}
+
+	// The image is streamed from the request, so only the first part is read
+	// and nothing after it.
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+	part, err := mr.NextPart()
+	if err != nil {
+		return web.NewRequestError(errors.Wrap(err, "reading multipart form"), http.StatusBadRequest)
+	}
+	defer part.Close()
+
+	if part.FormName() != avatarField {
+		err := errors.Errorf("expected the image in the %q field", avatarField)
+		return web.NewRequestError(err, http.StatusBadRequest)
+	}
+
+	av, err := u.avatars.Upload(ctx, claims.Subject, part)
+	if err != nil {
+		switch errors.Cause(err) {
+		case avatar.ErrTooLarge:
+			return web.NewRequestError(err, http.StatusRequestEntityTooLarge)
+		case avatar.ErrUnsupportedType:
+			return web.NewRequestError(err, http.StatusUnsupportedMediaType)
+		case avatar.ErrDimensions:
+			return web.NewRequestError(err, http.StatusUnprocessableEntity)
+		case storage.ErrInvalidUserID:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		case storage.ErrNotFound:
+			return web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return errors.Wrapf(err, "uploading avatar of %q", claims.Subject)
+		}
+	}
+
+	resp := UploadAvatarResponse{
+		Avatar:     av.URL,
+		Thumbnails: av.Thumbnails,
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+	"github.com/igomonov88/users/internal/username"
+)
+
+// checkUserName applies the user name policy to a name the user with the ID
+// wants to take, including whether it looks like the name of another user.
+// The ID is empty for new users. Violations are reported for the field.
+func (u *User) checkUserName(ctx context.Context, field, name, userID string) error {
+	if err := u.names.Check(name); err != nil {
+		return userNameError(field, err)
+	}
+
+	exist, err := storage.DoesUserNameSkeletonExist(ctx, u.db, username.Skeleton(name), userID)
+	if err != nil {
+		return err
+	}
+	if !exist {
+		return nil
+	}
+
+	reason := username.ReasonConfusable
+	if exist, err := storage.DoesUserNameExist(ctx, u.db, name); err == nil && exist {
+		reason = username.ReasonTaken
+	}
+
+	return userNameError(field, &username.Violation{Reason: reason})
+}
+
+// userNameError turns a violation of the user name policy into an error
+// naming the reason for the field.
+func userNameError(field string, err error) error {
+	v, ok := err.(*username.Violation)
+	if !ok {
+		return err
+	}
+
+	code := codeUserNameRejected
+	if v.Reason == username.ReasonTaken {
+		code = catalog[storage.ErrUserNameAlreadyExist]
+	}
+
+	return &web.Error{
+		Err:    err,
+		Status: http.StatusBadRequest,
+		Code:   code.Code,
+		Title:  code.Title,
+		Fields: []web.FieldError{{Field: field, Error: v.Reason}},
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"net/http"
 
+	"github.com/pkg/errors"
 	"go.opencensus.io/trace"
 
 	"github.com/igomonov88/users/internal/platform/web"
@@ -21,18 +22,18 @@ func (u *User) UserNameExists(ctx context.Context, w http.ResponseWriter, r *htt
 
 	un, ok := params["user_name"]
 	if !ok {
-		return web.Respond(ctx, w, UserNameExistResponse{}, http.StatusBadRequest)
+		return web.NewRequestError(errors.New("user_name is missing"), http.StatusBadRequest)
 	}
 
 	exist, err := storage.DoesUserNameExist(ctx, u.db, un)
 	if err != nil {
-		switch err {
-		case storage.ErrNotFound:
-			return web.Respond(ctx, w, web.ErrorResponse{
-				Error:"User Not Found",}, http.StatusNotFound)
-		default:
-			return web.Respond(ctx, w, web.ErrorResponse{
-			Error: "Internal Server Error",}, http.StatusInternalServerError)
+		return errors.Wrapf(err, "checking user name %q", un)
+	}
+
+	// Names which can not be taken are reported with the reason.
+	if !exist {
+		if err := u.checkUserName(ctx, "user_name", un, ""); err != nil {
+			return err
 		}
 	}
 
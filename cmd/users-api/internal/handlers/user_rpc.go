@@ -0,0 +1,363 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/igomonov88/users/internal/mid"
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/rpc"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// UserRPC implements the Users service over the handlers of User.
+type UserRPC struct {
+	u *User
+}
+
+// CreateUser creates a user like Create does.
+func (s *UserRPC) CreateUser(ctx context.Context, req *CreateUserRequest) (*CreateUserResponse, error) {
+	ctx, span := trace.StartSpan(ctx, "handlers.UserRPC.CreateUser")
+	defer span.End()
+
+	txn := s.u.relict.StartTransaction("rpc create user", nil, nil)
+	defer txn.End()
+
+	if err := web.Check(req); err != nil {
+		return nil, err
+	}
+
+	usr, err := s.u.create(ctx, *req)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CreateUserResponse{UserID: usr.ID}, nil
+}
+
+// RetrieveUser returns the user with the id.
+func (s *UserRPC) RetrieveUser(ctx context.Context, req *RetrieveUserRequest) (*RetrieveUserResponse, error) {
+	ctx, span := trace.StartSpan(ctx, "handlers.UserRPC.RetrieveUser")
+	defer span.End()
+
+	txn := s.u.relict.StartTransaction("rpc retrieve user", nil, nil)
+	defer txn.End()
+
+	return s.retrieve(ctx, req)
+}
+
+// RetrieveUserByEmail returns the user with the email address.
+func (s *UserRPC) RetrieveUserByEmail(ctx context.Context, req *RetrieveUserByEmailRequest) (*RetrieveUserResponse, error) {
+	ctx, span := trace.StartSpan(ctx, "handlers.UserRPC.RetrieveUserByEmail")
+	defer span.End()
+
+	txn := s.u.relict.StartTransaction("rpc retrieve user by email", nil, nil)
+	defer txn.End()
+
+	if err := web.Check(req); err != nil {
+		return nil, err
+	}
+
+	usr, err := storage.RetrieveByEmail(ctx, s.u.db, req.Email)
+	if err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			return nil, web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return nil, errors.Wrapf(err, "retrieving user by email %q", req.Email)
+		}
+	}
+
+	resp := userResponse(usr)
+
+	return &resp, nil
+}
+
+// RetrieveUserByName returns the user with the name.
+func (s *UserRPC) RetrieveUserByName(ctx context.Context, req *RetrieveUserByNameRequest) (*RetrieveUserResponse, error) {
+	ctx, span := trace.StartSpan(ctx, "handlers.UserRPC.RetrieveUserByName")
+	defer span.End()
+
+	txn := s.u.relict.StartTransaction("rpc retrieve user by name", nil, nil)
+	defer txn.End()
+
+	if err := web.Check(req); err != nil {
+		return nil, err
+	}
+
+	usr, err := storage.RetrieveByUserName(ctx, s.u.db, req.UserName)
+	if err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			return nil, web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return nil, errors.Wrapf(err, "retrieving user by name %q", req.UserName)
+		}
+	}
+
+	resp := userResponse(usr)
+
+	return &resp, nil
+}
+
+// RetrieveUsers answers every request of the stream with the user of the
+// id until the client closes its side of the stream.
+func (s *UserRPC) RetrieveUsers(stream Users_RetrieveUsersServer) error {
+	ctx, span := trace.StartSpan(stream.Context(), "handlers.UserRPC.RetrieveUsers")
+	defer span.End()
+
+	txn := s.u.relict.StartTransaction("rpc retrieve users", nil, nil)
+	defer txn.End()
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		resp, err := s.retrieve(ctx, req)
+		if err != nil {
+			return err
+		}
+
+		if err := stream.Send(resp); err != nil {
+			return err
+		}
+	}
+}
+
+// retrieve returns the user of the request like Retrieve does.
+func (s *UserRPC) retrieve(ctx context.Context, req *RetrieveUserRequest) (*RetrieveUserResponse, error) {
+	if err := web.Check(req); err != nil {
+		return nil, err
+	}
+
+	usr, err := storage.Retrieve(ctx, s.u.db, req.UserID)
+	if err != nil {
+		switch err {
+		case storage.ErrInvalidUserID:
+			return nil, web.NewRequestError(err, http.StatusBadRequest)
+		case storage.ErrNotFound:
+			return nil, web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return nil, errors.Wrapf(err, "retrieving user %q", req.UserID)
+		}
+	}
+
+	resp := userResponse(usr)
+
+	return &resp, nil
+}
+
+// UpdateUser changes the name, email address and locale of a user like
+// Update does. Users may update themselves only, admins anyone.
+func (s *UserRPC) UpdateUser(ctx context.Context, req *UpdateUserRequest) (*UpdateUserResponse, error) {
+	ctx, span := trace.StartSpan(ctx, "handlers.UserRPC.UpdateUser")
+	defer span.End()
+
+	txn := s.u.relict.StartTransaction("rpc update user", nil, nil)
+	defer txn.End()
+
+	if err := web.Check(req); err != nil {
+		return nil, err
+	}
+	if err := s.selfOrAdmin(ctx, req.UserID); err != nil {
+		return nil, err
+	}
+
+	usr, err := storage.Retrieve(ctx, s.u.db, req.UserID)
+	if err != nil {
+		switch err {
+		case storage.ErrInvalidUserID:
+			return nil, web.NewRequestError(err, http.StatusBadRequest)
+		case storage.ErrNotFound:
+			return nil, web.NewRequestError(err, http.StatusNotFound)
+		default:
+			return nil, errors.Wrapf(err, "retrieving user %q", req.UserID)
+		}
+	}
+	if err := s.u.update(ctx, usr, req.Name, req.Email, req.Locale); err != nil {
+		return nil, err
+	}
+
+	return &UpdateUserResponse{}, nil
+}
+
+// DeleteUser deletes a user like Delete does. Users may delete themselves
+// only, admins anyone.
+func (s *UserRPC) DeleteUser(ctx context.Context, req *DeleteUserRequest) (*DeleteUserResponse, error) {
+	ctx, span := trace.StartSpan(ctx, "handlers.UserRPC.DeleteUser")
+	defer span.End()
+
+	txn := s.u.relict.StartTransaction("rpc delete user", nil, nil)
+	defer txn.End()
+
+	if err := s.selfOrAdmin(ctx, req.UserID); err != nil {
+		return nil, err
+	}
+
+	if err := storage.Delete(ctx, s.u.db, req.UserID); err != nil {
+		switch err {
+		case storage.ErrInvalidUserID:
+			return nil, web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return nil, errors.Wrapf(err, "deleting user %q", req.UserID)
+		}
+	}
+
+	if err := s.u.audit(ctx, req.UserID, subject(ctx), storage.AuditUserDeleted); err != nil {
+		return nil, err
+	}
+
+	return &DeleteUserResponse{}, nil
+}
+
+// CreateToken authenticates a user by email and password like Token does.
+// Calls for locked accounts get the seconds until they may try again in the
+// retry-after trailer.
+func (s *UserRPC) CreateToken(ctx context.Context, req *TokenRequest) (*TokenResponse, error) {
+	ctx, span := trace.StartSpan(ctx, "handlers.UserRPC.CreateToken")
+	defer span.End()
+
+	txn := s.u.relict.StartTransaction("rpc get token", nil, nil)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return nil, web.NewShutdownError("web value missing from context")
+	}
+
+	if err := web.Check(req); err != nil {
+		return nil, err
+	}
+
+	tkn, err := s.u.token(ctx, req.Email, req.Password, rpc.UserAgent(ctx), rpc.ClientIP(ctx, s.u.trusted), v.Now)
+	if err != nil {
+		if secs, ok := lockedSeconds(err); ok {
+			grpc.SetTrailer(ctx, metadata.Pairs("retry-after", strconv.Itoa(secs)))
+		}
+		return nil, err
+	}
+
+	return &tkn, nil
+}
+
+// TokenOTP answers the challenge returned by CreateToken for users with
+// two-factor authentication enabled like TokenOTP does.
+func (s *UserRPC) TokenOTP(ctx context.Context, req *TokenOTPRequest) (*TokenResponse, error) {
+	ctx, span := trace.StartSpan(ctx, "handlers.UserRPC.TokenOTP")
+	defer span.End()
+
+	txn := s.u.relict.StartTransaction("rpc get token otp", nil, nil)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return nil, web.NewShutdownError("web value missing from context")
+	}
+
+	if err := web.Check(req); err != nil {
+		return nil, err
+	}
+
+	tkn, err := s.u.tokenOTP(ctx, *req, rpc.UserAgent(ctx), rpc.ClientIP(ctx, s.u.trusted), v.Now)
+	if err != nil {
+		if secs, ok := lockedSeconds(err); ok {
+			grpc.SetTrailer(ctx, metadata.Pairs("retry-after", strconv.Itoa(secs)))
+		}
+		return nil, err
+	}
+
+	return &tkn, nil
+}
+
+// EmailExists reports if a user has the email address.
+func (s *UserRPC) EmailExists(ctx context.Context, req *EmailExistRequest) (*EmailExistResponse, error) {
+	ctx, span := trace.StartSpan(ctx, "handlers.UserRPC.EmailExists")
+	defer span.End()
+
+	txn := s.u.relict.StartTransaction("rpc email exist", nil, nil)
+	defer txn.End()
+
+	if err := web.Check(req); err != nil {
+		return nil, err
+	}
+
+	exist, err := storage.DoesEmailExist(ctx, s.u.db, req.Email)
+	if err != nil {
+		return nil, errors.Wrapf(err, "checking email %q", req.Email)
+	}
+
+	return &EmailExistResponse{Exist: exist}, nil
+}
+
+// UserNameExists reports if a user has the name like UserNameExists does.
+// Names which can not be taken are reported with the reason.
+func (s *UserRPC) UserNameExists(ctx context.Context, req *UserNameExistRequest) (*UserNameExistResponse, error) {
+	ctx, span := trace.StartSpan(ctx, "handlers.UserRPC.UserNameExists")
+	defer span.End()
+
+	txn := s.u.relict.StartTransaction("rpc user name exist", nil, nil)
+	defer txn.End()
+
+	if err := web.Check(req); err != nil {
+		return nil, err
+	}
+
+	exist, err := storage.DoesUserNameExist(ctx, s.u.db, req.UserName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "checking user name %q", req.UserName)
+	}
+
+	if !exist {
+		if err := s.u.checkUserName(ctx, "user_name", req.UserName, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return &UserNameExistResponse{Exist: exist}, nil
+}
+
+// selfOrAdmin validates that the user a call is about is the authenticated
+// user or that the authenticated user is an admin, like mid.SelfOrRole does
+// for routes.
+func selfOrAdmin(ctx context.Context, userID string) error {
+	claims, ok := ctx.Value(auth.Key).(auth.Claims)
+	if !ok {
+		return errors.New("claims missing from context: call is not authenticated")
+	}
+
+	if claims.Subject != userID && !claims.HasRole(auth.RoleAdmin) {
+		return mid.ErrForbidden
+	}
+
+	return nil
+}
+
+// selfOrAdmin validates like selfOrAdmin that the user a call changes is the
+// authenticated user or that the authenticated user is an admin. Admins may
+// be forced to use a second factor to change other users, like
+// mid.RequireMFAForOthers does for routes.
+func (s *UserRPC) selfOrAdmin(ctx context.Context, userID string) error {
+	if err := selfOrAdmin(ctx, userID); err != nil {
+		return err
+	}
+
+	claims := ctx.Value(auth.Key).(auth.Claims)
+	if s.u.mfa.RequireForAdmin() && claims.Subject != userID && claims.HasRole(auth.RoleAdmin) && !claims.MFA {
+		return mid.ErrMFARequired
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"github.com/jmoiron/sqlx"
+	newrelic "github.com/newrelic/go-agent"
+
+	"github.com/igomonov88/users/internal/storage"
+	"github.com/igomonov88/users/internal/webhook"
+)
+
+// maxDeliveries is the number of latest deliveries listed for a webhook.
+const maxDeliveries = 100
+
+// Webhooks represents the handler set to manage webhooks. It is available
+// for admins only.
+type Webhooks struct {
+	db     *sqlx.DB
+	relict newrelic.Application
+	hooks  *webhook.Webhooks
+}
+
+// toWebhook converts a stored webhook into its response form. The secret
+// is never part of it.
+func toWebhook(h *storage.Webhook) Webhook {
+	wh := Webhook{
+		ID:        h.ID,
+		URL:       h.URL,
+		Events:    h.Events,
+		Failures:  h.Failures,
+		CreatedAt: h.CreatedAt,
+	}
+	if h.DisabledAt.Valid {
+		wh.DisabledAt = &h.DisabledAt.Time
+	}
+	return wh
+}
+
+// toWebhookDelivery converts a stored delivery with its attempts into its
+// response form. Only pending deliveries have a next attempt.
+func toWebhookDelivery(d *storage.WebhookDelivery, attempts []storage.WebhookAttempt) WebhookDelivery {
+	wd := WebhookDelivery{
+		ID:        d.ID,
+		Event:     d.Event,
+		State:     d.State,
+		Attempts:  make([]WebhookAttempt, len(attempts)),
+		CreatedAt: d.CreatedAt,
+	}
+	if d.State == storage.DeliveryPending {
+		wd.NextAttemptAt = &d.NextAttemptAt
+	}
+	if d.DeliveredAt.Valid {
+		wd.DeliveredAt = &d.DeliveredAt.Time
+	}
+	for i, a := range attempts {
+		wd.Attempts[i] = WebhookAttempt{
+			StatusCode: a.StatusCode,
+			Error:      a.Error,
+			DurationMS: a.DurationMS,
+			CreatedAt:  a.CreatedAt,
+		}
+	}
+	return wd
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/webhook"
+)
+
+// Create subscribes a URL to events. The secret payloads are signed
+// with is generated unless one is provided and is part of the response
+// only once.
+func (wh *Webhooks) Create(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Webhooks.Create")
+	defer span.End()
+
+	txn := wh.relict.StartTransaction("create webhook", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	var req CreateWebhookRequest
+	if err := web.Decode(r, &req); err != nil {
+		return errors.Wrap(err, "decoding webhook")
+	}
+
+	h, secret, err := wh.hooks.Create(ctx, req.URL, req.Events, req.Secret, v.Now)
+	if err != nil {
+		switch errors.Cause(err) {
+		case webhook.ErrUnknownEvent:
+			return web.NewRequestError(err, http.StatusBadRequest)
+		default:
+			return errors.Wrapf(err, "creating webhook for %q", req.URL)
+		}
+	}
+
+	resp := CreateWebhookResponse{
+		Webhook: toWebhook(h),
+		Secret:  secret,
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusCreated)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// Delete removes the specified webhook with its deliveries. Pending
+// deliveries are never attempted.
+func (wh *Webhooks) Delete(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Webhooks.Delete")
+	defer span.End()
+
+	txn := wh.relict.StartTransaction("delete webhook", w, r)
+	defer txn.End()
+
+	if err := storage.DeleteWebhook(ctx, wh.db, params["webhook_id"]); err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			return web.NewCodedError(err, http.StatusNotFound, codeWebhookNotFound)
+		default:
+			return errors.Wrapf(err, "deleting webhook %q", params["webhook_id"])
+		}
+	}
+
+	return web.Respond(ctx, w, DeleteWebhookResponse{}, http.StatusOK)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// ListDeliveries returns the latest deliveries of the specified webhook
+// with their attempts, the most recent first.
+func (wh *Webhooks) ListDeliveries(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Webhooks.ListDeliveries")
+	defer span.End()
+
+	txn := wh.relict.StartTransaction("list webhook deliveries", w, r)
+	defer txn.End()
+
+	webhookID := params["webhook_id"]
+	if _, err := storage.RetrieveWebhook(ctx, wh.db, webhookID); err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			return web.NewCodedError(err, http.StatusNotFound, codeWebhookNotFound)
+		default:
+			return errors.Wrapf(err, "retrieving webhook %q", webhookID)
+		}
+	}
+
+	deliveries, err := storage.ListDeliveries(ctx, wh.db, webhookID, maxDeliveries)
+	if err != nil {
+		return errors.Wrapf(err, "listing deliveries of %q", webhookID)
+	}
+
+	ids := make([]string, len(deliveries))
+	for i, d := range deliveries {
+		ids[i] = d.ID
+	}
+	attempts, err := storage.ListDeliveryAttempts(ctx, wh.db, ids)
+	if err != nil {
+		return errors.Wrapf(err, "listing delivery attempts of %q", webhookID)
+	}
+
+	byDelivery := make(map[string][]storage.WebhookAttempt)
+	for _, a := range attempts {
+		byDelivery[a.DeliveryID] = append(byDelivery[a.DeliveryID], a)
+	}
+
+	resp := ListWebhookDeliveriesResponse{Deliveries: make([]WebhookDelivery, len(deliveries))}
+	for i := range deliveries {
+		resp.Deliveries[i] = toWebhookDelivery(&deliveries[i], byDelivery[deliveries[i].ID])
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+// Redeliver queues the specified delivery again, also when it is dead or
+// was delivered already. It is attempted with the next deliveries.
+func (wh *Webhooks) Redeliver(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Webhooks.Redeliver")
+	defer span.End()
+
+	txn := wh.relict.StartTransaction("redeliver webhook", w, r)
+	defer txn.End()
+
+	v, ok := ctx.Value(web.KeyValues).(*web.Values)
+	if !ok {
+		return web.NewShutdownError("web value missing from context")
+	}
+
+	if err := storage.Redeliver(ctx, wh.db, params["webhook_id"], params["delivery_id"], v.Now); err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			return web.NewCodedError(err, http.StatusNotFound, codeDeliveryNotFound)
+		default:
+			return errors.Wrapf(err, "redelivering %q", params["delivery_id"])
+		}
+	}
+
+	return web.Respond(ctx, w, RedeliverWebhookResponse{}, http.StatusAccepted)
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// Enable enables the specified webhook again after it was disabled for
+// failing too often. Its pending deliveries are attempted again.
+func (wh *Webhooks) Enable(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Webhooks.Enable")
+	defer span.End()
+
+	txn := wh.relict.StartTransaction("enable webhook", w, r)
+	defer txn.End()
+
+	if err := storage.EnableWebhook(ctx, wh.db, params["webhook_id"]); err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			return web.NewCodedError(err, http.StatusNotFound, codeWebhookNotFound)
+		default:
+			return errors.Wrapf(err, "enabling webhook %q", params["webhook_id"])
+		}
+	}
+
+	return web.Respond(ctx, w, EnableWebhookResponse{}, http.StatusOK)
+}
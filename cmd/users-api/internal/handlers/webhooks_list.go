@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// List returns all webhooks, the oldest first.
+func (wh *Webhooks) List(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Webhooks.List")
+	defer span.End()
+
+	txn := wh.relict.StartTransaction("list webhooks", w, r)
+	defer txn.End()
+
+	hooks, err := storage.ListWebhooks(ctx, wh.db)
+	if err != nil {
+		return errors.Wrap(err, "listing webhooks")
+	}
+
+	resp := ListWebhooksResponse{Webhooks: make([]Webhook, len(hooks))}
+	for i := range hooks {
+		resp.Webhooks[i] = toWebhook(&hooks[i])
+	}
+
+	return web.Respond(ctx, w, resp, http.StatusOK)
+}
+
+// Retrieve returns the specified webhook.
+func (wh *Webhooks) Retrieve(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+	ctx, span := trace.StartSpan(ctx, "handlers.Webhooks.Retrieve")
+	defer span.End()
+
+	txn := wh.relict.StartTransaction("retrieve webhook", w, r)
+	defer txn.End()
+
+	h, err := storage.RetrieveWebhook(ctx, wh.db, params["webhook_id"])
+	if err != nil {
+		switch err {
+		case storage.ErrNotFound:
+			return web.NewCodedError(err, http.StatusNotFound, codeWebhookNotFound)
+		default:
+			return errors.Wrapf(err, "retrieving webhook %q", params["webhook_id"])
+		}
+	}
+
+	return web.Respond(ctx, w, toWebhook(h), http.StatusOK)
+}
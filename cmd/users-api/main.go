@@ -2,12 +2,14 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/rsa"
 	"expvar"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"syscall"
@@ -23,8 +25,25 @@ import (
 	"go.opencensus.io/trace"
 
 	"github.com/igomonov88/users/cmd/users-api/internal/handlers"
+	"github.com/igomonov88/users/internal/avatar"
+	"github.com/igomonov88/users/internal/email"
+	"github.com/igomonov88/users/internal/federation"
+	"github.com/igomonov88/users/internal/lockout"
+	"github.com/igomonov88/users/internal/mfa"
+	"github.com/igomonov88/users/internal/mid"
+	"github.com/igomonov88/users/internal/oauth"
+	"github.com/igomonov88/users/internal/password"
 	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/cache"
+	"github.com/igomonov88/users/internal/platform/content_uploader"
 	"github.com/igomonov88/users/internal/platform/database"
+	"github.com/igomonov88/users/internal/platform/encryption"
+	"github.com/igomonov88/users/internal/platform/graph"
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/platform/ratelimit"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/username"
+	"github.com/igomonov88/users/internal/webhook"
 )
 
 /*
@@ -45,27 +64,65 @@ symbols in profiles: https://github.com/golang/go/issues/23376 / https://github.
 var build = "develop"
 
 func main() {
-	if err := run(); err != nil {
-		log.Println("error :", err)
-		os.Exit(1)
-	}
-}
 
-func run() error {
 	// =========================================================================
 	// Logging
 
-	log := log.New(os.Stdout, "USERS : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	log := logger.New(os.Stdout, logger.Info).With("service", "users-api")
+
+	if err := run(log); err != nil {
+		log.Error("main : error", "error", err, "error_chain", logger.Chain(err))
+		os.Exit(1)
+	}
+}
+
+func run(log *logger.Logger) error {
 
 	// =========================================================================
 	// Configuration
 	var cfg struct {
 		Web struct {
-			APIHost         string        `conf:"default:0.0.0.0:5000"`
-			DebugHost       string        `conf:"default:0.0.0.0:6000"`
-			ReadTimeout     time.Duration `conf:"default:5s"`
-			WriteTimeout    time.Duration `conf:"default:5s"`
-			ShutdownTimeout time.Duration `conf:"default:5s"`
+			APIHost          string        `conf:"default:0.0.0.0:5000"`
+			DebugHost        string        `conf:"default:0.0.0.0:6000"`
+			RPCHost          string        `conf:"default:0.0.0.0:7000"`
+			ReadTimeout      time.Duration `conf:"default:5s"`
+			WriteTimeout     time.Duration `conf:"default:5s"`
+			ShutdownTimeout  time.Duration `conf:"default:5s"`
+			TrustedProxies   []string
+			ValidateRequests bool `conf:"default:false"`
+
+			// The routes of v1 which have a successor in v2 are deprecated
+			// since the date and removed at the sunset date.
+			V1Deprecated string `conf:"default:2026-10-19"`
+			V1Sunset     string `conf:"default:2027-04-19"`
+		}
+		Log struct {
+			Level string `conf:"default:info"`
+		}
+		RateLimit struct {
+			Backend     string        `conf:"default:memory"`
+			TokenRate   int           `conf:"default:10"`
+			TokenBurst  int           `conf:"default:10"`
+			SignupRate  int           `conf:"default:5"`
+			SignupBurst int           `conf:"default:5"`
+			ExistRate   int           `conf:"default:60"`
+			ExistBurst  int           `conf:"default:20"`
+			WriteRate   int           `conf:"default:30"`
+			WriteBurst  int           `conf:"default:10"`
+			Period      time.Duration `conf:"default:1m"`
+		}
+		Webhook struct {
+			Timeout      time.Duration `conf:"default:10s"`
+			MaxAttempts  int           `conf:"default:8"`
+			MinBackoff   time.Duration `conf:"default:30s"`
+			MaxBackoff   time.Duration `conf:"default:6h"`
+			DisableAfter int           `conf:"default:50"`
+			BatchSize    int           `conf:"default:50"`
+			PollInterval time.Duration `conf:"default:5s"`
+		}
+		GraphQL struct {
+			MaxDepth      int `conf:"default:10"`
+			MaxComplexity int `conf:"default:1000"`
 		}
 		DB struct {
 			User       string `conf:"default:postgres"`
@@ -78,6 +135,65 @@ func run() error {
 			KeyID          string `conf:"default:1"`
 			PrivateKeyFile string `conf:"default:/app/private.pem"`
 			Algorithm      string `conf:"default:RS256"`
+			EncryptionKey  string `conf:"noprint"`
+		}
+		MFA struct {
+			Issuer            string        `conf:"default:users"`
+			RequireForAdmin   bool          `conf:"default:true"`
+			ChallengeTTL      time.Duration `conf:"default:5m"`
+			ChallengeFailures int           `conf:"default:5"`
+			RecoveryCodes     int           `conf:"default:10"`
+		}
+		OAuth struct {
+			Issuer     string        `conf:"default:http://localhost:5000"`
+			AccessTTL  time.Duration `conf:"default:1h"`
+			CodeTTL    time.Duration `conf:"default:1m"`
+			RefreshTTL time.Duration `conf:"default:720h"`
+		}
+		OIDC struct {
+			ProvidersFile string
+			StateTTL      time.Duration `conf:"default:10m"`
+			Timeout       time.Duration `conf:"default:10s"`
+		}
+		Blob struct {
+			Backend         string `conf:"default:s3"`
+			Dir             string `conf:"default:/tmp/users-blobs"`
+			Region          string `conf:"default:eu-central-1"`
+			Bucket          string `conf:"default:users-avatars"`
+			Endpoint        string
+			PathStyle       bool
+			AccessKeyID     string
+			SecretAccessKey string `conf:"noprint"`
+			BaseURL         string
+		}
+		Avatar struct {
+			MaxSize           int64         `conf:"default:5242880"`
+			MinDimension      int           `conf:"default:64"`
+			MaxDimension      int           `conf:"default:4096"`
+			Sizes             []int         `conf:"default:64;128;256"`
+			UploadTTL         time.Duration `conf:"default:5m"`
+			JanitorInterval   time.Duration `conf:"default:10m"`
+			ReconcileInterval time.Duration
+			ReconcileGrace    time.Duration `conf:"default:24h"`
+		}
+		UserName struct {
+			MinLength     int      `conf:"default:3"`
+			MaxLength     int      `conf:"default:32"`
+			Allowed       string   `conf:"default:\\p{L}\\p{N}_.-"`
+			Reserved      []string `conf:"default:admin;administrator;root;system;support;help;security;staff;moderator;official;api;www;mail;null;undefined"`
+			ProfanityFile string
+			DenylistFile  string
+		}
+		Password struct {
+			MinLength    int     `conf:"default:8"`
+			MinEntropy   float64 `conf:"default:30"`
+			BreachedFile string
+		}
+		Email struct {
+			Allow          []string
+			Deny           []string
+			DisposableFile string
+			InviteOnly     bool
 		}
 		Zipkin struct {
 			LocalEndpoint string  `conf:"default:0.0.0.0:3000"`
@@ -85,6 +201,18 @@ func run() error {
 			ServiceName   string  `conf:"default:users-api"`
 			Probability   float64 `conf:"default:0.05"`
 		}
+		Lockout struct {
+			Window             time.Duration `conf:"default:15m"`
+			DelayThreshold     int           `conf:"default:3"`
+			LockoutThreshold   int           `conf:"default:10"`
+			IPDelayThreshold   int           `conf:"default:20"`
+			IPLockoutThreshold int           `conf:"default:100"`
+			BaseDelay          time.Duration `conf:"default:1s"`
+			MaxDelay           time.Duration `conf:"default:1m"`
+			Duration           time.Duration `conf:"default:15m"`
+			AttemptTimeout     time.Duration `conf:"default:10s"`
+			CacheSize          int           `conf:"default:10000"`
+		}
 		Relic struct {
 			AppName    string `conf:"default:users"`
 			LicenseKey string `conf:"default:eu01xxd79c2cf8960df91cbb1d93c5a71f8dNRAL"`
@@ -103,24 +231,30 @@ func run() error {
 		return errors.Wrap(err, "parsing config")
 	}
 
+	level, err := logger.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		return errors.Wrap(err, "parsing log level")
+	}
+	log.SetLevel(level)
+
 	// =========================================================================
 	// App Starting
 
 	// Print the build version for our logs. Also expose it under /debug/vars.
 	expvar.NewString("build").Set(build)
-	log.Printf("main : Started : Application initializing : version %q", build)
-	defer log.Println("main : Completed")
+	log.Info("main : Started : Application initializing", "version", build)
+	defer log.Info("main : Completed")
 
 	out, err := conf.String(&cfg)
 	if err != nil {
 		return errors.Wrap(err, "generating config for output")
 	}
-	log.Printf("main : Config :\n%v\n", out)
+	log.Info("main : Config", "config", out)
 
 	// =========================================================================
 	// Initialize authentication support
 
-	log.Println("main : Started : Initializing authentication support")
+	log.Info("main : Started : Initializing authentication support")
 
 	keyContents, err := ioutil.ReadFile(cfg.Auth.PrivateKeyFile)
 	if err != nil {
@@ -141,7 +275,7 @@ func run() error {
 	// =========================================================================
 	// Start Database
 
-	log.Println("main : Started : Initializing database support")
+	log.Info("main : Started : Initializing database support")
 
 	db, err := database.Open(database.Config{
 		User:       cfg.DB.User,
@@ -154,14 +288,296 @@ func run() error {
 		return errors.Wrap(err, "connecting to db")
 	}
 	defer func() {
-		log.Printf("main : Database Stopping : %s", cfg.DB.Host)
+		log.Info("main : Database Stopping", "host", cfg.DB.Host)
 		db.Close()
 	}()
 
+	// =========================================================================
+	// Start Login Lockout Support
+
+	log.Info("main : Started : Initializing login lockout support")
+
+	lockoutCache, err := cache.New(cache.Config{
+		DefaultDuration: cfg.Lockout.Duration,
+		Size:            cfg.Lockout.CacheSize,
+	})
+	if err != nil {
+		return errors.Wrap(err, "constructing lockout cache")
+	}
+
+	guard := lockout.New(lockout.Config{
+		Window:             cfg.Lockout.Window,
+		DelayThreshold:     cfg.Lockout.DelayThreshold,
+		LockoutThreshold:   cfg.Lockout.LockoutThreshold,
+		IPDelayThreshold:   cfg.Lockout.IPDelayThreshold,
+		IPLockoutThreshold: cfg.Lockout.IPLockoutThreshold,
+		BaseDelay:          cfg.Lockout.BaseDelay,
+		MaxDelay:           cfg.Lockout.MaxDelay,
+		LockoutDuration:    cfg.Lockout.Duration,
+		AttemptTimeout:     cfg.Lockout.AttemptTimeout,
+	}, db, lockoutCache, lockout.LogNotifier{Log: log})
+
+	// =========================================================================
+	// Start Two-Factor Authentication Support
+
+	log.Info("main : Started : Initializing two-factor authentication support")
+
+	// Secrets are stored sealed with this key, so there is no default to
+	// fall back on.
+	if cfg.Auth.EncryptionKey == "" {
+		return errors.New("encryption key is required, set USERS_AUTH_ENCRYPTION_KEY")
+	}
+
+	enc, err := encryption.New(cfg.Auth.EncryptionKey)
+	if err != nil {
+		return errors.Wrap(err, "constructing encrypter")
+	}
+
+	m := mfa.New(mfa.Config{
+		Issuer:            cfg.MFA.Issuer,
+		RequireForAdmin:   cfg.MFA.RequireForAdmin,
+		ChallengeTTL:      cfg.MFA.ChallengeTTL,
+		ChallengeFailures: cfg.MFA.ChallengeFailures,
+		RecoveryCodes:     cfg.MFA.RecoveryCodes,
+	}, db, enc)
+
+	// =========================================================================
+	// Start OAuth Support
+
+	log.Info("main : Started : Initializing oauth support")
+
+	provider := oauth.New(oauth.Config{
+		Issuer:     cfg.OAuth.Issuer,
+		AccessTTL:  cfg.OAuth.AccessTTL,
+		CodeTTL:    cfg.OAuth.CodeTTL,
+		RefreshTTL: cfg.OAuth.RefreshTTL,
+	}, db, authenticator)
+
+	// =========================================================================
+	// Start Email Policy Support
+
+	log.Info("main : Started : Initializing email policy support")
+
+	emails, err := email.New(email.Config{
+		Allow:          cfg.Email.Allow,
+		Deny:           cfg.Email.Deny,
+		DisposableFile: cfg.Email.DisposableFile,
+		InviteOnly:     cfg.Email.InviteOnly,
+	})
+	if err != nil {
+		return errors.Wrap(err, "constructing email policy")
+	}
+
+	// =========================================================================
+	// Start User Name Policy Support
+
+	log.Info("main : Started : Initializing user name policy support")
+
+	names, err := username.New(username.Config{
+		MinLength:     cfg.UserName.MinLength,
+		MaxLength:     cfg.UserName.MaxLength,
+		Allowed:       cfg.UserName.Allowed,
+		Reserved:      cfg.UserName.Reserved,
+		ProfanityFile: cfg.UserName.ProfanityFile,
+		DenylistFile:  cfg.UserName.DenylistFile,
+	})
+	if err != nil {
+		return errors.Wrap(err, "constructing user name policy")
+	}
+
+	// =========================================================================
+	// Start Federated Login Support
+
+	log.Info("main : Started : Initializing federated login support")
+
+	var providers []federation.ProviderConfig
+	if cfg.OIDC.ProvidersFile != "" {
+		if providers, err = federation.LoadProviders(cfg.OIDC.ProvidersFile); err != nil {
+			return errors.Wrap(err, "loading identity providers")
+		}
+	}
+
+	fed := federation.New(federation.Config{
+		StateTTL: cfg.OIDC.StateTTL,
+		Emails:   emails,
+		Names:    names,
+	}, db, enc, &http.Client{Timeout: cfg.OIDC.Timeout, Transport: &web.Transport{}}, providers)
+
+	// =========================================================================
+	// Start Blob Storage Support
+
+	log.Info("main : Started : Initializing blob storage support")
+
+	// The memory and filesystem stores are served by the API itself. Their
+	// URLs are signed with a key which only lives as long as the process.
+	var blobs content_uploader.BlobStore
+	var signer *content_uploader.Signer
+	switch cfg.Blob.Backend {
+	case "s3":
+		blobs, err = content_uploader.New(content_uploader.Config{
+			Region:          cfg.Blob.Region,
+			Bucket:          cfg.Blob.Bucket,
+			Endpoint:        cfg.Blob.Endpoint,
+			PathStyle:       cfg.Blob.PathStyle,
+			AccessKeyID:     cfg.Blob.AccessKeyID,
+			SecretAccessKey: cfg.Blob.SecretAccessKey,
+			BaseURL:         cfg.Blob.BaseURL,
+			Transport:       &web.Transport{},
+		})
+		if err != nil {
+			return errors.Wrap(err, "constructing s3 blob store")
+		}
+	case "memory", "filesystem":
+		baseURL := cfg.Blob.BaseURL
+		if baseURL == "" {
+			baseURL = "http://localhost:5000/blobs"
+		}
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return errors.Wrap(err, "generating blob signing key")
+		}
+		signer = content_uploader.NewSigner(baseURL, secret)
+
+		if cfg.Blob.Backend == "memory" {
+			blobs = content_uploader.NewMemory(signer)
+			break
+		}
+		if blobs, err = content_uploader.NewFilesystem(cfg.Blob.Dir, signer); err != nil {
+			return errors.Wrap(err, "constructing filesystem blob store")
+		}
+	default:
+		return errors.Errorf("unknown blob backend %q", cfg.Blob.Backend)
+	}
+
+	// =========================================================================
+	// Start Avatar Support
+
+	log.Info("main : Started : Initializing avatar support")
+
+	avatars := avatar.New(avatar.Config{
+		MaxSize:      cfg.Avatar.MaxSize,
+		MinDimension: cfg.Avatar.MinDimension,
+		MaxDimension: cfg.Avatar.MaxDimension,
+		Sizes:        cfg.Avatar.Sizes,
+		UploadTTL:    cfg.Avatar.UploadTTL,
+	}, db, blobs, log)
+
+	// Direct uploads which were never completed are removed. Not concerned
+	// with stopping this on shutdown.
+	go func() {
+		for range time.Tick(cfg.Avatar.JanitorInterval) {
+			n, err := avatars.Clean(context.Background(), time.Now())
+			if err != nil {
+				log.Error("main : Avatar janitor", "error", err)
+			}
+			if n > 0 {
+				log.Info("main : Avatar janitor : removed uploads", "count", n)
+			}
+		}
+	}()
+
+	// Objects no user references are removed when reconciling is enabled.
+	// Not concerned with stopping this on shutdown.
+	if cfg.Avatar.ReconcileInterval > 0 {
+		go func() {
+			for range time.Tick(cfg.Avatar.ReconcileInterval) {
+				rep, err := avatars.Reconcile(context.Background(), time.Now(), cfg.Avatar.ReconcileGrace, false)
+				if err != nil {
+					log.Error("main : Avatar reconcile", "error", err)
+				}
+				if rep != nil && rep.Deleted > 0 {
+					log.Info("main : Avatar reconcile : removed objects", "deleted", rep.Deleted, "scanned", rep.Scanned)
+				}
+			}
+		}()
+	}
+
+	// =========================================================================
+	// Start Webhook Support
+
+	log.Info("main : Started : Initializing webhook support")
+
+	hooks := webhook.New(webhook.Config{
+		Timeout:      cfg.Webhook.Timeout,
+		MaxAttempts:  cfg.Webhook.MaxAttempts,
+		MinBackoff:   cfg.Webhook.MinBackoff,
+		MaxBackoff:   cfg.Webhook.MaxBackoff,
+		DisableAfter: cfg.Webhook.DisableAfter,
+		BatchSize:    cfg.Webhook.BatchSize,
+	}, db, enc, &http.Client{Transport: &web.Transport{}}, log)
+
+	// Deliveries which are due are attempted in batches until none is left.
+	// Not concerned with stopping this on shutdown.
+	go func() {
+		for range time.Tick(cfg.Webhook.PollInterval) {
+			for {
+				n, err := hooks.Deliver(context.Background(), time.Now())
+				if err != nil {
+					log.Error("main : Webhook delivery", "error", err)
+				}
+				if n < cfg.Webhook.BatchSize {
+					break
+				}
+			}
+		}
+	}()
+
+	// =========================================================================
+	// Start Password Policy Support
+
+	log.Info("main : Started : Initializing password policy support")
+
+	passwords, err := password.New(password.Config{
+		MinLength:    cfg.Password.MinLength,
+		MinEntropy:   cfg.Password.MinEntropy,
+		BreachedFile: cfg.Password.BreachedFile,
+	})
+	if err != nil {
+		return errors.Wrap(err, "constructing password policy")
+	}
+
+	// =========================================================================
+	// Start Rate Limiting Support
+
+	log.Info("main : Started : Initializing rate limiting support")
+
+	trusted, err := web.ParseCIDRs(cfg.Web.TrustedProxies)
+	if err != nil {
+		return errors.Wrap(err, "parsing trusted proxies")
+	}
+
+	var limiter ratelimit.Store
+	switch cfg.RateLimit.Backend {
+	case "memory":
+		limiter = ratelimit.NewMemory()
+	case "postgres":
+		pg := ratelimit.NewPostgres(db)
+		limiter = pg
+
+		// Buckets which were not touched for a day are full again, so they
+		// can be dropped. Not concerned with stopping this on shutdown.
+		go func() {
+			for range time.Tick(time.Hour) {
+				if err := pg.Prune(context.Background(), time.Now().Add(-24*time.Hour)); err != nil {
+					log.Error("main : Rate limit prune", "error", err)
+				}
+			}
+		}()
+	default:
+		return errors.Errorf("unknown rate limit backend %q", cfg.RateLimit.Backend)
+	}
+
+	limits := handlers.RateLimits{
+		Token:  ratelimit.Limit{Rate: cfg.RateLimit.TokenRate, Period: cfg.RateLimit.Period, Burst: cfg.RateLimit.TokenBurst},
+		Signup: ratelimit.Limit{Rate: cfg.RateLimit.SignupRate, Period: cfg.RateLimit.Period, Burst: cfg.RateLimit.SignupBurst},
+		Exist:  ratelimit.Limit{Rate: cfg.RateLimit.ExistRate, Period: cfg.RateLimit.Period, Burst: cfg.RateLimit.ExistBurst},
+		Write:  ratelimit.Limit{Rate: cfg.RateLimit.WriteRate, Period: cfg.RateLimit.Period, Burst: cfg.RateLimit.WriteBurst},
+	}
+
 	// =========================================================================
 	// Start Tracing Support
 
-	log.Println("main : Started : Initializing zipkin tracing support")
+	log.Info("main : Started : Initializing zipkin tracing support")
 
 	localEndpoint, err := openzipkin.NewEndpoint(cfg.Zipkin.ServiceName, cfg.Zipkin.LocalEndpoint)
 	if err != nil {
@@ -177,7 +593,7 @@ func run() error {
 	})
 
 	defer func() {
-		log.Printf("main : Tracing Stopping : %s", cfg.Zipkin.LocalEndpoint)
+		log.Info("main : Tracing Stopping", "endpoint", cfg.Zipkin.LocalEndpoint)
 		reporter.Close()
 	}()
 
@@ -186,14 +602,17 @@ func run() error {
 	//
 	// /debug/pprof - Added to the default mux by importing the net/http/pprof package.
 	// /debug/vars - Added to the default mux by importing the expvar package.
+	// /debug/log/level - Reports and changes the level of the logs.
 	//
 	// Not concerned with shutting this down when the application is shutdown.
 
-	log.Println("main : Started : Initializing debugging support")
+	log.Info("main : Started : Initializing debugging support")
+
+	http.Handle("/debug/log/level", log.LevelHandler())
 
 	go func() {
-		log.Printf("main : Debug Listening %s", cfg.Web.DebugHost)
-		log.Printf("main : Debug Listener closed : %v", http.ListenAndServe(cfg.Web.DebugHost, http.DefaultServeMux))
+		log.Info("main : Debug Listening", "host", cfg.Web.DebugHost)
+		log.Info("main : Debug Listener closed", "error", http.ListenAndServe(cfg.Web.DebugHost, http.DefaultServeMux))
 	}()
 
 	// =========================================================================
@@ -206,30 +625,73 @@ func run() error {
 	// =========================================================================
 	// Start API Service
 
-	log.Println("main : Started : Initializing API support")
+	log.Info("main : Started : Initializing API support")
 
 	// Make a channel to listen for an interrupt or terminate signal from the OS.
 	// Use a buffered channel because the signal package requires it.
 	shutdown := make(chan os.Signal, 1)
 	signal.Notify(shutdown, os.Interrupt, syscall.SIGTERM)
 
+	var v1 mid.Deprecation
+	if v1.Since, err = time.Parse("2006-01-02", cfg.Web.V1Deprecated); err != nil {
+		return errors.Wrap(err, "parsing deprecation date of v1")
+	}
+	if v1.Sunset, err = time.Parse("2006-01-02", cfg.Web.V1Sunset); err != nil {
+		return errors.Wrap(err, "parsing sunset date of v1")
+	}
+
+	graphQL := graph.Limits{MaxDepth: cfg.GraphQL.MaxDepth, MaxComplexity: cfg.GraphQL.MaxComplexity}
+
+	handler := handlers.API(build, shutdown, log, db, rel, authenticator, guard, limiter, limits, trusted, m, provider, fed, avatars, names, passwords, emails, hooks, v1, graphQL, cfg.Web.ValidateRequests)
+	if signer != nil {
+		u, err := url.Parse(signer.URL(""))
+		if err != nil {
+			return errors.Wrap(err, "parsing blob base url")
+		}
+		mux := http.NewServeMux()
+		mux.Handle(u.Path, content_uploader.Handler(blobs, signer, cfg.Avatar.MaxSize))
+		mux.Handle("/", handler)
+		handler = mux
+	}
+
 	api := http.Server{
 		Addr:         cfg.Web.APIHost,
-		Handler:      handlers.API(build, shutdown, log, db, rel, authenticator),
+		Handler:      handler,
 		ReadTimeout:  cfg.Web.ReadTimeout,
 		WriteTimeout: cfg.Web.WriteTimeout,
+		ErrorLog:     log.Std(logger.Error),
 	}
 
-	// Make a channel to listen for errors coming from the listener. Use a
-	// buffered channel so the goroutine can exit if we don't collect this error.
-	serverErrors := make(chan error, 1)
+	// Make a channel to listen for errors coming from the listeners. Use a
+	// buffered channel so the goroutines can exit if we don't collect these
+	// errors.
+	serverErrors := make(chan error, 2)
 
 	// Start the service listening for requests.
 	go func() {
-		log.Printf("main : API listening on %s", api.Addr)
+		log.Info("main : API listening", "host", api.Addr)
 		serverErrors <- api.ListenAndServe()
 	}()
 
+	// =========================================================================
+	// Start RPC Service
+
+	log.Info("main : Started : Initializing RPC support")
+
+	rpcServer := handlers.RPC(shutdown, log, db, rel, authenticator, guard, limiter, limits, trusted, m, avatars, names, passwords, emails, hooks)
+
+	lis, err := net.Listen("tcp", cfg.Web.RPCHost)
+	if err != nil {
+		return errors.Wrap(err, "listening for rpc")
+	}
+
+	// Start the service listening for calls. Errors are sent on the same
+	// channel as the ones of the API, as either stops the service.
+	go func() {
+		log.Info("main : RPC listening", "host", cfg.Web.RPCHost)
+		serverErrors <- rpcServer.Serve(lis)
+	}()
+
 	// =========================================================================
 	// Shutdown
 
@@ -239,19 +701,36 @@ func run() error {
 		return errors.Wrap(err, "server error")
 
 	case sig := <-shutdown:
-		log.Printf("main : %v : Start shutdown", sig)
+		log.Info("main : Start shutdown", "signal", sig.String())
 
 		// Give outstanding requests a deadline for completion.
 		ctx, cancel := context.WithTimeout(context.Background(), cfg.Web.ShutdownTimeout)
 		defer cancel()
 
-		// Asking listener to shutdown and load shed.
+		// Asking listeners to shutdown and load shed. Both servers share the
+		// deadline.
+		rpcErrors := make(chan error, 1)
+		go func() {
+			rpcErrors <- rpcServer.Shutdown(ctx)
+		}()
+
 		err := api.Shutdown(ctx)
 		if err != nil {
-			log.Printf("main : Graceful shutdown did not complete in %v : %v", cfg.Web.ShutdownTimeout, err)
+			log.Error("main : Graceful shutdown did not complete", "timeout", cfg.Web.ShutdownTimeout, "error", err)
 			err = api.Close()
 		}
 
+		// The rpc server cancels the calls still pending itself.
+		if rpcErr := <-rpcErrors; rpcErr != nil {
+			log.Error("main : Graceful RPC shutdown did not complete", "timeout", cfg.Web.ShutdownTimeout, "error", rpcErr)
+			if err == nil {
+				err = rpcErr
+			}
+		}
+
+		// Let the objects of replaced avatars be deleted.
+		avatars.Wait()
+
 		// Log the status of this shutdown.
 		switch {
 		case sig == syscall.SIGSTOP:
@@ -0,0 +1,87 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igomonov88/users/cmd/users-api/internal/handlers"
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/storage"
+	"github.com/igomonov88/users/internal/tests"
+)
+
+// TestAPIKeys authenticates requests with API keys from their creation to
+// their revocation.
+func TestAPIKeys(t *testing.T) {
+	test := tests.NewIntegration(t)
+	defer test.Teardown()
+	ctx := tests.Context()
+
+	api := newAPI(t, test, nil)
+
+	t.Log("Given the need to authenticate with API keys.")
+	{
+		usr, err := storage.Create(ctx, test.DB, "gopher@example.com", "gopher", "", "qwerty")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create a user : %s.", tests.Failed, err)
+		}
+		tkn := login(t, api, usr.Email, "qwerty")
+
+		body := handlers.CreateAPIKeyRequest{Name: "reports", Scopes: []string{auth.ScopeRead}}
+		w := request(t, api, http.MethodPost, "/v1/users/api_keys", bearer(tkn), body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("\t%s\tShould be able to create an API key : got %d %s.", tests.Failed, w.Code, w.Body)
+		}
+		var created handlers.CreateAPIKeyResponse
+		if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("\t%s\tShould be able to create an API key.", tests.Success)
+
+		key := http.Header{"X-Api-Key": {created.Key}}
+		if w := request(t, api, http.MethodGet, "/v1/users/"+usr.ID, key, nil); w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould authenticate with the API key : got %d %s.", tests.Failed, w.Code, w.Body)
+		}
+		keys, err := storage.ListAPIKeys(ctx, test.DB, usr.ID)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to list the API keys : %s.", tests.Failed, err)
+		}
+		if len(keys) != 1 || !keys[0].LastUsedAt.Valid || keys[0].LastUsedIP == "" {
+			t.Fatalf("\t%s\tShould record the use of the API key : got %+v.", tests.Failed, keys)
+		}
+		t.Logf("\t%s\tShould authenticate with the API key and record its use.", tests.Success)
+
+		forged := http.Header{"X-Api-Key": {created.Key[:strings.LastIndex(created.Key, "_")+1] + strings.Repeat("A", 32)}}
+		if w := request(t, api, http.MethodGet, "/v1/users/"+usr.ID, forged, nil); w.Code != http.StatusUnauthorized {
+			t.Fatalf("\t%s\tShould reject a key with the wrong secret : got %d.", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould reject a key with the wrong secret.", tests.Success)
+
+		w = request(t, api, http.MethodPost, "/v1/users/update", key, handlers.UpdateUserRequest{})
+		if w.Code != http.StatusForbidden || problemCode(t, w) != "auth.forbidden" {
+			t.Fatalf("\t%s\tShould reject a read-only key on a write : got %d %s.", tests.Failed, w.Code, w.Body)
+		}
+		t.Logf("\t%s\tShould reject a read-only key on a write.", tests.Success)
+
+		now := time.Now()
+		_, expired, err := storage.CreateAPIKey(ctx, test.DB, usr.ID, "old", []string{auth.ScopeRead}, now.Add(-2*time.Hour), now.Add(-time.Hour))
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create an expired API key : %s.", tests.Failed, err)
+		}
+		if w := request(t, api, http.MethodGet, "/v1/users/"+usr.ID, http.Header{"X-Api-Key": {expired}}, nil); w.Code != http.StatusUnauthorized {
+			t.Fatalf("\t%s\tShould reject an expired key : got %d.", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould reject an expired key.", tests.Success)
+
+		if w := request(t, api, http.MethodDelete, "/v1/users/api_keys/"+created.APIKey.ID, bearer(tkn), nil); w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould be able to revoke the API key : got %d %s.", tests.Failed, w.Code, w.Body)
+		}
+		if w := request(t, api, http.MethodGet, "/v1/users/"+usr.ID, key, nil); w.Code != http.StatusUnauthorized {
+			t.Fatalf("\t%s\tShould reject a revoked key : got %d.", tests.Failed, w.Code)
+		}
+		t.Logf("\t%s\tShould reject a revoked key.", tests.Success)
+	}
+}
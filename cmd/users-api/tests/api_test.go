@@ -0,0 +1,246 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	newrelic "github.com/newrelic/go-agent"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/igomonov88/users/cmd/users-api/internal/handlers"
+	"github.com/igomonov88/users/internal/lockout"
+	"github.com/igomonov88/users/internal/mfa"
+	"github.com/igomonov88/users/internal/mid"
+	"github.com/igomonov88/users/internal/oauth"
+	"github.com/igomonov88/users/internal/platform/cache"
+	"github.com/igomonov88/users/internal/platform/encryption"
+	"github.com/igomonov88/users/internal/platform/graph"
+	"github.com/igomonov88/users/internal/platform/ratelimit"
+	"github.com/igomonov88/users/internal/platform/totp"
+	"github.com/igomonov88/users/internal/tests"
+	"github.com/igomonov88/users/internal/username"
+	"github.com/igomonov88/users/internal/webhook"
+)
+
+// deps are what the API and the gRPC server are constructed with, next to
+// the database of the test. Limits are high enough to never get in the way.
+type deps struct {
+	relic  newrelic.Application
+	guard  *lockout.Guard
+	m      *mfa.MFA
+	names  *username.Policy
+	hooks  *webhook.Webhooks
+	limits handlers.RateLimits
+}
+
+// newDeps constructs the dependencies on the database of the test.
+func newDeps(t *testing.T, test *tests.Test) deps {
+	t.Helper()
+
+	cfg := newrelic.NewConfig("users-test", "")
+	cfg.Enabled = false
+	relic, err := newrelic.NewApplication(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := cache.New(cache.Config{DefaultDuration: time.Minute, Size: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	guard := lockout.New(lockout.Config{
+		Window:             time.Minute,
+		DelayThreshold:     100,
+		LockoutThreshold:   100,
+		IPDelayThreshold:   100,
+		IPLockoutThreshold: 100,
+		BaseDelay:          time.Second,
+		MaxDelay:           time.Second,
+		LockoutDuration:    time.Minute,
+		AttemptTimeout:     time.Minute,
+	}, test.DB, c, lockout.LogNotifier{Log: test.Log})
+
+	enc, err := encryption.New("ZGV2ZWxvcG1lbnQta2V5LWRvLW5vdC11c2UtaW4tcHI=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names, err := username.New(username.Config{MinLength: 3, MaxLength: 32, Allowed: `\p{L}\p{N}_.-`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limit := ratelimit.Limit{Rate: 100, Period: time.Minute, Burst: 100}
+
+	d := deps{
+		relic:  relic,
+		guard:  guard,
+		m:      mfa.New(mfa.Config{Issuer: "users", RequireForAdmin: true, ChallengeTTL: time.Minute, ChallengeFailures: 3, RecoveryCodes: 1}, test.DB, enc),
+		names:  names,
+		hooks:  webhook.New(webhook.Config{Timeout: time.Second}, test.DB, enc, http.DefaultClient, test.Log),
+		limits: handlers.RateLimits{Token: limit, Signup: limit, Exist: limit, Write: limit},
+	}
+
+	return d
+}
+
+// newAPI constructs the API on the database of the test. A provider for a
+// local issuer is used when none is given.
+func newAPI(t *testing.T, test *tests.Test, provider *oauth.Provider) http.Handler {
+	t.Helper()
+
+	d := newDeps(t, test)
+
+	if provider == nil {
+		provider = oauth.New(oauth.Config{
+			Issuer:     "http://localhost",
+			AccessTTL:  time.Hour,
+			CodeTTL:    time.Minute,
+			RefreshTTL: time.Hour,
+		}, test.DB, test.Authenticator)
+	}
+
+	return handlers.API("test", make(chan os.Signal, 1), test.Log, test.DB, d.relic, test.Authenticator, d.guard,
+		ratelimit.NewMemory(), d.limits, nil, d.m, provider, nil, nil, d.names, nil, nil, d.hooks, mid.Deprecation{}, graph.Limits{}, false)
+}
+
+// newRPC serves the gRPC server on the database of the test on an in-memory
+// listener and connects to it.
+func newRPC(t *testing.T, test *tests.Test) *grpc.ClientConn {
+	t.Helper()
+
+	d := newDeps(t, test)
+	srv := handlers.RPC(make(chan os.Signal, 1), test.Log, test.DB, d.relic, test.Authenticator, d.guard,
+		ratelimit.NewMemory(), d.limits, nil, d.m, nil, d.names, nil, nil, d.hooks)
+
+	lis := bufconn.Listen(1 << 20)
+	go srv.Serve(lis)
+
+	dialer := func(string, time.Duration) (net.Conn, error) {
+		return lis.Dial()
+	}
+	conn, err := grpc.Dial("bufnet", grpc.WithDialer(dialer), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+// request sends a request to the API. The body is encoded as JSON unless
+// it is nil, the header is set on the request. Bodies are sent as JSON
+// unless the header sets another content type.
+func request(t *testing.T, api http.Handler, method, path string, header http.Header, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var b bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&b).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := httptest.NewRequest(method, path, &b)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	if body != nil && r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/json")
+	}
+
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+
+	return w
+}
+
+// login returns a token for the email and password of a user.
+func login(t *testing.T, api http.Handler, email, password string) string {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+	r.SetBasicAuth(email, password)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+
+	var tkn handlers.TokenResponse
+	if w.Code != http.StatusOK {
+		t.Fatalf("\t%s\tShould be able to log in : got %d %s.", tests.Failed, w.Code, w.Body)
+	}
+	if err := json.NewDecoder(w.Body).Decode(&tkn); err != nil {
+		t.Fatal(err)
+	}
+
+	return tkn.Token
+}
+
+// challenge logs in a user with two-factor authentication enabled and
+// returns the challenge to answer.
+func challenge(t *testing.T, api http.Handler, email, password string) string {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/users/token", nil)
+	r.SetBasicAuth(email, password)
+	w := httptest.NewRecorder()
+	api.ServeHTTP(w, r)
+
+	var tkn handlers.TokenResponse
+	if err := json.NewDecoder(w.Body).Decode(&tkn); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || !tkn.MFARequired || tkn.Challenge == "" {
+		t.Fatalf("\t%s\tShould get a challenge for the password : got %d %+v.", tests.Failed, w.Code, tkn)
+	}
+
+	return tkn.Challenge
+}
+
+// enroll enables two-factor authentication for the user of the token. It
+// returns the recovery codes.
+func enroll(t *testing.T, api http.Handler, token string) []string {
+	t.Helper()
+
+	w := request(t, api, http.MethodPost, "/v1/users/mfa/totp", bearer(token), nil)
+	var enrollment handlers.EnrollTOTPResponse
+	if err := json.NewDecoder(w.Body).Decode(&enrollment); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("\t%s\tShould be able to enroll : got %d %v.", tests.Failed, w.Code, err)
+	}
+
+	code, err := totp.Code(enrollment.Secret, totp.Step(time.Now()), 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w = request(t, api, http.MethodPost, "/v1/users/mfa/totp/confirm", bearer(token), handlers.TOTPCodeRequest{Code: code})
+	var recovery handlers.RecoveryCodesResponse
+	if err := json.NewDecoder(w.Body).Decode(&recovery); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("\t%s\tShould be able to confirm the enrollment : got %d %v.", tests.Failed, w.Code, err)
+	}
+
+	return recovery.RecoveryCodes
+}
+
+// bearer returns the header authenticating requests with the token.
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+// problemCode returns the code of the problem details of an error response.
+func problemCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var p struct {
+		Code string `json:"code"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &p); err != nil {
+		t.Fatalf("\t%s\tShould respond with problem details : %s.", tests.Failed, err)
+	}
+
+	return p.Code
+}
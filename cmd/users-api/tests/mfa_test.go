@@ -0,0 +1,75 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/igomonov88/users/cmd/users-api/internal/handlers"
+	"github.com/igomonov88/users/internal/storage"
+	"github.com/igomonov88/users/internal/tests"
+)
+
+// TestTokenOTP logs in with two-factor authentication and checks wrong
+// codes can't be guessed on without limit.
+func TestTokenOTP(t *testing.T) {
+	test := tests.NewIntegration(t)
+	defer test.Teardown()
+	ctx := tests.Context()
+
+	api := newAPI(t, test, nil)
+
+	// failures returns the number of failures recorded for the email.
+	failures := func(email string) int {
+		t.Helper()
+		var n int
+		if err := test.DB.GetContext(ctx, &n, `SELECT COUNT(*) FROM login_failures WHERE email = $1 AND NOT pending`, email); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	t.Log("Given the need to log in with a second factor.")
+	{
+		usr, err := storage.Create(ctx, test.DB, "gopher@example.com", "gopher", "", "qwerty")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create a user : %s.", tests.Failed, err)
+		}
+		tkn := login(t, api, usr.Email, "qwerty")
+
+		codes := enroll(t, api, tkn)
+		if len(codes) != 1 {
+			t.Fatalf("\t%s\tShould get the recovery codes : got %v.", tests.Failed, codes)
+		}
+		t.Logf("\t%s\tShould be able to enable two-factor authentication.", tests.Success)
+
+		id := challenge(t, api, usr.Email, "qwerty")
+		for i := 0; i < 3; i++ {
+			w := request(t, api, http.MethodPost, "/v1/users/token/otp", nil, handlers.TokenOTPRequest{Challenge: id, Code: "000000"})
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tShould reject a wrong code : got %d %s.", tests.Failed, w.Code, w.Body)
+			}
+		}
+		w := request(t, api, http.MethodPost, "/v1/users/token/otp", nil, handlers.TokenOTPRequest{Challenge: id, RecoveryCode: codes[0]})
+		if w.Code != http.StatusUnauthorized {
+			t.Fatalf("\t%s\tShould remove a challenge answered wrong too often : got %d %s.", tests.Failed, w.Code, w.Body)
+		}
+		t.Logf("\t%s\tShould remove a challenge answered wrong too often.", tests.Success)
+
+		id = challenge(t, api, usr.Email, "qwerty")
+		if n := failures(usr.Email); n != 3 {
+			t.Fatalf("\t%s\tShould keep the failures when only the password is given : got %d.", tests.Failed, n)
+		}
+		t.Logf("\t%s\tShould keep the failures when only the password is given.", tests.Success)
+
+		w = request(t, api, http.MethodPost, "/v1/users/token/otp", nil, handlers.TokenOTPRequest{Challenge: id, RecoveryCode: codes[0]})
+		var otp handlers.TokenResponse
+		if err := json.NewDecoder(w.Body).Decode(&otp); err != nil || w.Code != http.StatusOK || otp.Token == "" {
+			t.Fatalf("\t%s\tShould log in with the second factor : got %d %+v.", tests.Failed, w.Code, otp)
+		}
+		if n := failures(usr.Email); n != 0 {
+			t.Fatalf("\t%s\tShould reset the failures once the second factor passed : got %d.", tests.Failed, n)
+		}
+		t.Logf("\t%s\tShould reset the failures once the second factor passed.", tests.Success)
+	}
+}
@@ -0,0 +1,240 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igomonov88/users/internal/oauth"
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/storage"
+	"github.com/igomonov88/users/internal/tests"
+)
+
+// TestOAuth runs an authorization code flow with PKCE end to end.
+func TestOAuth(t *testing.T) {
+	test := tests.NewIntegration(t)
+	defer test.Teardown()
+	ctx := tests.Context()
+
+	// The issuer is only known once the server listens.
+	var api http.Handler
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	provider := oauth.New(oauth.Config{
+		Issuer:     srv.URL,
+		AccessTTL:  time.Hour,
+		CodeTTL:    time.Minute,
+		RefreshTTL: time.Hour,
+	}, test.DB, test.Authenticator)
+
+	api = newAPI(t, test, provider)
+
+	// Don't follow the redirects back to the client.
+	client := srv.Client()
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	t.Log("Given the need to sign users in to other applications.")
+	{
+		usr, err := storage.Create(ctx, test.DB, "gopher@example.com", "gopher", "", "qwerty")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create a user : %s.", tests.Failed, err)
+		}
+
+		// Clients must not get the roles of the user, so the user is an
+		// admin.
+		const q = `UPDATE users SET roles = '{ADMIN,USER}' WHERE user_id = $1;`
+		if _, err := test.DB.ExecContext(ctx, q, usr.ID); err != nil {
+			t.Fatalf("\t%s\tShould be able to make the user an admin : %s.", tests.Failed, err)
+		}
+
+		redirect := "https://app.example.com/callback"
+		oc, _, err := provider.RegisterClient(ctx, oauth.NewClient{
+			Name:         "app",
+			RedirectURIs: []string{redirect},
+			GrantTypes:   []string{oauth.GrantAuthorizationCode, oauth.GrantRefreshToken},
+			Scopes:       []string{"openid", "email", "users:read"},
+		}, time.Now())
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to register a client : %s.", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould be able to register a public client.", tests.Success)
+
+		var d oauth.Discovery
+		getJSON(t, client, srv.URL+"/.well-known/openid-configuration", &d)
+		if d.Issuer != srv.URL || d.TokenEndpoint != srv.URL+oauth.PathToken {
+			t.Fatalf("\t%s\tShould advertise the endpoints : %+v.", tests.Failed, d)
+		}
+		t.Logf("\t%s\tShould advertise the endpoints.", tests.Success)
+
+		verifier := strings.Repeat("verifier", 6)
+		ar := oauth.AuthorizeRequest{
+			ResponseType:        "code",
+			ClientID:            oc.ID,
+			RedirectURI:         redirect,
+			Scope:               "openid email",
+			State:               "state",
+			Nonce:               "nonce",
+			CodeChallenge:       oauth.ChallengeS256(verifier),
+			CodeChallengeMethod: oauth.S256,
+		}
+
+		resp, err := client.Get(srv.URL + oauth.PathAuthorize + "?" + ar.Values().Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("\t%s\tShould show the login form : got %d.", tests.Failed, resp.StatusCode)
+		}
+		t.Logf("\t%s\tShould show the login form.", tests.Success)
+
+		form := ar.Values()
+		form.Set("email", usr.Email)
+		form.Set("password", "qwerty")
+		resp, err = client.PostForm(srv.URL+oauth.PathAuthorize, form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		loc, err := url.Parse(resp.Header.Get("Location"))
+		if err != nil || resp.StatusCode != http.StatusSeeOther {
+			t.Fatalf("\t%s\tShould redirect to the client : got %d.", tests.Failed, resp.StatusCode)
+		}
+		code := loc.Query().Get("code")
+		if code == "" || loc.Query().Get("state") != "state" || loc.Query().Get("iss") != srv.URL {
+			t.Fatalf("\t%s\tShould redirect with a code, the state and the issuer : %s.", tests.Failed, loc)
+		}
+		t.Logf("\t%s\tShould redirect to the client with a code.", tests.Success)
+
+		exchange := url.Values{
+			"grant_type":    {oauth.GrantAuthorizationCode},
+			"client_id":     {oc.ID},
+			"code":          {code},
+			"redirect_uri":  {redirect},
+			"code_verifier": {strings.Repeat("attacker", 6)},
+		}
+		if status, _ := postToken(t, client, srv.URL, exchange); status != http.StatusBadRequest {
+			t.Fatalf("\t%s\tShould reject a wrong code verifier : got %d.", tests.Failed, status)
+		}
+		t.Logf("\t%s\tShould reject a wrong code verifier.", tests.Success)
+
+		// The code was used by the failed attempt, so a new one is needed.
+		resp, err = client.PostForm(srv.URL+oauth.PathAuthorize, form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		loc, _ = url.Parse(resp.Header.Get("Location"))
+		exchange.Set("code", loc.Query().Get("code"))
+		exchange.Set("code_verifier", verifier)
+
+		status, tkn := postToken(t, client, srv.URL, exchange)
+		if status != http.StatusOK || tkn.AccessToken == "" || tkn.IDToken == "" || tkn.RefreshToken == "" {
+			t.Fatalf("\t%s\tShould exchange the code for tokens : got %d.", tests.Failed, status)
+		}
+		t.Logf("\t%s\tShould exchange the code for tokens.", tests.Success)
+
+		claims, err := test.Authenticator.ParseClaims(tkn.AccessToken)
+		if err != nil {
+			t.Fatalf("\t%s\tShould issue an access token the API accepts : %s.", tests.Failed, err)
+		}
+		if len(claims.Roles) != 1 || claims.Roles[0] != auth.RoleUser {
+			t.Fatalf("\t%s\tShould not give the client the roles of the user : got %v.", tests.Failed, claims.Roles)
+		}
+		t.Logf("\t%s\tShould not give the client the roles of the user.", tests.Success)
+
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+"/v1/users/"+usr.ID, nil)
+		req.Header.Set("Authorization", "Bearer "+tkn.IDToken)
+		resp, err = client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("\t%s\tShould reject the id token as a bearer token : got %d.", tests.Failed, resp.StatusCode)
+		}
+		t.Logf("\t%s\tShould reject the id token as a bearer token.", tests.Success)
+
+		req, _ = http.NewRequest(http.MethodGet, srv.URL+oauth.PathUserInfo, nil)
+		req.Header.Set("Authorization", "Bearer "+tkn.AccessToken)
+		resp, err = client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ui oauth.UserInfo
+		if err := json.NewDecoder(resp.Body).Decode(&ui); err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if ui.Subject != usr.ID || ui.Email != usr.Email {
+			t.Fatalf("\t%s\tShould return the user info : %+v.", tests.Failed, ui)
+		}
+		t.Logf("\t%s\tShould return the user info.", tests.Success)
+
+		refresh := url.Values{
+			"grant_type":    {oauth.GrantRefreshToken},
+			"client_id":     {oc.ID},
+			"refresh_token": {tkn.RefreshToken},
+		}
+		status, rotated := postToken(t, client, srv.URL, refresh)
+		if status != http.StatusOK || rotated.RefreshToken == tkn.RefreshToken {
+			t.Fatalf("\t%s\tShould rotate the refresh token : got %d.", tests.Failed, status)
+		}
+		t.Logf("\t%s\tShould rotate the refresh token.", tests.Success)
+
+		if status, _ := postToken(t, client, srv.URL, refresh); status != http.StatusBadRequest {
+			t.Fatalf("\t%s\tShould reject a reused refresh token : got %d.", tests.Failed, status)
+		}
+		refresh.Set("refresh_token", rotated.RefreshToken)
+		if status, _ := postToken(t, client, srv.URL, refresh); status != http.StatusBadRequest {
+			t.Fatalf("\t%s\tShould revoke the rotated token after a reuse : got %d.", tests.Failed, status)
+		}
+		t.Logf("\t%s\tShould revoke all refresh tokens when one is reused.", tests.Success)
+	}
+}
+
+// getJSON decodes the response of a GET request.
+func getJSON(t *testing.T, client *http.Client, url string, v interface{}) {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// postToken sends a request to the token endpoint.
+func postToken(t *testing.T, client *http.Client, base string, form url.Values) (int, oauth.TokenResponse) {
+	t.Helper()
+
+	resp, err := client.PostForm(base+oauth.PathToken, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	var tkn oauth.TokenResponse
+	if resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(&tkn); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return resp.StatusCode, tkn
+}
@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igomonov88/users/cmd/users-api/internal/handlers"
+	"github.com/igomonov88/users/internal/mfa"
+	"github.com/igomonov88/users/internal/mid"
+	"github.com/igomonov88/users/internal/platform/graph"
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/platform/openapi"
+	"github.com/igomonov88/users/internal/platform/ratelimit"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/tests"
+)
+
+// specFile is the committed specification consumers of the API read.
+const specFile = "../openapi.json"
+
+var update = flag.Bool("update", false, "update the committed OpenAPI specification")
+
+// TestOpenAPI fails when the specification drifts from the routes of the
+// API. It needs no database as the requests it makes never get past the
+// authentication.
+func TestOpenAPI(t *testing.T) {
+	limit := ratelimit.Limit{Rate: 100, Period: time.Minute, Burst: 100}
+	limits := handlers.RateLimits{Token: limit, Signup: limit, Exist: limit, Write: limit}
+	m := mfa.New(mfa.Config{}, nil, nil)
+
+	api := handlers.API("test", make(chan os.Signal, 1), logger.New(os.Stdout, logger.Error), nil, nil, nil, nil,
+		ratelimit.NewMemory(), limits, nil, m, nil, nil, nil, nil, nil, nil, nil, mid.Deprecation{}, graph.Limits{}, false)
+
+	app, ok := api.(*web.App)
+	if !ok {
+		t.Fatalf("\t%s\tShould construct a web.App : got %T.", tests.Failed, api)
+	}
+	spec := handlers.Spec()
+
+	t.Log("Given the need to describe every route of the API.")
+	{
+		if err := spec.Check(app.Routes()); err != nil {
+			t.Fatalf("\t%s\tShould describe every route : %s.", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould describe every route.", tests.Success)
+
+		for _, r := range app.Routes() {
+			op, _ := spec.Operation(r.Method, r.Path)
+			if !requiresToken(op) {
+				continue
+			}
+
+			path := r.Path
+			for _, s := range strings.Split(r.Path, "/") {
+				if strings.HasPrefix(s, ":") {
+					path = strings.Replace(path, s, "x", 1)
+				}
+			}
+
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, httptest.NewRequest(r.Method, path, nil))
+			if w.Code != http.StatusUnauthorized {
+				t.Fatalf("\t%s\tShould require authentication for %s %s : got %d.", tests.Failed, r.Method, r.Path, w.Code)
+			}
+		}
+		t.Logf("\t%s\tShould require authentication where the specification says so.", tests.Success)
+
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould serve the specification : got %d.", tests.Failed, w.Code)
+		}
+
+		var served bytes.Buffer
+		if err := json.Indent(&served, w.Body.Bytes(), "", "  "); err != nil {
+			t.Fatalf("\t%s\tShould serve JSON : %s.", tests.Failed, err)
+		}
+		served.WriteByte('\n')
+
+		if *update {
+			if err := ioutil.WriteFile(specFile, served.Bytes(), 0644); err != nil {
+				t.Fatalf("\t%s\tShould be able to update %s : %s.", tests.Failed, specFile, err)
+			}
+		}
+
+		committed, err := ioutil.ReadFile(specFile)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to read %s : %s.", tests.Failed, specFile, err)
+		}
+		if !bytes.Equal(committed, served.Bytes()) {
+			t.Fatalf("\t%s\tShould match %s, run go test ./cmd/users-api/tests -run TestOpenAPI -update.", tests.Failed, specFile)
+		}
+		t.Logf("\t%s\tShould serve the committed specification.", tests.Success)
+	}
+}
+
+// requiresToken reports if the operation is behind the authentication of
+// users, which rejects requests without a token or an API key.
+func requiresToken(op *openapi.Operation) bool {
+	for _, s := range op.Security {
+		if _, ok := s["apiKeyAuth"]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/igomonov88/users/cmd/users-api/internal/handlers"
+	"github.com/igomonov88/users/internal/storage"
+	"github.com/igomonov88/users/internal/tests"
+)
+
+// TestRPCAdminMFA deletes users over gRPC as an admin and checks admins need
+// a second factor to delete anyone but themselves.
+func TestRPCAdminMFA(t *testing.T) {
+	test := tests.NewIntegration(t)
+	defer test.Teardown()
+	ctx := tests.Context()
+
+	api := newAPI(t, test, nil)
+	conn := newRPC(t, test)
+	defer conn.Close()
+
+	// remove deletes the user over gRPC with the token.
+	remove := func(token, userID string) error {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+		return conn.Invoke(ctx, "/users.v1.Users/DeleteUser", &handlers.DeleteUserRequest{UserID: userID}, &handlers.DeleteUserResponse{})
+	}
+
+	t.Log("Given the need to require a second factor for admins deleting users over gRPC.")
+	{
+		admin, err := storage.Create(ctx, test.DB, "admin@example.com", "boss", "", "qwerty")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create a user : %s.", tests.Failed, err)
+		}
+		const q = `UPDATE users SET roles = '{ADMIN,USER}' WHERE user_id = $1;`
+		if _, err := test.DB.ExecContext(ctx, q, admin.ID); err != nil {
+			t.Fatalf("\t%s\tShould be able to make the user an admin : %s.", tests.Failed, err)
+		}
+		usr, err := storage.Create(ctx, test.DB, "gopher@example.com", "gopher", "", "qwerty")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create a user : %s.", tests.Failed, err)
+		}
+
+		tkn := login(t, api, admin.Email, "qwerty")
+		if err := remove(tkn, usr.ID); status.Code(err) != codes.PermissionDenied {
+			t.Fatalf("\t%s\tShould reject an admin deleting another user without a second factor : got %v.", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould reject an admin deleting another user without a second factor.", tests.Success)
+
+		recovery := enroll(t, api, tkn)
+		id := challenge(t, api, admin.Email, "qwerty")
+		w := request(t, api, http.MethodPost, "/v1/users/token/otp", nil, handlers.TokenOTPRequest{Challenge: id, RecoveryCode: recovery[0]})
+		var otp handlers.TokenResponse
+		if err := json.NewDecoder(w.Body).Decode(&otp); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould log in with the second factor : got %d %+v.", tests.Failed, w.Code, otp)
+		}
+
+		if err := remove(otp.Token, usr.ID); err != nil {
+			t.Fatalf("\t%s\tShould let an admin with a second factor delete another user : %s.", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould let an admin with a second factor delete another user.", tests.Success)
+	}
+}
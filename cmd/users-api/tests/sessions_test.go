@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/igomonov88/users/cmd/users-api/internal/handlers"
+	"github.com/igomonov88/users/internal/storage"
+	"github.com/igomonov88/users/internal/tests"
+)
+
+// TestSessions revokes the sessions of a user and checks their tokens stop
+// authenticating.
+func TestSessions(t *testing.T) {
+	test := tests.NewIntegration(t)
+	defer test.Teardown()
+	ctx := tests.Context()
+
+	api := newAPI(t, test, nil)
+
+	t.Log("Given the need to revoke the sessions of a user.")
+	{
+		usr, err := storage.Create(ctx, test.DB, "gopher@example.com", "gopher", "", "qwerty")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create a user : %s.", tests.Failed, err)
+		}
+		current := login(t, api, usr.Email, "qwerty")
+		other := login(t, api, usr.Email, "qwerty")
+
+		w := request(t, api, http.MethodGet, "/v1/users/sessions", bearer(current), nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould be able to list the sessions : got %d %s.", tests.Failed, w.Code, w.Body)
+		}
+		var list handlers.ListSessionsResponse
+		if err := json.NewDecoder(w.Body).Decode(&list); err != nil {
+			t.Fatal(err)
+		}
+		var otherID string
+		for _, s := range list.Sessions {
+			if !s.Current {
+				otherID = s.ID
+			}
+		}
+		if len(list.Sessions) != 2 || otherID == "" {
+			t.Fatalf("\t%s\tShould list both sessions : got %+v.", tests.Failed, list.Sessions)
+		}
+		t.Logf("\t%s\tShould list both sessions.", tests.Success)
+
+		if w := request(t, api, http.MethodGet, "/v1/users/"+usr.ID, bearer(other), nil); w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould authenticate with the token of the session : got %d %s.", tests.Failed, w.Code, w.Body)
+		}
+		if w := request(t, api, http.MethodDelete, "/v1/users/sessions/"+otherID, bearer(current), nil); w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould be able to revoke the session : got %d %s.", tests.Failed, w.Code, w.Body)
+		}
+		w = request(t, api, http.MethodGet, "/v1/users/"+usr.ID, bearer(other), nil)
+		if w.Code != http.StatusUnauthorized || problemCode(t, w) != "auth.session_revoked" {
+			t.Fatalf("\t%s\tShould reject the token of a revoked session : got %d %s.", tests.Failed, w.Code, w.Body)
+		}
+		t.Logf("\t%s\tShould reject the token of a revoked session.", tests.Success)
+
+		other = login(t, api, usr.Email, "qwerty")
+		w = request(t, api, http.MethodDelete, "/v1/users/sessions", bearer(current), nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould be able to revoke the other sessions : got %d %s.", tests.Failed, w.Code, w.Body)
+		}
+		var revoked handlers.RevokeOtherSessionsResponse
+		if err := json.NewDecoder(w.Body).Decode(&revoked); err != nil {
+			t.Fatal(err)
+		}
+		if revoked.Revoked != 1 {
+			t.Fatalf("\t%s\tShould revoke the other session only : got %d.", tests.Failed, revoked.Revoked)
+		}
+		if w := request(t, api, http.MethodGet, "/v1/users/"+usr.ID, bearer(current), nil); w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould keep the current session : got %d %s.", tests.Failed, w.Code, w.Body)
+		}
+		w = request(t, api, http.MethodGet, "/v1/users/"+usr.ID, bearer(other), nil)
+		if w.Code != http.StatusUnauthorized || problemCode(t, w) != "auth.session_revoked" {
+			t.Fatalf("\t%s\tShould reject the tokens of the other sessions : got %d %s.", tests.Failed, w.Code, w.Body)
+		}
+		t.Logf("\t%s\tShould revoke the other sessions and keep the current one.", tests.Success)
+	}
+}
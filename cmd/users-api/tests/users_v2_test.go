@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/igomonov88/users/cmd/users-api/internal/handlers"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+	"github.com/igomonov88/users/internal/tests"
+)
+
+// TestAdminMFA changes users as an admin and checks admins need a second
+// factor to change anyone but themselves.
+func TestAdminMFA(t *testing.T) {
+	test := tests.NewIntegration(t)
+	defer test.Teardown()
+	ctx := tests.Context()
+
+	api := newAPI(t, test, nil)
+
+	// merge returns the header of a merge patch with the token.
+	merge := func(token string) http.Header {
+		h := bearer(token)
+		h.Set("Content-Type", web.ContentTypeMergePatch)
+		return h
+	}
+
+	t.Log("Given the need to require a second factor for admins changing users.")
+	{
+		admin, err := storage.Create(ctx, test.DB, "admin@example.com", "boss", "", "qwerty")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create a user : %s.", tests.Failed, err)
+		}
+		const q = `UPDATE users SET roles = '{ADMIN,USER}' WHERE user_id = $1;`
+		if _, err := test.DB.ExecContext(ctx, q, admin.ID); err != nil {
+			t.Fatalf("\t%s\tShould be able to make the user an admin : %s.", tests.Failed, err)
+		}
+		usr, err := storage.Create(ctx, test.DB, "gopher@example.com", "gopher", "", "qwerty")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create a user : %s.", tests.Failed, err)
+		}
+
+		tkn := login(t, api, admin.Email, "qwerty")
+		for _, r := range []struct{ method, path string }{
+			{http.MethodPatch, "/v2/users/" + usr.ID},
+			{http.MethodDelete, "/v2/users/" + usr.ID},
+			{http.MethodDelete, "/v2/users/" + usr.ID + "/avatar"},
+		} {
+			w := request(t, api, r.method, r.path, merge(tkn), map[string]string{"name": "renamed"})
+			if w.Code != http.StatusForbidden || problemCode(t, w) != "auth.mfa_required" {
+				t.Fatalf("\t%s\tShould reject %s %s without a second factor : got %d %s.", tests.Failed, r.method, r.path, w.Code, w.Body)
+			}
+		}
+		t.Logf("\t%s\tShould reject an admin changing another user without a second factor.", tests.Success)
+
+		if w := request(t, api, http.MethodPatch, "/v2/users/"+admin.ID, merge(tkn), map[string]string{"name": "chief"}); w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould let an admin change themselves without a second factor : got %d %s.", tests.Failed, w.Code, w.Body)
+		}
+		t.Logf("\t%s\tShould let an admin change themselves without a second factor.", tests.Success)
+
+		codes := enroll(t, api, tkn)
+		id := challenge(t, api, admin.Email, "qwerty")
+		w := request(t, api, http.MethodPost, "/v1/users/token/otp", nil, handlers.TokenOTPRequest{Challenge: id, RecoveryCode: codes[0]})
+		var otp handlers.TokenResponse
+		if err := json.NewDecoder(w.Body).Decode(&otp); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould log in with the second factor : got %d %+v.", tests.Failed, w.Code, otp)
+		}
+
+		if w := request(t, api, http.MethodPatch, "/v2/users/"+usr.ID, merge(otp.Token), map[string]string{"name": "renamed"}); w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould let an admin with a second factor change another user : got %d %s.", tests.Failed, w.Code, w.Body)
+		}
+		t.Logf("\t%s\tShould let an admin with a second factor change another user.", tests.Success)
+	}
+}
+
+// TestEmailPrivacy looks users up as another user and checks their emails
+// are neither shown nor usable to find them.
+func TestEmailPrivacy(t *testing.T) {
+	test := tests.NewIntegration(t)
+	defer test.Teardown()
+	ctx := tests.Context()
+
+	api := newAPI(t, test, nil)
+
+	// retrieve gets the user with the token.
+	retrieve := func(token, path string) handlers.RetrieveUserResponse {
+		t.Helper()
+		w := request(t, api, http.MethodGet, path, bearer(token), nil)
+		var resp handlers.RetrieveUserResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould be able to retrieve %s : got %d %+v.", tests.Failed, path, w.Code, resp)
+		}
+		return resp
+	}
+
+	// find lists the users matching the query with the token.
+	find := func(token, query string) []*handlers.RetrieveUserResponse {
+		t.Helper()
+		w := request(t, api, http.MethodGet, "/v2/users?"+query, bearer(token), nil)
+		var resp handlers.ListUsersResponse
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("\t%s\tShould be able to find users by %s : got %d %+v.", tests.Failed, query, w.Code, resp)
+		}
+		return resp.Users
+	}
+
+	t.Log("Given the need to keep the emails of users private.")
+	{
+		usr, err := storage.Create(ctx, test.DB, "gopher@example.com", "gopher", "", "qwerty")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create a user : %s.", tests.Failed, err)
+		}
+		other, err := storage.Create(ctx, test.DB, "other@example.com", "other", "", "qwerty")
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create a user : %s.", tests.Failed, err)
+		}
+		tkn := login(t, api, usr.Email, "qwerty")
+
+		if resp := retrieve(tkn, "/v2/users/"+usr.ID); resp.Email != usr.Email {
+			t.Fatalf("\t%s\tShould show users their own email : got %+v.", tests.Failed, resp)
+		}
+		if resp := retrieve(tkn, "/v2/users/"+other.ID); resp.UserID != other.ID || resp.Email != "" {
+			t.Fatalf("\t%s\tShould leave out the email of other users : got %+v.", tests.Failed, resp)
+		}
+		if users := find(tkn, "user_name=other"); len(users) != 1 || users[0].Email != "" {
+			t.Fatalf("\t%s\tShould leave out the email of other users found : got %+v.", tests.Failed, users)
+		}
+		t.Logf("\t%s\tShould leave out the email of other users.", tests.Success)
+
+		if users := find(tkn, "email="+usr.Email); len(users) != 1 || users[0].Email != usr.Email {
+			t.Fatalf("\t%s\tShould find users by their own email : got %+v.", tests.Failed, users)
+		}
+		if users := find(tkn, "email="+other.Email); len(users) != 0 {
+			t.Fatalf("\t%s\tShould not find other users by their email : got %+v.", tests.Failed, users)
+		}
+		t.Logf("\t%s\tShould not find other users by their email.", tests.Success)
+	}
+}
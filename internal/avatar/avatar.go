@@ -0,0 +1,257 @@
+// Package avatar implements uploading avatars of users. Uploads are checked,
+// normalized and stored with thumbnails under content-addressed keys.
+package avatar
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/content_uploader"
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// prefix is the key prefix all avatars are stored under.
+const prefix = "avatars"
+
+// deleteTimeout is how long deleting the objects of a replaced avatar may
+// take.
+const deleteTimeout = time.Minute
+
+// Config is the required properties to use avatars.
+type Config struct {
+
+	// MaxSize is the largest upload accepted in bytes.
+	MaxSize int64
+
+	// MinDimension and MaxDimension bound the width and height of uploads
+	// in pixels.
+	MinDimension int
+	MaxDimension int
+
+	// Sizes are the widths of the square thumbnails in pixels.
+	Sizes []int
+
+	// UploadTTL is how long a presigned URL for a direct upload is valid.
+	UploadTTL time.Duration
+}
+
+// cacheControl is stored with the objects of avatars. They never change
+// since their keys are derived from their content.
+const cacheControl = "public, max-age=31536000, immutable"
+
+// Avatar is the stored avatar of a user. Thumbnails are keyed by size.
+type Avatar struct {
+	URL        string
+	Thumbnails map[int]string
+}
+
+// Avatars manages the avatars of users.
+type Avatars struct {
+	cfg   Config
+	db    *sqlx.DB
+	store content_uploader.BlobStore
+	log   *logger.Logger
+	wg    sync.WaitGroup
+}
+
+// New constructs Avatars for use.
+func New(cfg Config, db *sqlx.DB, store content_uploader.BlobStore, log *logger.Logger) *Avatars {
+	return &Avatars{
+		cfg:   cfg,
+		db:    db,
+		store: store,
+		log:   log,
+	}
+}
+
+// Upload processes the image and replaces the avatar of the user with it.
+// The URL of the normalized image is saved for the user, the thumbnails are
+// stored next to it. The objects of the previous avatar are deleted in the
+// background.
+func (a *Avatars) Upload(ctx context.Context, userID string, r io.Reader) (*Avatar, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.avatar.Upload")
+	defer span.End()
+
+	usr, err := storage.Retrieve(ctx, a.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.replace(ctx, usr, r)
+}
+
+// replace processes the image, stores it and saves it as the avatar of the
+// user.
+func (a *Avatars) replace(ctx context.Context, usr *storage.User, r io.Reader) (*Avatar, error) {
+	img, err := Process(r, a.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// The objects are stored and saved for the user under the lock of the
+	// hash, so deleting the avatar another user replaced can't remove them
+	// before they are referenced.
+	av := Avatar{
+		Thumbnails: make(map[int]string),
+	}
+	err = storage.LockAvatar(ctx, a.db, img.Hash, func() error {
+		for _, rd := range img.Renditions {
+			key := Key(img.Hash, rd.Name, rd.Ext)
+			opts := content_uploader.PutOptions{
+				ContentType:  rd.ContentType,
+				CacheControl: cacheControl,
+			}
+			if err := a.store.Put(ctx, key, bytes.NewReader(rd.Data), opts); err != nil {
+				return errors.Wrap(err, "uploading avatar")
+			}
+
+			url := a.store.URL(key)
+			if rd.Name == Original {
+				av.URL = url
+				continue
+			}
+			size, _ := strconv.Atoi(rd.Name)
+			av.Thumbnails[size] = url
+		}
+
+		return storage.UpdateAvatar(ctx, a.db, usr.ID, av.URL)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if usr.Avatar != "" && usr.Avatar != av.URL {
+		a.deleteLater(usr.Avatar)
+	}
+
+	return &av, nil
+}
+
+// Wait blocks until the objects of replaced avatars are deleted.
+func (a *Avatars) Wait() {
+	a.wg.Wait()
+}
+
+// Key returns the key of a rendition of the image with the hash.
+func Key(hash, name, ext string) string {
+	return path.Join(prefix, hash, name+ext)
+}
+
+// parse returns the hash and the extension of an avatar URL. It fails for
+// URLs which do not point to the store.
+func (a *Avatars) parse(url string) (string, string, bool) {
+	base := a.store.URL(prefix + "/")
+	if !strings.HasPrefix(url, base) {
+		return "", "", false
+	}
+
+	parts := strings.Split(strings.TrimPrefix(url, base), "/")
+	if len(parts) != 2 || parts[0] == "" {
+		return "", "", false
+	}
+
+	ext := path.Ext(parts[1])
+	if parts[1] != Original+ext {
+		return "", "", false
+	}
+
+	return parts[0], ext, true
+}
+
+// Remove clears the avatar of the user and deletes its objects unless another
+// user references them. The objects are deleted under the lock of their hash
+// like the ones of replaced avatars. Failing to delete an object is only
+// logged, reconciliation deletes what is left.
+func (a *Avatars) Remove(ctx context.Context, userID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.avatar.Remove")
+	defer span.End()
+
+	usr, err := storage.Retrieve(ctx, a.db, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := storage.DeleteAvatar(ctx, a.db, usr.ID); err != nil {
+		return err
+	}
+
+	a.deleteUnreferenced(ctx, usr.Avatar)
+	return nil
+}
+
+// keys returns the keys of the objects of an avatar. URLs which do not point
+// to the store have none.
+func (a *Avatars) keys(url string) []string {
+	hash, ext, ok := a.parse(url)
+	if !ok {
+		return nil
+	}
+
+	keys := []string{Key(hash, Original, ext)}
+	for _, size := range a.cfg.Sizes {
+		keys = append(keys, Key(hash, strconv.Itoa(size), ext))
+	}
+
+	return keys
+}
+
+// delete deletes the objects with the keys. Failures are only logged, the
+// objects are unreferenced anyway.
+func (a *Avatars) delete(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if err := a.store.Delete(ctx, key); err != nil {
+			a.log.Warn("avatar : Delete", "key", key, "error", err)
+		}
+	}
+}
+
+// deleteLater deletes the objects of an avatar in the background unless
+// another user references them.
+func (a *Avatars) deleteLater(url string) {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		ctx, cancel := context.WithTimeout(context.Background(), deleteTimeout)
+		defer cancel()
+
+		a.deleteUnreferenced(ctx, url)
+	}()
+}
+
+// deleteUnreferenced deletes the objects of an avatar unless any user
+// references its hash. Users uploading the same image share its objects, they
+// are only deleted once nobody references them. The check and the deletion
+// hold the lock of the hash, so an upload of the same image can't store and
+// reference the objects in between. Failures are only logged, Reconcile
+// deletes what is left.
+func (a *Avatars) deleteUnreferenced(ctx context.Context, url string) {
+	keys := a.keys(url)
+	if len(keys) == 0 {
+		return
+	}
+
+	hash := storage.AvatarHash(url)
+	err := storage.LockAvatar(ctx, a.db, hash, func() error {
+		referenced, err := storage.IsAvatarReferenced(ctx, a.db, hash)
+		if err != nil || referenced {
+			return err
+		}
+		a.delete(ctx, keys)
+		return nil
+	})
+	if err != nil {
+		a.log.Warn("avatar : delete", "hash", hash, "error", err)
+	}
+}
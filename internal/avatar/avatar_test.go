@@ -0,0 +1,111 @@
+package avatar_test
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"testing"
+
+	"github.com/igomonov88/users/internal/avatar"
+	"github.com/igomonov88/users/internal/platform/content_uploader"
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/storage"
+	"github.com/igomonov88/users/internal/tests"
+)
+
+// TestShared replaces and removes an avatar two users uploaded and checks
+// its objects are kept while either of them references it.
+func TestShared(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	ctx := context.Background()
+	store := content_uploader.NewMemory(content_uploader.NewSigner("http://localhost/blobs", []byte("secret")))
+	avatars := avatar.New(cfg, db, store, logger.New(ioutil.Discard, logger.Error))
+
+	picture := func(c color.RGBA) []byte {
+		img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+		for y := 0; y < 64; y++ {
+			for x := 0; x < 64; x++ {
+				img.Set(x, y, c)
+			}
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	shared := picture(color.RGBA{R: 255, A: 255})
+
+	// stored reports if every object of the avatar is in the store.
+	stored := func(av *avatar.Avatar) bool {
+		t.Helper()
+		urls := []string{av.URL}
+		for _, url := range av.Thumbnails {
+			urls = append(urls, url)
+		}
+		for _, url := range urls {
+			key := url[len(store.URL("")):]
+			ok, err := store.Exists(ctx, key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	t.Log("Given the need to keep the objects of avatars users share.")
+	{
+		var users []*storage.User
+		for _, email := range []string{"a@example.com", "b@example.com"} {
+			usr, err := storage.Create(ctx, db, email, "gopher", "", "qwerty")
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to create a user : %s.", failed, err)
+			}
+			users = append(users, usr)
+		}
+
+		var av *avatar.Avatar
+		for _, usr := range users {
+			var err error
+			if av, err = avatars.Upload(ctx, usr.ID, bytes.NewReader(shared)); err != nil {
+				t.Fatalf("\t%s\tShould be able to upload an avatar : %s.", failed, err)
+			}
+		}
+
+		if _, err := avatars.Upload(ctx, users[0].ID, bytes.NewReader(picture(color.RGBA{B: 255, A: 255}))); err != nil {
+			t.Fatalf("\t%s\tShould be able to replace the avatar : %s.", failed, err)
+		}
+		avatars.Wait()
+		if !stored(av) {
+			t.Fatalf("\t%s\tShould keep the objects of a replaced avatar another user references.", failed)
+		}
+		t.Logf("\t%s\tShould keep the objects of a replaced avatar another user references.", success)
+
+		if _, err := avatars.Upload(ctx, users[0].ID, bytes.NewReader(shared)); err != nil {
+			t.Fatalf("\t%s\tShould be able to upload an avatar : %s.", failed, err)
+		}
+		if err := avatars.Remove(ctx, users[1].ID); err != nil {
+			t.Fatalf("\t%s\tShould be able to remove the avatar : %s.", failed, err)
+		}
+		if !stored(av) {
+			t.Fatalf("\t%s\tShould keep the objects of a removed avatar another user references.", failed)
+		}
+		t.Logf("\t%s\tShould keep the objects of a removed avatar another user references.", success)
+
+		if err := avatars.Remove(ctx, users[0].ID); err != nil {
+			t.Fatalf("\t%s\tShould be able to remove the avatar : %s.", failed, err)
+		}
+		if stored(av) {
+			t.Fatalf("\t%s\tShould delete the objects once nobody references them.", failed)
+		}
+		t.Logf("\t%s\tShould delete the objects once nobody references them.", success)
+	}
+}
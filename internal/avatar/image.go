@@ -0,0 +1,221 @@
+package avatar
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	// Register the gif decoder. The jpeg and png decoders are registered
+	// by the packages above.
+	_ "image/gif"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrTooLarge is returned for an upload exceeding the maximum size.
+	ErrTooLarge = errors.New("image is too large")
+
+	// ErrUnsupportedType is returned for an upload which is not a jpeg, png
+	// or gif image, whatever it claims to be.
+	ErrUnsupportedType = errors.New("image type is not supported")
+
+	// ErrDimensions is returned for an image which is too small or too big.
+	ErrDimensions = errors.New("image dimensions are out of bounds")
+)
+
+// Original is the name of the rendition which is not a thumbnail.
+const Original = "original"
+
+// jpegQuality is the quality all jpeg renditions are encoded with.
+const jpegQuality = 85
+
+// Rendition is an encoded version of an avatar.
+type Rendition struct {
+	Name        string
+	ContentType string
+	Ext         string
+	Data        []byte
+}
+
+// Image is an avatar ready to be stored. Hash identifies the content of the
+// image and is the same for every upload of the same picture.
+type Image struct {
+	Hash       string
+	Renditions []Rendition
+}
+
+// Process reads an uploaded image, checks its real type, size and
+// dimensions and re-encodes it. Re-encoding drops all metadata, EXIF
+// included, so the orientation recorded by cameras is applied to the pixels
+// first. Jpeg images stay jpeg, everything else becomes png to keep the
+// transparency. Each size of the config gets a square thumbnail cut from the
+// center of the image.
+func Process(r io.Reader, cfg Config) (*Image, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, cfg.MaxSize+1))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading image")
+	}
+	if int64(len(data)) > cfg.MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	var format string
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+		format = "jpeg"
+	case "image/png":
+		format = "png"
+	case "image/gif":
+		format = "gif"
+	default:
+		return nil, ErrUnsupportedType
+	}
+
+	// The dimensions are checked before decoding, so a small file claiming
+	// huge dimensions is not expanded in memory.
+	ic, decoded, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || decoded != format {
+		return nil, ErrUnsupportedType
+	}
+	if ic.Width < cfg.MinDimension || ic.Height < cfg.MinDimension ||
+		ic.Width > cfg.MaxDimension || ic.Height > cfg.MaxDimension {
+		return nil, ErrDimensions
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedType
+	}
+
+	contentType, ext := "image/png", ".png"
+	if format == "jpeg" {
+		contentType, ext = "image/jpeg", ".jpg"
+		img = orient(img, exifOrientation(data))
+	}
+
+	original, err := encode(img, contentType)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(original)
+
+	out := Image{
+		Hash: hex.EncodeToString(sum[:]),
+		Renditions: []Rendition{
+			{Name: Original, ContentType: contentType, Ext: ext, Data: original},
+		},
+	}
+
+	square := crop(img)
+	for _, size := range cfg.Sizes {
+		b, err := encode(resize(square, size, size), contentType)
+		if err != nil {
+			return nil, err
+		}
+		out.Renditions = append(out.Renditions, Rendition{
+			Name:        strconv.Itoa(size),
+			ContentType: contentType,
+			Ext:         ext,
+			Data:        b,
+		})
+	}
+
+	return &out, nil
+}
+
+// encode encodes the image with the content type.
+func encode(img image.Image, contentType string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	switch contentType {
+	case "image/jpeg":
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, errors.Wrap(err, "encoding jpeg")
+		}
+	default:
+		enc := png.Encoder{CompressionLevel: png.BestCompression}
+		if err := enc.Encode(&buf, img); err != nil {
+			return nil, errors.Wrap(err, "encoding png")
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// crop returns the largest square from the center of the image.
+func crop(img image.Image) image.Image {
+	b := img.Bounds()
+	side := b.Dx()
+	if b.Dy() < side {
+		side = b.Dy()
+	}
+
+	x := b.Min.X + (b.Dx()-side)/2
+	y := b.Min.Y + (b.Dy()-side)/2
+	r := image.Rect(x, y, x+side, y+side)
+
+	dst := image.NewNRGBA(image.Rect(0, 0, side, side))
+	for dy := 0; dy < side; dy++ {
+		for dx := 0; dx < side; dx++ {
+			dst.Set(dx, dy, img.At(r.Min.X+dx, r.Min.Y+dy))
+		}
+	}
+
+	return dst
+}
+
+// resize scales the image to the width and height. Each pixel is the average
+// of the pixels it covers in the source, which is good enough for scaling
+// down. Scaling up repeats pixels.
+func resize(src image.Image, w, h int) image.Image {
+	b := src.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := b.Min.Y + (y+1)*b.Dy()/h
+		if y1 == y0 {
+			y1++
+		}
+
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := b.Min.X + (x+1)*b.Dx()/w
+			if x1 == x0 {
+				x1++
+			}
+
+			// The colors are premultiplied, so averaging them is correct for
+			// transparent pixels too.
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n),
+				G: uint16(g / n),
+				B: uint16(bl / n),
+				A: uint16(a / n),
+			})
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,114 @@
+package avatar_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/igomonov88/users/internal/avatar"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+var cfg = avatar.Config{
+	MaxSize:      1 << 20,
+	MinDimension: 16,
+	MaxDimension: 512,
+	Sizes:        []int{32, 64},
+}
+
+// exifRotated is an APP1 segment with the orientation telling viewers to
+// rotate the image 90 degrees clockwise.
+var exifRotated = []byte{
+	0xFF, 0xE1, 0x00, 0x22,
+	'E', 'x', 'i', 'f', 0x00, 0x00,
+	'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08,
+	0x00, 0x01,
+	0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, 0x06, 0x00, 0x00,
+	0x00, 0x00, 0x00, 0x00,
+}
+
+func TestProcess(t *testing.T) {
+	t.Log("Given the need to normalize uploaded avatars.")
+	{
+		img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+		for y := 0; y < 50; y++ {
+			for x := 0; x < 100; x++ {
+				img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, img, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		// Insert the EXIF data right after the start of image marker.
+		data := append([]byte{0xFF, 0xD8}, exifRotated...)
+		data = append(data, buf.Bytes()[2:]...)
+
+		out, err := avatar.Process(bytes.NewReader(data), cfg)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to process a jpeg : %s.", failed, err)
+		}
+		if len(out.Renditions) != 1+len(cfg.Sizes) {
+			t.Fatalf("\t%s\tShould create a thumbnail for every size : got %d renditions.", failed, len(out.Renditions))
+		}
+		t.Logf("\t%s\tShould be able to process a jpeg.", success)
+
+		original := out.Renditions[0]
+		if original.ContentType != "image/jpeg" || bytes.Contains(original.Data, []byte("Exif")) {
+			t.Fatalf("\t%s\tShould re-encode the jpeg without EXIF data.", failed)
+		}
+		ic, err := jpeg.DecodeConfig(bytes.NewReader(original.Data))
+		if err != nil || ic.Width != 50 || ic.Height != 100 {
+			t.Fatalf("\t%s\tShould apply the orientation : got %dx%d.", failed, ic.Width, ic.Height)
+		}
+		t.Logf("\t%s\tShould strip EXIF data and apply the orientation.", success)
+
+		for i, size := range cfg.Sizes {
+			ic, err := jpeg.DecodeConfig(bytes.NewReader(out.Renditions[i+1].Data))
+			if err != nil || ic.Width != size || ic.Height != size {
+				t.Fatalf("\t%s\tShould create a square thumbnail of %d : got %dx%d.", failed, size, ic.Width, ic.Height)
+			}
+		}
+		t.Logf("\t%s\tShould create square thumbnails.", success)
+
+		again, err := avatar.Process(bytes.NewReader(data), cfg)
+		if err != nil || again.Hash != out.Hash {
+			t.Fatalf("\t%s\tShould address the same picture with the same hash.", failed)
+		}
+		t.Logf("\t%s\tShould address the same picture with the same hash.", success)
+	}
+
+	t.Log("Given the need to reject bad uploads.")
+	{
+		if _, err := avatar.Process(bytes.NewReader([]byte("<svg></svg>")), cfg); err != avatar.ErrUnsupportedType {
+			t.Fatalf("\t%s\tShould reject what is not an image : got %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould reject what is not an image.", success)
+
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := avatar.Process(bytes.NewReader(buf.Bytes()), cfg); err != avatar.ErrDimensions {
+			t.Fatalf("\t%s\tShould reject a too small image : got %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould reject a too small image.", success)
+
+		small := cfg
+		small.MaxSize = int64(buf.Len() - 1)
+		if _, err := avatar.Process(bytes.NewReader(buf.Bytes()), small); err != avatar.ErrTooLarge {
+			t.Fatalf("\t%s\tShould reject a too large upload : got %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould reject a too large upload.", success)
+	}
+}
@@ -0,0 +1,85 @@
+package avatar
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/content_uploader"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// Report is the outcome of a reconciliation. Orphaned counts the objects no
+// user references which are older than the grace period, Deleted the ones of
+// them which were deleted.
+type Report struct {
+	Scanned  int
+	Orphaned int
+	Deleted  int
+}
+
+// Reconcile deletes the objects in the blob store which no user references
+// anymore, like the ones left behind when deleting them failed. Objects are
+// only deleted once they are older than the grace period, so avatars being
+// uploaded are kept. With dryRun nothing is deleted, the report only tells
+// what would be.
+func (a *Avatars) Reconcile(ctx context.Context, now time.Time, grace time.Duration, dryRun bool) (*Report, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.avatar.Reconcile")
+	defer span.End()
+
+	hashes, err := storage.ListAvatarHashes(ctx, a.db)
+	if err != nil {
+		return nil, err
+	}
+
+	referenced := make(map[string]bool)
+	for _, hash := range hashes {
+		referenced[hash] = true
+	}
+
+	var report Report
+	reconcile := func(cutoff time.Time, orphaned func(key string) bool) content_uploader.ListFunc {
+		return func(info content_uploader.Info) error {
+			report.Scanned++
+			if !info.ModTime.Before(cutoff) || !orphaned(info.Key) {
+				return nil
+			}
+
+			report.Orphaned++
+			if dryRun {
+				return nil
+			}
+			if err := a.store.Delete(ctx, info.Key); err != nil {
+				return errors.Wrapf(err, "deleting %q", info.Key)
+			}
+			report.Deleted++
+			return nil
+		}
+	}
+
+	// Objects of avatars are kept if any user references their hash. Keys
+	// not looking like avatars are kept as well.
+	avatarObject := func(key string) bool {
+		parts := strings.Split(key, "/")
+		return len(parts) == 3 && !referenced[parts[1]]
+	}
+	if err := a.store.List(ctx, prefix+"/", reconcile(now.Add(-grace), avatarObject)); err != nil {
+		return &report, err
+	}
+
+	// Direct uploads are never referenced by users. Once they expired, Clean
+	// deletes them along with their records, anything older is left over.
+	ttl := grace
+	if a.cfg.UploadTTL > ttl {
+		ttl = a.cfg.UploadTTL
+	}
+	upload := func(string) bool { return true }
+	if err := a.store.List(ctx, uploadPrefix+"/", reconcile(now.Add(-ttl), upload)); err != nil {
+		return &report, err
+	}
+
+	return &report, nil
+}
@@ -0,0 +1,172 @@
+package avatar
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/content_uploader"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// ErrUploadNotFound is returned when completing an upload which was not
+// presigned for the user, has expired or was never made.
+var ErrUploadNotFound = errors.New("avatar upload not found or expired")
+
+// uploadPrefix is the key prefix of direct uploads. Every user has a
+// directory of their own below it.
+const uploadPrefix = "uploads"
+
+// contentTypes are the types accepted for direct uploads.
+var contentTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// Presigned is an upload a user may make directly to the blob store. The
+// upload has to be sent with the content type.
+type Presigned struct {
+	URL         string
+	Key         string
+	ContentType string
+	ExpiresAt   time.Time
+}
+
+// Presign allows the user to upload an image of the content type directly
+// to the blob store. The upload becomes the avatar of the user once it is
+// completed with Complete.
+func (a *Avatars) Presign(ctx context.Context, userID, contentType string, now time.Time) (*Presigned, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.avatar.Presign")
+	defer span.End()
+
+	if !allowed(contentType) {
+		return nil, ErrUnsupportedType
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.Wrap(err, "reading random key")
+	}
+	key := path.Join(uploadPrefix, userID, hex.EncodeToString(b))
+
+	au, err := storage.CreateAvatarUpload(ctx, a.db, userID, key, contentType, now, now.Add(a.cfg.UploadTTL))
+	if err != nil {
+		return nil, err
+	}
+
+	opts := content_uploader.PutOptions{
+		ContentType: contentType,
+	}
+	url, err := a.store.PresignPut(ctx, key, a.cfg.UploadTTL, opts)
+	if err != nil {
+		return nil, errors.Wrap(err, "presigning upload")
+	}
+
+	p := Presigned{
+		URL:         url,
+		Key:         key,
+		ContentType: contentType,
+		ExpiresAt:   au.ExpiresAt,
+	}
+
+	return &p, nil
+}
+
+// Complete makes the uploaded image with the key the avatar of the user. The
+// key has to be presigned for the user and the object is checked like any
+// other upload before it is processed. The uploaded object is removed.
+func (a *Avatars) Complete(ctx context.Context, userID, key string, now time.Time) (*Avatar, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.avatar.Complete")
+	defer span.End()
+
+	if !strings.HasPrefix(key, path.Join(uploadPrefix, userID)+"/") {
+		return nil, ErrUploadNotFound
+	}
+
+	au, err := storage.RetrieveAvatarUpload(ctx, a.db, userID, key)
+	switch err {
+	case nil:
+	case storage.ErrNotFound:
+		return nil, ErrUploadNotFound
+	default:
+		return nil, err
+	}
+	if !now.Before(au.ExpiresAt) {
+		return nil, ErrUploadNotFound
+	}
+
+	body, info, err := a.store.Get(ctx, key)
+	switch err {
+	case nil:
+	case content_uploader.ErrNotFound:
+		return nil, ErrUploadNotFound
+	default:
+		return nil, errors.Wrap(err, "reading upload")
+	}
+	defer body.Close()
+
+	if info.Size > a.cfg.MaxSize {
+		return nil, ErrTooLarge
+	}
+	if info.ContentType != au.ContentType {
+		return nil, ErrUnsupportedType
+	}
+
+	usr, err := storage.Retrieve(ctx, a.db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	av, err := a.replace(ctx, usr, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// The avatar is saved already, so what is left over here is removed by
+	// Clean once the upload expires.
+	if err := a.store.Delete(ctx, key); err != nil {
+		a.log.Warn("avatar : Complete : deleting upload", "key", key, "error", err)
+		return av, nil
+	}
+	if err := storage.DeleteAvatarUpload(ctx, a.db, key); err != nil {
+		a.log.Warn("avatar : Complete : deleting upload record", "key", key, "error", err)
+	}
+
+	return av, nil
+}
+
+// Clean removes the uploads which expired before the time without being
+// completed. It returns the number of removed uploads.
+func (a *Avatars) Clean(ctx context.Context, now time.Time) (int, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.avatar.Clean")
+	defer span.End()
+
+	uploads, err := storage.ListExpiredAvatarUploads(ctx, a.db, now)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, au := range uploads {
+		if err := a.store.Delete(ctx, au.Key); err != nil {
+			return i, errors.Wrapf(err, "deleting upload %q", au.Key)
+		}
+		if err := storage.DeleteAvatarUpload(ctx, a.db, au.Key); err != nil {
+			return i, err
+		}
+	}
+
+	return len(uploads), nil
+}
+
+// allowed reports if the content type is accepted for direct uploads.
+func allowed(contentType string) bool {
+	for _, ct := range contentTypes {
+		if ct == contentType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package email
+
+// disposable are well known providers of disposable email addresses. The
+// list can be extended with Config.DisposableFile, which users-admin can
+// download from a maintained list.
+var disposable = []string{
+	"10minutemail.com",
+	"10minutemail.net",
+	"20minutemail.com",
+	"33mail.com",
+	"anonbox.net",
+	"burnermail.io",
+	"discard.email",
+	"dispostable.com",
+	"dropmail.me",
+	"emailondeck.com",
+	"fakeinbox.com",
+	"fakemail.net",
+	"getairmail.com",
+	"getnada.com",
+	"grr.la",
+	"guerrillamail.biz",
+	"guerrillamail.com",
+	"guerrillamail.de",
+	"guerrillamail.info",
+	"guerrillamail.net",
+	"guerrillamail.org",
+	"guerrillamailblock.com",
+	"harakirimail.com",
+	"inboxkitten.com",
+	"incognitomail.org",
+	"jetable.org",
+	"mailcatch.com",
+	"maildrop.cc",
+	"mailinator.com",
+	"mailinator.net",
+	"mailnesia.com",
+	"mailsac.com",
+	"mintemail.com",
+	"mohmal.com",
+	"mytemp.email",
+	"mytrashmail.com",
+	"nada.email",
+	"pokemail.net",
+	"sharklasers.com",
+	"spam4.me",
+	"spambox.us",
+	"spamgourmet.com",
+	"tempail.com",
+	"tempinbox.com",
+	"temp-mail.io",
+	"temp-mail.org",
+	"tempmail.dev",
+	"tempmailo.com",
+	"tempr.email",
+	"throwawaymail.com",
+	"trashmail.com",
+	"trashmail.de",
+	"trashmail.net",
+	"wegwerfmail.de",
+	"yopmail.com",
+	"yopmail.fr",
+	"yopmail.net",
+}
@@ -0,0 +1,167 @@
+// Package email validates email addresses and decides which domains users
+// may sign up with. Addresses are checked against the addr-spec syntax of
+// RFC 5322, internationalized domains are converted with IDNA.
+package email
+
+import (
+	"net"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/idna"
+)
+
+// ErrInvalid is returned for addresses which are not syntactically valid.
+var ErrInvalid = errors.New("invalid email address")
+
+// Limits of RFC 5321 on the length of addresses in octets.
+const (
+	maxLocal   = 64
+	maxDomain  = 253
+	maxAddress = 254
+	maxLabel   = 63
+)
+
+// Address is a parsed email address. The domain is in its ASCII form and
+// lower case, the local part as it was given.
+type Address struct {
+	Local  string
+	Domain string
+}
+
+// String returns the address with the ASCII form of the domain.
+func (a Address) String() string {
+	return a.Local + "@" + a.Domain
+}
+
+// Parse parses an address in the addr-spec form, without a display name or
+// comments. Local parts may contain UTF-8 as allowed by RFC 6532.
+func Parse(addr string) (*Address, error) {
+	if !utf8.ValidString(addr) || len(addr) > maxAddress {
+		return nil, ErrInvalid
+	}
+
+	i := strings.LastIndexByte(addr, '@')
+	if i <= 0 || i == len(addr)-1 {
+		return nil, ErrInvalid
+	}
+	local, domain := addr[:i], addr[i+1:]
+
+	if len(local) > maxLocal || !(dotAtom(local) || quoted(local)) {
+		return nil, ErrInvalid
+	}
+
+	if strings.HasPrefix(domain, "[") {
+		if !literal(domain) {
+			return nil, ErrInvalid
+		}
+		return &Address{Local: local, Domain: domain}, nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(domain)
+	if err != nil || !hostname(ascii) {
+		return nil, ErrInvalid
+	}
+
+	return &Address{Local: local, Domain: strings.ToLower(ascii)}, nil
+}
+
+// Valid reports if the address can be parsed.
+func Valid(addr string) bool {
+	_, err := Parse(addr)
+	return err == nil
+}
+
+// dotAtom reports if the local part is atoms separated by single dots.
+func dotAtom(s string) bool {
+	for _, atom := range strings.Split(s, ".") {
+		if atom == "" {
+			return false
+		}
+		for _, r := range atom {
+			if !atext(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// atext reports if the rune may be part of an atom.
+func atext(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return true
+	case strings.ContainsRune("!#$%&'*+-/=?^_`{|}~", r):
+		return true
+	}
+	return r >= 0x80 && r != utf8.RuneError
+}
+
+// quoted reports if the local part is a quoted string. Quoted pairs escape
+// any printable character.
+func quoted(s string) bool {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return false
+	}
+
+	rs := []rune(s[1 : len(s)-1])
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case r == '\\':
+			i++
+			if i == len(rs) || rs[i] < ' ' || rs[i] == 0x7f {
+				return false
+			}
+		case r == '"', r < ' ' && r != '\t', r == 0x7f:
+			return false
+		}
+	}
+	return true
+}
+
+// literal reports if the domain is an address literal like [192.0.2.1] or
+// [IPv6:2001:db8::1].
+func literal(s string) bool {
+	if !strings.HasSuffix(s, "]") {
+		return false
+	}
+	s = s[1 : len(s)-1]
+
+	if strings.HasPrefix(s, "IPv6:") {
+		ip := net.ParseIP(s[len("IPv6:"):])
+		return ip != nil && ip.To4() == nil
+	}
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+}
+
+// hostname reports if the ASCII domain has at least two labels made of
+// letters, digits and hyphens and a top-level domain which is not numeric.
+func hostname(s string) bool {
+	if len(s) > maxDomain {
+		return false
+	}
+
+	labels := strings.Split(s, ".")
+	if len(labels) < 2 {
+		return false
+	}
+
+	for _, l := range labels {
+		if l == "" || len(l) > maxLabel || l[0] == '-' || l[len(l)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(l); i++ {
+			c := l[i]
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+
+	tld := labels[len(labels)-1]
+	return strings.Trim(tld, "0123456789") != ""
+}
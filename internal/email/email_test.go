@@ -0,0 +1,125 @@
+package email
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestParse(t *testing.T) {
+	valid := []struct {
+		addr   string
+		domain string
+	}{
+		{"gopher@example.com", "example.com"},
+		{"first.last+tag@Example.COM", "example.com"},
+		{"o'brien@mail.example.co.uk", "mail.example.co.uk"},
+		{"\"john doe\"@example.com", "example.com"},
+		{"\"a\\\"b\"@example.com", "example.com"},
+		{"user@[192.0.2.1]", "[192.0.2.1]"},
+		{"user@[IPv6:2001:db8::1]", "[IPv6:2001:db8::1]"},
+		{"user@b\u00fccher.example", "xn--bcher-kva.example"},
+		{"\u00fcser@example.com", "example.com"},
+	}
+
+	invalid := []string{
+		"",
+		"gopher",
+		"@example.com",
+		"gopher@",
+		"go..pher@example.com",
+		".gopher@example.com",
+		"gopher.@example.com",
+		"go pher@example.com",
+		"gopher@localhost",
+		"gopher@example..com",
+		"gopher@-example.com",
+		"gopher@example.com.",
+		"gopher@example.123",
+		"gopher@exa_mple.com",
+		"gopher@[300.0.0.1]",
+		"gopher@[2001:db8::1]",
+		"\"unterminated@example.com",
+		"a@b@example.com",
+	}
+
+	t.Log("Given the need to parse email addresses.")
+	{
+		for _, tt := range valid {
+			a, err := Parse(tt.addr)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to parse %q : %s.", failed, tt.addr, err)
+			}
+			if a.Domain != tt.domain {
+				t.Fatalf("\t%s\tShould get domain of %q : got %q, want %q.", failed, tt.addr, a.Domain, tt.domain)
+			}
+		}
+		t.Logf("\t%s\tShould be able to parse valid addresses.", success)
+
+		for _, addr := range invalid {
+			if Valid(addr) {
+				t.Fatalf("\t%s\tShould reject %q.", failed, addr)
+			}
+		}
+		t.Logf("\t%s\tShould reject invalid addresses.", success)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "disposable.txt")
+	if err := ioutil.WriteFile(file, []byte("# providers\n\nthrowaway.example\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		cfg    Config
+		addr   string
+		reason string
+	}{
+		{"open", Config{}, "gopher@example.com", ""},
+		{"invalid", Config{}, "gopher@", ReasonInvalid},
+		{"bundled", Config{}, "gopher@mailinator.com", ReasonDisposable},
+		{"file", Config{DisposableFile: file}, "gopher@mx.throwaway.example", ReasonDisposable},
+		{"deny", Config{Deny: []string{"spam.example"}}, "gopher@eu.spam.example", ReasonDenied},
+		{"allow", Config{Allow: []string{"yopmail.com"}}, "gopher@yopmail.com", ""},
+		{"idna", Config{Deny: []string{"b\u00fccher.example"}}, "gopher@xn--bcher-kva.example", ReasonDenied},
+		{"invited", Config{Allow: []string{"corp.example"}, InviteOnly: true}, "gopher@dev.corp.example", ""},
+		{"not invited", Config{Allow: []string{"corp.example"}, InviteOnly: true}, "gopher@example.com", ReasonNotInvited},
+	}
+
+	t.Log("Given the need to enforce the email policy.")
+	{
+		for _, tt := range tests {
+			p, err := New(tt.cfg)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to construct a policy for %s : %s.", failed, tt.name, err)
+			}
+
+			err = p.Check(tt.addr)
+			var reason string
+			if v, ok := err.(*Violation); ok {
+				reason = v.Reason
+			} else if err != nil {
+				t.Fatalf("\t%s\tShould only return violations for %s : got %v.", failed, tt.name, err)
+			}
+			if reason != tt.reason {
+				t.Fatalf("\t%s\tShould check %s : got %q, want %q.", failed, tt.name, reason, tt.reason)
+			}
+		}
+		t.Logf("\t%s\tShould reject addresses which break the policy.", success)
+	}
+}
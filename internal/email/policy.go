@@ -0,0 +1,172 @@
+package email
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/idna"
+)
+
+// Reasons an address is rejected for. They are reported as the error of the
+// field holding the address.
+const (
+	ReasonInvalid    = "invalid"
+	ReasonDenied     = "denied_domain"
+	ReasonDisposable = "disposable_domain"
+	ReasonNotInvited = "domain_not_invited"
+)
+
+// Violation is returned for addresses which break the policy.
+type Violation struct {
+	Reason string
+}
+
+// Error implements the error interface.
+func (v *Violation) Error() string {
+	return "email rejected : " + v.Reason
+}
+
+// Config is the required properties to use the policy. Domains match their
+// subdomains as well.
+type Config struct {
+
+	// Allow are domains which are always accepted. In invite-only mode they
+	// are the only ones.
+	Allow []string
+
+	// Deny are domains which are never accepted.
+	Deny []string
+
+	// DisposableFile is an optional list of disposable email providers with
+	// one domain per line, which extends the bundled list. Empty lines and
+	// lines starting with # are skipped.
+	DisposableFile string
+
+	// InviteOnly restricts sign ups to the allowed domains, for example the
+	// ones of a company.
+	InviteOnly bool
+}
+
+// Policy checks email addresses.
+type Policy struct {
+	cfg        Config
+	allow      map[string]bool
+	deny       map[string]bool
+	disposable map[string]bool
+}
+
+// New constructs a Policy for use, reading the disposable providers of the
+// config.
+func New(cfg Config) (*Policy, error) {
+	p := Policy{
+		cfg:        cfg,
+		allow:      make(map[string]bool),
+		deny:       make(map[string]bool),
+		disposable: make(map[string]bool),
+	}
+
+	domains := disposable
+	if cfg.DisposableFile != "" {
+		f, err := os.Open(cfg.DisposableFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "opening disposable domains")
+		}
+		defer f.Close()
+
+		extra, err := ReadDomains(f)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading disposable domains")
+		}
+		domains = append(append([]string(nil), disposable...), extra...)
+	}
+
+	if err := add(p.allow, cfg.Allow); err != nil {
+		return nil, err
+	}
+	if err := add(p.deny, cfg.Deny); err != nil {
+		return nil, err
+	}
+	if err := add(p.disposable, domains); err != nil {
+		return nil, err
+	}
+
+	return &p, nil
+}
+
+// Check reports if users may sign up with the address.
+func (p *Policy) Check(addr string) error {
+	a, err := Parse(addr)
+	if err != nil {
+		return &Violation{Reason: ReasonInvalid}
+	}
+
+	switch {
+	case match(p.allow, a.Domain):
+		return nil
+	case p.cfg.InviteOnly:
+		return &Violation{Reason: ReasonNotInvited}
+	case match(p.deny, a.Domain):
+		return &Violation{Reason: ReasonDenied}
+	case match(p.disposable, a.Domain):
+		return &Violation{Reason: ReasonDisposable}
+	}
+
+	return nil
+}
+
+// ReadDomains reads a list of domains with one domain per line. Empty lines
+// and lines starting with # are skipped.
+func ReadDomains(r io.Reader) ([]string, error) {
+	var domains []string
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		domains = append(domains, line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return domains, nil
+}
+
+// match reports if the domain or one of its parents is in the set.
+func match(set map[string]bool, domain string) bool {
+	for {
+		if set[domain] {
+			return true
+		}
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			return false
+		}
+		domain = domain[i+1:]
+	}
+}
+
+// add adds the domains to the set in their normalized form.
+func add(set map[string]bool, domains []string) error {
+	for _, d := range domains {
+		ascii, err := normalize(d)
+		if err != nil {
+			return errors.Wrapf(err, "invalid domain %q", d)
+		}
+		set[ascii] = true
+	}
+	return nil
+}
+
+// normalize returns the lower case ASCII form of a domain of a list.
+func normalize(domain string) (string, error) {
+	ascii, err := idna.Lookup.ToASCII(strings.TrimSpace(domain))
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(strings.TrimSuffix(ascii, ".")), nil
+}
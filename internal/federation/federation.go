@@ -0,0 +1,384 @@
+// Package federation implements logging in with external OpenID Connect
+// identity providers. External identities are linked to local users.
+package federation
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/email"
+	"github.com/igomonov88/users/internal/platform/encryption"
+	"github.com/igomonov88/users/internal/storage"
+	"github.com/igomonov88/users/internal/username"
+)
+
+var (
+	// ErrUnknownProvider is returned for a provider which is not configured.
+	ErrUnknownProvider = errors.New("unknown identity provider")
+
+	// ErrInvalidState is returned when the callback does not belong to a
+	// login started by the same browser or the login took too long.
+	ErrInvalidState = errors.New("invalid or expired login state")
+
+	// ErrExchange is returned when the provider rejects the code.
+	ErrExchange = errors.New("exchanging authorization code failed")
+
+	// ErrInvalidIDToken is returned when an ID token fails verification.
+	ErrInvalidIDToken = errors.New("invalid id token")
+
+	// ErrNoAccount is returned when the identity is not linked to a user and
+	// can't be linked or provisioned.
+	ErrNoAccount = errors.New("no account is linked to this identity")
+)
+
+// ProviderConfig is the configuration of an identity provider.
+type ProviderConfig struct {
+	Name         string   `json:"name"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	RedirectURL  string   `json:"redirect_url"`
+	Scopes       []string `json:"scopes"`
+
+	// Provision creates a local user on the first login of an identity
+	// which is not linked yet.
+	Provision bool `json:"provision"`
+
+	// LinkByEmail links an identity which is not linked yet to the local
+	// user with the same email, if the provider verified the email. Only
+	// enable it for providers which are trusted to verify emails.
+	LinkByEmail bool `json:"link_by_email"`
+}
+
+// LoadProviders reads the configuration of the providers from a JSON file
+// holding an array of provider configurations.
+func LoadProviders(path string) ([]ProviderConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading providers")
+	}
+
+	var cfgs []ProviderConfig
+	if err := json.Unmarshal(b, &cfgs); err != nil {
+		return nil, errors.Wrap(err, "decoding providers")
+	}
+
+	for _, c := range cfgs {
+		if c.Name == "" || c.Issuer == "" || c.ClientID == "" || c.RedirectURL == "" {
+			return nil, errors.Errorf("provider %q requires name, issuer, client_id and redirect_url", c.Name)
+		}
+	}
+
+	return cfgs, nil
+}
+
+// Config is the required properties to use federation.
+type Config struct {
+
+	// StateTTL is how long a user has to log in at the provider.
+	StateTTL time.Duration
+
+	// Emails is the policy provisioned users are held to like users signing
+	// up. Nil accepts any address.
+	Emails *email.Policy
+
+	// Names is the user name policy provisioned users are held to. Nil
+	// accepts any name.
+	Names *username.Policy
+}
+
+// Federation logs users in with the configured providers.
+type Federation struct {
+	cfg       Config
+	db        *sqlx.DB
+	enc       *encryption.Encrypter
+	providers map[string]*Provider
+}
+
+// New constructs a Federation for use. The state of logins in progress is
+// kept encrypted by the browser, so it needs no storage.
+func New(cfg Config, db *sqlx.DB, enc *encryption.Encrypter, client *http.Client, providers []ProviderConfig) *Federation {
+	f := Federation{
+		cfg:       cfg,
+		db:        db,
+		enc:       enc,
+		providers: make(map[string]*Provider),
+	}
+	for _, pc := range providers {
+		f.providers[pc.Name] = NewProvider(pc, client)
+	}
+
+	return &f
+}
+
+// Providers returns the names of the configured providers.
+func (f *Federation) Providers() []string {
+	names := make([]string, 0, len(f.providers))
+	for name := range f.providers {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Secure reports if the callback of the provider is served over https, so
+// the state cookie can be marked secure.
+func (f *Federation) Secure(provider string) bool {
+	p, ok := f.providers[provider]
+	return ok && strings.HasPrefix(p.cfg.RedirectURL, "https://")
+}
+
+// state is what is kept between starting and finishing a login.
+type state struct {
+	Provider string `json:"p"`
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"`
+	Expires  int64  `json:"e"`
+}
+
+// Begin starts a login with the provider. It returns the uri to send the
+// user to and the sealed state which has to be presented to Finish, usually
+// in a cookie.
+func (f *Federation) Begin(ctx context.Context, provider string, now time.Time) (string, string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.federation.Begin")
+	defer span.End()
+
+	p, ok := f.providers[provider]
+	if !ok {
+		return "", "", ErrUnknownProvider
+	}
+
+	st := state{
+		Provider: provider,
+		Expires:  now.Add(f.cfg.StateTTL).Unix(),
+	}
+	for _, s := range []*string{&st.State, &st.Nonce, &st.Verifier} {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return "", "", errors.Wrap(err, "reading random bytes")
+		}
+		*s = base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	sum := sha256.Sum256([]byte(st.Verifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	uri, err := p.AuthCodeURL(ctx, st.State, st.Nonce, challenge)
+	if err != nil {
+		return "", "", err
+	}
+
+	b, err := json.Marshal(st)
+	if err != nil {
+		return "", "", errors.Wrap(err, "encoding state")
+	}
+	sealed, err := f.enc.Seal(b, []byte(provider))
+	if err != nil {
+		return "", "", errors.Wrap(err, "encrypting state")
+	}
+
+	return uri, base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Finish completes a login with the code the provider redirected the user
+// back with. It returns the local user and the verified ID token.
+func (f *Federation) Finish(ctx context.Context, provider, sealed, stateParam, code string, now time.Time) (*storage.User, *IDToken, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.federation.Finish")
+	defer span.End()
+
+	p, ok := f.providers[provider]
+	if !ok {
+		return nil, nil, ErrUnknownProvider
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, nil, ErrInvalidState
+	}
+	b, err = f.enc.Open(b, []byte(provider))
+	if err != nil {
+		return nil, nil, ErrInvalidState
+	}
+	var st state
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, nil, ErrInvalidState
+	}
+	if st.Provider != provider || now.Unix() > st.Expires ||
+		subtle.ConstantTimeCompare([]byte(st.State), []byte(stateParam)) != 1 {
+		return nil, nil, ErrInvalidState
+	}
+
+	raw, err := p.Exchange(ctx, code, st.Verifier)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tok, err := p.Verify(ctx, raw, st.Nonce, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	usr, err := f.user(ctx, p, tok, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return usr, tok, nil
+}
+
+// user finds the local user linked to the identity. Identities which are
+// not linked yet are linked by email or provisioned if the provider allows.
+func (f *Federation) user(ctx context.Context, p *Provider, tok *IDToken, now time.Time) (*storage.User, error) {
+	id, err := storage.RetrieveIdentity(ctx, f.db, p.cfg.Name, tok.Subject)
+	switch err {
+	case nil:
+		if err := storage.TouchIdentity(ctx, f.db, p.cfg.Name, tok.Subject, tok.Email, now); err != nil {
+			return nil, err
+		}
+		return storage.Retrieve(ctx, f.db, id.UserID)
+	case storage.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	if tok.Email == "" || !tok.EmailVerified {
+		return nil, ErrNoAccount
+	}
+
+	var usr *storage.User
+	if p.cfg.LinkByEmail {
+		usr, err = storage.RetrieveByEmail(ctx, f.db, tok.Email)
+		if err != nil && err != storage.ErrNotFound {
+			return nil, err
+		}
+	}
+
+	if usr == nil {
+		if !p.cfg.Provision {
+			return nil, ErrNoAccount
+		}
+		if usr, err = f.provision(ctx, tok); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := storage.LinkIdentity(ctx, f.db, p.cfg.Name, tok.Subject, usr.ID, tok.Email, now); err != nil {
+		return nil, err
+	}
+
+	return usr, nil
+}
+
+// provision creates a local user for the identity. The user gets a random
+// password, so logging in is only possible through the provider until the
+// password is reset.
+func (f *Federation) provision(ctx context.Context, tok *IDToken) (*storage.User, error) {
+	if f.cfg.Emails != nil {
+		if err := f.cfg.Emails.Check(tok.Email); err != nil {
+			return nil, ErrNoAccount
+		}
+	}
+
+	name := tok.PreferredUsername
+	if name == "" {
+		name = strings.SplitN(tok.Email, "@", 2)[0]
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, errors.Wrap(err, "reading random password")
+	}
+	password := base64.RawURLEncoding.EncodeToString(b)
+
+	for attempt := 0; ; attempt++ {
+		candidate, err := f.userName(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+
+		usr, err := storage.Create(ctx, f.db, tok.Email, candidate, "", password)
+		switch errors.Cause(err) {
+		case nil:
+			return usr, nil
+		case storage.ErrUserNameAlreadyExist:
+
+			// The name was taken since it was checked.
+			if attempt < 3 {
+				continue
+			}
+		case storage.ErrEmailAlreadyExist:
+
+			// Only LinkByEmail may link to an existing user.
+			return nil, ErrNoAccount
+		}
+		return nil, errors.Wrap(err, "provisioning user")
+	}
+}
+
+// userName returns a name for a provisioned user which follows the user name
+// policy and can't be confused with the name of another user, like the names
+// users sign up with. A name the policy rejects is replaced by a generated
+// one, a taken name gets a random suffix.
+func (f *Federation) userName(ctx context.Context, name string) (string, error) {
+	generated := false
+	if err := f.checkUserName(name); err != nil {
+		if _, ok := err.(*username.Violation); !ok {
+			return "", err
+		}
+		generated = true
+	}
+
+	for attempt := 0; attempt < 5; attempt++ {
+		candidate := name
+		if generated {
+			candidate = "user"
+		}
+		if generated || attempt > 0 {
+			suffix := make([]byte, 3)
+			if _, err := rand.Read(suffix); err != nil {
+				return "", errors.Wrap(err, "reading random suffix")
+			}
+			candidate += "-" + strings.ToLower(base64.RawURLEncoding.EncodeToString(suffix))
+		}
+
+		// A suffix can make the name too long for the policy.
+		if err := f.checkUserName(candidate); err != nil {
+			if _, ok := err.(*username.Violation); !ok {
+				return "", err
+			}
+			generated = true
+			continue
+		}
+
+		exist, err := storage.DoesUserNameSkeletonExist(ctx, f.db, username.Skeleton(candidate), "")
+		if err != nil {
+			return "", err
+		}
+		if !exist {
+			return candidate, nil
+		}
+	}
+
+	return "", errors.Wrapf(ErrNoAccount, "no user name available for %q", name)
+}
+
+// checkUserName checks the name against the user name policy, if there is
+// one.
+func (f *Federation) checkUserName(name string) error {
+	if f.cfg.Names == nil {
+		return nil
+	}
+	return f.cfg.Names.Check(name)
+}
@@ -0,0 +1,329 @@
+package federation_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+
+	"github.com/igomonov88/users/internal/federation"
+	"github.com/igomonov88/users/internal/platform/encryption"
+	"github.com/igomonov88/users/internal/storage"
+	"github.com/igomonov88/users/internal/tests"
+	"github.com/igomonov88/users/internal/username"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// idp is a mock OpenID Connect provider.
+type idp struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	claims    jwt.MapClaims
+}
+
+// newIDP starts a mock provider which issues an ID token with the claims
+// for a code requested with the matching PKCE verifier.
+func newIDP(t *testing.T) *idp {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := idp{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "idp",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if id != "client" || secret != "secret" || r.PostFormValue("code") != "code" ||
+			base64.RawURLEncoding.EncodeToString(sum[:]) != p.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": p.sign(t, p.key, p.claims)})
+	})
+	p.Server = httptest.NewServer(mux)
+
+	return &p
+}
+
+// sign returns the claims as a token signed with the key.
+func (p *idp) sign(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	tkn := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tkn.Header["kid"] = "idp"
+	s, err := tkn.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestProvider(t *testing.T) {
+	p := newIDP(t)
+	defer p.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+	prov := federation.NewProvider(federation.ProviderConfig{
+		Name:         "corp",
+		Issuer:       p.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/callback",
+	}, p.Client())
+
+	claims := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":            p.URL,
+			"sub":            "employee-1",
+			"aud":            "client",
+			"exp":            now.Add(time.Minute).Unix(),
+			"iat":            now.Unix(),
+			"nonce":          "nonce",
+			"email":          "gopher@corp.example.com",
+			"email_verified": true,
+			"amr":            []string{"pwd", "mfa"},
+		}
+	}
+
+	t.Log("Given the need to log in with an external identity provider.")
+	{
+		verifier := "verifier-verifier-verifier-verifier-verifier"
+		sum := sha256.Sum256([]byte(verifier))
+		p.challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+
+		uri, err := prov.AuthCodeURL(ctx, "state", "nonce", p.challenge)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to build the login uri : %s.", failed, err)
+		}
+		u, _ := url.Parse(uri)
+		q := u.Query()
+		if u.Path != "/authorize" || q.Get("client_id") != "client" || q.Get("code_challenge_method") != "S256" || q.Get("state") != "state" {
+			t.Fatalf("\t%s\tShould build the login uri from the discovery document : %s.", failed, uri)
+		}
+		t.Logf("\t%s\tShould build the login uri from the discovery document.", success)
+
+		p.claims = claims()
+		raw, err := prov.Exchange(ctx, "code", verifier)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to exchange the code : %s.", failed, err)
+		}
+		t.Logf("\t%s\tShould be able to exchange the code.", success)
+
+		if _, err := prov.Exchange(ctx, "code", "another-verifier-another-verifier-another"); err == nil {
+			t.Fatalf("\t%s\tShould fail the exchange with another verifier.", failed)
+		}
+		t.Logf("\t%s\tShould fail the exchange with another verifier.", success)
+
+		tok, err := prov.Verify(ctx, raw, "nonce", now)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to verify the id token : %s.", failed, err)
+		}
+		if tok.Subject != "employee-1" || tok.Email != "gopher@corp.example.com" || !tok.EmailVerified || !tok.MFA() {
+			t.Fatalf("\t%s\tShould read the claims of the id token : %+v.", failed, tok)
+		}
+		t.Logf("\t%s\tShould verify the id token against the provider keys.", success)
+
+		if _, err := prov.Verify(ctx, raw, "other", now); err == nil {
+			t.Fatalf("\t%s\tShould reject another nonce.", failed)
+		}
+		t.Logf("\t%s\tShould reject another nonce.", success)
+
+		if _, err := prov.Verify(ctx, raw, "nonce", now.Add(time.Hour)); err == nil {
+			t.Fatalf("\t%s\tShould reject an expired id token.", failed)
+		}
+		t.Logf("\t%s\tShould reject an expired id token.", success)
+
+		c := claims()
+		c["aud"] = []string{"other"}
+		if _, err := prov.Verify(ctx, p.sign(t, p.key, c), "nonce", now); err == nil {
+			t.Fatalf("\t%s\tShould reject an id token for another client.", failed)
+		}
+		t.Logf("\t%s\tShould reject an id token for another client.", success)
+
+		c = claims()
+		c["iss"] = "https://evil.example.com"
+		if _, err := prov.Verify(ctx, p.sign(t, p.key, c), "nonce", now); err == nil {
+			t.Fatalf("\t%s\tShould reject an id token of another issuer.", failed)
+		}
+		t.Logf("\t%s\tShould reject an id token of another issuer.", success)
+
+		other, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := prov.Verify(ctx, p.sign(t, other, claims()), "nonce", now); err == nil {
+			t.Fatalf("\t%s\tShould reject an id token signed with another key.", failed)
+		}
+		t.Logf("\t%s\tShould reject an id token signed with another key.", success)
+	}
+}
+
+func TestState(t *testing.T) {
+	p := newIDP(t)
+	defer p.Close()
+
+	enc, err := encryption.New("ZGV2ZWxvcG1lbnQta2V5LWRvLW5vdC11c2UtaW4tcHI=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	f := federation.New(federation.Config{StateTTL: time.Minute}, nil, enc, p.Client(), []federation.ProviderConfig{{
+		Name:         "corp",
+		Issuer:       p.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/callback",
+	}})
+
+	t.Log("Given the need to bind a login to the browser which started it.")
+	{
+		if _, _, err := f.Begin(ctx, "unknown", now); err != federation.ErrUnknownProvider {
+			t.Fatalf("\t%s\tShould reject an unknown provider : %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould reject an unknown provider.", success)
+
+		uri, sealed, err := f.Begin(ctx, "corp", now)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to begin a login : %s.", failed, err)
+		}
+		u, _ := url.Parse(uri)
+		state := u.Query().Get("state")
+		t.Logf("\t%s\tShould be able to begin a login.", success)
+
+		if _, _, err := f.Finish(ctx, "corp", sealed, "forged", "code", now); err != federation.ErrInvalidState {
+			t.Fatalf("\t%s\tShould reject another state : %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould reject another state.", success)
+
+		if _, _, err := f.Finish(ctx, "corp", sealed[1:], state, "code", now); err != federation.ErrInvalidState {
+			t.Fatalf("\t%s\tShould reject a tampered state : %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould reject a tampered state.", success)
+
+		if _, _, err := f.Finish(ctx, "corp", sealed, state, "code", now.Add(2*time.Minute)); err != federation.ErrInvalidState {
+			t.Fatalf("\t%s\tShould reject an expired state : %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould reject an expired state.", success)
+	}
+}
+
+func TestProvision(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	p := newIDP(t)
+	defer p.Close()
+
+	enc, err := encryption.New("ZGV2ZWxvcG1lbnQta2V5LWRvLW5vdC11c2UtaW4tcHI=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names, err := username.New(username.Config{
+		MinLength: 3,
+		MaxLength: 32,
+		Allowed:   `\p{L}\p{N}_.-`,
+		Reserved:  []string{"admin"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	f := federation.New(federation.Config{StateTTL: time.Minute, Names: names}, db, enc, p.Client(), []federation.ProviderConfig{{
+		Name:         "corp",
+		Issuer:       p.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost/callback",
+		Provision:    true,
+	}})
+
+	// login logs in with the identity and returns the provisioned user.
+	login := func(subject, name string) *storage.User {
+		t.Helper()
+		uri, sealed, err := f.Begin(ctx, "corp", now)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to begin a login : %s.", failed, err)
+		}
+		u, _ := url.Parse(uri)
+		q := u.Query()
+		p.challenge = q.Get("code_challenge")
+		p.claims = jwt.MapClaims{
+			"iss":                p.URL,
+			"sub":                subject,
+			"aud":                "client",
+			"exp":                now.Add(time.Minute).Unix(),
+			"iat":                now.Unix(),
+			"nonce":              q.Get("nonce"),
+			"email":              subject + "@corp.example.com",
+			"email_verified":     true,
+			"preferred_username": name,
+		}
+		usr, _, err := f.Finish(ctx, "corp", sealed, q.Get("state"), "code", now)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to log in : %s.", failed, err)
+		}
+		return usr
+	}
+
+	t.Log("Given the need to hold provisioned users to the user name policy.")
+	{
+		if usr := login("employee-1", "gopher"); usr.Name != "gopher" {
+			t.Fatalf("\t%s\tShould keep the name of the identity : got %q.", failed, usr.Name)
+		}
+		t.Logf("\t%s\tShould keep the name of the identity.", success)
+
+		if usr := login("employee-2", "gоpher"); !strings.HasPrefix(usr.Name, "gоpher-") {
+			t.Fatalf("\t%s\tShould add a suffix to a name confusable with another : got %q.", failed, usr.Name)
+		}
+		t.Logf("\t%s\tShould add a suffix to a name confusable with another.", success)
+
+		if usr := login("employee-3", "admin"); !strings.HasPrefix(usr.Name, "user-") {
+			t.Fatalf("\t%s\tShould generate a name for a reserved one : got %q.", failed, usr.Name)
+		}
+		if usr := login("employee-4", "go pher!"); !strings.HasPrefix(usr.Name, "user-") {
+			t.Fatalf("\t%s\tShould generate a name for one with invalid characters : got %q.", failed, usr.Name)
+		}
+		t.Logf("\t%s\tShould generate a name for one the policy rejects.", success)
+	}
+}
@@ -0,0 +1,367 @@
+package federation
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// leeway is the clock skew allowed when checking the times of ID tokens.
+const leeway = time.Minute
+
+// refetchInterval limits how often the keys of a provider are fetched again
+// when a token is signed with an unknown key id.
+const refetchInterval = time.Minute
+
+// IDToken holds the verified claims of an ID token.
+type IDToken struct {
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	Name              string
+	PreferredUsername string
+	AMR               []string
+}
+
+// MFA reports if the provider says the user authenticated with more than
+// one factor.
+func (t *IDToken) MFA() bool {
+	for _, m := range t.AMR {
+		switch m {
+		case "mfa", "otp", "hwk", "swk", "sms":
+			return true
+		}
+	}
+	return false
+}
+
+// metadata is the part of the discovery document of a provider we use.
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Provider is an external OpenID Connect identity provider. The discovery
+// document and the keys are fetched on first use, so the service starts
+// while a provider is unavailable.
+type Provider struct {
+	cfg    ProviderConfig
+	client *http.Client
+
+	mu      sync.Mutex
+	meta    *metadata
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+// NewProvider constructs a Provider for use.
+func NewProvider(cfg ProviderConfig, client *http.Client) *Provider {
+	cfg.Issuer = strings.TrimRight(cfg.Issuer, "/")
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"openid", "email", "profile"}
+	}
+
+	return &Provider{
+		cfg:    cfg,
+		client: client,
+	}
+}
+
+// Name returns the name the provider is configured with.
+func (p *Provider) Name() string {
+	return p.cfg.Name
+}
+
+// AuthCodeURL returns the uri of the provider users are sent to for logging
+// in. The code challenge is the S256 challenge of the code verifier.
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, challenge string) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", errors.Wrap(err, "parsing authorization endpoint")
+	}
+
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.cfg.ClientID)
+	q.Set("redirect_uri", p.cfg.RedirectURL)
+	q.Set("scope", strings.Join(p.cfg.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", challenge)
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// Exchange redeems the authorization code at the token endpoint of the
+// provider and returns the raw ID token.
+func (p *Provider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.federation.Exchange")
+	defer span.End()
+
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.cfg.RedirectURL)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequest(http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", errors.Wrap(err, "creating token request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "requesting token")
+	}
+	defer resp.Body.Close()
+
+	var tkn struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tkn); err != nil {
+		return "", errors.Wrap(err, "decoding token response")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Wrapf(ErrExchange, "%s: %s %s", resp.Status, tkn.Error, tkn.ErrorDescription)
+	}
+	if tkn.IDToken == "" {
+		return "", errors.Wrap(ErrExchange, "no id token in response")
+	}
+
+	return tkn.IDToken, nil
+}
+
+// Verify checks the signature, issuer, audience, times and nonce of the ID
+// token and returns its claims.
+func (p *Provider) Verify(ctx context.Context, raw, nonce string, now time.Time) (*IDToken, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.federation.Verify")
+	defer span.End()
+
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	keyFunc := func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return p.key(ctx, kid)
+	}
+
+	parser := jwt.Parser{ValidMethods: []string{"RS256", "RS384", "RS512"}}
+
+	var c idClaims
+	if _, err := parser.ParseWithClaims(raw, &c, keyFunc); err != nil {
+		return nil, errors.Wrap(ErrInvalidIDToken, err.Error())
+	}
+
+	switch {
+	case c.Issuer != meta.Issuer:
+		return nil, errors.Wrapf(ErrInvalidIDToken, "issuer %q", c.Issuer)
+	case !c.Audience.contains(p.cfg.ClientID):
+		return nil, errors.Wrap(ErrInvalidIDToken, "audience")
+	case len(c.Audience) > 1 && c.AuthorizedParty != p.cfg.ClientID:
+		return nil, errors.Wrap(ErrInvalidIDToken, "authorized party")
+	case now.After(time.Unix(c.ExpiresAt, 0).Add(leeway)):
+		return nil, errors.Wrap(ErrInvalidIDToken, "expired")
+	case now.Add(leeway).Before(time.Unix(c.IssuedAt, 0)):
+		return nil, errors.Wrap(ErrInvalidIDToken, "issued in the future")
+	case c.Subject == "":
+		return nil, errors.Wrap(ErrInvalidIDToken, "no subject")
+	case subtle.ConstantTimeCompare([]byte(c.Nonce), []byte(nonce)) != 1:
+		return nil, errors.Wrap(ErrInvalidIDToken, "nonce")
+	}
+
+	t := IDToken{
+		Subject:           c.Subject,
+		Email:             c.Email,
+		EmailVerified:     c.EmailVerified,
+		Name:              c.Name,
+		PreferredUsername: c.PreferredUsername,
+		AMR:               c.AMR,
+	}
+
+	return &t, nil
+}
+
+// metadata fetches the discovery document of the provider once.
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	var meta metadata
+	if err := p.get(ctx, p.cfg.Issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, errors.Wrapf(err, "discovering provider %q", p.cfg.Name)
+	}
+
+	// The issuer must be exactly the configured one (OpenID Connect
+	// Discovery section 4.3).
+	if meta.Issuer != p.cfg.Issuer {
+		return nil, errors.Errorf("provider %q issuer %q does not match %q", p.cfg.Name, meta.Issuer, p.cfg.Issuer)
+	}
+
+	p.meta = &meta
+	return p.meta, nil
+}
+
+// key returns the public key with the key id. The keys are fetched again
+// when the id is unknown, as the provider may have rotated its keys.
+func (p *Provider) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if k, ok := p.keys[kid]; ok {
+		return k, nil
+	}
+
+	if time.Since(p.fetched) < refetchInterval {
+		return nil, errors.Errorf("unknown key id %q", kid)
+	}
+	p.fetched = time.Now()
+
+	var set struct {
+		Keys []struct {
+			KeyType string `json:"kty"`
+			KeyID   string `json:"kid"`
+			Use     string `json:"use"`
+			N       string `json:"n"`
+			E       string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := p.get(ctx, p.meta.JWKSURI, &set); err != nil {
+		return nil, errors.Wrapf(err, "fetching keys of provider %q", p.cfg.Name)
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range set.Keys {
+		if k.KeyType != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[k.KeyID] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	p.keys = keys
+
+	k, ok := p.keys[kid]
+	if !ok {
+		return nil, errors.Errorf("unknown key id %q", kid)
+	}
+
+	return k, nil
+}
+
+// get decodes the JSON document at the url.
+func (p *Provider) get(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status %s", resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// idClaims are the claims of an ID token we read.
+type idClaims struct {
+	Issuer            string   `json:"iss"`
+	Subject           string   `json:"sub"`
+	Audience          audience `json:"aud"`
+	AuthorizedParty   string   `json:"azp"`
+	ExpiresAt         int64    `json:"exp"`
+	IssuedAt          int64    `json:"iat"`
+	Nonce             string   `json:"nonce"`
+	Email             string   `json:"email"`
+	EmailVerified     bool     `json:"email_verified"`
+	Name              string   `json:"name"`
+	PreferredUsername string   `json:"preferred_username"`
+	AMR               []string `json:"amr"`
+}
+
+// Valid is called during the parsing of a token. The claims are checked by
+// Verify against the provided time instead.
+func (c *idClaims) Valid() error {
+	return nil
+}
+
+// audience is the aud claim, which is a string or an array of strings.
+type audience []string
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err == nil {
+		*a = audience{s}
+		return nil
+	}
+
+	var l []string
+	if err := json.Unmarshal(b, &l); err != nil {
+		return err
+	}
+	*a = l
+	return nil
+}
+
+// contains reports if the audience includes the client id.
+func (a audience) contains(clientID string) bool {
+	for _, s := range a {
+		if s == clientID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,224 @@
+// Package identicon generates default avatars for users without one. The
+// avatars are derived from the user ID, so a user always gets the same one.
+package identicon
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Bounds of the sizes avatars are generated in, in pixels.
+const (
+	MinSize     = 16
+	MaxSize     = 512
+	DefaultSize = 128
+)
+
+// version is part of the ETag, so changing how avatars are drawn invalidates
+// the cached ones.
+const version = "1"
+
+// grid is the number of cells of the pattern in each direction. The pattern
+// is mirrored, so only the first three columns come from the hash.
+const grid = 5
+
+// Avatar is the generated avatar of a user. It shows the initials of the
+// user if there are any, otherwise a symmetric pattern.
+type Avatar struct {
+	id       string
+	hash     [32]byte
+	initials string
+}
+
+// New constructs the pattern avatar of the user.
+func New(userID string) *Avatar {
+	return &Avatar{
+		id:   userID,
+		hash: sha256.Sum256([]byte(userID)),
+	}
+}
+
+// NewInitials constructs the avatar showing the initials of the name. Names
+// without letters or digits the font has get the pattern avatar.
+func NewInitials(userID, name string) *Avatar {
+	a := New(userID)
+	a.initials = initials(name)
+	return a
+}
+
+// ETag identifies the avatar in the format and size.
+func (a *Avatar) ETag(format string, size int) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{version, a.id, a.initials, format, strconv.Itoa(size)}, "\x00")))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// PNG draws the avatar as a square PNG image of the size.
+func (a *Avatar) PNG(size int) ([]byte, error) {
+	bg, fg := a.colors()
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{bg, fg})
+
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			if a.at(x, y, size) {
+				img.SetColorIndex(x, y, 1)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, errors.Wrap(err, "encoding png")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SVG draws the avatar as a square SVG image of the size.
+func (a *Avatar) SVG(size int) []byte {
+	bg, fg := a.colors()
+
+	var buf bytes.Buffer
+	if a.initials != "" {
+		fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 100 100">`, size, size)
+		fmt.Fprintf(&buf, `<rect width="100" height="100" fill="%s"/>`, hexColor(bg))
+		fmt.Fprintf(&buf, `<text x="50" y="50" dy=".35em" text-anchor="middle" font-family="sans-serif" font-size="40" fill="%s">%s</text>`, hexColor(fg), a.initials)
+		buf.WriteString(`</svg>`)
+		return buf.Bytes()
+	}
+
+	// Cells are two units wide with a margin of one unit.
+	units := 2*grid + 2
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, units, units)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="%s"/>`, units, units, hexColor(bg))
+	for row := 0; row < grid; row++ {
+		for col := 0; col < grid; col++ {
+			if a.filled(row, col) {
+				fmt.Fprintf(&buf, `<rect x="%d" y="%d" width="2" height="2" fill="%s"/>`, 1+2*col, 1+2*row, hexColor(fg))
+			}
+		}
+	}
+	buf.WriteString(`</svg>`)
+
+	return buf.Bytes()
+}
+
+// at reports if the pixel of an image of the size has the foreground color.
+func (a *Avatar) at(x, y, size int) bool {
+	px, py := float64(x)+0.5, float64(y)+0.5
+
+	if a.initials == "" {
+		unit := float64(size) / float64(grid+1)
+		col := int(math.Floor(px/unit - 0.5))
+		row := int(math.Floor(py/unit - 0.5))
+		if col < 0 || col >= grid || row < 0 || row >= grid {
+			return false
+		}
+		return a.filled(row, col)
+	}
+
+	// The text takes half of the image and is centered.
+	n := len(a.initials)
+	tw := n*glyphWidth + n - 1
+	scale := float64(size) / 2 / math.Max(float64(tw), glyphHeight)
+	u := (px - (float64(size)-float64(tw)*scale)/2) / scale
+	v := (py - (float64(size)-glyphHeight*scale)/2) / scale
+	if u < 0 || u >= float64(tw) || v < 0 || v >= glyphHeight {
+		return false
+	}
+
+	i, gx := int(u)/(glyphWidth+1), int(u)%(glyphWidth+1)
+	if gx >= glyphWidth {
+		return false
+	}
+	return font[rune(a.initials[i])][int(v)][gx] == '#'
+}
+
+// filled reports if the cell of the pattern has the foreground color.
+func (a *Avatar) filled(row, col int) bool {
+	if col >= (grid+1)/2 {
+		col = grid - 1 - col
+	}
+	bit := row*((grid+1)/2) + col
+	return a.hash[2+bit/8]>>(uint(bit)%8)&1 == 1
+}
+
+// colors returns the background and the foreground color of the avatar. The
+// hue comes from the hash.
+func (a *Avatar) colors() (color.RGBA, color.RGBA) {
+	hue := float64(binary.BigEndian.Uint16(a.hash[:2])) / 65536 * 360
+	c := hsl(hue, 0.5, 0.5)
+
+	if a.initials != "" {
+		return c, color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	}
+	return color.RGBA{R: 240, G: 240, B: 240, A: 255}, c
+}
+
+// initials returns the first characters of the first two words of the
+// name, if the font has them.
+func initials(name string) string {
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var out []rune
+	for _, w := range words {
+		r := unicode.ToUpper([]rune(w)[0])
+		if _, ok := font[r]; !ok {
+			continue
+		}
+		out = append(out, r)
+		if len(out) == 2 {
+			break
+		}
+	}
+
+	return string(out)
+}
+
+// hsl converts a color from hue, saturation and lightness to RGB.
+func hsl(h, s, l float64) color.RGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	return color.RGBA{
+		R: uint8(math.Round((r + m) * 255)),
+		G: uint8(math.Round((g + m) * 255)),
+		B: uint8(math.Round((b + m) * 255)),
+		A: 255,
+	}
+}
+
+// hexColor formats the color for SVG.
+func hexColor(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
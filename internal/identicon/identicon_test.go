@@ -0,0 +1,86 @@
+package identicon
+
+import (
+	"bytes"
+	"encoding/xml"
+	"image/png"
+	"testing"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestAvatar(t *testing.T) {
+	const id = "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"
+
+	t.Log("Given the need to generate default avatars.")
+	{
+		for r, g := range font {
+			for _, row := range g {
+				if len(row) != glyphWidth {
+					t.Fatalf("\t%s\tShould have glyphs %d wide : %q is not.", failed, glyphWidth, r)
+				}
+			}
+		}
+		t.Logf("\t%s\tShould have a well formed font.", success)
+
+		a, err := New(id).PNG(DefaultSize)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to draw a png : %s.", failed, err)
+		}
+		b, _ := New(id).PNG(DefaultSize)
+		if !bytes.Equal(a, b) {
+			t.Fatalf("\t%s\tShould draw the same avatar for the same user.", failed)
+		}
+		if c, _ := New("another").PNG(DefaultSize); bytes.Equal(a, c) {
+			t.Fatalf("\t%s\tShould draw another avatar for another user.", failed)
+		}
+		img, err := png.Decode(bytes.NewReader(a))
+		if err != nil || img.Bounds().Dx() != DefaultSize || img.Bounds().Dy() != DefaultSize {
+			t.Fatalf("\t%s\tShould draw a png of the size : %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould draw a deterministic png.", success)
+
+		for _, av := range []*Avatar{New(id), NewInitials(id, "ada lovelace")} {
+			var v struct{}
+			if err := xml.Unmarshal(av.SVG(MinSize), &v); err != nil {
+				t.Fatalf("\t%s\tShould draw a well formed svg : %s.", failed, err)
+			}
+		}
+		t.Logf("\t%s\tShould draw a well formed svg.", success)
+
+		if New(id).ETag("png", 64) == New(id).ETag("svg", 64) || New(id).ETag("png", 64) == New(id).ETag("png", 32) {
+			t.Fatalf("\t%s\tShould have an ETag per format and size.", failed)
+		}
+		t.Logf("\t%s\tShould have an ETag per format and size.", success)
+	}
+
+	t.Log("Given the need to show initials.")
+	{
+		tests := []struct {
+			name string
+			want string
+		}{
+			{"ada lovelace", "AL"},
+			{"grace_brewster_hopper", "GB"},
+			{"gopher", "G"},
+			{"1337", "1"},
+			{"  ", ""},
+			{"жора", ""},
+		}
+		for _, tt := range tests {
+			if got := initials(tt.name); got != tt.want {
+				t.Fatalf("\t%s\tShould get %q from %q : got %q.", failed, tt.want, tt.name, got)
+			}
+		}
+		t.Logf("\t%s\tShould get the initials the font has.", success)
+
+		if _, err := NewInitials(id, "ada lovelace").PNG(MaxSize); err != nil {
+			t.Fatalf("\t%s\tShould be able to draw initials : %s.", failed, err)
+		}
+		t.Logf("\t%s\tShould be able to draw initials.", success)
+	}
+}
@@ -0,0 +1,260 @@
+// Package lockout protects password authentication against brute force by
+// tracking failed login attempts per account and per source ip.
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/cache"
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// Config is the required properties to use the Guard.
+type Config struct {
+
+	// Window is the sliding window in which failed attempts are counted.
+	Window time.Duration
+
+	// DelayThreshold is the number of failures for an account after which
+	// every further attempt has to wait an exponentially growing delay.
+	DelayThreshold int
+
+	// LockoutThreshold is the number of failures for an account after which
+	// the account is locked for LockoutDuration.
+	LockoutThreshold int
+
+	// IPDelayThreshold and IPLockoutThreshold are the same thresholds applied
+	// to the failures made from a single source ip across all accounts.
+	IPDelayThreshold   int
+	IPLockoutThreshold int
+
+	// BaseDelay is the delay applied on reaching a delay threshold. It is
+	// doubled for every further failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// LockoutDuration is how long a locked account or ip has to wait.
+	LockoutDuration time.Duration
+
+	// AttemptTimeout is how long an allowed attempt counts as a failure
+	// before it is known to have failed. Concurrent attempts see each other,
+	// so a burst can't get past the thresholds. Attempts which neither fail
+	// nor succeed, like the ones answered with a two-factor challenge, stop
+	// counting after it.
+	AttemptTimeout time.Duration
+}
+
+// LockedError is returned when a login attempt is not allowed yet.
+type LockedError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+// Error implements the error interface.
+func (e *LockedError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("too many failed login attempts, locked for %v", e.RetryAfter)
+	}
+	return fmt.Sprintf("too many failed login attempts, retry in %v", e.RetryAfter)
+}
+
+// Notifier is used to tell the owner of an account that it has been locked.
+type Notifier interface {
+	Locked(ctx context.Context, email string, until time.Time) error
+}
+
+// LogNotifier is a Notifier which writes lockouts to the log. It is used
+// when no other way of reaching users is configured.
+type LogNotifier struct {
+	Log *logger.Logger
+}
+
+// Locked implements the Notifier interface.
+func (n LogNotifier) Locked(ctx context.Context, email string, until time.Time) error {
+	n.Log.Warn("lockout : account locked", "email", email, "until", until.Format(time.RFC3339))
+	return nil
+}
+
+// Guard decides if a login attempt is allowed. Counters are kept in the
+// database so they hold across replicas, the cache is used as a fast path to
+// reject attempts for keys which are already known to be blocked.
+type Guard struct {
+	cfg      Config
+	db       *sqlx.DB
+	cache    *cache.Cache
+	notifier Notifier
+}
+
+// New constructs a Guard for use.
+func New(cfg Config, db *sqlx.DB, c *cache.Cache, n Notifier) *Guard {
+	return &Guard{
+		cfg:      cfg,
+		db:       db,
+		cache:    c,
+		notifier: n,
+	}
+}
+
+// Check returns a *LockedError if a login attempt for the email from the ip
+// is not allowed at the provided time. An allowed attempt is recorded as
+// pending while the account and the ip are locked, so concurrent attempts are
+// decided one at a time on the counts including each other. Fail records it
+// as failed, Succeed clears it.
+func (g *Guard) Check(ctx context.Context, email, ip string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.lockout.Check")
+	defer span.End()
+
+	email = normalize(email)
+
+	for _, key := range []string{accountKey(email), ipKey(ip)} {
+		if v, ok := g.cache.Get(key); ok {
+			if until := v.(blocked); now.Before(until.at) {
+				return &LockedError{RetryAfter: until.at.Sub(now), Locked: until.locked}
+			}
+		}
+	}
+
+	since := now.Add(-g.cfg.Window)
+	pending := now.Add(-g.cfg.AttemptTimeout)
+
+	f := func() error {
+		af, err := storage.AccountLoginFailures(ctx, g.db, email, since, pending)
+		if err != nil {
+			return errors.Wrap(err, "checking account failures")
+		}
+		if err := g.block(accountKey(email), af, g.cfg.DelayThreshold, g.cfg.LockoutThreshold, now); err != nil {
+			return err
+		}
+
+		ipf, err := storage.IPLoginFailures(ctx, g.db, ip, since, pending)
+		if err != nil {
+			return errors.Wrap(err, "checking ip failures")
+		}
+		if err := g.block(ipKey(ip), ipf, g.cfg.IPDelayThreshold, g.cfg.IPLockoutThreshold, now); err != nil {
+			return err
+		}
+
+		if err := storage.RecordLoginAttempt(ctx, g.db, email, ip, now, since); err != nil {
+			return errors.Wrap(err, "recording attempt")
+		}
+		return nil
+	}
+
+	return storage.LockLoginAttempts(ctx, g.db, email, ip, f)
+}
+
+// Fail records the attempt allowed by Check as failed and notifies the owner
+// of the account when the attempt is the one which locks it.
+func (g *Guard) Fail(ctx context.Context, email, ip string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.lockout.Fail")
+	defer span.End()
+
+	email = normalize(email)
+
+	if err := storage.RecordLoginFailure(ctx, g.db, email, ip, now, now.Add(-g.cfg.Window)); err != nil {
+		return errors.Wrap(err, "recording failure")
+	}
+
+	af, err := storage.AccountLoginFailures(ctx, g.db, email, now.Add(-g.cfg.Window), now.Add(-g.cfg.AttemptTimeout))
+	if err != nil {
+		return errors.Wrap(err, "counting account failures")
+	}
+
+	// Refresh the fast path so other attempts are rejected without a query.
+	g.block(accountKey(email), af, g.cfg.DelayThreshold, g.cfg.LockoutThreshold, now)
+
+	if af.Count == g.cfg.LockoutThreshold {
+		if err := g.notifier.Locked(ctx, email, now.Add(g.cfg.LockoutDuration)); err != nil {
+			return errors.Wrap(err, "notifying user")
+		}
+	}
+
+	return nil
+}
+
+// Succeed resets the failures recorded for the account. Failures recorded for
+// the source ip are kept so a single valid account can't be used to reset
+// them.
+func (g *Guard) Succeed(ctx context.Context, email string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.lockout.Succeed")
+	defer span.End()
+
+	return g.Unlock(ctx, email)
+}
+
+// Unlock removes the failures recorded for the account so it can be used
+// again immediately.
+func (g *Guard) Unlock(ctx context.Context, email string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.lockout.Unlock")
+	defer span.End()
+
+	email = normalize(email)
+
+	if err := storage.ClearLoginFailures(ctx, g.db, email); err != nil {
+		return errors.Wrap(err, "clearing failures")
+	}
+	g.cache.Remove(accountKey(email))
+
+	return nil
+}
+
+// block returns a *LockedError if the failures do not allow another attempt
+// at the provided time. The time until which the key is blocked is stored in
+// the cache.
+func (g *Guard) block(key string, lf storage.LoginFailures, delayThreshold, lockoutThreshold int, now time.Time) error {
+	wait, locked := g.cfg.Wait(lf.Count, delayThreshold, lockoutThreshold)
+	until := lf.Last.Add(wait)
+	if wait == 0 || !now.Before(until) {
+		return nil
+	}
+
+	g.cache.Add(key, blocked{at: until, locked: locked})
+	return &LockedError{RetryAfter: until.Sub(now), Locked: locked}
+}
+
+// Wait returns how long after the last failure a new attempt has to wait
+// given the number of failures made within the window, and whether the wait
+// is a lockout rather than a delay.
+func (cfg Config) Wait(failures, delayThreshold, lockoutThreshold int) (time.Duration, bool) {
+	switch {
+	case failures >= lockoutThreshold:
+		return cfg.LockoutDuration, true
+	case failures < delayThreshold:
+		return 0, false
+	}
+
+	d := cfg.BaseDelay
+	for i := delayThreshold; i < failures; i++ {
+		d *= 2
+		if d >= cfg.MaxDelay {
+			return cfg.MaxDelay, false
+		}
+	}
+	return d, false
+}
+
+// blocked is the value stored in the cache for a blocked key.
+type blocked struct {
+	at     time.Time
+	locked bool
+}
+
+func normalize(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+func accountKey(email string) string {
+	return "lockout:account:" + email
+}
+
+func ipKey(ip string) string {
+	return "lockout:ip:" + ip
+}
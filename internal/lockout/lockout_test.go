@@ -0,0 +1,117 @@
+package lockout_test
+
+import (
+	"context"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/igomonov88/users/internal/lockout"
+	"github.com/igomonov88/users/internal/platform/cache"
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/tests"
+)
+
+func TestWait(t *testing.T) {
+	cfg := lockout.Config{
+		DelayThreshold:   3,
+		LockoutThreshold: 10,
+		BaseDelay:        time.Second,
+		MaxDelay:         10 * time.Second,
+		LockoutDuration:  15 * time.Minute,
+	}
+
+	t.Log("Given the need to compute the wait after failed login attempts.")
+	{
+		cases := []struct {
+			failures int
+			wait     time.Duration
+			locked   bool
+		}{
+			{0, 0, false},
+			{2, 0, false},
+			{3, time.Second, false},
+			{4, 2 * time.Second, false},
+			{6, 8 * time.Second, false},
+			{7, 10 * time.Second, false},
+			{9, 10 * time.Second, false},
+			{10, 15 * time.Minute, true},
+			{42, 15 * time.Minute, true},
+		}
+
+		for _, tc := range cases {
+			wait, locked := cfg.Wait(tc.failures, cfg.DelayThreshold, cfg.LockoutThreshold)
+			if wait != tc.wait || locked != tc.locked {
+				t.Fatalf("\t%s\tShould wait %v (locked %v) after %d failures, got %v (locked %v).",
+					tests.Failed, tc.wait, tc.locked, tc.failures, wait, locked)
+			}
+		}
+		t.Logf("\t%s\tShould grow the delay exponentially and then lock.", tests.Success)
+	}
+}
+
+// TestBurst makes concurrent login attempts and checks they can't get past
+// the thresholds before any of them failed.
+func TestBurst(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	c, err := cache.New(cache.Config{DefaultDuration: time.Minute, Size: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := lockout.Config{
+		Window:             time.Minute,
+		DelayThreshold:     3,
+		LockoutThreshold:   3,
+		IPDelayThreshold:   100,
+		IPLockoutThreshold: 100,
+		BaseDelay:          time.Second,
+		MaxDelay:           time.Second,
+		LockoutDuration:    time.Minute,
+		AttemptTimeout:     time.Minute,
+	}
+	guard := lockout.New(cfg, db, c, lockout.LogNotifier{Log: logger.New(ioutil.Discard, logger.Error)})
+
+	ctx := context.Background()
+	now := time.Now()
+
+	t.Log("Given the need to limit concurrent login attempts.")
+	{
+		var (
+			wg      sync.WaitGroup
+			mu      sync.Mutex
+			allowed int
+		)
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				err := guard.Check(ctx, "gopher@example.com", "203.0.113.7", now)
+				if _, ok := err.(*lockout.LockedError); err != nil && !ok {
+					t.Errorf("\t%s\tShould be able to check an attempt : %s.", tests.Failed, err)
+				}
+				if err == nil {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if allowed != cfg.LockoutThreshold {
+			t.Fatalf("\t%s\tShould allow %d concurrent attempts : got %d.", tests.Failed, cfg.LockoutThreshold, allowed)
+		}
+		t.Logf("\t%s\tShould allow as many concurrent attempts as the threshold.", tests.Success)
+
+		if err := guard.Succeed(ctx, "gopher@example.com"); err != nil {
+			t.Fatalf("\t%s\tShould be able to reset the failures : %s.", tests.Failed, err)
+		}
+		if err := guard.Check(ctx, "gopher@example.com", "203.0.113.7", now); err != nil {
+			t.Fatalf("\t%s\tShould allow an attempt once the pending ones succeeded : %s.", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould allow an attempt once the pending ones succeeded.", tests.Success)
+	}
+}
@@ -0,0 +1,327 @@
+// Package mfa implements two-factor authentication with time-based one-time
+// passwords and one-time recovery codes.
+package mfa
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/igomonov88/users/internal/platform/encryption"
+	"github.com/igomonov88/users/internal/platform/totp"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+var (
+	// ErrInvalidCode is returned when a one-time password or a recovery code
+	// does not match.
+	ErrInvalidCode = errors.New("invalid two-factor authentication code")
+
+	// ErrNotEnrolled is returned when the user has no confirmed secret.
+	ErrNotEnrolled = errors.New("two-factor authentication is not enabled")
+
+	// ErrChallengeNotFound is returned for unknown and expired challenges.
+	ErrChallengeNotFound = errors.New("two-factor challenge not found or expired")
+)
+
+// qrSize is the size of the generated QR code images in pixels.
+const qrSize = 256
+
+// Config is the required properties to use MFA.
+type Config struct {
+
+	// Issuer is the name shown for the account in authenticator apps.
+	Issuer string
+
+	// RequireForAdmin forces users with the admin role to use a second
+	// factor to get a token accepted by admin routes.
+	RequireForAdmin bool
+
+	// ChallengeTTL is how long a user has to provide the second factor
+	// after the password was verified.
+	ChallengeTTL time.Duration
+
+	// ChallengeFailures is how often a challenge may be answered wrong
+	// before it is removed and the password has to be given again.
+	ChallengeFailures int
+
+	// RecoveryCodes is the number of recovery codes generated for a user.
+	RecoveryCodes int
+}
+
+// Enrollment is the data a user needs to add the secret to an app.
+type Enrollment struct {
+	Secret string
+	URI    string
+	QRCode []byte
+}
+
+// MFA manages the second factors of users. Secrets are encrypted before
+// they are stored and recovery codes are stored hashed.
+type MFA struct {
+	cfg Config
+	db  *sqlx.DB
+	enc *encryption.Encrypter
+}
+
+// New constructs an MFA for use.
+func New(cfg Config, db *sqlx.DB, enc *encryption.Encrypter) *MFA {
+	return &MFA{
+		cfg: cfg,
+		db:  db,
+		enc: enc,
+	}
+}
+
+// RequireForAdmin reports if admins have to use a second factor.
+func (m *MFA) RequireForAdmin() bool {
+	return m.cfg.RequireForAdmin
+}
+
+// Enroll generates a new secret for the user. The secret is not used for
+// logging in until it is confirmed with a first code.
+func (m *MFA) Enroll(ctx context.Context, usr *storage.User, now time.Time) (Enrollment, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Enroll")
+	defer span.End()
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return Enrollment{}, err
+	}
+
+	sealed, err := m.enc.Seal([]byte(secret), []byte(usr.ID))
+	if err != nil {
+		return Enrollment{}, errors.Wrap(err, "encrypting secret")
+	}
+
+	if err := storage.SaveTOTP(ctx, m.db, usr.ID, sealed, now); err != nil {
+		return Enrollment{}, err
+	}
+
+	uri := totp.URI(m.cfg.Issuer, usr.Email, secret)
+	png, err := totp.QRCode(uri, qrSize)
+	if err != nil {
+		return Enrollment{}, err
+	}
+
+	e := Enrollment{
+		Secret: secret,
+		URI:    uri,
+		QRCode: png,
+	}
+
+	return e, nil
+}
+
+// Confirm enables two-factor authentication for the user with the first
+// code generated from the enrolled secret. It returns the recovery codes
+// which are shown to the user only once.
+func (m *MFA) Confirm(ctx context.Context, userID, code string, now time.Time) ([]string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Confirm")
+	defer span.End()
+
+	t, err := storage.RetrieveTOTP(ctx, m.db, userID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, ErrNotEnrolled
+		}
+		return nil, err
+	}
+	if t.ConfirmedAt.Valid {
+		return nil, storage.ErrTOTPAlreadyConfirmed
+	}
+
+	if err := m.verify(ctx, t, code, now); err != nil {
+		return nil, err
+	}
+
+	return m.recoveryCodes(ctx, userID)
+}
+
+// Enrolled reports if the user has a confirmed secret.
+func (m *MFA) Enrolled(ctx context.Context, userID string) (bool, error) {
+	t, err := storage.RetrieveTOTP(ctx, m.db, userID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return t.ConfirmedAt.Valid, nil
+}
+
+// Verify checks a one-time password of a user with a confirmed secret. A
+// code can be used only once.
+func (m *MFA) Verify(ctx context.Context, userID, code string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Verify")
+	defer span.End()
+
+	t, err := storage.RetrieveTOTP(ctx, m.db, userID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return ErrNotEnrolled
+		}
+		return err
+	}
+	if !t.ConfirmedAt.Valid {
+		return ErrNotEnrolled
+	}
+
+	return m.verify(ctx, t, code, now)
+}
+
+// Recover checks a recovery code of the user and marks it as used.
+func (m *MFA) Recover(ctx context.Context, userID, code string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Recover")
+	defer span.End()
+
+	codes, err := storage.UnusedRecoveryCodes(ctx, m.db, userID)
+	if err != nil {
+		return err
+	}
+
+	code = normalizeRecoveryCode(code)
+	for _, rc := range codes {
+		if bcrypt.CompareHashAndPassword(rc.CodeHash, []byte(code)) != nil {
+			continue
+		}
+		if err := storage.UseRecoveryCode(ctx, m.db, rc.ID, now); err != nil {
+			if err == storage.ErrNotFound {
+				return ErrInvalidCode
+			}
+			return err
+		}
+		return nil
+	}
+
+	return ErrInvalidCode
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user after
+// verifying a one-time password.
+func (m *MFA) RegenerateRecoveryCodes(ctx context.Context, userID, code string, now time.Time) ([]string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.RegenerateRecoveryCodes")
+	defer span.End()
+
+	if err := m.Verify(ctx, userID, code, now); err != nil {
+		return nil, err
+	}
+
+	return m.recoveryCodes(ctx, userID)
+}
+
+// Disable removes the secret and the recovery codes of the user after
+// verifying a one-time password.
+func (m *MFA) Disable(ctx context.Context, userID, code string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Disable")
+	defer span.End()
+
+	if err := m.Verify(ctx, userID, code, now); err != nil {
+		return err
+	}
+
+	return storage.DeleteTOTP(ctx, m.db, userID)
+}
+
+// Challenge starts the second step of logging in for a user whose password
+// was verified. The returned id has to be presented with the second factor.
+func (m *MFA) Challenge(ctx context.Context, userID string, now time.Time) (string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.Challenge")
+	defer span.End()
+
+	return storage.CreateMFAChallenge(ctx, m.db, userID, now.Add(m.cfg.ChallengeTTL))
+}
+
+// ChallengeUser returns the id of the user the challenge was created for.
+func (m *MFA) ChallengeUser(ctx context.Context, challengeID string, now time.Time) (string, error) {
+	userID, err := storage.RetrieveMFAChallenge(ctx, m.db, challengeID, now)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return "", ErrChallengeNotFound
+		}
+		return "", err
+	}
+
+	return userID, nil
+}
+
+// FailChallenge records a wrong answer to the challenge. The challenge is
+// removed once it was answered wrong too often.
+func (m *MFA) FailChallenge(ctx context.Context, challengeID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.mfa.FailChallenge")
+	defer span.End()
+
+	return storage.FailMFAChallenge(ctx, m.db, challengeID, m.cfg.ChallengeFailures)
+}
+
+// CloseChallenge removes a challenge once the second factor was verified.
+func (m *MFA) CloseChallenge(ctx context.Context, challengeID string, now time.Time) error {
+	return storage.DeleteMFAChallenge(ctx, m.db, challengeID, now)
+}
+
+// verify checks the code against the decrypted secret and records the used
+// time step.
+func (m *MFA) verify(ctx context.Context, t *storage.TOTP, code string, now time.Time) error {
+	secret, err := m.enc.Open(t.Secret, []byte(t.UserID))
+	if err != nil {
+		return errors.Wrap(err, "decrypting secret")
+	}
+
+	step, ok := totp.Validate(string(secret), code, now, t.LastStep)
+	if !ok {
+		return ErrInvalidCode
+	}
+
+	if err := storage.UseTOTPStep(ctx, m.db, t.UserID, step, now); err != nil {
+		if err == storage.ErrStepAlreadyUsed {
+			return ErrInvalidCode
+		}
+		return err
+	}
+
+	return nil
+}
+
+// recoveryCodes generates and stores a new set of recovery codes.
+func (m *MFA) recoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	codes := make([]string, m.cfg.RecoveryCodes)
+	hashes := make([][]byte, m.cfg.RecoveryCodes)
+
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, errors.Wrap(err, "reading random code")
+		}
+
+		// Sixteen base32 characters shown in groups of four.
+		c := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes[i] = c[:4] + "-" + c[4:8] + "-" + c[8:12] + "-" + c[12:16]
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(c), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, errors.Wrap(err, "generating code hash")
+		}
+		hashes[i] = hash
+	}
+
+	if err := storage.ReplaceRecoveryCodes(ctx, m.db, userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// normalizeRecoveryCode removes the separators and spaces users may type.
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(code)
+	code = strings.Replace(code, "-", "", -1)
+	return strings.Replace(code, " ", "", -1)
+}
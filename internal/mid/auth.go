@@ -18,8 +18,28 @@ var ErrForbidden = web.NewRequestError(
 	http.StatusForbidden,
 )
 
-// Authenticate validates a JWT from the `Authorization` header.
-func Authenticate(authenticator *auth.Authenticator) web.Middleware {
+// APIKeyFunc is used to authenticate a request which carries an API key
+// instead of a JWT. It returns the claims of the owner of the key.
+type APIKeyFunc func(ctx context.Context, r *http.Request, key string) (auth.Claims, error)
+
+// ErrSessionRevoked is returned for a token whose session was revoked or
+// has expired.
+var ErrSessionRevoked = web.NewRequestError(
+	errors.New("session has been revoked"),
+	http.StatusUnauthorized,
+)
+
+// SessionFunc is used to check if the session a token was issued for is
+// still active.
+type SessionFunc func(ctx context.Context, r *http.Request, sessionID string) (bool, error)
+
+// Authenticate validates a JWT from the `Authorization` header or, when keys
+// is not nil, an API key from the `X-API-Key` header. Both produce the same
+// claims in the context. Claims limited by scopes must have the read scope
+// for safe methods and the write scope for every other method. When sessions
+// is not nil, tokens issued for a session are rejected once the session is
+// not active anymore.
+func Authenticate(authenticator *auth.Authenticator, keys APIKeyFunc, sessions SessionFunc) web.Middleware {
 
 	f := func(after web.Handler) web.Handler {
 
@@ -28,17 +48,57 @@ func Authenticate(authenticator *auth.Authenticator) web.Middleware {
 			ctx, span := trace.StartSpan(ctx, "internal.mid.Authenticate")
 			defer span.End()
 
-			// Parse the authorization header. Expected header is of
-			// the format `Bearer <token>`.
-			parts := strings.Split(r.Header.Get("Authorization"), " ")
-			if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-				err := errors.New("expected authorization header format: Bearer <token>")
-				return web.NewRequestError(err, http.StatusUnauthorized)
+			var claims auth.Claims
+
+			if key := r.Header.Get("X-API-Key"); key != "" && keys != nil {
+				var err error
+				claims, err = keys(ctx, r, key)
+				if err != nil {
+					return web.NewRequestError(err, http.StatusUnauthorized)
+				}
+			} else {
+
+				// Parse the authorization header. Expected header is of
+				// the format `Bearer <token>`.
+				parts := strings.Split(r.Header.Get("Authorization"), " ")
+				if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+					err := errors.New("expected authorization header format: Bearer <token>")
+					return web.NewRequestError(err, http.StatusUnauthorized)
+				}
+
+				var err error
+				claims, err = authenticator.ParseClaims(parts[1])
+				if err != nil {
+					return web.NewRequestError(err, http.StatusUnauthorized)
+				}
+
+				if claims.SessionID != "" && sessions != nil {
+					active, err := sessions(ctx, r, claims.SessionID)
+					if err != nil {
+						return err
+					}
+					if !active {
+						return ErrSessionRevoked
+					}
+				}
+			}
+
+			scope := auth.ScopeWrite
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+				scope = auth.ScopeRead
+			}
+			if !claims.HasScope(scope) {
+				return ErrForbidden
 			}
 
-			claims, err := authenticator.ParseClaims(parts[1])
-			if err != nil {
-				web.NewRequestError(err, http.StatusUnauthorized)
+			// Let the request logger know who made the request and respond
+			// in the locale the user chose over the one of the client.
+			if v, ok := ctx.Value(web.KeyValues).(*web.Values); ok {
+				v.Subject = claims.Subject
+				if claims.Locale != "" {
+					v.Locale = claims.Locale
+				}
 			}
 
 			ctx = context.WithValue(ctx, auth.Key, claims)
@@ -51,3 +111,127 @@ func Authenticate(authenticator *auth.Authenticator) web.Middleware {
 
 	return f
 }
+
+// HasRole validates that an authenticated user has at least one role from a
+// specified list. This method constructs the actual function that is used.
+func HasRole(roles ...string) web.Middleware {
+
+	f := func(after web.Handler) web.Handler {
+
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			ctx, span := trace.StartSpan(ctx, "internal.mid.HasRole")
+			defer span.End()
+
+			claims, ok := ctx.Value(auth.Key).(auth.Claims)
+			if !ok {
+				return errors.New("claims missing from context: HasRole called without/before Authenticate")
+			}
+
+			if !claims.HasRole(roles...) {
+				return ErrForbidden
+			}
+
+			return after(ctx, w, r, params)
+		}
+
+		return h
+	}
+
+	return f
+}
+
+// SelfOrRole validates that the user a route is about, named by the route
+// parameter, is the authenticated user or that the authenticated user has
+// one of the roles. It must be used after Authenticate in the middleware
+// chain.
+func SelfOrRole(param string, roles ...string) web.Middleware {
+
+	f := func(after web.Handler) web.Handler {
+
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			ctx, span := trace.StartSpan(ctx, "internal.mid.SelfOrRole")
+			defer span.End()
+
+			claims, ok := ctx.Value(auth.Key).(auth.Claims)
+			if !ok {
+				return errors.New("claims missing from context: SelfOrRole called without/before Authenticate")
+			}
+
+			if claims.Subject != params[param] && !claims.HasRole(roles...) {
+				return ErrForbidden
+			}
+
+			return after(ctx, w, r, params)
+		}
+
+		return h
+	}
+
+	return f
+}
+
+// ErrMFARequired is returned when a user with a role which requires a second
+// factor uses a token which was issued without one.
+var ErrMFARequired = web.NewRequestError(
+	errors.New("two-factor authentication is required for that action"),
+	http.StatusForbidden,
+)
+
+// RequireMFA validates that users who have one of the specified roles got
+// their token using a second factor. Users without these roles are not
+// affected.
+func RequireMFA(roles ...string) web.Middleware {
+
+	f := func(after web.Handler) web.Handler {
+
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			ctx, span := trace.StartSpan(ctx, "internal.mid.RequireMFA")
+			defer span.End()
+
+			claims, ok := ctx.Value(auth.Key).(auth.Claims)
+			if !ok {
+				return errors.New("claims missing from context: RequireMFA called without/before Authenticate")
+			}
+
+			if claims.HasRole(roles...) && !claims.MFA {
+				return ErrMFARequired
+			}
+
+			return after(ctx, w, r, params)
+		}
+
+		return h
+	}
+
+	return f
+}
+
+// RequireMFAForOthers validates like RequireMFA, but only when the user a
+// route is about, named by the route parameter, is not the authenticated
+// user. Users acting on themselves are not affected. It must be used after
+// Authenticate in the middleware chain.
+func RequireMFAForOthers(param string, roles ...string) web.Middleware {
+
+	f := func(after web.Handler) web.Handler {
+
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			ctx, span := trace.StartSpan(ctx, "internal.mid.RequireMFAForOthers")
+			defer span.End()
+
+			claims, ok := ctx.Value(auth.Key).(auth.Claims)
+			if !ok {
+				return errors.New("claims missing from context: RequireMFAForOthers called without/before Authenticate")
+			}
+
+			if claims.Subject != params[param] && claims.HasRole(roles...) && !claims.MFA {
+				return ErrMFARequired
+			}
+
+			return after(ctx, w, r, params)
+		}
+
+		return h
+	}
+
+	return f
+}
@@ -0,0 +1,58 @@
+package mid
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// Deprecation describes when routes were deprecated and when they are going
+// to be removed.
+type Deprecation struct {
+	Since  time.Time
+	Sunset time.Time
+}
+
+// Deprecated marks the responses of a route as deprecated with the
+// Deprecation header of RFC 9745 and the Sunset header of RFC 8594. The
+// successor is the path of the route replacing it, parameters like :user_id
+// are filled in from the ones of the request.
+func Deprecated(d Deprecation, successor string) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	f := func(after web.Handler) web.Handler {
+
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			ctx, span := trace.StartSpan(ctx, "internal.mid.Deprecated")
+			defer span.End()
+
+			// Headers are set first as the handler writes the response.
+			if !d.Since.IsZero() {
+				w.Header().Set("Deprecation", "@"+strconv.FormatInt(d.Since.Unix(), 10))
+			}
+			if !d.Sunset.IsZero() {
+				w.Header().Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if successor != "" {
+				link := successor
+				for name, value := range params {
+					link = strings.Replace(link, ":"+name, url.PathEscape(value), -1)
+				}
+				w.Header().Add("Link", "<"+link+`>; rel="successor-version"`)
+			}
+
+			return after(ctx, w, r, params)
+		}
+
+		return h
+	}
+
+	return f
+}
@@ -2,18 +2,20 @@ package mid
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"net/http"
 
 	"go.opencensus.io/trace"
 
+	"github.com/igomonov88/users/internal/platform/logger"
 	"github.com/igomonov88/users/internal/platform/web"
 )
 
 // Errors handles errors coming out of the call chain. It detects normal
 // application errors which are used to respond to the client in a uniform way.
-// Unexpected errors (status >= 500) are logged.
-func Errors(log *log.Logger) web.Middleware {
+// Errors are logged with their chain, unexpected errors (status >= 500) with
+// their stack trace as well.
+func Errors(log *logger.Logger) web.Middleware {
 
 	// This is the actual middleware function to be executed.
 	f := func(before web.Handler) web.Handler {
@@ -32,14 +34,26 @@ func Errors(log *log.Logger) web.Middleware {
 
 			if err := before(ctx, w, r, params); err != nil {
 
-				// Log the error.
-				log.Printf("%s : ERROR : %+v", v.TraceID, err)
-
 				// Respond to the error.
 				if err := web.ResponseError(ctx, w, err); err != nil {
 					return err
 				}
 
+				// Log the error.
+				fields := []interface{}{
+					"trace_id", v.TraceID,
+					"request_id", v.RequestID,
+					"route", v.Route,
+					"status", v.StatusCode,
+					"error", err,
+					"error_chain", logger.Chain(err),
+				}
+				if v.StatusCode >= http.StatusInternalServerError {
+					log.Error("request error", append(fields, "stack", fmt.Sprintf("%+v", err))...)
+				} else {
+					log.Info("request error", fields...)
+				}
+
 				// If we receive the shutdown err we need to return it
 				// back to the base handler to shutdown the service.
 				if ok := web.IsShutdown(err); ok {
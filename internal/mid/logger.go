@@ -2,17 +2,20 @@ package mid
 
 import (
 	"context"
-	"log"
+	"net"
 	"net/http"
 	"time"
 
+	"github.com/igomonov88/users/internal/platform/logger"
 	"github.com/igomonov88/users/internal/platform/web"
 	"go.opencensus.io/trace"
 )
 
-// Logger writes some information about the request to the logs in the
-// format: TraceID : (200) GET /foo -> IP ADDR (latency)
-func Logger(log *log.Logger) web.Middleware {
+// Logger writes an entry about every request to the logs with the trace and
+// request ids, the method, path and route pattern, the status, the latency,
+// the client ip address and the subject of the authenticated user. Requests
+// failing with a server error are written as errors.
+func Logger(log *logger.Logger, trusted []*net.IPNet) web.Middleware {
 
 	// This is the actual middleware function to be executed.
 	f := func(before web.Handler) web.Handler {
@@ -32,10 +35,21 @@ func Logger(log *log.Logger) web.Middleware {
 
 			err := before(ctx, w, r, params)
 
-			log.Printf("%s : (%d) : %s %s -> %s (%s)",
-				v.TraceID, v.StatusCode,
-				r.Method, r.URL.Path,
-				r.RemoteAddr, time.Since(v.Now),
+			level := logger.Info
+			if v.StatusCode >= http.StatusInternalServerError {
+				level = logger.Error
+			}
+
+			log.Log(level, "request completed",
+				"trace_id", v.TraceID,
+				"request_id", v.RequestID,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"route", v.Route,
+				"status", v.StatusCode,
+				"latency_ms", float64(time.Since(v.Now))/float64(time.Millisecond),
+				"remote_ip", web.ClientIP(r, trusted),
+				"subject", v.Subject,
 			)
 
 			// Return the error so it can be handled further up the chain.
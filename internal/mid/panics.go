@@ -2,17 +2,17 @@ package mid
 
 import (
 	"context"
-	"log"
 	"net/http"
 	"runtime/debug"
 
+	"github.com/igomonov88/users/internal/platform/logger"
 	"github.com/igomonov88/users/internal/platform/web"
 	"github.com/pkg/errors"
 )
 
 // Panics recovers from panics and converts the panic to an error so it is
 // reported in Metrics and handled in Errors.
-func Panics(log *log.Logger) web.Middleware {
+func Panics(log *logger.Logger) web.Middleware {
 
 	// This is the actual middleware function to be executed.
 	f := func(after web.Handler) web.Handler {
@@ -34,7 +34,8 @@ func Panics(log *log.Logger) web.Middleware {
 					err = errors.Errorf("panic: %v", r)
 
 					// Log the Go stack trace for this panic'd goroutine.
-					log.Printf("%s :\n%s", v.TraceID, debug.Stack())
+					log.Error("panic", "trace_id", v.TraceID, "request_id", v.RequestID, "route", v.Route,
+						"error", err, "stack", string(debug.Stack()))
 				}
 			}()
 
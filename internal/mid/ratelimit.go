@@ -0,0 +1,102 @@
+package mid
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/ratelimit"
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// ErrTooManyRequests is returned when a client exceeds the rate limit of a
+// route.
+var ErrTooManyRequests = web.NewRequestError(
+	errors.New("rate limit exceeded"),
+	http.StatusTooManyRequests,
+)
+
+// KeyFunc returns the key which identifies the client a request is counted
+// against.
+type KeyFunc func(ctx context.Context, r *http.Request) string
+
+// KeyByIP counts requests per client ip. The X-Forwarded-For header is only
+// used for requests coming from one of the trusted proxies.
+func KeyByIP(trusted []*net.IPNet) KeyFunc {
+	return func(ctx context.Context, r *http.Request) string {
+		return "ip:" + web.ClientIP(r, trusted)
+	}
+}
+
+// KeyBySubject counts requests per authenticated user. Requests without
+// claims in the context are counted using the fallback. It must be used
+// after Authenticate in the middleware chain.
+func KeyBySubject(fallback KeyFunc) KeyFunc {
+	return func(ctx context.Context, r *http.Request) string {
+		if claims, ok := ctx.Value(auth.Key).(auth.Claims); ok && claims.Subject != "" {
+			return "sub:" + claims.Subject
+		}
+		return fallback(ctx, r)
+	}
+}
+
+// KeyByRoute counts all requests to a route together.
+func KeyByRoute() KeyFunc {
+	return func(ctx context.Context, r *http.Request) string {
+		return "route"
+	}
+}
+
+// RateLimit limits the number of requests a client can make to a route using
+// a token bucket per key. The bucket of a key is specific to the route the
+// middleware is registered for, so each route can be given its own limit.
+func RateLimit(store ratelimit.Store, limit ratelimit.Limit, key KeyFunc) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	f := func(after web.Handler) web.Handler {
+
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			ctx, span := trace.StartSpan(ctx, "internal.mid.RateLimit")
+			defer span.End()
+
+			// If the context is missing this value, request the service
+			// to be shutdown gracefully.
+			v, ok := ctx.Value(web.KeyValues).(*web.Values)
+			if !ok {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			res, err := store.Take(ctx, v.Route+"|"+key(ctx, r), limit, v.Now)
+			if err != nil {
+				return errors.Wrap(err, "taking rate limit token")
+			}
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			w.Header().Set("RateLimit-Reset", strconv.Itoa(seconds(res.Reset)))
+
+			if !res.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(seconds(res.RetryAfter)))
+				return ErrTooManyRequests
+			}
+
+			return after(ctx, w, r, params)
+		}
+
+		return h
+	}
+
+	return f
+}
+
+// seconds rounds the duration up to whole seconds.
+func seconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
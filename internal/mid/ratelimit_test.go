@@ -0,0 +1,76 @@
+package mid_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/igomonov88/users/internal/mid"
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/ratelimit"
+	"github.com/igomonov88/users/internal/tests"
+)
+
+// TestKeys checks the keys requests are counted against.
+func TestKeys(t *testing.T) {
+	byIP := mid.KeyByIP(nil)
+	bySubject := mid.KeyBySubject(byIP)
+	claims := auth.NewClaims("gopher", time.Now(), time.Hour)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "203.0.113.7:1234"
+
+	t.Log("Given the need to count requests per client.")
+	{
+		if got := bySubject(context.WithValue(context.Background(), auth.Key, claims), r); got != "sub:gopher" {
+			t.Fatalf("\t%s\tShould count authenticated requests per subject : got %q.", tests.Failed, got)
+		}
+		t.Logf("\t%s\tShould count authenticated requests per subject.", tests.Success)
+
+		if got := bySubject(context.Background(), r); got != "ip:203.0.113.7" {
+			t.Fatalf("\t%s\tShould count requests without claims using the fallback : got %q.", tests.Failed, got)
+		}
+		t.Logf("\t%s\tShould count requests without claims using the fallback.", tests.Success)
+
+		if got := mid.KeyByRoute()(context.Background(), r); got != "route" {
+			t.Fatalf("\t%s\tShould count all requests to a route together : got %q.", tests.Failed, got)
+		}
+		t.Logf("\t%s\tShould count all requests to a route together.", tests.Success)
+	}
+}
+
+// TestRateLimitBySubject checks users get their own bucket wherever their
+// requests come from.
+func TestRateLimitBySubject(t *testing.T) {
+	limit := ratelimit.Limit{Rate: 1, Period: time.Minute, Burst: 1}
+	ok := func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+		return nil
+	}
+	h := mid.RateLimit(ratelimit.NewMemory(), limit, mid.KeyBySubject(mid.KeyByIP(nil)))(ok)
+
+	// take makes a request of the subject from the address.
+	take := func(subject, addr string) error {
+		ctx := context.WithValue(tests.Context(), auth.Key, auth.NewClaims(subject, time.Now(), time.Hour))
+		r := httptest.NewRequest(http.MethodPatch, "/", nil)
+		r.RemoteAddr = addr
+		return h(ctx, httptest.NewRecorder(), r, nil)
+	}
+
+	t.Log("Given the need to limit the requests of authenticated users.")
+	{
+		if err := take("gopher", "203.0.113.7:1234"); err != nil {
+			t.Fatalf("\t%s\tShould allow the first request : %s.", tests.Failed, err)
+		}
+		if err := take("gopher", "198.51.100.1:1234"); err != mid.ErrTooManyRequests {
+			t.Fatalf("\t%s\tShould limit a user changing their address : got %v.", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould limit a user changing their address.", tests.Success)
+
+		if err := take("other", "203.0.113.7:1234"); err != nil {
+			t.Fatalf("\t%s\tShould not limit another user at the same address : %s.", tests.Failed, err)
+		}
+		t.Logf("\t%s\tShould not limit another user at the same address.", tests.Success)
+	}
+}
@@ -0,0 +1,333 @@
+package mid
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/platform/ratelimit"
+	"github.com/igomonov88/users/internal/platform/rpc"
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// The middleware below is the gRPC counterpart of the middleware for HTTP.
+// Calls are logged, counted and authenticated the same way requests are.
+
+// RPCLogger writes an entry about every call to the logs like Logger does
+// for requests, with the method and the code of the call. Calls failing
+// with a server error are written as errors.
+func RPCLogger(log *logger.Logger, trusted []*net.IPNet) rpc.Middleware {
+
+	// This is the actual middleware function to be executed.
+	f := func(before rpc.Handler) rpc.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, method string) error {
+			ctx, span := trace.StartSpan(ctx, "internal.mid.RPCLogger")
+			defer span.End()
+
+			// If the context is missing this value, request the service
+			// to be shutdown gracefully.
+			v, ok := ctx.Value(web.KeyValues).(*web.Values)
+			if !ok {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			err := before(ctx, method)
+
+			level := logger.Info
+			if v.StatusCode >= http.StatusInternalServerError {
+				level = logger.Error
+			}
+
+			log.Log(level, "call completed",
+				"trace_id", v.TraceID,
+				"request_id", v.RequestID,
+				"method", method,
+				"code", status.Code(err).String(),
+				"status", v.StatusCode,
+				"latency_ms", float64(time.Since(v.Now))/float64(time.Millisecond),
+				"remote_ip", rpc.ClientIP(ctx, trusted),
+				"subject", v.Subject,
+			)
+
+			// Return the error so it can be handled further up the chain.
+			return err
+		}
+
+		return h
+	}
+
+	return f
+}
+
+// RPCErrors handles errors coming out of the call chain like Errors does
+// for requests. Errors are converted into the status of the call and logged
+// with their chain, unexpected errors with their stack trace as well.
+func RPCErrors(log *logger.Logger) rpc.Middleware {
+
+	// This is the actual middleware function to be executed.
+	f := func(before rpc.Handler) rpc.Handler {
+
+		// Create the handler that will be attached in the middleware chain.
+		h := func(ctx context.Context, method string) error {
+			ctx, span := trace.StartSpan(ctx, "internal.mid.RPCErrors")
+			defer span.End()
+
+			// If the context is missing this value, request the service
+			// to be shutdown gracefully.
+			v, ok := ctx.Value(web.KeyValues).(*web.Values)
+			if !ok {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			err := before(ctx, method)
+			v.StatusCode = rpc.StatusCode(err)
+			if err == nil {
+				return nil
+			}
+
+			// Log the error.
+			fields := []interface{}{
+				"trace_id", v.TraceID,
+				"request_id", v.RequestID,
+				"route", v.Route,
+				"status", v.StatusCode,
+				"error", err,
+				"error_chain", logger.Chain(err),
+			}
+			if v.StatusCode >= http.StatusInternalServerError {
+				log.Error("call error", append(fields, "stack", fmt.Sprintf("%+v", err))...)
+			} else {
+				log.Info("call error", fields...)
+			}
+
+			// If we receive the shutdown err we need to return it
+			// back to the server to shutdown the service.
+			if web.IsShutdown(err) {
+				return err
+			}
+
+			return rpc.Status(ctx, err)
+		}
+
+		return h
+	}
+
+	return f
+}
+
+// RPCMetrics updates the program counters Metrics does, calls are counted
+// as requests.
+func RPCMetrics() rpc.Middleware {
+
+	// This is the actual middleware function to be executed.
+	f := func(before rpc.Handler) rpc.Handler {
+		h := func(ctx context.Context, method string) error {
+			ctx, span := trace.StartSpan(ctx, "internal.mid.RPCMetrics")
+			defer span.End()
+
+			err := before(ctx, method)
+
+			m.req.Add(1)
+
+			// Update the count for the number of active goroutines every 100 requests.
+			if m.req.Value()%100 == 0 {
+				m.gr.Set(int64(runtime.NumGoroutine()))
+			}
+
+			// Increment the errors counter if an error occurred on this call.
+			if err != nil {
+				m.err.Add(1)
+			}
+
+			// Return the error so it can be handled further up the chain.
+			return err
+		}
+
+		return h
+	}
+
+	return f
+}
+
+// RPCPanics recovers from panics and converts the panic to an error so it
+// is reported in RPCMetrics and handled in RPCErrors.
+func RPCPanics(log *logger.Logger) rpc.Middleware {
+
+	// This is the actual middleware function to be executed.
+	f := func(after rpc.Handler) rpc.Handler {
+
+		// Wrap this handler around the next one provided.
+		h := func(ctx context.Context, method string) (err error) {
+
+			// If the context is missing this value, request the service
+			// to be shutdown gracefully.
+			v, ok := ctx.Value(web.KeyValues).(*web.Values)
+			if !ok {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			// Defer a function to recover from a panic and set the err return
+			// variable after the fact.
+			defer func() {
+				if r := recover(); r != nil {
+					err = pkgerrors.Errorf("panic: %v", r)
+
+					// Log the Go stack trace for this panic'd goroutine.
+					log.Error("panic", "trace_id", v.TraceID, "request_id", v.RequestID, "route", v.Route,
+						"error", err, "stack", string(debug.Stack()))
+				}
+			}()
+
+			// Call the next Handler and set its return value in the err variable.
+			return after(ctx, method)
+		}
+
+		return h
+	}
+
+	return f
+}
+
+// RPCAPIKeyFunc is used to authenticate a call which carries an API key
+// instead of a JWT. It returns the claims of the owner of the key.
+type RPCAPIKeyFunc func(ctx context.Context, key string) (auth.Claims, error)
+
+// RPCSessionFunc is used to check if the session a token was issued for is
+// still active.
+type RPCSessionFunc func(ctx context.Context, sessionID string) (bool, error)
+
+// RPCAuthenticate validates a JWT from the `authorization` metadata or, when
+// keys is not nil, an API key from the `x-api-key` metadata like
+// Authenticate does for requests. Methods do not tell reads from writes, so
+// the claims must have the scope the middleware is registered with.
+func RPCAuthenticate(authenticator *auth.Authenticator, keys RPCAPIKeyFunc, sessions RPCSessionFunc, scope string) rpc.Middleware {
+
+	f := func(after rpc.Handler) rpc.Handler {
+
+		// This is the actual middleware function to be executed.
+		h := func(ctx context.Context, method string) error {
+			ctx, span := trace.StartSpan(ctx, "internal.mid.RPCAuthenticate")
+			defer span.End()
+
+			md, _ := metadata.FromIncomingContext(ctx)
+
+			var claims auth.Claims
+
+			if key := first(md, "x-api-key"); key != "" && keys != nil {
+				var err error
+				claims, err = keys(ctx, key)
+				if err != nil {
+					return web.NewRequestError(err, http.StatusUnauthorized)
+				}
+			} else {
+
+				// Parse the authorization metadata. Expected value is of
+				// the format `Bearer <token>`.
+				parts := strings.Split(first(md, "authorization"), " ")
+				if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+					err := errors.New("expected authorization metadata format: Bearer <token>")
+					return web.NewRequestError(err, http.StatusUnauthorized)
+				}
+
+				var err error
+				claims, err = authenticator.ParseClaims(parts[1])
+				if err != nil {
+					return web.NewRequestError(err, http.StatusUnauthorized)
+				}
+
+				if claims.SessionID != "" && sessions != nil {
+					active, err := sessions(ctx, claims.SessionID)
+					if err != nil {
+						return err
+					}
+					if !active {
+						return ErrSessionRevoked
+					}
+				}
+			}
+
+			if !claims.HasScope(scope) {
+				return ErrForbidden
+			}
+
+			// Let the call logger know who made the call and respond in the
+			// locale the user chose over the one of the client.
+			if v, ok := ctx.Value(web.KeyValues).(*web.Values); ok {
+				v.Subject = claims.Subject
+				if claims.Locale != "" {
+					v.Locale = claims.Locale
+				}
+			}
+
+			ctx = context.WithValue(ctx, auth.Key, claims)
+
+			return after(ctx, method)
+		}
+
+		return h
+	}
+
+	return f
+}
+
+// RPCRateLimit limits the number of calls a client ip can make to a method
+// like RateLimit does for routes. The x-forwarded-for metadata is only used
+// for calls coming from one of the trusted proxies.
+func RPCRateLimit(store ratelimit.Store, limit ratelimit.Limit, trusted []*net.IPNet) rpc.Middleware {
+
+	// This is the actual middleware function to be executed.
+	f := func(after rpc.Handler) rpc.Handler {
+
+		h := func(ctx context.Context, method string) error {
+			ctx, span := trace.StartSpan(ctx, "internal.mid.RPCRateLimit")
+			defer span.End()
+
+			// If the context is missing this value, request the service
+			// to be shutdown gracefully.
+			v, ok := ctx.Value(web.KeyValues).(*web.Values)
+			if !ok {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			res, err := store.Take(ctx, v.Route+"|ip:"+rpc.ClientIP(ctx, trusted), limit, v.Now)
+			if err != nil {
+				return pkgerrors.Wrap(err, "taking rate limit token")
+			}
+
+			if !res.Allowed {
+				grpc.SetTrailer(ctx, metadata.Pairs("retry-after", fmt.Sprint(seconds(res.RetryAfter))))
+				return ErrTooManyRequests
+			}
+
+			return after(ctx, method)
+		}
+
+		return h
+	}
+
+	return f
+}
+
+// first returns the first value of the metadata key or an empty string.
+func first(md metadata.MD, key string) string {
+	if vals := md.Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
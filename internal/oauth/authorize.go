@@ -0,0 +1,169 @@
+package oauth
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// AuthorizeRequest is the query of an authorization request.
+type AuthorizeRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+// ParseAuthorizeRequest reads an AuthorizeRequest from the query or form
+// values.
+func ParseAuthorizeRequest(v url.Values) AuthorizeRequest {
+	return AuthorizeRequest{
+		ResponseType:        v.Get("response_type"),
+		ClientID:            v.Get("client_id"),
+		RedirectURI:         v.Get("redirect_uri"),
+		Scope:               v.Get("scope"),
+		State:               v.Get("state"),
+		Nonce:               v.Get("nonce"),
+		CodeChallenge:       v.Get("code_challenge"),
+		CodeChallengeMethod: v.Get("code_challenge_method"),
+	}
+}
+
+// Values returns the request as values, so it can be carried through the
+// login form.
+func (ar AuthorizeRequest) Values() url.Values {
+	v := url.Values{}
+	v.Set("response_type", ar.ResponseType)
+	v.Set("client_id", ar.ClientID)
+	v.Set("redirect_uri", ar.RedirectURI)
+	v.Set("scope", ar.Scope)
+	v.Set("state", ar.State)
+	v.Set("nonce", ar.Nonce)
+	v.Set("code_challenge", ar.CodeChallenge)
+	v.Set("code_challenge_method", ar.CodeChallengeMethod)
+	return v
+}
+
+// Client finds the client of the request and checks the redirect uri is
+// registered for it. Until this succeeds errors must not be redirected, as
+// the redirect uri is not trusted.
+func (p *Provider) Client(ctx context.Context, ar AuthorizeRequest) (*storage.OAuthClient, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.oauth.Client")
+	defer span.End()
+
+	if ar.ClientID == "" {
+		return nil, newError("invalid_request", "client_id is required")
+	}
+
+	c, err := storage.RetrieveOAuthClient(ctx, p.db, ar.ClientID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, newError("invalid_client", "unknown client")
+		}
+		return nil, errors.Wrap(err, "retrieving client")
+	}
+
+	// Redirect uris are compared exactly, no prefix or wildcard matching.
+	if !contains(c.RedirectURIs, ar.RedirectURI) {
+		return nil, newError("invalid_request", "redirect_uri is not registered for the client")
+	}
+
+	return c, nil
+}
+
+// Validate checks the parameters of the request for the client. It
+// defaults the scope to all scopes of the client. The returned errors are
+// to be sent to the client with ErrorRedirect.
+func (p *Provider) Validate(c *storage.OAuthClient, ar *AuthorizeRequest) error {
+	if ar.ResponseType != "code" {
+		return newError("unsupported_response_type", "response_type must be code")
+	}
+	if !contains(c.GrantTypes, GrantAuthorizationCode) {
+		return newError("unauthorized_client", "client may not use the authorization code grant")
+	}
+
+	// PKCE is required for all clients as OAuth 2.1 recommends.
+	if ar.CodeChallengeMethod != S256 {
+		return newError("invalid_request", "code_challenge_method must be S256")
+	}
+	if !validVerifier(ar.CodeChallenge) {
+		return newError("invalid_request", "code_challenge is invalid")
+	}
+
+	if ar.Scope == "" {
+		ar.Scope = strings.Join(c.Scopes, " ")
+	}
+	if !subset(strings.Fields(ar.Scope), c.Scopes) {
+		return newError("invalid_scope", "scope is not allowed for the client")
+	}
+
+	return nil
+}
+
+// Grant issues an authorization code for the request on behalf of the user.
+// It returns the uri the user is redirected to.
+func (p *Provider) Grant(ctx context.Context, ar AuthorizeRequest, userID string, authTime, now time.Time) (string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.oauth.Grant")
+	defer span.End()
+
+	g := storage.OAuthGrant{
+		ClientID:      ar.ClientID,
+		UserID:        userID,
+		RedirectURI:   ar.RedirectURI,
+		Scope:         ar.Scope,
+		Nonce:         ar.Nonce,
+		CodeChallenge: ar.CodeChallenge,
+		AuthTime:      authTime,
+		ExpiresAt:     now.Add(p.cfg.CodeTTL),
+	}
+
+	code, err := storage.CreateOAuthCode(ctx, p.db, g)
+	if err != nil {
+		return "", errors.Wrap(err, "creating code")
+	}
+
+	v := url.Values{}
+	v.Set("code", code)
+
+	return p.redirect(ar, v), nil
+}
+
+// ErrorRedirect returns the uri which sends the error to the client.
+func (p *Provider) ErrorRedirect(ar AuthorizeRequest, err *Error) string {
+	v := url.Values{}
+	v.Set("error", err.Code)
+	if err.Description != "" {
+		v.Set("error_description", err.Description)
+	}
+
+	return p.redirect(ar, v)
+}
+
+// redirect adds the response values to the redirect uri of the request. The
+// issuer is added so clients can detect mix-up attacks (RFC 9207).
+func (p *Provider) redirect(ar AuthorizeRequest, v url.Values) string {
+	if ar.State != "" {
+		v.Set("state", ar.State)
+	}
+	v.Set("iss", p.cfg.Issuer)
+
+	// The redirect uri was registered as an absolute uri, so it parses.
+	u, _ := url.Parse(ar.RedirectURI)
+	q := u.Query()
+	for k := range v {
+		q.Set(k, v.Get(k))
+	}
+	u.RawQuery = q.Encode()
+
+	return u.String()
+}
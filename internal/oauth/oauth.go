@@ -0,0 +1,229 @@
+// Package oauth implements an OAuth 2.1 authorization server and OpenID
+// Connect provider so other applications can sign users in with this service
+// instead of collecting their passwords.
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// These are the grant types a client can be registered for.
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+// These are the paths the endpoints are served on. They are advertised in
+// the discovery document.
+const (
+	PathAuthorize = "/oauth/authorize"
+	PathToken     = "/oauth/token"
+	PathUserInfo  = "/oauth/userinfo"
+	PathJWKS      = "/oauth/jwks"
+)
+
+// Config is the required properties to use the provider.
+type Config struct {
+
+	// Issuer is the URL the service is reachable on. It is the iss of all
+	// issued tokens.
+	Issuer string
+
+	// AccessTTL is how long access and ID tokens are valid.
+	AccessTTL time.Duration
+
+	// CodeTTL is how long an authorization code can be redeemed.
+	CodeTTL time.Duration
+
+	// RefreshTTL is how long a refresh token can be used.
+	RefreshTTL time.Duration
+}
+
+// Provider issues authorization codes and tokens to registered clients.
+type Provider struct {
+	cfg           Config
+	db            *sqlx.DB
+	authenticator *auth.Authenticator
+}
+
+// New constructs a Provider for use.
+func New(cfg Config, db *sqlx.DB, authenticator *auth.Authenticator) *Provider {
+	cfg.Issuer = strings.TrimRight(cfg.Issuer, "/")
+	return &Provider{
+		cfg:           cfg,
+		db:            db,
+		authenticator: authenticator,
+	}
+}
+
+// Error is an OAuth error response as defined by RFC 6749 section 5.2.
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	Status      int    `json:"-"`
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// newError constructs an *Error. Most errors are a bad request.
+func newError(code, description string) *Error {
+	status := http.StatusBadRequest
+	switch code {
+	case "invalid_client", "invalid_token":
+		status = http.StatusUnauthorized
+	case "server_error":
+		status = http.StatusInternalServerError
+	}
+
+	return &Error{
+		Code:        code,
+		Description: description,
+		Status:      status,
+	}
+}
+
+// Discovery is the OpenID Connect discovery document.
+type Discovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// Discovery returns the discovery document served under
+// /.well-known/openid-configuration.
+func (p *Provider) Discovery() Discovery {
+	return Discovery{
+		Issuer:                            p.cfg.Issuer,
+		AuthorizationEndpoint:             p.cfg.Issuer + PathAuthorize,
+		TokenEndpoint:                     p.cfg.Issuer + PathToken,
+		UserInfoEndpoint:                  p.cfg.Issuer + PathUserInfo,
+		JWKSURI:                           p.cfg.Issuer + PathJWKS,
+		ScopesSupported:                   scopes,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               grantTypes,
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{p.authenticator.Algorithm()},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "email", "preferred_username"},
+	}
+}
+
+// JWKS returns the keys ID tokens are signed with.
+func (p *Provider) JWKS() auth.JWKS {
+	return p.authenticator.JWKS()
+}
+
+// NewClient contains the information needed to register a client.
+type NewClient struct {
+	Name         string
+	RedirectURIs []string
+	GrantTypes   []string
+	Scopes       []string
+	Confidential bool
+}
+
+// RegisterClient validates and stores a new client. It returns the secret
+// of confidential clients, which is never available again.
+func (p *Provider) RegisterClient(ctx context.Context, nc NewClient, now time.Time) (*storage.OAuthClient, string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.oauth.RegisterClient")
+	defer span.End()
+
+	const code = "invalid_client_metadata"
+
+	if len(nc.GrantTypes) == 0 {
+		return nil, "", newError(code, "at least one grant type is required")
+	}
+	for _, g := range nc.GrantTypes {
+		if !contains(grantTypes, g) {
+			return nil, "", newError(code, "unsupported grant type "+g)
+		}
+	}
+	if contains(nc.GrantTypes, GrantRefreshToken) && !contains(nc.GrantTypes, GrantAuthorizationCode) {
+		return nil, "", newError(code, "refresh_token requires authorization_code")
+	}
+	if contains(nc.GrantTypes, GrantClientCredentials) && !nc.Confidential {
+		return nil, "", newError(code, "client_credentials requires a confidential client")
+	}
+
+	if contains(nc.GrantTypes, GrantAuthorizationCode) && len(nc.RedirectURIs) == 0 {
+		return nil, "", newError("invalid_redirect_uri", "at least one redirect uri is required")
+	}
+	for _, uri := range nc.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Fragment != "" {
+			return nil, "", newError("invalid_redirect_uri", "redirect uri must be absolute without a fragment")
+		}
+	}
+
+	if len(nc.Scopes) == 0 {
+		return nil, "", newError(code, "at least one scope is required")
+	}
+	if !subset(nc.Scopes, scopes) {
+		return nil, "", newError(code, "unsupported scope")
+	}
+
+	c, secret, err := storage.CreateOAuthClient(ctx, p.db, nc.Name, nc.RedirectURIs, nc.GrantTypes, nc.Scopes, nc.Confidential, now)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "creating client")
+	}
+
+	return c, secret, nil
+}
+
+// scopes are the scopes a client can request.
+var scopes = []string{auth.ScopeOpenID, auth.ScopeProfile, auth.ScopeEmail, auth.ScopeRead, auth.ScopeWrite}
+
+// apiScopes are the scopes which allow calling the API with an access token.
+var apiScopes = []string{auth.ScopeRead, auth.ScopeWrite}
+
+// grantTypes are the supported grant types.
+var grantTypes = []string{GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials}
+
+// contains reports if s is in list.
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// subset reports if every element of a is in b.
+func subset(a, b []string) bool {
+	for _, s := range a {
+		if !contains(b, s) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,43 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// S256 is the only supported code challenge method. The plain method would
+// not protect codes leaked through the redirect.
+const S256 = "S256"
+
+// ChallengeS256 returns the S256 code challenge for the verifier.
+func ChallengeS256(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// VerifyPKCE reports if the verifier matches the S256 code challenge. The
+// verifier has to follow RFC 7636 section 4.1.
+func VerifyPKCE(verifier, challenge string) bool {
+	if !validVerifier(verifier) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(ChallengeS256(verifier)), []byte(challenge)) == 1
+}
+
+// validVerifier reports if s is 43 to 128 unreserved characters, which is
+// also the form of a S256 code challenge.
+func validVerifier(s string) bool {
+	if len(s) < 43 || len(s) > 128 {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '.', c == '_', c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
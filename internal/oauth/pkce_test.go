@@ -0,0 +1,50 @@
+package oauth_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/igomonov88/users/internal/oauth"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestVerifyPKCE(t *testing.T) {
+	t.Log("Given the need to verify PKCE code verifiers.")
+	{
+		verifier := "dBjftJeZ4CVP-mB92K0uhbUJU1p1r_wW1gFWFOEjXkg"
+		challenge := "Il203ycfsZGUk8iE3LDsu165Z7yNX4dquVx5YzuSe_M"
+
+		if got := oauth.ChallengeS256(verifier); got != challenge {
+			t.Fatalf("\t%s\tShould derive the S256 challenge, got %s.", failed, got)
+		}
+		t.Logf("\t%s\tShould derive the S256 challenge.", success)
+
+		if !oauth.VerifyPKCE(verifier, challenge) {
+			t.Fatalf("\t%s\tShould accept the matching verifier.", failed)
+		}
+		t.Logf("\t%s\tShould accept the matching verifier.", success)
+
+		other := strings.Repeat("a", 43)
+		if oauth.VerifyPKCE(other, challenge) {
+			t.Fatalf("\t%s\tShould reject another verifier.", failed)
+		}
+		t.Logf("\t%s\tShould reject another verifier.", success)
+
+		short := "short"
+		if oauth.VerifyPKCE(short, oauth.ChallengeS256(short)) {
+			t.Fatalf("\t%s\tShould reject a verifier shorter than 43 characters.", failed)
+		}
+		t.Logf("\t%s\tShould reject a verifier shorter than 43 characters.", success)
+
+		invalid := strings.Repeat("a", 42) + "/"
+		if oauth.VerifyPKCE(invalid, oauth.ChallengeS256(invalid)) {
+			t.Fatalf("\t%s\tShould reject a verifier with reserved characters.", failed)
+		}
+		t.Logf("\t%s\tShould reject a verifier with reserved characters.", success)
+	}
+}
@@ -0,0 +1,308 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// TokenRequest is the form of a token request. The client credentials are
+// taken from Basic auth or the form.
+type TokenRequest struct {
+	GrantType    string
+	ClientID     string
+	ClientSecret string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+	Scope        string
+}
+
+// TokenResponse is a successful token response.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope"`
+}
+
+// IDClaims are the claims of an OpenID Connect ID token.
+type IDClaims struct {
+	Nonce             string `json:"nonce,omitempty"`
+	AuthTime          int64  `json:"auth_time"`
+	Email             string `json:"email,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+	jwt.StandardClaims
+}
+
+// Token handles a request to the token endpoint.
+func (p *Provider) Token(ctx context.Context, tr TokenRequest, now time.Time) (*TokenResponse, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.oauth.Token")
+	defer span.End()
+
+	c, err := p.authenticateClient(ctx, tr.ClientID, tr.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+
+	switch tr.GrantType {
+	case GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials:
+	case "":
+		return nil, newError("invalid_request", "grant_type is required")
+	default:
+		return nil, newError("unsupported_grant_type", "")
+	}
+	if !contains(c.GrantTypes, tr.GrantType) {
+		return nil, newError("unauthorized_client", "client may not use the "+tr.GrantType+" grant")
+	}
+
+	switch tr.GrantType {
+	case GrantAuthorizationCode:
+		return p.authorizationCode(ctx, c, tr, now)
+	case GrantRefreshToken:
+		return p.refreshToken(ctx, c, tr, now)
+	default:
+		return p.clientCredentials(ctx, c, tr, now)
+	}
+}
+
+// authenticateClient finds the client and verifies the secret of
+// confidential clients. Public clients must not present a secret.
+func (p *Provider) authenticateClient(ctx context.Context, clientID, secret string) (*storage.OAuthClient, error) {
+	if clientID == "" {
+		return nil, newError("invalid_client", "client authentication is required")
+	}
+
+	c, err := storage.RetrieveOAuthClient(ctx, p.db, clientID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, newError("invalid_client", "client authentication failed")
+		}
+		return nil, errors.Wrap(err, "retrieving client")
+	}
+
+	if c.SecretHash == "" {
+		if secret != "" {
+			return nil, newError("invalid_client", "client authentication failed")
+		}
+		return c, nil
+	}
+
+	if !storage.VerifyOAuthClientSecret(c, secret) {
+		return nil, newError("invalid_client", "client authentication failed")
+	}
+
+	return c, nil
+}
+
+// authorizationCode redeems an authorization code.
+func (p *Provider) authorizationCode(ctx context.Context, c *storage.OAuthClient, tr TokenRequest, now time.Time) (*TokenResponse, error) {
+	if tr.Code == "" || tr.CodeVerifier == "" {
+		return nil, newError("invalid_request", "code and code_verifier are required")
+	}
+
+	// The code is removed before it is checked, so a code which was sent by
+	// an attacker is not usable by the client either.
+	g, err := storage.RedeemOAuthCode(ctx, p.db, tr.Code, now)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, newError("invalid_grant", "code is invalid or expired")
+		}
+		return nil, errors.Wrap(err, "redeeming code")
+	}
+
+	if g.ClientID != c.ID || g.RedirectURI != tr.RedirectURI {
+		return nil, newError("invalid_grant", "code was issued to another client or redirect_uri")
+	}
+	if !VerifyPKCE(tr.CodeVerifier, g.CodeChallenge) {
+		return nil, newError("invalid_grant", "code_verifier does not match")
+	}
+
+	return p.issue(ctx, c, *g, now)
+}
+
+// refreshToken rotates a refresh token. The scope may be narrowed.
+func (p *Provider) refreshToken(ctx context.Context, c *storage.OAuthClient, tr TokenRequest, now time.Time) (*TokenResponse, error) {
+	if tr.RefreshToken == "" {
+		return nil, newError("invalid_request", "refresh_token is required")
+	}
+
+	g, err := storage.RotateOAuthRefreshToken(ctx, p.db, c.ID, tr.RefreshToken, now)
+	if err != nil {
+		switch err {
+		case storage.ErrNotFound, storage.ErrTokenReused:
+			return nil, newError("invalid_grant", "refresh token is invalid or expired")
+		default:
+			return nil, errors.Wrap(err, "rotating refresh token")
+		}
+	}
+
+	if tr.Scope != "" {
+		if !subset(strings.Fields(tr.Scope), strings.Fields(g.Scope)) {
+			return nil, newError("invalid_scope", "scope exceeds the original grant")
+		}
+		g.Scope = tr.Scope
+	}
+
+	// A nonce is only returned in the ID token of the original request.
+	g.Nonce = ""
+
+	return p.issue(ctx, c, *g, now)
+}
+
+// clientCredentials issues an access token to the client itself. The client
+// is the subject of the token and only API scopes can be granted.
+func (p *Provider) clientCredentials(ctx context.Context, c *storage.OAuthClient, tr TokenRequest, now time.Time) (*TokenResponse, error) {
+	var allowed []string
+	for _, s := range c.Scopes {
+		if contains(apiScopes, s) {
+			allowed = append(allowed, s)
+		}
+	}
+
+	requested := allowed
+	if tr.Scope != "" {
+		requested = strings.Fields(tr.Scope)
+	}
+	if len(requested) == 0 || !subset(requested, allowed) {
+		return nil, newError("invalid_scope", "scope is not allowed for the client")
+	}
+
+	claims := auth.NewClaims(c.ID, now, p.cfg.AccessTTL)
+	claims.Issuer = p.cfg.Issuer
+	claims.Audience = c.ID
+	claims.Scopes = requested
+
+	access, err := p.authenticator.GenerateToken(claims)
+	if err != nil {
+		return nil, errors.Wrap(err, "generating access token")
+	}
+
+	tkn := TokenResponse{
+		AccessToken: access,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(p.cfg.AccessTTL.Seconds()),
+		Scope:       strings.Join(requested, " "),
+	}
+
+	return &tkn, nil
+}
+
+// issue generates the tokens for a grant of a user.
+func (p *Provider) issue(ctx context.Context, c *storage.OAuthClient, g storage.OAuthGrant, now time.Time) (*TokenResponse, error) {
+	usr, err := storage.Retrieve(ctx, p.db, g.UserID)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, newError("invalid_grant", "user no longer exists")
+		}
+		return nil, errors.Wrapf(err, "retrieving user %q", g.UserID)
+	}
+
+	scope := strings.Fields(g.Scope)
+
+	// The access token is accepted by the API, limited to the granted scopes.
+	// Clients act as a user only, whatever roles the user has.
+	claims := storage.NewClaims(usr, now)
+	claims.Roles = []string{auth.RoleUser}
+	claims.MFA = false
+	claims.Issuer = p.cfg.Issuer
+	claims.Audience = c.ID
+	claims.ExpiresAt = now.Add(p.cfg.AccessTTL).Unix()
+	claims.Scopes = scope
+
+	tkn := TokenResponse{
+		TokenType: "Bearer",
+		ExpiresIn: int64(p.cfg.AccessTTL.Seconds()),
+		Scope:     g.Scope,
+	}
+
+	if tkn.AccessToken, err = p.authenticator.GenerateToken(claims); err != nil {
+		return nil, errors.Wrap(err, "generating access token")
+	}
+
+	if contains(scope, auth.ScopeOpenID) {
+		id := IDClaims{
+			Nonce:    g.Nonce,
+			AuthTime: g.AuthTime.Unix(),
+			StandardClaims: jwt.StandardClaims{
+				Issuer:    p.cfg.Issuer,
+				Subject:   usr.ID,
+				Audience:  c.ID,
+				IssuedAt:  now.Unix(),
+				ExpiresAt: now.Add(p.cfg.AccessTTL).Unix(),
+			},
+		}
+		if contains(scope, auth.ScopeEmail) {
+			id.Email = usr.Email
+		}
+		if contains(scope, auth.ScopeProfile) {
+			id.PreferredUsername = usr.Name
+		}
+
+		if tkn.IDToken, err = p.authenticator.SignToken(id); err != nil {
+			return nil, errors.Wrap(err, "generating id token")
+		}
+	}
+
+	if contains(c.GrantTypes, GrantRefreshToken) {
+		g.ExpiresAt = now.Add(p.cfg.RefreshTTL)
+		if tkn.RefreshToken, err = storage.CreateOAuthRefreshToken(ctx, p.db, g); err != nil {
+			return nil, errors.Wrap(err, "creating refresh token")
+		}
+	}
+
+	return &tkn, nil
+}
+
+// UserInfo is the response of the userinfo endpoint.
+type UserInfo struct {
+	Subject           string `json:"sub"`
+	Email             string `json:"email,omitempty"`
+	PreferredUsername string `json:"preferred_username,omitempty"`
+}
+
+// UserInfo returns the claims about the user an access token was issued
+// for, limited to the granted scopes.
+func (p *Provider) UserInfo(ctx context.Context, token string) (*UserInfo, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.oauth.UserInfo")
+	defer span.End()
+
+	claims, err := p.authenticator.ParseClaims(token)
+	if err != nil {
+		return nil, newError("invalid_token", "access token is invalid")
+	}
+	if !contains(claims.Scopes, auth.ScopeOpenID) {
+		return nil, &Error{Code: "insufficient_scope", Description: "openid scope is required", Status: http.StatusForbidden}
+	}
+
+	usr, err := storage.Retrieve(ctx, p.db, claims.Subject)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			return nil, newError("invalid_token", "user no longer exists")
+		}
+		return nil, errors.Wrapf(err, "retrieving user %q", claims.Subject)
+	}
+
+	ui := UserInfo{
+		Subject: usr.ID,
+	}
+	if contains(claims.Scopes, auth.ScopeEmail) {
+		ui.Email = usr.Email
+	}
+	if contains(claims.Scopes, auth.ScopeProfile) {
+		ui.PreferredUsername = usr.Name
+	}
+
+	return &ui, nil
+}
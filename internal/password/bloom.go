@@ -0,0 +1,187 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidBloom is returned when reading something which is not a bloom
+// filter written by WriteTo.
+var ErrInvalidBloom = errors.New("invalid bloom filter")
+
+// bloomMagic starts every bloom filter file.
+const bloomMagic = "PWBLOOM1"
+
+// Bloom is a bloom filter of SHA-1 hashes of passwords. It never misses a
+// password which was added, but reports passwords which were not with the
+// false positive rate it was sized for. Keeping only the hashes means the
+// corpus it is built from can be the hash lists published for k-anonymity
+// lookups.
+type Bloom struct {
+	m    uint64
+	k    uint64
+	bits []uint64
+}
+
+// NewBloom constructs an empty filter sized for n passwords and the false
+// positive rate.
+func NewBloom(n int, rate float64) *Bloom {
+	if n < 1 {
+		n = 1
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(rate) / (math.Ln2 * math.Ln2)))
+	m = (m + 63) / 64 * 64
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+
+	return &Bloom{
+		m:    m,
+		k:    k,
+		bits: make([]uint64, m/64),
+	}
+}
+
+// Add adds the SHA-1 hash of a password.
+func (b *Bloom) Add(sum [sha1.Size]byte) {
+	h1, h2 := split(sum)
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % b.m
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// Has reports if the SHA-1 hash of a password was probably added.
+func (b *Bloom) Has(sum [sha1.Size]byte) bool {
+	h1, h2 := split(sum)
+	for i := uint64(0); i < b.k; i++ {
+		bit := (h1 + i*h2) % b.m
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTo writes the filter in the form ReadBloom reads.
+func (b *Bloom) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(bloomMagic)
+
+	var buf [8]byte
+	for _, v := range append([]uint64{b.m, b.k}, b.bits...) {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		bw.Write(buf[:])
+	}
+
+	if err := bw.Flush(); err != nil {
+		return 0, err
+	}
+
+	return int64(len(bloomMagic) + 8*(2+len(b.bits))), nil
+}
+
+// ReadBloom reads a filter written by WriteTo.
+func ReadBloom(r io.Reader) (*Bloom, error) {
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(bloomMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != bloomMagic {
+		return nil, ErrInvalidBloom
+	}
+
+	var head [2]uint64
+	if err := binary.Read(br, binary.LittleEndian, &head); err != nil {
+		return nil, ErrInvalidBloom
+	}
+	m, k := head[0], head[1]
+	if m == 0 || m%64 != 0 || k == 0 {
+		return nil, ErrInvalidBloom
+	}
+
+	b := Bloom{
+		m:    m,
+		k:    k,
+		bits: make([]uint64, m/64),
+	}
+	if err := binary.Read(br, binary.LittleEndian, b.bits); err != nil {
+		return nil, errors.Wrap(err, "reading bloom filter bits")
+	}
+
+	return &b, nil
+}
+
+// BuildBloom builds a filter from the list of breached passwords in the
+// file. Lines are either SHA-1 hashes in hex, optionally followed by a colon
+// and how often the password was seen as in the Pwned Passwords downloads,
+// or plain passwords. Hashes seen less than minCount times are skipped. It
+// returns the filter and the number of passwords added.
+func BuildBloom(name string, rate float64, minCount int) (*Bloom, int, error) {
+	var n int
+	if err := readCorpus(name, minCount, func([sha1.Size]byte) { n++ }); err != nil {
+		return nil, 0, err
+	}
+
+	b := NewBloom(n, rate)
+	if err := readCorpus(name, minCount, b.Add); err != nil {
+		return nil, 0, err
+	}
+
+	return b, n, nil
+}
+
+// readCorpus calls fn with the hash of each password in the list.
+func readCorpus(name string, minCount int, fn func([sha1.Size]byte)) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return errors.Wrap(err, "opening breached passwords")
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" {
+			continue
+		}
+
+		hash, count := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			hash, count = line[:i], line[i+1:]
+		}
+
+		var sum [sha1.Size]byte
+		if len(hash) == 2*sha1.Size {
+			if _, err := hex.Decode(sum[:], []byte(hash)); err == nil {
+				if c, err := strconv.Atoi(count); err == nil && c < minCount {
+					continue
+				}
+				fn(sum)
+				continue
+			}
+		}
+
+		fn(sha1.Sum([]byte(line)))
+	}
+	if err := s.Err(); err != nil {
+		return errors.Wrap(err, "reading breached passwords")
+	}
+
+	return nil
+}
+
+// split derives the two hashes of double hashing from a SHA-1 hash. The
+// second is odd, so it never repeats a bit before all were visited.
+func split(sum [sha1.Size]byte) (uint64, uint64) {
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:16]) | 1
+}
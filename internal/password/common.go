@@ -0,0 +1,31 @@
+package password
+
+// common are the most used passwords and words of leaked password lists,
+// most used first. Their rank is the number of guesses needed to find them.
+var common = []string{
+	"123456", "password", "123456789", "12345678", "12345", "qwerty",
+	"1234567", "111111", "1234567890", "123123", "abc123", "1234",
+	"password1", "iloveyou", "1q2w3e4r", "000000", "qwerty123", "zaq12wsx",
+	"dragon", "sunshine", "princess", "letmein", "654321", "monkey",
+	"1qaz2wsx", "123321", "qwertyuiop", "superman", "asdfghjkl", "football",
+	"baseball", "welcome", "shadow", "master", "michael", "jennifer",
+	"hunter", "trustno1", "admin", "login", "starwars", "whatever",
+	"freedom", "passw0rd", "hello", "charlie", "donald", "access", "flower",
+	"mustang", "batman", "ninja", "azerty", "loveme", "secret", "summer",
+	"winter", "spring", "autumn", "computer", "internet", "cheese",
+	"pepper", "orange", "banana", "chocolate", "soccer", "hockey", "killer",
+	"jordan", "harley", "ranger", "buster", "thomas", "robert", "daniel",
+	"andrew", "joshua", "matthew", "ashley", "jessica", "amanda", "nicole",
+	"maggie", "ginger", "tigger", "cookie", "purple", "yellow", "silver",
+	"golden", "diamond", "angel", "lovely", "google", "samsung", "apple",
+	"changeme", "default", "test", "guest", "root", "user", "pass", "love",
+	"money", "life", "family", "friend", "happy", "sweet", "baby", "girl",
+	"boy", "king", "queen", "star", "sun", "moon", "blue", "red", "green",
+	"black", "white", "dog", "cat", "fish", "bear", "tiger", "lion",
+	"eagle", "wolf", "horse", "magic", "dream", "heart", "music", "game",
+	"gamer", "player", "super", "power", "energy", "peace", "faith",
+	"jesus", "god", "angel1", "monday", "friday", "sunday", "january",
+	"july", "august", "october", "december", "qazwsx", "asdf", "zxcv",
+	"letmein1", "welcome1", "master1", "hello1", "abcd", "abcdef",
+	"abcdefg", "qwe123", "asd123", "zxc123",
+}
@@ -0,0 +1,238 @@
+package password
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// keyboard are the rows of a QWERTY keyboard. Runs along them are among the
+// first guesses of attackers.
+var keyboard = []string{
+	"`1234567890-=",
+	"qwertyuiop[]\\",
+	"asdfghjkl;'",
+	"zxcvbnm,./",
+	"1qaz2wsx3edc4rfv5tgb6yhn7ujm8ik,9ol.0p;/",
+}
+
+// leet are the substitutions of letters undone before looking up words.
+var leet = map[rune]rune{
+	'4': 'a',
+	'@': 'a',
+	'3': 'e',
+	'1': 'i',
+	'!': 'i',
+	'0': 'o',
+	'$': 's',
+	'5': 's',
+	'7': 't',
+	'+': 't',
+}
+
+// Entropy estimates the strength of the password in bits. Like zxcvbn it
+// looks for the weakest way to split the password into patterns attackers
+// try first: common passwords and words, the inputs, repeats, sequences,
+// keyboard runs and years. Whatever no pattern covers counts as guessed by
+// brute force.
+func Entropy(password string, inputs ...string) float64 {
+	runes := []rune(password)
+	n := len(runes)
+	if n == 0 {
+		return 0
+	}
+
+	words := make(map[string]int, len(common)+len(inputs))
+	for i, w := range common {
+		words[w] = i + 1
+	}
+	for _, in := range inputs {
+		words[strings.ToLower(in)] = 1
+	}
+
+	perChar := math.Log2(cardinality(runes))
+
+	// best[j] is the lowest entropy of the first j characters.
+	best := make([]float64, n+1)
+	for j := 1; j <= n; j++ {
+		best[j] = best[j-1] + perChar
+		for i := 0; i < j-1; i++ {
+			if g := guesses(runes[i:j], words); g > 0 {
+				if e := best[i] + math.Log2(g); e < best[j] {
+					best[j] = e
+				}
+			}
+		}
+	}
+
+	return best[n]
+}
+
+// guesses returns how many guesses the weakest pattern matching all of the
+// segment needs, or zero if none does.
+func guesses(seg []rune, words map[string]int) float64 {
+	var g float64
+	take := func(v float64) {
+		if v > 0 && (g == 0 || v < g) {
+			g = v
+		}
+	}
+
+	lower := strings.ToLower(string(seg))
+	n := float64(len(seg))
+
+	if rank, ok := words[lower]; ok {
+		take(float64(rank) * caseVariations(seg))
+	}
+	if un, subs := unleet(lower); subs > 0 {
+		if rank, ok := words[un]; ok {
+			take(float64(rank) * caseVariations(seg) * math.Pow(2, float64(subs)))
+		}
+	}
+
+	if len(seg) >= 3 {
+		if repeated(seg) {
+			take(cardinality(seg[:1]) * n)
+		}
+		if sequence(seg) {
+			take(4 * n)
+		}
+	}
+
+	if len(seg) >= 4 {
+		for _, row := range keyboard {
+			if strings.Contains(row, lower) || strings.Contains(reverse(row), lower) {
+				take(float64(len(row)) * 2 * n)
+				break
+			}
+		}
+	}
+
+	if len(seg) == 4 && lower >= "1900" && lower <= "2099" {
+		take(200)
+	}
+
+	return g
+}
+
+// cardinality returns the size of the smallest character set holding all of
+// the runes.
+func cardinality(runes []rune) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < 128:
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	var c float64
+	for _, set := range []struct {
+		used bool
+		size float64
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if set.used {
+			c += set.size
+		}
+	}
+
+	return c
+}
+
+// caseVariations returns how many ways of capitalizing a word have to be
+// tried to find the one of the segment.
+func caseVariations(seg []rune) float64 {
+	var upper, lower int
+	for _, r := range seg {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+
+	switch {
+	case upper == 0:
+		return 1
+	case lower == 0, upper == 1 && unicode.IsUpper(seg[0]):
+		return 2
+	}
+
+	// Any mix of the fewer of both cases.
+	k := upper
+	if lower < k {
+		k = lower
+	}
+	var v float64
+	for i := 1; i <= k; i++ {
+		v += binomial(len(seg), i)
+	}
+
+	return v
+}
+
+// unleet undoes the substitutions in the word and returns how many there
+// were.
+func unleet(word string) (string, int) {
+	var subs int
+	out := strings.Map(func(r rune) rune {
+		if l, ok := leet[r]; ok {
+			subs++
+			return l
+		}
+		return r
+	}, word)
+	return out, subs
+}
+
+// repeated reports if the segment is a single character repeated.
+func repeated(seg []rune) bool {
+	for _, r := range seg[1:] {
+		if r != seg[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// sequence reports if the characters of the segment follow each other, up
+// or down, like abc or 987.
+func sequence(seg []rune) bool {
+	d := seg[1] - seg[0]
+	if d != 1 && d != -1 {
+		return false
+	}
+	for i := 2; i < len(seg); i++ {
+		if seg[i]-seg[i-1] != d {
+			return false
+		}
+	}
+	return true
+}
+
+// reverse returns the string backwards.
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+// binomial returns n choose k.
+func binomial(n, k int) float64 {
+	v := 1.0
+	for i := 1; i <= k; i++ {
+		v = v * float64(n-k+i) / float64(i)
+	}
+	return v
+}
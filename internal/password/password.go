@@ -0,0 +1,135 @@
+// Package password implements the policy passwords have to follow. Passwords
+// need a minimum length and estimated strength, may not contain details of
+// their user and may not be in a corpus of breached passwords.
+package password
+
+import (
+	"crypto/sha1"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// Reasons a password is rejected for. They are reported as the error of the
+// field holding the password.
+const (
+	ReasonRequired = "required"
+	ReasonTooShort = "too_short"
+	ReasonTooLong  = "too_long"
+	ReasonTooWeak  = "too_weak"
+	ReasonPersonal = "contains_user_info"
+	ReasonBreached = "breached"
+)
+
+// maxBytes is the longest password bcrypt takes into account.
+const maxBytes = 72
+
+// Violation is returned for passwords which break the policy.
+type Violation struct {
+	Reason string
+}
+
+// Error implements the error interface.
+func (v *Violation) Error() string {
+	return "password rejected : " + v.Reason
+}
+
+// Config is the required properties to use the policy.
+type Config struct {
+
+	// MinLength is the shortest password accepted in characters.
+	MinLength int
+
+	// MinEntropy is the lowest estimated strength accepted in bits, which is
+	// the base 2 logarithm of the guesses needed to find the password.
+	MinEntropy float64
+
+	// BreachedFile is an optional bloom filter of breached passwords as
+	// built by BuildBloom.
+	BreachedFile string
+}
+
+// Policy checks passwords.
+type Policy struct {
+	cfg      Config
+	breached *Bloom
+}
+
+// New constructs a Policy for use, reading the breached passwords of the
+// config.
+func New(cfg Config) (*Policy, error) {
+	p := Policy{
+		cfg: cfg,
+	}
+
+	if cfg.BreachedFile != "" {
+		f, err := os.Open(cfg.BreachedFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "opening breached passwords")
+		}
+		defer f.Close()
+
+		if p.breached, err = ReadBloom(f); err != nil {
+			return nil, errors.Wrap(err, "reading breached passwords")
+		}
+	}
+
+	return &p, nil
+}
+
+// Check reports if the password follows the policy. The inputs are details
+// of the user like their email and name, which the password may not contain
+// and which make it easier to guess.
+func (p *Policy) Check(password string, inputs ...string) error {
+	if password == "" {
+		return &Violation{Reason: ReasonRequired}
+	}
+	if utf8.RuneCountInString(password) < p.cfg.MinLength {
+		return &Violation{Reason: ReasonTooShort}
+	}
+	if len(password) > maxBytes {
+		return &Violation{Reason: ReasonTooLong}
+	}
+
+	words := userWords(inputs)
+	lower := strings.ToLower(password)
+	for _, w := range words {
+		if strings.Contains(lower, w) {
+			return &Violation{Reason: ReasonPersonal}
+		}
+	}
+
+	if p.breached != nil && p.breached.Has(sha1.Sum([]byte(password))) {
+		return &Violation{Reason: ReasonBreached}
+	}
+
+	if Entropy(password, words...) < p.cfg.MinEntropy {
+		return &Violation{Reason: ReasonTooWeak}
+	}
+
+	return nil
+}
+
+// userWords returns the parts of the user details a password may not
+// contain. Emails count with their local part as well. Parts shorter than
+// three characters are too common to reject.
+func userWords(inputs []string) []string {
+	var words []string
+	add := func(w string) {
+		w = strings.ToLower(strings.TrimSpace(w))
+		if utf8.RuneCountInString(w) >= 3 {
+			words = append(words, w)
+		}
+	}
+
+	for _, in := range inputs {
+		add(in)
+		if i := strings.LastIndex(in, "@"); i > 0 {
+			add(in[:i])
+		}
+	}
+
+	return words
+}
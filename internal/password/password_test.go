@@ -0,0 +1,130 @@
+package password
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The corpus mixes hashes with counts and plain passwords.
+	sum := sha1.Sum([]byte("Tr0ub4dor&3"))
+	rare := sha1.Sum([]byte("kX8#mQ2$vL9!"))
+	corpus := fmt.Sprintf("%X:42\n%X:1\nhunter2hunter2\n", sum, rare)
+	list := filepath.Join(dir, "breached.txt")
+	if err := ioutil.WriteFile(list, []byte(corpus), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Log("Given the need to build a filter of breached passwords.")
+	{
+		b, n, err := BuildBloom(list, 0.001, 2)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to build a filter : %s.", failed, err)
+		}
+		if n != 2 {
+			t.Fatalf("\t%s\tShould skip hashes seen too rarely : added %d.", failed, n)
+		}
+		t.Logf("\t%s\tShould be able to build a filter.", success)
+
+		var buf bytes.Buffer
+		if _, err := b.WriteTo(&buf); err != nil {
+			t.Fatalf("\t%s\tShould be able to write a filter : %s.", failed, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, "breached.bloom"), buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := ReadBloom(bytes.NewReader([]byte("not a filter"))); err != ErrInvalidBloom {
+			t.Fatalf("\t%s\tShould reject files which are no filter : got %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould be able to write and read a filter.", success)
+	}
+
+	p, err := New(Config{
+		MinLength:    8,
+		MinEntropy:   30,
+		BreachedFile: filepath.Join(dir, "breached.bloom"),
+	})
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to construct a policy : %s.", failed, err)
+	}
+
+	tests := []struct {
+		password string
+		reason   string
+	}{
+		{"kX8#mQ2$vL9!", ""},
+		{"correct horse battery staple", ""},
+		{"", ReasonRequired},
+		{"aB3$x", ReasonTooShort},
+		{string(bytes.Repeat([]byte("x"), 73)), ReasonTooLong},
+		{"Password1!", ReasonTooWeak},
+		{"qwertyuiop123", ReasonTooWeak},
+		{"Tr0ub4dor&3", ReasonBreached},
+		{"hunter2hunter2", ReasonBreached},
+		{"my-Gopher-42!", ReasonPersonal},
+		{"xx.igor.smith.xx", ReasonPersonal},
+	}
+
+	t.Log("Given the need to enforce the password policy.")
+	{
+		for _, tt := range tests {
+			err := p.Check(tt.password, "gopher", "igor.smith@example.com")
+			var reason string
+			if v, ok := err.(*Violation); ok {
+				reason = v.Reason
+			} else if err != nil {
+				t.Fatalf("\t%s\tShould only return violations : %q got %v.", failed, tt.password, err)
+			}
+			if reason != tt.reason {
+				t.Fatalf("\t%s\tShould check %q : got %q, want %q.", failed, tt.password, reason, tt.reason)
+			}
+		}
+		t.Logf("\t%s\tShould reject passwords which break the policy.", success)
+	}
+}
+
+func TestBloom(t *testing.T) {
+	const n = 10000
+	b := NewBloom(n, 0.01)
+
+	t.Log("Given the need to look up breached passwords.")
+	{
+		for i := 0; i < n; i++ {
+			b.Add(sha1.Sum([]byte(fmt.Sprint("added", i))))
+		}
+		for i := 0; i < n; i++ {
+			if !b.Has(sha1.Sum([]byte(fmt.Sprint("added", i)))) {
+				t.Fatalf("\t%s\tShould never miss an added password.", failed)
+			}
+		}
+		t.Logf("\t%s\tShould never miss an added password.", success)
+
+		var fp int
+		for i := 0; i < n; i++ {
+			if b.Has(sha1.Sum([]byte(fmt.Sprint("other", i)))) {
+				fp++
+			}
+		}
+		if rate := float64(fp) / n; rate > 0.02 {
+			t.Fatalf("\t%s\tShould keep to the false positive rate : got %.3f.", failed, rate)
+		}
+		t.Logf("\t%s\tShould keep to the false positive rate.", success)
+	}
+}
@@ -2,7 +2,10 @@ package auth
 
 import (
 	"crypto/rsa"
+	"encoding/base64"
 	"fmt"
+	"math/big"
+
 	"github.com/dgrijalva/jwt-go"
 	"github.com/pkg/errors"
 )
@@ -79,8 +82,17 @@ func NewAuthenticator(privateKey *rsa.PrivateKey, activeKID, algorithm string, p
 	return &a, nil
 }
 
-// GenerateToken generates a signed JWT token string representing the user Claims.
+// GenerateToken generates a signed JWT token string representing the user
+// Claims. Only these tokens are accepted by ParseClaims.
 func (a *Authenticator) GenerateToken(claims Claims) (string, error) {
+	claims.Type = TokenTypeAccess
+	return a.SignToken(claims)
+}
+
+// SignToken generates a signed JWT token string for any set of claims, for
+// example the ID tokens of OpenID Connect. ParseClaims rejects these tokens
+// unless they are of the access type.
+func (a *Authenticator) SignToken(claims jwt.Claims) (string, error) {
 	method := jwt.GetSigningMethod(a.algorithm)
 
 	tkn := jwt.NewWithClaims(method, claims)
@@ -126,3 +138,39 @@ func (a *Authenticator) ParseClaims(tokenStr string) (Claims, error) {
 
 	return claims, nil
 }
+
+// Algorithm returns the name of the algorithm tokens are signed with.
+func (a *Authenticator) Algorithm() string {
+	return a.algorithm
+}
+
+// JWK represents an RSA public key as a JSON Web Key (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+}
+
+// JWKS represents a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public key of the active key id so others can verify the
+// tokens we sign.
+func (a *Authenticator) JWKS() JWKS {
+	pub := a.privateKey.PublicKey
+	k := JWK{
+		KeyType:   "RSA",
+		KeyID:     a.activeKID,
+		Use:       "sig",
+		Algorithm: a.algorithm,
+		N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}
+
+	return JWKS{Keys: []JWK{k}}
+}
@@ -38,6 +38,17 @@ func TestAuthenticator(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// Other tokens signed with the same key, like ID tokens, must not be
+	// accepted as claims.
+	idStr, err := a.SignToken(jwt.StandardClaims{Subject: "user", Audience: "client"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.ParseClaims(idStr); err == nil {
+		t.Fatal("accepted a token which is not an access token")
+	}
+
 }
 
 // The key id we would have generated for the private below key
@@ -12,6 +12,25 @@ const (
 	RoleUser  = "USER"
 )
 
+// These are the scopes an API key can be limited to. Tokens without scopes
+// are not limited.
+const (
+	ScopeRead  = "users:read"
+	ScopeWrite = "users:write"
+)
+
+// These are the OpenID Connect scopes a client can request for a user in
+// addition to the API scopes.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// TokenTypeAccess is the type of the tokens the API accepts. Other tokens
+// signed with the same key, like ID tokens, don't have it.
+const TokenTypeAccess = "access"
+
 // ctxKey represents the type of value for the context key.
 type ctxKey int
 
@@ -21,6 +40,25 @@ const Key ctxKey = 1
 // Claims represents the authorization claims transmitted via a JWT.
 type Claims struct {
 	Roles []string `json:"roles"`
+
+	// MFA is set when the user proved a second factor to get the token.
+	MFA bool `json:"mfa,omitempty"`
+
+	// SessionID is the session the token was issued for. Tokens of revoked
+	// sessions are rejected.
+	SessionID string `json:"sid,omitempty"`
+
+	// Scopes limits what the claims allow. It is set for API keys and tokens
+	// issued to OAuth clients only.
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Locale is the locale the user chose for messages. It is empty when the
+	// user did not choose one.
+	Locale string `json:"locale,omitempty"`
+
+	// Type is set by GenerateToken, only tokens of the access type are
+	// valid claims.
+	Type string `json:"typ,omitempty"`
 	jwt.StandardClaims
 }
 
@@ -39,8 +77,37 @@ func NewClaims(subject string, now time.Time, expires time.Duration) Claims {
 	return c
 }
 
+// HasRole returns true if the claims has at least one of the provided roles.
+func (c Claims) HasRole(roles ...string) bool {
+	for _, has := range c.Roles {
+		for _, want := range roles {
+			if has == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasScope returns true if the claims are not limited by scopes or have the
+// provided scope.
+func (c Claims) HasScope(scope string) bool {
+	if len(c.Scopes) == 0 {
+		return true
+	}
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 // Valid is called during the parsing of a token.
 func (c Claims) Valid() error {
+	if c.Type != TokenTypeAccess {
+		return fmt.Errorf("invalid token type %q", c.Type)
+	}
 	for _, r := range c.Roles {
 		switch r {
 		case RoleAdmin, RoleUser:
@@ -49,6 +116,13 @@ func (c Claims) Valid() error {
 
 		}
 	}
+	for _, s := range c.Scopes {
+		switch s {
+		case ScopeRead, ScopeWrite, ScopeOpenID, ScopeProfile, ScopeEmail:
+		default:
+			return fmt.Errorf("invalid scope %q", s)
+		}
+	}
 	if err := c.StandardClaims.Valid(); err != nil {
 		return errors.Wrap(err, "validating standard claims")
 	}
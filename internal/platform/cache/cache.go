@@ -115,6 +115,18 @@ func get(cache *Cache, key string) (interface{}, bool) {
 	return nil, false
 }
 
+// Remove knows how to remove value with given key from the cache. It is a
+// no-op if the key is not present.
+func (c *Cache) Remove(key string) {
+	c.lock.Lock()
+	if element, exist := c.items[key]; exist {
+		c.entryList.Remove(element)
+		delete(c.items, key)
+		c.currentSize--
+	}
+	c.lock.Unlock()
+}
+
 // Purge knows hot to purge cache
 func (c *Cache) Purge() {
 	c.lock.Lock()
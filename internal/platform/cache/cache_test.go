@@ -40,6 +40,15 @@ func TestCache(t *testing.T) {
 			t.Logf("\t%s\t Should be able to get the same value as was pushed.", success)
 		}
 
+		{
+			cache.Add("key", rand.Int31n(10))
+			cache.Remove("key")
+			if _, exist := cache.Get("key"); exist {
+				t.Fatalf("\t%s\t Should be able to remove item from the cache.", failed)
+			}
+			t.Logf("\t%s\t Should be able to remove item from the cache.", success)
+		}
+
 		{
 			cache.Purge()
 			if _, exist := cache.Get("key"); exist {
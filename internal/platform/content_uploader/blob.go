@@ -0,0 +1,84 @@
+package content_uploader
+
+import (
+	"context"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrNotFound is returned for a key which has no object.
+	ErrNotFound = errors.New("object not found")
+
+	// ErrInvalidKey is returned for keys which are empty, absolute or try to
+	// leave the store with dot segments.
+	ErrInvalidKey = errors.New("invalid object key")
+)
+
+// PutOptions is the metadata stored with an object and returned when it is
+// served.
+type PutOptions struct {
+	ContentType  string
+	CacheControl string
+}
+
+// Info describes a stored object.
+type Info struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	CacheControl string
+	ModTime      time.Time
+}
+
+// ListFunc is called for each object found by List. Only the key, the size
+// and the modification time of the info are set. Returning an error stops
+// the listing.
+type ListFunc func(info Info) error
+
+// BlobStore keeps objects under keys. URL returns where a public object is
+// served from, the presign methods return URLs which allow reading or writing
+// an object without credentials until they expire.
+type BlobStore interface {
+	Put(ctx context.Context, key string, body io.Reader, opts PutOptions) error
+	Get(ctx context.Context, key string) (io.ReadCloser, *Info, error)
+	Delete(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	List(ctx context.Context, prefix string, fn ListFunc) error
+	PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error)
+	PresignPut(ctx context.Context, key string, ttl time.Duration, opts PutOptions) (string, error)
+	URL(key string) string
+}
+
+// validKey reports if the key is a clean relative path, so it maps to the
+// same object in every store. Keys never point to a parent directory, since
+// filesystem stores would resolve them outside of their root.
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == ".." {
+			return false
+		}
+	}
+	return path.Clean(key) == key && key != "."
+}
+
+// ctxReader stops reading once the context is done, so copying a large body
+// can be cancelled.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,246 @@
+package content_uploader_test
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/igomonov88/users/internal/platform/content_uploader"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestMemory(t *testing.T) {
+	signer := content_uploader.NewSigner("http://localhost/blobs", []byte("secret"))
+	testBlobStore(t, content_uploader.NewMemory(signer))
+}
+
+func TestFilesystem(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	signer := content_uploader.NewSigner("http://localhost/blobs", []byte("secret"))
+	fs, err := content_uploader.NewFilesystem(dir, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBlobStore(t, fs)
+}
+
+// TestS3 runs against an S3 compatible service like MinIO when one is
+// configured in the environment, for example:
+//
+//	USERS_TEST_S3_ENDPOINT=http://localhost:9000 USERS_TEST_S3_BUCKET=test \
+//	USERS_TEST_S3_ACCESS_KEY=minioadmin USERS_TEST_S3_SECRET_KEY=minioadmin
+func TestS3(t *testing.T) {
+	endpoint := os.Getenv("USERS_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("USERS_TEST_S3_ENDPOINT is not set")
+	}
+
+	u, err := content_uploader.New(content_uploader.Config{
+		Region:          "us-east-1",
+		Bucket:          os.Getenv("USERS_TEST_S3_BUCKET"),
+		Endpoint:        endpoint,
+		PathStyle:       true,
+		AccessKeyID:     os.Getenv("USERS_TEST_S3_ACCESS_KEY"),
+		SecretAccessKey: os.Getenv("USERS_TEST_S3_SECRET_KEY"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBlobStore(t, u)
+}
+
+// testBlobStore checks the behavior every BlobStore has to provide.
+func testBlobStore(t *testing.T, store content_uploader.BlobStore) {
+	ctx := context.Background()
+	key := "tests/" + time.Now().Format("20060102150405.000000000") + "/object.txt"
+	body := []byte("hello blobs")
+	opts := content_uploader.PutOptions{
+		ContentType:  "text/plain",
+		CacheControl: "public, max-age=60",
+	}
+
+	t.Log("Given the need to store objects.")
+	{
+		if _, _, err := store.Get(ctx, key); err != content_uploader.ErrNotFound {
+			t.Fatalf("\t%s\tShould not find a missing object : got %v.", failed, err)
+		}
+		if ok, err := store.Exists(ctx, key); err != nil || ok {
+			t.Fatalf("\t%s\tShould report a missing object : %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould not find a missing object.", success)
+
+		if err := store.Put(ctx, key, bytes.NewReader(body), opts); err != nil {
+			t.Fatalf("\t%s\tShould be able to put an object : %s.", failed, err)
+		}
+		if ok, err := store.Exists(ctx, key); err != nil || !ok {
+			t.Fatalf("\t%s\tShould report a stored object : %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould be able to put an object.", success)
+
+		rc, info, err := store.Get(ctx, key)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to get an object : %s.", failed, err)
+		}
+		got, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil || !bytes.Equal(got, body) {
+			t.Fatalf("\t%s\tShould get back the stored body : %q.", failed, got)
+		}
+		if info.Size != int64(len(body)) || info.ContentType != opts.ContentType || info.CacheControl != opts.CacheControl {
+			t.Fatalf("\t%s\tShould get back the metadata : %+v.", failed, info)
+		}
+		t.Logf("\t%s\tShould get back the body and the metadata.", success)
+
+		if u, err := store.PresignGet(ctx, key, time.Minute); err != nil || !strings.Contains(u, "object.txt") {
+			t.Fatalf("\t%s\tShould presign a download : %q %v.", failed, u, err)
+		}
+		if u, err := store.PresignPut(ctx, key, time.Minute, opts); err != nil || !strings.Contains(u, "object.txt") {
+			t.Fatalf("\t%s\tShould presign an upload : %q %v.", failed, u, err)
+		}
+		t.Logf("\t%s\tShould presign downloads and uploads.", success)
+
+		var listed []content_uploader.Info
+		err = store.List(ctx, path.Dir(key)+"/", func(info content_uploader.Info) error {
+			listed = append(listed, info)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to list objects : %s.", failed, err)
+		}
+		if len(listed) != 1 || listed[0].Key != key || listed[0].Size != int64(len(body)) || listed[0].ModTime.IsZero() {
+			t.Fatalf("\t%s\tShould list the stored object : %+v.", failed, listed)
+		}
+		t.Logf("\t%s\tShould be able to list objects.", success)
+
+		if err := store.Delete(ctx, key); err != nil {
+			t.Fatalf("\t%s\tShould be able to delete an object : %s.", failed, err)
+		}
+		if ok, err := store.Exists(ctx, key); err != nil || ok {
+			t.Fatalf("\t%s\tShould not find a deleted object : %v.", failed, err)
+		}
+		if err := store.Delete(ctx, key); err != nil {
+			t.Fatalf("\t%s\tShould be able to delete a missing object : %s.", failed, err)
+		}
+		t.Logf("\t%s\tShould be able to delete an object.", success)
+	}
+
+	t.Log("Given the need to reject unsafe requests.")
+	{
+		for _, bad := range []string{"", "/abs", "..", "../escape", "a/..", "a/../../b", "a//b"} {
+			if err := store.Put(ctx, bad, bytes.NewReader(body), opts); err != content_uploader.ErrInvalidKey {
+				t.Fatalf("\t%s\tShould reject the key %q : got %v.", failed, bad, err)
+			}
+		}
+		t.Logf("\t%s\tShould reject keys which are not clean relative paths.", success)
+
+		cctx, cancel := context.WithCancel(ctx)
+		cancel()
+		if err := store.Put(cctx, key, bytes.NewReader(body), opts); err == nil {
+			t.Fatalf("\t%s\tShould stop a put when the context is cancelled.", failed)
+		}
+		if _, err := store.Exists(cctx, key); err == nil {
+			t.Fatalf("\t%s\tShould stop a check when the context is cancelled.", failed)
+		}
+		t.Logf("\t%s\tShould stop when the context is cancelled.", success)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	var store content_uploader.BlobStore
+	var signer *content_uploader.Signer
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content_uploader.Handler(store, signer, 8).ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	signer = content_uploader.NewSigner(srv.URL+"/blobs", []byte("secret"))
+	store = content_uploader.NewMemory(signer)
+	ctx := context.Background()
+
+	t.Log("Given the need to upload directly with presigned URLs.")
+	{
+		opts := content_uploader.PutOptions{ContentType: "image/png"}
+		u, err := store.PresignPut(ctx, "avatars/a.png", time.Minute, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		putBody := func(url, contentType, body string) int {
+			req, _ := http.NewRequest(http.MethodPut, url, strings.NewReader(body))
+			req.Header.Set("Content-Type", contentType)
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			return resp.StatusCode
+		}
+		put := func(url, contentType string) int {
+			return putBody(url, contentType, "png")
+		}
+
+		if status := put(strings.Replace(u, "a.png", "b.png", 1), "image/png"); status != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould reject an upload to another key : got %d.", failed, status)
+		}
+		if status := put(u, "text/html"); status != http.StatusForbidden {
+			t.Fatalf("\t%s\tShould reject an upload of another content type : got %d.", failed, status)
+		}
+		t.Logf("\t%s\tShould reject uploads the URL was not signed for.", success)
+
+		if status := put(u, "image/png"); status != http.StatusOK {
+			t.Fatalf("\t%s\tShould accept a presigned upload : got %d.", failed, status)
+		}
+		t.Logf("\t%s\tShould accept a presigned upload.", success)
+
+		large, err := store.PresignPut(ctx, "avatars/large.png", time.Minute, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status := putBody(large, "image/png", "too large png"); status != http.StatusRequestEntityTooLarge {
+			t.Fatalf("\t%s\tShould reject an upload larger than the maximum : got %d.", failed, status)
+		}
+		req, _ := http.NewRequest(http.MethodPut, large, ioutil.NopCloser(strings.NewReader("too large png")))
+		req.Header.Set("Content-Type", "image/png")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Fatalf("\t%s\tShould reject an upload of unknown length larger than the maximum : got %d.", failed, resp.StatusCode)
+		}
+		if ok, err := store.Exists(ctx, "avatars/large.png"); err != nil || ok {
+			t.Fatalf("\t%s\tShould not store an upload larger than the maximum : got %v %v.", failed, ok, err)
+		}
+		t.Logf("\t%s\tShould reject an upload larger than the maximum.", success)
+
+		resp, err = http.Get(store.URL("avatars/a.png"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(b) != "png" || resp.Header.Get("Content-Type") != "image/png" {
+			t.Fatalf("\t%s\tShould serve the uploaded object : got %d %q.", failed, resp.StatusCode, b)
+		}
+		t.Logf("\t%s\tShould serve the uploaded object.", success)
+	}
+}
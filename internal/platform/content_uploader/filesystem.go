@@ -0,0 +1,255 @@
+package content_uploader
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Filesystem is a BlobStore keeping objects as files in a directory. The
+// data of an object is kept under objects/ and its metadata under meta/, so
+// no key can collide with the metadata of another.
+type Filesystem struct {
+	dir    string
+	signer *Signer
+}
+
+// NewFilesystem constructs a Filesystem store in the directory, creating it
+// if needed. The signer provides the URLs of the objects.
+func NewFilesystem(dir string, signer *Signer) (*Filesystem, error) {
+	for _, sub := range []string{"objects", "meta"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0755); err != nil {
+			return nil, errors.Wrap(err, "creating blob directory")
+		}
+	}
+
+	fs := Filesystem{
+		dir:    dir,
+		signer: signer,
+	}
+
+	return &fs, nil
+}
+
+// paths returns the files of the data and the metadata of the key.
+func (fs *Filesystem) paths(key string) (string, string) {
+	p := filepath.FromSlash(key)
+	return filepath.Join(fs.dir, "objects", p), filepath.Join(fs.dir, "meta", p+".json")
+}
+
+// Put stores the body under the key, replacing any object stored before.
+// The files are written next to their final place and renamed, so readers
+// never see a partial object.
+func (fs *Filesystem) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+
+	data, meta := fs.paths(key)
+	for _, p := range []string{data, meta} {
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			return errors.Wrapf(err, "creating directory of %q", key)
+		}
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(data), ".put-")
+	if err != nil {
+		return errors.Wrapf(err, "creating %q", key)
+	}
+	defer os.Remove(f.Name())
+
+	n, err := io.Copy(f, ctxReader{ctx: ctx, r: body})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "writing %q", key)
+	}
+
+	info := Info{
+		Key:          key,
+		Size:         n,
+		ContentType:  opts.ContentType,
+		CacheControl: opts.CacheControl,
+		ModTime:      time.Now().UTC(),
+	}
+	b, err := json.Marshal(info)
+	if err != nil {
+		return errors.Wrapf(err, "encoding metadata of %q", key)
+	}
+	if err := writeFile(meta, b); err != nil {
+		return errors.Wrapf(err, "writing metadata of %q", key)
+	}
+
+	if err := os.Rename(f.Name(), data); err != nil {
+		return errors.Wrapf(err, "storing %q", key)
+	}
+
+	return nil
+}
+
+// Get returns the object stored under the key.
+func (fs *Filesystem) Get(ctx context.Context, key string) (io.ReadCloser, *Info, error) {
+	if !validKey(key) {
+		return nil, nil, ErrInvalidKey
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	data, meta := fs.paths(key)
+
+	f, err := os.Open(data)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, errors.Wrapf(err, "opening %q", key)
+	}
+
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, errors.Wrapf(err, "reading %q", key)
+	}
+
+	// Objects put there by other means have no metadata.
+	info := Info{Key: key}
+	if b, err := ioutil.ReadFile(meta); err == nil {
+		json.Unmarshal(b, &info)
+	}
+	info.Size = st.Size()
+	info.ModTime = st.ModTime().UTC()
+
+	return f, &info, nil
+}
+
+// Delete removes the object stored under the key. Removing an object which
+// does not exist is not an error.
+func (fs *Filesystem) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	data, meta := fs.paths(key)
+	for _, p := range []string{data, meta} {
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "deleting %q", key)
+		}
+	}
+
+	return nil
+}
+
+// Exists reports if an object is stored under the key.
+func (fs *Filesystem) Exists(ctx context.Context, key string) (bool, error) {
+	if !validKey(key) {
+		return false, ErrInvalidKey
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	data, _ := fs.paths(key)
+	st, err := os.Stat(data)
+	switch {
+	case err == nil:
+		return st.Mode().IsRegular(), nil
+	case os.IsNotExist(err):
+		return false, nil
+	default:
+		return false, errors.Wrapf(err, "checking %q", key)
+	}
+}
+
+// List calls fn for the objects whose keys start with the prefix, in the
+// order of their keys.
+func (fs *Filesystem) List(ctx context.Context, prefix string, fn ListFunc) error {
+	root := filepath.Join(fs.dir, "objects")
+
+	err := filepath.Walk(root, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// Files being written are skipped.
+		if !fi.Mode().IsRegular() || strings.HasPrefix(fi.Name(), ".put-") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		return fn(Info{
+			Key:     key,
+			Size:    fi.Size(),
+			ModTime: fi.ModTime().UTC(),
+		})
+	})
+	if err != nil {
+		return errors.Wrapf(err, "listing %q", prefix)
+	}
+
+	return nil
+}
+
+// PresignGet returns a URL to read the object, which is served by Handler.
+func (fs *Filesystem) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	return fs.signer.Presign(http.MethodGet, key, time.Now().Add(ttl), PutOptions{}), nil
+}
+
+// PresignPut returns a URL to upload the object, which is served by Handler.
+func (fs *Filesystem) PresignPut(ctx context.Context, key string, ttl time.Duration, opts PutOptions) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	return fs.signer.Presign(http.MethodPut, key, time.Now().Add(ttl), opts), nil
+}
+
+// URL returns the address the object with the key is served from.
+func (fs *Filesystem) URL(key string) string {
+	return fs.signer.URL(key)
+}
+
+// writeFile writes the file through a temporary file, so it is replaced
+// atomically.
+func writeFile(name string, b []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), ".put-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
@@ -0,0 +1,170 @@
+package content_uploader
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// memoryObject is an object kept by Memory.
+type memoryObject struct {
+	data []byte
+	info Info
+}
+
+// Memory is a BlobStore keeping objects in memory. It is meant for tests and
+// development, the objects are lost when the process exits.
+type Memory struct {
+	signer  *Signer
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+// NewMemory constructs an empty Memory store. The signer provides the URLs
+// of the objects.
+func NewMemory(signer *Signer) *Memory {
+	return &Memory{
+		signer:  signer,
+		objects: make(map[string]memoryObject),
+	}
+}
+
+// Put stores the body under the key, replacing any object stored before.
+func (m *Memory) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+
+	data, err := ioutil.ReadAll(ctxReader{ctx: ctx, r: body})
+	if err != nil {
+		return errors.Wrapf(err, "reading %q", key)
+	}
+
+	obj := memoryObject{
+		data: data,
+		info: Info{
+			Key:          key,
+			Size:         int64(len(data)),
+			ContentType:  opts.ContentType,
+			CacheControl: opts.CacheControl,
+			ModTime:      time.Now().UTC(),
+		},
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.objects[key] = obj
+	return nil
+}
+
+// Get returns the object stored under the key.
+func (m *Memory) Get(ctx context.Context, key string) (io.ReadCloser, *Info, error) {
+	if !validKey(key) {
+		return nil, nil, ErrInvalidKey
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	obj, ok := m.objects[key]
+	if !ok {
+		return nil, nil, ErrNotFound
+	}
+
+	info := obj.info
+	return ioutil.NopCloser(bytes.NewReader(obj.data)), &info, nil
+}
+
+// Delete removes the object stored under the key. Removing an object which
+// does not exist is not an error.
+func (m *Memory) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.objects, key)
+	return nil
+}
+
+// Exists reports if an object is stored under the key.
+func (m *Memory) Exists(ctx context.Context, key string) (bool, error) {
+	if !validKey(key) {
+		return false, ErrInvalidKey
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.objects[key]
+	return ok, nil
+}
+
+// List calls fn for the objects whose keys start with the prefix, in the
+// order of their keys.
+func (m *Memory) List(ctx context.Context, prefix string, fn ListFunc) error {
+	m.mu.RLock()
+	var infos []Info
+	for key, obj := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			infos = append(infos, obj.info)
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Key < infos[j].Key
+	})
+
+	for _, info := range infos {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(info); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PresignGet returns a URL to read the object, which is served by Handler.
+func (m *Memory) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	return m.signer.Presign(http.MethodGet, key, time.Now().Add(ttl), PutOptions{}), nil
+}
+
+// PresignPut returns a URL to upload the object, which is served by Handler.
+func (m *Memory) PresignPut(ctx context.Context, key string, ttl time.Duration, opts PutOptions) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+	return m.signer.Presign(http.MethodPut, key, time.Now().Add(ttl), opts), nil
+}
+
+// URL returns the address the object with the key is served from.
+func (m *Memory) URL(key string) string {
+	return m.signer.URL(key)
+}
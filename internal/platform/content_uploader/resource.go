@@ -1,55 +1,285 @@
 package content_uploader
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"net/http"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/pkg/errors"
 )
 
+// Uploader is a BlobStore keeping objects in an S3 bucket. Any S3 compatible
+// service like MinIO or LocalStack can be used through a custom endpoint.
 type Uploader struct {
 	cfg      Config
+	client   *s3.S3
 	uploader *s3manager.Uploader
 }
 
 type Config struct {
 	Region string
 	Bucket string
+
+	// Endpoint is the address of an S3 compatible service. It defaults to
+	// AWS. PathStyle puts the bucket into the path instead of the host name,
+	// which most compatible services need.
+	Endpoint  string
+	PathStyle bool
+
+	// AccessKeyID and SecretAccessKey are static credentials. Without them
+	// the credentials are looked up the default AWS way.
+	AccessKeyID     string
+	SecretAccessKey string
+
+	// BaseURL is the address the objects of the bucket are served from,
+	// for example a CDN. It defaults to the address of the bucket itself.
+	BaseURL string
+
+	// Transport makes the requests to the service. It defaults to the one
+	// of the AWS SDK.
+	Transport http.RoundTripper
 }
 
 // New returns new Content Uploader
 func New(cfg Config) (*Uploader, error) {
+	ac := aws.Config{
+		Region:           aws.String(cfg.Region),
+		S3ForcePathStyle: aws.Bool(cfg.PathStyle),
+	}
+	if cfg.Endpoint != "" {
+		ac.Endpoint = aws.String(cfg.Endpoint)
+	}
+	if cfg.AccessKeyID != "" {
+		ac.Credentials = credentials.NewStaticCredentials(cfg.AccessKeyID, cfg.SecretAccessKey, "")
+	}
+	if cfg.Transport != nil {
+		ac.HTTPClient = &http.Client{Transport: cfg.Transport}
+	}
 
 	// create new session with given configuration
-	s, err := session.NewSession(&aws.Config{
-		Region: aws.String(cfg.Region),
-	})
+	s, err := session.NewSession(&ac)
 	if err != nil {
 		return nil, errors.Wrap(err, "creating content_uploader client")
 	}
 
-	//
+	switch {
+	case cfg.BaseURL != "":
+	case cfg.Endpoint != "" && cfg.PathStyle:
+		cfg.BaseURL = strings.TrimSuffix(cfg.Endpoint, "/") + "/" + cfg.Bucket
+	case cfg.Endpoint != "":
+		scheme, host := "https://", cfg.Endpoint
+		if i := strings.Index(host, "://"); i >= 0 {
+			scheme, host = host[:i+3], host[i+3:]
+		}
+		cfg.BaseURL = scheme + cfg.Bucket + "." + strings.TrimSuffix(host, "/")
+	default:
+		cfg.BaseURL = fmt.Sprintf("https://%s.s3.%s.amazonaws.com", cfg.Bucket, cfg.Region)
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+
 	u := Uploader{
-		cfg: cfg,
+		cfg:      cfg,
+		client:   s3.New(s),
 		uploader: s3manager.NewUploader(s),
 	}
 
 	return &u, nil
 }
 
-// Upload uploads file with given name to s3 bucket
-func (u *Uploader) Upload(fileName string, file io.Reader) error {
+// Put uploads file with given key and metadata to s3 bucket
+func (u *Uploader) Put(ctx context.Context, key string, body io.Reader, opts PutOptions) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+
 	ui := s3manager.UploadInput{
-		Body:                      file,
-		Bucket:                    aws.String(u.cfg.Bucket),
-		Key:                       aws.String(fileName),
+		Body:         body,
+		Bucket:       aws.String(u.cfg.Bucket),
+		Key:          aws.String(key),
+		ContentType:  optional(opts.ContentType),
+		CacheControl: optional(opts.CacheControl),
+	}
+
+	if _, err := u.uploader.UploadWithContext(ctx, &ui); err != nil {
+		return errors.Wrapf(err, "uploading %q", key)
+	}
+
+	return nil
+}
+
+// Get returns the object with given key from s3 bucket
+func (u *Uploader) Get(ctx context.Context, key string) (io.ReadCloser, *Info, error) {
+	if !validKey(key) {
+		return nil, nil, ErrInvalidKey
 	}
-	
-	if _, err := u.uploader.Upload(&ui); err != nil {
-		return err
+
+	gi := s3.GetObjectInput{
+		Bucket: aws.String(u.cfg.Bucket),
+		Key:    aws.String(key),
+	}
+
+	out, err := u.client.GetObjectWithContext(ctx, &gi)
+	if err != nil {
+		if notFound(err) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, errors.Wrapf(err, "getting %q", key)
+	}
+
+	info := Info{
+		Key:          key,
+		Size:         aws.Int64Value(out.ContentLength),
+		ContentType:  aws.StringValue(out.ContentType),
+		CacheControl: aws.StringValue(out.CacheControl),
+		ModTime:      aws.TimeValue(out.LastModified),
+	}
+
+	return out.Body, &info, nil
+}
+
+// Delete removes file with given key from s3 bucket. Removing a file which
+// does not exist is not an error.
+func (u *Uploader) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return ErrInvalidKey
+	}
+
+	di := s3.DeleteObjectInput{
+		Bucket: aws.String(u.cfg.Bucket),
+		Key:    aws.String(key),
+	}
+
+	if _, err := u.client.DeleteObjectWithContext(ctx, &di); err != nil {
+		return errors.Wrapf(err, "deleting %q", key)
 	}
 
 	return nil
 }
+
+// Exists reports if a file with given key is in s3 bucket
+func (u *Uploader) Exists(ctx context.Context, key string) (bool, error) {
+	if !validKey(key) {
+		return false, ErrInvalidKey
+	}
+
+	hi := s3.HeadObjectInput{
+		Bucket: aws.String(u.cfg.Bucket),
+		Key:    aws.String(key),
+	}
+
+	if _, err := u.client.HeadObjectWithContext(ctx, &hi); err != nil {
+		if notFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "checking %q", key)
+	}
+
+	return true, nil
+}
+
+// List calls fn for the files in s3 bucket whose keys start with given prefix
+func (u *Uploader) List(ctx context.Context, prefix string, fn ListFunc) error {
+	li := s3.ListObjectsV2Input{
+		Bucket: aws.String(u.cfg.Bucket),
+		Prefix: aws.String(prefix),
+	}
+
+	var ferr error
+	err := u.client.ListObjectsV2PagesWithContext(ctx, &li, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, obj := range page.Contents {
+			info := Info{
+				Key:     aws.StringValue(obj.Key),
+				Size:    aws.Int64Value(obj.Size),
+				ModTime: aws.TimeValue(obj.LastModified),
+			}
+			if ferr = fn(info); ferr != nil {
+				return false
+			}
+		}
+		return true
+	})
+	if ferr != nil {
+		return ferr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "listing %q", prefix)
+	}
+
+	return nil
+}
+
+// PresignGet returns a URL to download the file with given key
+func (u *Uploader) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+
+	req, _ := u.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(u.cfg.Bucket),
+		Key:    aws.String(key),
+	})
+	req.SetContext(ctx)
+
+	url, err := req.Presign(ttl)
+	if err != nil {
+		return "", errors.Wrapf(err, "presigning %q", key)
+	}
+
+	return url, nil
+}
+
+// PresignPut returns a URL to upload the file with given key. The metadata is
+// part of the signature, so the upload has to send the same headers.
+func (u *Uploader) PresignPut(ctx context.Context, key string, ttl time.Duration, opts PutOptions) (string, error) {
+	if !validKey(key) {
+		return "", ErrInvalidKey
+	}
+
+	req, _ := u.client.PutObjectRequest(&s3.PutObjectInput{
+		Bucket:       aws.String(u.cfg.Bucket),
+		Key:          aws.String(key),
+		ContentType:  optional(opts.ContentType),
+		CacheControl: optional(opts.CacheControl),
+	})
+	req.SetContext(ctx)
+
+	url, err := req.Presign(ttl)
+	if err != nil {
+		return "", errors.Wrapf(err, "presigning %q", key)
+	}
+
+	return url, nil
+}
+
+// URL returns the address the file with given key is served from.
+func (u *Uploader) URL(key string) string {
+	return u.cfg.BaseURL + "/" + key
+}
+
+// optional returns nil for empty strings, so they are not sent.
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+// notFound reports if the error means that the object does not exist.
+func notFound(err error) bool {
+	if rf, ok := err.(awserr.RequestFailure); ok && rf.StatusCode() == http.StatusNotFound {
+		return true
+	}
+	if ae, ok := err.(awserr.Error); ok && ae.Code() == s3.ErrCodeNoSuchKey {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,191 @@
+package content_uploader
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidSignature is returned for a presigned URL which was altered or
+// has expired.
+var ErrInvalidSignature = errors.New("invalid or expired signature")
+
+// Signer presigns URLs for the stores which have no server of their own,
+// the memory and the filesystem stores. The URLs are served by Handler.
+type Signer struct {
+	base   string
+	secret []byte
+}
+
+// NewSigner constructs a Signer for objects served under the base URL.
+func NewSigner(baseURL string, secret []byte) *Signer {
+	return &Signer{
+		base:   strings.TrimSuffix(baseURL, "/"),
+		secret: secret,
+	}
+}
+
+// URL returns the address the object with the key is served from.
+func (s *Signer) URL(key string) string {
+	return s.base + "/" + key
+}
+
+// Presign returns a URL allowing the method on the key until the expiration
+// time. Content type and cache control of uploads are part of the signature,
+// so they can't be changed by the uploader.
+func (s *Signer) Presign(method, key string, expires time.Time, opts PutOptions) string {
+	q := url.Values{}
+	q.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	if opts.ContentType != "" {
+		q.Set("content_type", opts.ContentType)
+	}
+	if opts.CacheControl != "" {
+		q.Set("cache_control", opts.CacheControl)
+	}
+	q.Set("signature", s.signature(method, key, q))
+
+	return s.URL(key) + "?" + q.Encode()
+}
+
+// Verify checks the signature of a presigned URL for the method on the key.
+func (s *Signer) Verify(method, key string, q url.Values, now time.Time) error {
+	expires, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil || now.Unix() > expires {
+		return ErrInvalidSignature
+	}
+
+	want := s.signature(method, key, q)
+	if !hmac.Equal([]byte(want), []byte(q.Get("signature"))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// signature computes the signature of the method, the key and the signed
+// query parameters.
+func (s *Signer) signature(method, key string, q url.Values) string {
+	mac := hmac.New(sha256.New, s.secret)
+	for _, v := range []string{method, key, q.Get("expires"), q.Get("content_type"), q.Get("cache_control")} {
+		io.WriteString(mac, v)
+		mac.Write([]byte{0})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Handler serves the objects of a store under the path of the signer base
+// URL. Objects are public to read like in a public bucket, writing requires
+// a URL presigned for PUT. Uploads larger than maxSize bytes are rejected. It
+// is meant for development without S3.
+func Handler(store BlobStore, s *Signer, maxSize int64) http.Handler {
+	prefix := "/"
+	if u, err := url.Parse(s.base); err == nil {
+		prefix = strings.TrimSuffix(u.Path, "/") + "/"
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			http.NotFound(w, r)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, prefix)
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			body, info, err := store.Get(r.Context(), key)
+			if err != nil {
+				switch err {
+				case ErrNotFound, ErrInvalidKey:
+					http.NotFound(w, r)
+				default:
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+				}
+				return
+			}
+			defer body.Close()
+
+			if info.ContentType != "" {
+				w.Header().Set("Content-Type", info.ContentType)
+			}
+			if info.CacheControl != "" {
+				w.Header().Set("Cache-Control", info.CacheControl)
+			}
+			w.Header().Set("Content-Length", strconv.FormatInt(info.Size, 10))
+			w.WriteHeader(http.StatusOK)
+			if r.Method == http.MethodGet {
+				io.Copy(w, body)
+			}
+
+		case http.MethodPut:
+			q := r.URL.Query()
+			if err := s.Verify(http.MethodPut, key, q, time.Now()); err != nil {
+				http.Error(w, err.Error(), http.StatusForbidden)
+				return
+			}
+
+			opts := PutOptions{
+				ContentType:  q.Get("content_type"),
+				CacheControl: q.Get("cache_control"),
+			}
+			if opts.ContentType != "" && r.Header.Get("Content-Type") != opts.ContentType {
+				http.Error(w, "content type does not match the signature", http.StatusForbidden)
+				return
+			}
+
+			if r.ContentLength > maxSize {
+				http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			body := limitedBody{ReadCloser: http.MaxBytesReader(w, r.Body, maxSize), max: maxSize}
+			if err := store.Put(r.Context(), key, &body, opts); err != nil {
+				switch {
+				case body.exceeded():
+					http.Error(w, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+				case err == ErrInvalidKey:
+					http.Error(w, err.Error(), http.StatusBadRequest)
+				default:
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+				}
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+
+		default:
+			w.Header().Set("Allow", "GET, HEAD, PUT")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// limitedBody is the body of an upload read through http.MaxBytesReader. It
+// tells a body failing for being too large apart from other failures.
+type limitedBody struct {
+	io.ReadCloser
+	max int64
+	n   int64
+	err error
+}
+
+// Read implements the io.Reader interface.
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
+
+// exceeded reports if reading failed because the body is larger than max.
+func (b *limitedBody) exceeded() bool {
+	return b.err != nil && b.n >= b.max
+}
@@ -0,0 +1,108 @@
+// Package dataloader batches the loading of values by key, so resolving a
+// field for many objects costs one query instead of one per object.
+//
+// Loading a key only queues it and returns a thunk. Calling any of the
+// thunks loads every key queued so far at once. A Loader caches what it
+// loaded, it is meant to live as long as a single request.
+package dataloader
+
+import (
+	"context"
+	"sync"
+)
+
+// BatchFunc loads the values of the keys at once. Keys without a value are
+// left out of the map.
+type BatchFunc func(ctx context.Context, keys []string) (map[string]interface{}, error)
+
+// result is the outcome of loading a key.
+type result struct {
+	value interface{}
+	err   error
+	done  bool
+}
+
+// Loader loads values by key in batches.
+type Loader struct {
+	batch BatchFunc
+
+	mu      sync.Mutex
+	pending []string
+	results map[string]*result
+}
+
+// New constructs a Loader which loads values with the batch function.
+func New(batch BatchFunc) *Loader {
+	return &Loader{
+		batch:   batch,
+		results: make(map[string]*result),
+	}
+}
+
+// Load queues the key and returns a thunk returning its value. The value is
+// nil for keys the batch function has no value for.
+func (l *Loader) Load(ctx context.Context, key string) func() (interface{}, error) {
+	l.mu.Lock()
+	r := l.queue(key)
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		l.dispatch(ctx, r)
+		return r.value, r.err
+	}
+}
+
+// LoadMany queues the keys and returns a thunk returning their values in
+// the order of the keys. It fails with the first key which failed.
+func (l *Loader) LoadMany(ctx context.Context, keys []string) func() (interface{}, error) {
+	l.mu.Lock()
+	rs := make([]*result, len(keys))
+	for i, key := range keys {
+		rs[i] = l.queue(key)
+	}
+	l.mu.Unlock()
+
+	return func() (interface{}, error) {
+		values := make([]interface{}, len(rs))
+		for i, r := range rs {
+			l.dispatch(ctx, r)
+			if r.err != nil {
+				return nil, r.err
+			}
+			values[i] = r.value
+		}
+		return values, nil
+	}
+}
+
+// queue returns the result of the key, queueing the key if it was not
+// loaded or queued before. It must be called with the lock held.
+func (l *Loader) queue(key string) *result {
+	if r, ok := l.results[key]; ok {
+		return r
+	}
+
+	r := result{}
+	l.results[key] = &r
+	l.pending = append(l.pending, key)
+	return &r
+}
+
+// dispatch loads the queued keys unless the result is done already.
+func (l *Loader) dispatch(ctx context.Context, r *result) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if r.done {
+		return
+	}
+
+	keys := l.pending
+	l.pending = nil
+
+	values, err := l.batch(ctx, keys)
+	for _, key := range keys {
+		kr := l.results[key]
+		kr.value, kr.err, kr.done = values[key], err, true
+	}
+}
@@ -0,0 +1,82 @@
+package dataloader_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/igomonov88/users/internal/platform/dataloader"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestLoader(t *testing.T) {
+	ctx := context.Background()
+
+	var batches []string
+	l := dataloader.New(func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+		batches = append(batches, strings.Join(keys, ","))
+		values := make(map[string]interface{})
+		for _, k := range keys {
+			if k != "missing" {
+				values[k] = strings.ToUpper(k)
+			}
+		}
+		return values, nil
+	})
+
+	t.Log("Given the need to load values in batches.")
+	{
+		a := l.Load(ctx, "a")
+		b := l.Load(ctx, "b")
+		many := l.LoadMany(ctx, []string{"b", "c", "missing"})
+
+		if len(batches) != 0 {
+			t.Fatalf("\t%s\tShould only queue keys when loading : got %v.", failed, batches)
+		}
+		t.Logf("\t%s\tShould only queue keys when loading.", success)
+
+		if v, err := b(); err != nil || v != "B" {
+			t.Fatalf("\t%s\tShould return the value of the key : got %v, %v.", failed, v, err)
+		}
+		if v, err := a(); err != nil || v != "A" {
+			t.Fatalf("\t%s\tShould return the value of the key : got %v, %v.", failed, v, err)
+		}
+		v, err := many()
+		if err != nil {
+			t.Fatalf("\t%s\tShould return the values of the keys : %v.", failed, err)
+		}
+		values := v.([]interface{})
+		if len(values) != 3 || values[0] != "B" || values[1] != "C" || values[2] != nil {
+			t.Fatalf("\t%s\tShould return the values of the keys in order : got %v.", failed, values)
+		}
+		t.Logf("\t%s\tShould return the values of the keys in order.", success)
+
+		if len(batches) != 1 || batches[0] != "a,b,c,missing" {
+			t.Fatalf("\t%s\tShould load every queued key at once : got %v.", failed, batches)
+		}
+		t.Logf("\t%s\tShould load every queued key at once.", success)
+
+		if v, err := l.Load(ctx, "c")(); err != nil || v != "C" || len(batches) != 1 {
+			t.Fatalf("\t%s\tShould cache loaded values : got %v, %v after %v.", failed, v, err, batches)
+		}
+		t.Logf("\t%s\tShould cache loaded values.", success)
+	}
+
+	t.Log("Given the need to report failed batches.")
+	{
+		boom := errors.New("boom")
+		l := dataloader.New(func(ctx context.Context, keys []string) (map[string]interface{}, error) {
+			return nil, boom
+		})
+
+		if _, err := l.LoadMany(ctx, []string{"a", "b"})(); err != boom {
+			t.Fatalf("\t%s\tShould fail every key of the batch : got %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould fail every key of the batch.", success)
+	}
+}
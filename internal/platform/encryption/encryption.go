@@ -0,0 +1,68 @@
+// Package encryption provides authenticated encryption of values which have
+// to be stored at rest.
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidKey is returned when the key is not a base64 encoded 256 bit key.
+var ErrInvalidKey = errors.New("encryption key must be 32 bytes encoded in base64")
+
+// Encrypter seals and opens values with AES-256-GCM. A random nonce is
+// generated for every value and prepended to the ciphertext.
+type Encrypter struct {
+	aead cipher.AEAD
+}
+
+// New constructs an Encrypter from a base64 encoded 256 bit key.
+func New(key string) (*Encrypter, error) {
+	k, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(k) != 32 {
+		return nil, ErrInvalidKey
+	}
+
+	block, err := aes.NewCipher(k)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating cipher")
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating gcm")
+	}
+
+	return &Encrypter{aead: aead}, nil
+}
+
+// Seal encrypts the plaintext. The additional data is authenticated but not
+// encrypted, it should bind the value to its owner so ciphertexts can't be
+// swapped between rows.
+func (e *Encrypter) Seal(plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, e.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "reading nonce")
+	}
+
+	return e.aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+// Open decrypts a ciphertext produced by Seal with the same additional data.
+func (e *Encrypter) Open(ciphertext, additional []byte) ([]byte, error) {
+	size := e.aead.NonceSize()
+	if len(ciphertext) < size {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	plaintext, err := e.aead.Open(nil, ciphertext[:size], ciphertext[size:], additional)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening ciphertext")
+	}
+
+	return plaintext, nil
+}
@@ -0,0 +1,63 @@
+package encryption_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+
+	"github.com/igomonov88/users/internal/platform/encryption"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestEncrypter(t *testing.T) {
+	t.Log("Given the need to encrypt values at rest.")
+	{
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			t.Fatal(err)
+		}
+
+		enc, err := encryption.New(base64.StdEncoding.EncodeToString(key))
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to construct an encrypter: %s.", failed, err)
+		}
+		t.Logf("\t%s\tShould be able to construct an encrypter.", success)
+
+		plaintext := []byte("JBSWY3DPEHPK3PXP")
+		owner := []byte("45b5fbd3-755f-4379-8f07-a58d4a30fa2f")
+
+		ciphertext, err := enc.Seal(plaintext, owner)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to seal a value: %s.", failed, err)
+		}
+		if bytes.Contains(ciphertext, plaintext) {
+			t.Fatalf("\t%s\tShould not store the plaintext.", failed)
+		}
+		t.Logf("\t%s\tShould be able to seal a value.", success)
+
+		got, err := enc.Open(ciphertext, owner)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to open a sealed value: %s.", failed, err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Fatalf("\t%s\tShould get back the sealed value.", failed)
+		}
+		t.Logf("\t%s\tShould be able to open a sealed value.", success)
+
+		if _, err := enc.Open(ciphertext, []byte("another owner")); err == nil {
+			t.Fatalf("\t%s\tShould not open a value sealed for another owner.", failed)
+		}
+		t.Logf("\t%s\tShould not open a value sealed for another owner.", success)
+	}
+
+	if _, err := encryption.New("c2hvcnQ="); err != encryption.ErrInvalidKey {
+		t.Fatalf("\t%s\tShould reject a key of the wrong size.", failed)
+	}
+	t.Logf("\t%s\tShould reject a key of the wrong size.", success)
+}
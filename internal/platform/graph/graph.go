@@ -0,0 +1,298 @@
+// Package graph executes GraphQL requests with limits on the cost of
+// queries. Errors are reported with the code of the error catalog in their
+// extensions, like problem details report them.
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/location"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// Codes of errors of requests which are not valid queries.
+var (
+	CodeInvalidQuery = web.ErrorCode{Code: "graphql.invalid_query", Title: "Invalid GraphQL query"}
+	CodeTooDeep      = web.ErrorCode{Code: "graphql.too_deep", Title: "GraphQL query too deep"}
+	CodeTooComplex   = web.ErrorCode{Code: "graphql.too_complex", Title: "GraphQL query too complex"}
+)
+
+// ListFactor is how often the selections of fields returning lists count
+// towards the complexity of a query, as they are resolved for every item.
+const ListFactor = 10
+
+// Limits bound the cost of queries. The depth is the longest chain of
+// nested fields, the complexity the number of fields to resolve. Zero
+// values disable a limit.
+type Limits struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
+// Request is the body of GraphQL requests.
+type Request struct {
+	Query         string                 `json:"query" validate:"required"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Response is the body of GraphQL responses. Data is left out when the
+// request was not executed.
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []Error     `json:"errors,omitempty"`
+}
+
+// Error is an error of a GraphQL response. The extensions hold the code of
+// the error.
+type Error struct {
+	Message    string                    `json:"message"`
+	Locations  []location.SourceLocation `json:"locations,omitempty"`
+	Path       []interface{}             `json:"path,omitempty"`
+	Extensions map[string]interface{}    `json:"extensions,omitempty"`
+}
+
+// Execute validates the request against the schema and the limits and
+// executes it. It returns the response and the status to respond with,
+// 400 for requests which were not executed. Errors of resolvers which are
+// not an *web.Error are logged and not disclosed.
+func Execute(ctx context.Context, log *logger.Logger, schema *graphql.Schema, req Request, limits Limits) (Response, int) {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return requestErrors(CodeInvalidQuery, []gqlerrors.FormattedError{gqlerrors.FormatError(err)})
+	}
+
+	if vr := graphql.ValidateDocument(schema, doc, nil); !vr.IsValid {
+		return requestErrors(CodeInvalidQuery, vr.Errors)
+	}
+
+	op, err := operation(doc, req.OperationName)
+	if err != nil {
+		return requestErrors(CodeInvalidQuery, []gqlerrors.FormattedError{gqlerrors.FormatError(err)})
+	}
+
+	depth, complexity := Measure(schema, doc, op)
+	if limits.MaxDepth > 0 && depth > limits.MaxDepth {
+		err := fmt.Errorf("query depth %d exceeds the limit of %d", depth, limits.MaxDepth)
+		return requestErrors(CodeTooDeep, []gqlerrors.FormattedError{gqlerrors.FormatError(err)})
+	}
+	if limits.MaxComplexity > 0 && complexity > limits.MaxComplexity {
+		err := fmt.Errorf("query complexity %d exceeds the limit of %d", complexity, limits.MaxComplexity)
+		return requestErrors(CodeTooComplex, []gqlerrors.FormattedError{gqlerrors.FormatError(err)})
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        *schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+
+	resp := Response{Data: result.Data}
+	for _, fe := range result.Errors {
+		e := Error{
+			Locations: fe.Locations,
+			Path:      fe.Path,
+		}
+
+		// Errors of the request itself, like variables of the wrong type,
+		// have no cause of a resolver.
+		cause := originalError(fe)
+		if cause == nil {
+			e.Message = fe.Message
+			e.Extensions = map[string]interface{}{"code": CodeInvalidQuery.Code}
+			resp.Errors = append(resp.Errors, e)
+			continue
+		}
+
+		p := web.NewProblem(ctx, cause)
+		if p.Status >= http.StatusInternalServerError {
+			log.Error("graphql error", "request_id", p.RequestID, "path", fe.Path, "error", cause,
+				"error_chain", logger.Chain(cause), "stack", fmt.Sprintf("%+v", cause))
+		}
+
+		e.Message = p.Detail
+		if e.Message == "" {
+			e.Message = p.Title
+		}
+		e.Extensions = map[string]interface{}{"code": p.Code}
+		if len(p.Fields) > 0 {
+			e.Extensions["fields"] = p.Fields
+		}
+		resp.Errors = append(resp.Errors, e)
+	}
+
+	// Without data the request was not executed.
+	if result.Data == nil {
+		return resp, http.StatusBadRequest
+	}
+
+	return resp, http.StatusOK
+}
+
+// requestErrors returns the response to a request which is not executed.
+func requestErrors(code web.ErrorCode, errs []gqlerrors.FormattedError) (Response, int) {
+	var resp Response
+	for _, fe := range errs {
+		resp.Errors = append(resp.Errors, Error{
+			Message:    fe.Message,
+			Locations:  fe.Locations,
+			Extensions: map[string]interface{}{"code": code.Code},
+		})
+	}
+	return resp, http.StatusBadRequest
+}
+
+// originalError returns the error a resolver failed with, unwrapping the
+// errors the executor wraps it in. It returns nil for errors no resolver
+// returned.
+func originalError(fe gqlerrors.FormattedError) error {
+	var err error = fe
+	for {
+		var next error
+		switch e := err.(type) {
+		case gqlerrors.FormattedError:
+			next = e.OriginalError()
+		case *gqlerrors.Error:
+			next = e.OriginalError
+		default:
+			return err
+		}
+		if next == nil {
+			return nil
+		}
+		err = next
+	}
+}
+
+// operation returns the operation of the document to execute, the one
+// with the name or the only one.
+func operation(doc *ast.Document, name string) (*ast.OperationDefinition, error) {
+	var op *ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		def, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "" && op != nil:
+			return nil, errors.New("operation name required for documents with multiple operations")
+		case name == "" || (def.Name != nil && def.Name.Value == name):
+			op = def
+		}
+	}
+	if op == nil {
+		return nil, fmt.Errorf("unknown operation %q", name)
+	}
+	return op, nil
+}
+
+// Measure returns the depth and the complexity of the operation of the
+// document. Introspection fields are free, so clients can always fetch the
+// schema.
+func Measure(schema *graphql.Schema, doc *ast.Document, op *ast.OperationDefinition) (int, int) {
+	m := measurer{fragments: make(map[string]*ast.FragmentDefinition)}
+	for _, def := range doc.Definitions {
+		if def, ok := def.(*ast.FragmentDefinition); ok {
+			m.fragments[def.Name.Value] = def
+		}
+	}
+
+	var root *graphql.Object
+	switch op.Operation {
+	case ast.OperationTypeMutation:
+		root = schema.MutationType()
+	case ast.OperationTypeSubscription:
+		root = schema.SubscriptionType()
+	default:
+		root = schema.QueryType()
+	}
+
+	return m.selections(root, op.SelectionSet)
+}
+
+// measurer measures the selections of an operation.
+type measurer struct {
+	fragments map[string]*ast.FragmentDefinition
+}
+
+// selections returns the depth and the complexity of the selections on
+// the type. Fragments are measured where they are spread, documents with
+// cycles of fragments do not pass validation.
+func (m measurer) selections(t *graphql.Object, set *ast.SelectionSet) (int, int) {
+	if t == nil || set == nil {
+		return 0, 0
+	}
+
+	var depth, complexity int
+	add := func(d, c int) {
+		if d > depth {
+			depth = d
+		}
+		complexity += c
+	}
+
+	for _, sel := range set.Selections {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			add(m.field(t, sel))
+		case *ast.InlineFragment:
+			add(m.selections(t, sel.SelectionSet))
+		case *ast.FragmentSpread:
+			if f, ok := m.fragments[sel.Name.Value]; ok {
+				add(m.selections(t, f.SelectionSet))
+			}
+		}
+	}
+
+	return depth, complexity
+}
+
+// field returns the depth and the complexity of the field of the type.
+func (m measurer) field(t *graphql.Object, f *ast.Field) (int, int) {
+	if strings.HasPrefix(f.Name.Value, "__") {
+		return 0, 0
+	}
+
+	def, ok := t.Fields()[f.Name.Value]
+	if !ok {
+		return 1, 1
+	}
+
+	list := false
+	ft := def.Type
+	for {
+		switch wrapped := ft.(type) {
+		case *graphql.NonNull:
+			ft = wrapped.OfType
+			continue
+		case *graphql.List:
+			list = true
+			ft = wrapped.OfType
+			continue
+		}
+		break
+	}
+
+	obj, _ := ft.(*graphql.Object)
+	depth, complexity := m.selections(obj, f.SelectionSet)
+	if list {
+		complexity *= ListFactor
+	}
+
+	return depth + 1, complexity + 1
+}
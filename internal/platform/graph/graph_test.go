@@ -0,0 +1,111 @@
+package graph_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+
+	"github.com/igomonov88/users/internal/platform/graph"
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// schema returns a schema of nodes linking to other nodes. Nodes fail with
+// the error of their name.
+func schema(t *testing.T) *graphql.Schema {
+	errs := map[string]error{
+		"missing": web.NewRequestError(errors.New("node not found"), http.StatusNotFound),
+		"broken":  errors.New("connection refused"),
+	}
+
+	node := graphql.NewObject(graphql.ObjectConfig{
+		Name:   "Node",
+		Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+	})
+	resolve := func(p graphql.ResolveParams) (interface{}, error) {
+		name, _ := p.Args["name"].(string)
+		if err := errs[name]; err != nil {
+			return func() (interface{}, error) { return nil, err }, nil
+		}
+		return map[string]interface{}{"name": name}, nil
+	}
+	node.AddFieldConfig("next", &graphql.Field{Type: node, Resolve: resolve})
+	node.AddFieldConfig("children", &graphql.Field{Type: graphql.NewList(node), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return []interface{}{map[string]interface{}{"name": "child"}}, nil
+	}})
+
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"node": &graphql.Field{
+				Type:    node,
+				Args:    graphql.FieldConfigArgument{"name": &graphql.ArgumentConfig{Type: graphql.String}},
+				Resolve: resolve,
+			},
+		},
+	})
+
+	s, err := graphql.NewSchema(graphql.SchemaConfig{Query: query})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &s
+}
+
+func TestExecute(t *testing.T) {
+	log := logger.New(ioutil.Discard, logger.Error)
+	s := schema(t)
+	limits := graph.Limits{MaxDepth: 3, MaxComplexity: 20}
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		code   string
+	}{
+		{"valid query", `{ node(name: "a") { name next { name } } }`, http.StatusOK, ""},
+		{"introspection query", `{ __schema { types { name fields { name type { name ofType { name } } } } } }`, http.StatusOK, ""},
+		{"syntax error", `{ node(name: "a") {`, http.StatusBadRequest, graph.CodeInvalidQuery.Code},
+		{"unknown field", `{ node { email } }`, http.StatusBadRequest, graph.CodeInvalidQuery.Code},
+		{"too deep", `{ node { next { next { next { name } } } } }`, http.StatusBadRequest, graph.CodeTooDeep.Code},
+		{"too deep fragment", `{ node { ...f } } fragment f on Node { next { next { name } } }`, http.StatusBadRequest, graph.CodeTooDeep.Code},
+		{"too complex", `{ a: node { children { name } } b: node { children { name } } }`, http.StatusBadRequest, graph.CodeTooComplex.Code},
+		{"coded error", `{ node(name: "missing") { name } }`, http.StatusOK, "request.not_found"},
+		{"internal error", `{ node(name: "broken") { name } }`, http.StatusOK, web.CodeInternal.Code},
+	}
+
+	t.Log("Given the need to execute queries within limits.")
+	{
+		for i, tt := range tests {
+			resp, status := graph.Execute(context.Background(), log, s, graph.Request{Query: tt.query}, limits)
+			if status != tt.status {
+				t.Fatalf("\t%s\tTest %d:\tShould respond to a %s with %d : got %d %+v.", failed, i, tt.name, tt.status, status, resp.Errors)
+			}
+
+			if tt.code == "" {
+				if len(resp.Errors) != 0 {
+					t.Fatalf("\t%s\tTest %d:\tShould execute a %s : got %+v.", failed, i, tt.name, resp.Errors)
+				}
+				t.Logf("\t%s\tTest %d:\tShould execute a %s.", success, i, tt.name)
+				continue
+			}
+
+			if len(resp.Errors) != 1 || resp.Errors[0].Extensions["code"] != tt.code {
+				t.Fatalf("\t%s\tTest %d:\tShould report a %s with %q : got %+v.", failed, i, tt.name, tt.code, resp.Errors)
+			}
+			if tt.code == web.CodeInternal.Code && resp.Errors[0].Message == "connection refused" {
+				t.Fatalf("\t%s\tTest %d:\tShould not disclose internal errors : got %q.", failed, i, resp.Errors[0].Message)
+			}
+			t.Logf("\t%s\tTest %d:\tShould report a %s with %q.", success, i, tt.name, tt.code)
+		}
+	}
+}
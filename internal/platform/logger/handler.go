@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// levelDoc is the form the level handler reads and writes.
+type levelDoc struct {
+	Level string `json:"level"`
+}
+
+// LevelHandler returns a handler to inspect and change the level of the
+// logger at runtime. GET responds with the current level, PUT sets the
+// level of a document like {"level":"debug"}. It belongs on a debug server
+// which is not reachable from the outside.
+func (l *Logger) LevelHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut:
+			var doc levelDoc
+			if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			level, err := ParseLevel(doc.Level)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if old := l.Level(); old != level {
+				l.SetLevel(level)
+				l.Log(Warn, "logger : level changed", "from", old, "to", level)
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(levelDoc{Level: l.Level().String()})
+	})
+}
@@ -0,0 +1,225 @@
+// Package logger writes structured, leveled logs. Every entry is a single
+// line JSON document with the time, level and message of the entry followed
+// by its fields, so log pipelines can parse them without knowing the format
+// of the messages.
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Level is the severity of an entry. Entries below the level of a logger are
+// dropped.
+type Level int32
+
+// Set of levels from the least to the most severe.
+const (
+	Debug Level = iota
+	Info
+	Warn
+	Error
+)
+
+var levels = [...]string{"debug", "info", "warn", "error"}
+
+// String returns the name of the level.
+func (l Level) String() string {
+	if l < Debug || l > Error {
+		return fmt.Sprintf("level(%d)", int32(l))
+	}
+	return levels[l]
+}
+
+// MarshalText implements the encoding.TextMarshaler interface so levels are
+// written by name.
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// ParseLevel returns the level of the name.
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levels {
+		if strings.EqualFold(name, n) {
+			return Level(i), nil
+		}
+	}
+	return 0, errors.Errorf("unknown log level %q", name)
+}
+
+// output is shared by a logger and every logger derived from it with With,
+// so they write whole lines and changing the level applies to all of them.
+type output struct {
+	mu    sync.Mutex
+	w     io.Writer
+	level int32
+}
+
+// Logger writes entries to an output. The fields of a logger are added to
+// every entry it writes.
+type Logger struct {
+	out    *output
+	fields []interface{}
+}
+
+// New constructs a Logger writing entries of at least the level to w.
+func New(w io.Writer, level Level) *Logger {
+	return &Logger{
+		out: &output{w: w, level: int32(level)},
+	}
+}
+
+// With returns a logger adding the fields to every entry. Fields are given
+// as alternating keys and values.
+func (l *Logger) With(fields ...interface{}) *Logger {
+	return &Logger{
+		out:    l.out,
+		fields: append(append([]interface{}(nil), l.fields...), fields...),
+	}
+}
+
+// Level returns the current level of the logger.
+func (l *Logger) Level() Level {
+	return Level(atomic.LoadInt32(&l.out.level))
+}
+
+// SetLevel changes the level of the logger and every logger sharing its
+// output.
+func (l *Logger) SetLevel(level Level) {
+	atomic.StoreInt32(&l.out.level, int32(level))
+}
+
+// Enabled reports if entries of the level are written.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.Level()
+}
+
+// Debug writes an entry for diagnosing problems.
+func (l *Logger) Debug(msg string, fields ...interface{}) {
+	l.Log(Debug, msg, fields...)
+}
+
+// Info writes an entry about the normal operation of the service.
+func (l *Logger) Info(msg string, fields ...interface{}) {
+	l.Log(Info, msg, fields...)
+}
+
+// Warn writes an entry about something unusual the service recovered from.
+func (l *Logger) Warn(msg string, fields ...interface{}) {
+	l.Log(Warn, msg, fields...)
+}
+
+// Error writes an entry about a failure.
+func (l *Logger) Error(msg string, fields ...interface{}) {
+	l.Log(Error, msg, fields...)
+}
+
+// Fatal writes an entry about a failure and exits the program.
+func (l *Logger) Fatal(msg string, fields ...interface{}) {
+	l.Log(Error, msg, fields...)
+	os.Exit(1)
+}
+
+// Log writes an entry of the level if it is enabled.
+func (l *Logger) Log(level Level, msg string, fields ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"time":`)
+	encode(&buf, time.Now().UTC().Format(time.RFC3339Nano))
+	buf.WriteString(`,"level":`)
+	encode(&buf, level.String())
+	buf.WriteString(`,"msg":`)
+	encode(&buf, msg)
+	write(&buf, l.fields)
+	write(&buf, fields)
+	buf.WriteString("}\n")
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w.Write(buf.Bytes())
+}
+
+// Std returns a standard library logger writing each line as an entry of
+// the level, for packages like net/http which only accept those.
+func (l *Logger) Std(level Level) *log.Logger {
+	return log.New(writerFunc(func(p []byte) (int, error) {
+		l.Log(level, strings.TrimSuffix(string(p), "\n"))
+		return len(p), nil
+	}), "", 0)
+}
+
+// Chain returns the messages of an error and of every error it wraps, the
+// outermost first.
+func Chain(err error) []string {
+	var chain []string
+	for err != nil {
+		if msg := err.Error(); len(chain) == 0 || chain[len(chain)-1] != msg {
+			chain = append(chain, msg)
+		}
+		switch e := err.(type) {
+		case interface{ Cause() error }:
+			err = e.Cause()
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			err = nil
+		}
+	}
+	return chain
+}
+
+// write adds the key value pairs to the entry.
+func write(buf *bytes.Buffer, fields []interface{}) {
+	for i := 0; i < len(fields); i += 2 {
+		key, ok := fields[i].(string)
+		if !ok {
+			key = fmt.Sprint(fields[i])
+		}
+		var value interface{} = "!MISSING"
+		if i+1 < len(fields) {
+			value = fields[i+1]
+		}
+
+		buf.WriteByte(',')
+		encode(buf, key)
+		buf.WriteByte(':')
+		encode(buf, value)
+	}
+}
+
+// encode writes the value as JSON. Errors are written as their message and
+// values JSON can not represent as what fmt prints for them.
+func encode(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case error:
+		value = v.Error()
+	case time.Duration:
+		value = v.String()
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprintf("%+v", value))
+	}
+	buf.Write(data)
+}
+
+// writerFunc adapts a function to the io.Writer interface.
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
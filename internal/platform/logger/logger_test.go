@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestLogger(t *testing.T) {
+	t.Log("Given the need to write structured logs.")
+	{
+		var buf bytes.Buffer
+		log := New(&buf, Info).With("service", "users-api")
+
+		log.Debug("dropped")
+		if buf.Len() != 0 {
+			t.Fatalf("\t%s\tShould drop entries below the level : %s.", failed, buf.String())
+		}
+		t.Logf("\t%s\tShould drop entries below the level.", success)
+
+		err := errors.Wrap(errors.New("connection refused"), "querying users")
+		log.With("trace_id", "abc").Error("request failed", "status", 500, "error", err, "level_of", Warn)
+
+		var entry map[string]interface{}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("\t%s\tShould write an entry as JSON : %s : %s.", failed, err, buf.String())
+		}
+		t.Logf("\t%s\tShould write an entry as JSON.", success)
+
+		want := map[string]interface{}{
+			"level":    "error",
+			"msg":      "request failed",
+			"service":  "users-api",
+			"trace_id": "abc",
+			"status":   float64(500),
+			"error":    "querying users: connection refused",
+			"level_of": "warn",
+		}
+		for k, v := range want {
+			if entry[k] != v {
+				t.Fatalf("\t%s\tShould write the field %q : got %v, want %v.", failed, k, entry[k], v)
+			}
+		}
+		t.Logf("\t%s\tShould write the fields of the logger and the entry.", success)
+
+		chain := Chain(err)
+		if len(chain) != 2 || chain[1] != "connection refused" {
+			t.Fatalf("\t%s\tShould unwrap the error chain : %q.", failed, chain)
+		}
+		t.Logf("\t%s\tShould unwrap the error chain.", success)
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	t.Log("Given the need to change the level at runtime.")
+	{
+		var buf bytes.Buffer
+		log := New(&buf, Info)
+		derived := log.With("component", "mid")
+		h := log.LevelHandler()
+
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/debug/log/level", strings.NewReader(`{"level":"debug"}`)))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"debug"`) {
+			t.Fatalf("\t%s\tShould be able to set the level : %d %s.", failed, w.Code, w.Body.String())
+		}
+		if !derived.Enabled(Debug) {
+			t.Fatalf("\t%s\tShould change the level of derived loggers.", failed)
+		}
+		t.Logf("\t%s\tShould be able to set the level.", success)
+
+		w = httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/debug/log/level", strings.NewReader(`{"level":"loud"}`)))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("\t%s\tShould reject unknown levels : %d.", failed, w.Code)
+		}
+		t.Logf("\t%s\tShould reject unknown levels.", success)
+	}
+}
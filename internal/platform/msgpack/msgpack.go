@@ -0,0 +1,400 @@
+// Package msgpack encodes and decodes MessagePack. It works on the values
+// encoding/json decodes into, so Go types are converted through JSON first
+// and keep the names and options of their json tags.
+package msgpack
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// ErrTruncated is returned when the data ends in the middle of a value.
+var ErrTruncated = errors.New("msgpack: unexpected end of data")
+
+// Marshal returns the MessagePack encoding of v, which is encoded like
+// json.Marshal does.
+func Marshal(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return Encode(value)
+}
+
+// Unmarshal decodes the MessagePack data into v like json.Unmarshal does.
+// Objects with fields v does not have are rejected.
+func Unmarshal(data []byte, v interface{}) error {
+	value, err := Decode(data)
+	if err != nil {
+		return err
+	}
+
+	js, err := json.Marshal(value)
+	if err != nil {
+		return errors.Wrap(err, "msgpack: converting to json")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// Encode returns the MessagePack encoding of a value made of nil, bool,
+// numbers, json.Number, string, []byte, []interface{} and
+// map[string]interface{}. Keys of maps are sorted, so the encoding of a
+// value is always the same.
+func Encode(v interface{}) ([]byte, error) {
+	var e encoder
+	if err := e.encode(v); err != nil {
+		return nil, err
+	}
+	return e.buf, nil
+}
+
+// Decode returns the value encoded in data. Maps are decoded as
+// map[string]interface{} with the keys formatted as strings, integers as
+// int64 unless they only fit an uint64 and binary data as []byte.
+func Decode(data []byte) (interface{}, error) {
+	d := decoder{data: data}
+	v, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	if d.pos != len(d.data) {
+		return nil, errors.New("msgpack: data after the value")
+	}
+	return v, nil
+}
+
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) encode(v interface{}) error {
+	switch v := v.(type) {
+	case nil:
+		e.buf = append(e.buf, 0xc0)
+	case bool:
+		if v {
+			e.buf = append(e.buf, 0xc3)
+		} else {
+			e.buf = append(e.buf, 0xc2)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			e.int(i)
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return errors.Wrapf(err, "msgpack: encoding number %s", v)
+		}
+		e.float(f)
+	case int:
+		e.int(int64(v))
+	case int64:
+		e.int(v)
+	case uint64:
+		e.uint(v)
+	case float64:
+		e.float(v)
+	case string:
+		e.length(len(v), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		e.buf = append(e.buf, v...)
+	case []byte:
+		e.length(len(v), 0, -1, 0xc4, 0xc5, 0xc6)
+		e.buf = append(e.buf, v...)
+	case []interface{}:
+		e.length(len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := e.encode(item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		e.length(len(v), 0x80, 15, 0, 0xde, 0xdf)
+		for _, k := range keys {
+			if err := e.encode(k); err != nil {
+				return err
+			}
+			if err := e.encode(v[k]); err != nil {
+				return err
+			}
+		}
+	default:
+		return errors.Errorf("msgpack: cannot encode %T", v)
+	}
+	return nil
+}
+
+// length writes the header of a string, binary, array or map of n items.
+// The fixed form holds up to max items, formats of 0 are not defined for
+// the kind.
+func (e *encoder) length(n int, fixed byte, max int, f8, f16, f32 byte) {
+	switch {
+	case n <= max:
+		e.buf = append(e.buf, fixed|byte(n))
+	case n <= math.MaxUint8 && f8 != 0:
+		e.buf = append(e.buf, f8, byte(n))
+	case n <= math.MaxUint16:
+		e.buf = append(e.buf, f16, 0, 0)
+		binary.BigEndian.PutUint16(e.buf[len(e.buf)-2:], uint16(n))
+	default:
+		e.buf = append(e.buf, f32, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], uint32(n))
+	}
+}
+
+// int writes an integer in the smallest format holding it.
+func (e *encoder) int(i int64) {
+	switch {
+	case i >= 0:
+		e.uint(uint64(i))
+	case i >= -32:
+		e.buf = append(e.buf, byte(i))
+	case i >= math.MinInt8:
+		e.buf = append(e.buf, 0xd0, byte(i))
+	case i >= math.MinInt16:
+		e.buf = append(e.buf, 0xd1, 0, 0)
+		binary.BigEndian.PutUint16(e.buf[len(e.buf)-2:], uint16(i))
+	case i >= math.MinInt32:
+		e.buf = append(e.buf, 0xd2, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], uint32(i))
+	default:
+		e.buf = append(e.buf, 0xd3, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], uint64(i))
+	}
+}
+
+// uint writes an unsigned integer in the smallest format holding it.
+func (e *encoder) uint(u uint64) {
+	switch {
+	case u <= 0x7f:
+		e.buf = append(e.buf, byte(u))
+	case u <= math.MaxUint8:
+		e.buf = append(e.buf, 0xcc, byte(u))
+	case u <= math.MaxUint16:
+		e.buf = append(e.buf, 0xcd, 0, 0)
+		binary.BigEndian.PutUint16(e.buf[len(e.buf)-2:], uint16(u))
+	case u <= math.MaxUint32:
+		e.buf = append(e.buf, 0xce, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(e.buf[len(e.buf)-4:], uint32(u))
+	default:
+		e.buf = append(e.buf, 0xcf, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], u)
+	}
+}
+
+func (e *encoder) float(f float64) {
+	e.buf = append(e.buf, 0xcb, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(e.buf[len(e.buf)-8:], math.Float64bits(f))
+}
+
+// maxDepth limits the nesting of arrays and maps, so hostile data cannot
+// exhaust the stack.
+const maxDepth = 100
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) decode(depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errors.New("msgpack: nested too deeply")
+	}
+
+	b, err := d.byte()
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xe0 == 0xa0:
+		return d.str(int(b & 0x1f))
+	case b&0xf0 == 0x90:
+		return d.array(int(b&0x0f), depth)
+	case b&0xf0 == 0x80:
+		return d.mapping(int(b&0x0f), depth)
+	}
+
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.size(b - 0xc4)
+		if err != nil {
+			return nil, err
+		}
+		p, err := d.bytes(n)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte(nil), p...), nil
+	case 0xca:
+		p, err := d.bytes(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(p))), nil
+	case 0xcb:
+		p, err := d.bytes(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(p)), nil
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		p, err := d.bytes(1 << (b - 0xcc))
+		if err != nil {
+			return nil, err
+		}
+		u := uint64From(p)
+		if u > math.MaxInt64 {
+			return u, nil
+		}
+		return int64(u), nil
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		p, err := d.bytes(1 << (b - 0xd0))
+		if err != nil {
+			return nil, err
+		}
+		u := uint64From(p)
+		shift := 64 - 8*uint(len(p))
+		return int64(u<<shift) >> shift, nil
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.size(b - 0xd9)
+		if err != nil {
+			return nil, err
+		}
+		return d.str(n)
+	case 0xdc, 0xdd:
+		n, err := d.size(b - 0xdc + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.array(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.size(b - 0xde + 1)
+		if err != nil {
+			return nil, err
+		}
+		return d.mapping(n, depth)
+	}
+
+	return nil, errors.Errorf("msgpack: unsupported format 0x%02x", b)
+}
+
+func (d *decoder) byte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, ErrTruncated
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *decoder) bytes(n int) ([]byte, error) {
+	if n < 0 || n > len(d.data)-d.pos {
+		return nil, ErrTruncated
+	}
+	p := d.data[d.pos : d.pos+n]
+	d.pos += n
+	return p, nil
+}
+
+// size reads a length of 1, 2 or 4 bytes for the exponent 0, 1 or 2.
+func (d *decoder) size(exp byte) (int, error) {
+	p, err := d.bytes(1 << exp)
+	if err != nil {
+		return 0, err
+	}
+	n := uint64From(p)
+	if n > uint64(len(d.data)) {
+		return 0, ErrTruncated
+	}
+	return int(n), nil
+}
+
+func (d *decoder) str(n int) (interface{}, error) {
+	p, err := d.bytes(n)
+	if err != nil {
+		return nil, err
+	}
+	return string(p), nil
+}
+
+func (d *decoder) array(n, depth int) (interface{}, error) {
+	// Every item takes a byte at least, which bounds the allocation.
+	if n > len(d.data)-d.pos {
+		return nil, ErrTruncated
+	}
+	items := make([]interface{}, n)
+	for i := range items {
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		items[i] = v
+	}
+	return items, nil
+}
+
+func (d *decoder) mapping(n, depth int) (interface{}, error) {
+	if 2*n > len(d.data)-d.pos {
+		return nil, ErrTruncated
+	}
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		v, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			key = fmt.Sprint(k)
+		}
+		m[key] = v
+	}
+	return m, nil
+}
+
+// uint64From reads a big endian unsigned integer of 1 to 8 bytes.
+func uint64From(p []byte) uint64 {
+	var u uint64
+	for _, b := range p {
+		u = u<<8 | uint64(b)
+	}
+	return u
+}
@@ -0,0 +1,99 @@
+package msgpack
+
+import (
+	"bytes"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		hex   string
+	}{
+		{nil, "c0"},
+		{true, "c3"},
+		{int64(5), "05"},
+		{int64(-3), "fd"},
+		{int64(200), "ccc8"},
+		{int64(-200), "d1ff38"},
+		{int64(70000), "ce00011170"},
+		{1.5, "cb3ff8000000000000"},
+		{"abc", "a3616263"},
+		{strings.Repeat("a", 40), "d928" + strings.Repeat("61", 40)},
+		{[]interface{}{int64(1), "a"}, "9201a161"},
+		{map[string]interface{}{"b": int64(2), "a": int64(1)}, "82a16101a16202"},
+	}
+
+	t.Log("Given the need to encode values as MessagePack.")
+	{
+		for _, tt := range tests {
+			data, err := Encode(tt.value)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to encode %v : %s.", failed, tt.value, err)
+			}
+			if got := hex.EncodeToString(data); got != tt.hex {
+				t.Fatalf("\t%s\tShould encode %v as %s : got %s.", failed, tt.value, tt.hex, got)
+			}
+
+			value, err := Decode(data)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to decode %s : %s.", failed, tt.hex, err)
+			}
+			if !reflect.DeepEqual(value, tt.value) {
+				t.Fatalf("\t%s\tShould decode %s as %v : got %#v.", failed, tt.hex, tt.value, value)
+			}
+		}
+		t.Logf("\t%s\tShould use the smallest formats and decode them back.", success)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	type user struct {
+		ID     string         `json:"user_id"`
+		Admin  bool           `json:"admin,omitempty"`
+		Logins int            `json:"logins"`
+		Thumbs map[int]string `json:"thumbnails"`
+		Photo  []byte         `json:"photo"`
+	}
+	in := user{ID: "42", Logins: 300, Thumbs: map[int]string{64: "a.png"}, Photo: []byte{1, 2}}
+
+	t.Log("Given the need to encode Go values like JSON does.")
+	{
+		data, err := Marshal(in)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to marshal : %s.", failed, err)
+		}
+		if bytes.Contains(data, []byte("admin")) {
+			t.Fatalf("\t%s\tShould respect the options of json tags.", failed)
+		}
+
+		var out user
+		if err := Unmarshal(data, &out); err != nil {
+			t.Fatalf("\t%s\tShould be able to unmarshal : %s.", failed, err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Fatalf("\t%s\tShould get the value back : got %+v.", failed, out)
+		}
+		t.Logf("\t%s\tShould get the value back.", success)
+
+		unknown, _ := Encode(map[string]interface{}{"user_id": "42", "role": "admin"})
+		if err := Unmarshal(unknown, &out); err == nil {
+			t.Fatalf("\t%s\tShould reject unknown fields.", failed)
+		}
+		if err := Unmarshal(data[:len(data)-1], &out); err != ErrTruncated {
+			t.Fatalf("\t%s\tShould reject truncated data : got %v.", failed, err)
+		}
+		if _, err := Decode([]byte{0xdd, 0xff, 0xff, 0xff, 0xff}); err != ErrTruncated {
+			t.Fatalf("\t%s\tShould reject lengths past the data : got %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould reject unknown fields and broken data.", success)
+	}
+}
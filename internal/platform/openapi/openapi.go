@@ -0,0 +1,310 @@
+// Package openapi describes HTTP APIs with OpenAPI 3 documents. Schemas are
+// generated from the Go types of requests and responses, including the
+// constraints of their validate tags, so the document follows the code
+// instead of being written by hand.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// Version is the version of the OpenAPI specification documents follow.
+const Version = "3.0.3"
+
+// Document is the root of an OpenAPI document.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+
+	schemas *Schemas
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem holds the operations of a path by their lower case method.
+type PathItem map[string]*Operation
+
+// Operation describes what a route does.
+type Operation struct {
+	OperationID string                `json:"operationId,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+}
+
+// Parameter describes a path, query or header parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody describes the body of a request by its media types.
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response describes a response by its media types. Responses without a
+// body have no content.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the schemas referenced by operations and the ways to
+// authenticate.
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes a way to authenticate.
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Name         string `json:"name,omitempty"`
+	In           string `json:"in,omitempty"`
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// Endpoint describes a route by the Go values of its request and response.
+// Values are turned into schemas, a *Schema is used as it is.
+type Endpoint struct {
+	Summary     string
+	Description string
+	Tags        []string
+
+	// Security names the security schemes any of which authenticates the
+	// request. Endpoints without one are public.
+	Security []string
+
+	Query []Parameter
+
+	// Request is described as a patch of its object when RequestType is
+	// the type of JSON Merge Patch documents.
+	Request      interface{}
+	RequestType  string
+	Status       int
+	Response     interface{}
+	ResponseType string
+
+	// Errors are the statuses of expected errors besides the ones every
+	// endpoint of its kind has. Errors are problem details unless ErrorBody
+	// describes them.
+	Errors    []int
+	ErrorBody interface{}
+
+	Deprecated bool
+}
+
+// New constructs an empty document of the API. Schemas of the endpoints are
+// generated with schemas.
+func New(info Info, schemas *Schemas) *Document {
+	return &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   make(map[string]PathItem),
+		Components: Components{
+			Schemas:         schemas.components,
+			SecuritySchemes: make(map[string]*SecurityScheme),
+		},
+		schemas: schemas,
+	}
+}
+
+// AddSecurityScheme adds a way to authenticate endpoints can name.
+func (d *Document) AddSecurityScheme(name string, s SecurityScheme) {
+	d.Components.SecuritySchemes[name] = &s
+}
+
+// Add describes the route of the method and path, which is in the form
+// routes are registered with like /v1/users/:user_id.
+func (d *Document) Add(method, path string, e Endpoint) {
+	op := Operation{
+		OperationID: operationID(method, path),
+		Summary:     e.Summary,
+		Description: e.Description,
+		Tags:        e.Tags,
+		Responses:   make(map[string]*Response),
+		Deprecated:  e.Deprecated,
+	}
+
+	for _, name := range pathParams(path) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name:     name,
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+	op.Parameters = append(op.Parameters, e.Query...)
+
+	if e.Request != nil {
+		schema := d.schemas.Schema(e.Request)
+		if e.RequestType == web.ContentTypeMergePatch {
+			schema = d.schemas.Patch(e.Request)
+		}
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{contentType(e.RequestType): {Schema: schema}},
+		}
+	}
+
+	for _, name := range e.Security {
+		op.Security = append(op.Security, map[string][]string{name: {}})
+	}
+
+	status := e.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	resp := Response{Description: http.StatusText(status)}
+	if e.Response != nil {
+		resp.Content = map[string]MediaType{contentType(e.ResponseType): {Schema: d.schemas.Schema(e.Response)}}
+	}
+	op.Responses[strconv.Itoa(status)] = &resp
+
+	errs := append([]int{http.StatusInternalServerError}, e.Errors...)
+	if e.Request != nil || len(op.Parameters) > 0 {
+		errs = append(errs, http.StatusBadRequest)
+	}
+	if len(e.Security) > 0 {
+		errs = append(errs, http.StatusUnauthorized)
+	}
+	errType, errBody := web.ContentTypeProblem, interface{}(web.Problem{})
+	if e.ErrorBody != nil {
+		errType, errBody = "application/json", e.ErrorBody
+	}
+	for _, status := range errs {
+		op.Responses[strconv.Itoa(status)] = &Response{
+			Description: http.StatusText(status),
+			Content:     map[string]MediaType{errType: {Schema: d.schemas.Schema(errBody)}},
+		}
+	}
+
+	key := Path(path)
+	item, ok := d.Paths[key]
+	if !ok {
+		item = make(PathItem)
+		d.Paths[key] = item
+	}
+	item[strings.ToLower(method)] = &op
+}
+
+// Operation returns the operation of the route of the method and path,
+// which is in the form routes are registered with.
+func (d *Document) Operation(method, path string) (*Operation, bool) {
+	item, ok := d.Paths[Path(path)]
+	if !ok {
+		return nil, false
+	}
+	op, ok := item[strings.ToLower(method)]
+	return op, ok
+}
+
+// Check compares the document to the routes of an app. It fails when a
+// route is not described or an operation has no route, listing all of them.
+func (d *Document) Check(routes []web.Route) error {
+	seen := make(map[string]bool)
+	var missing []string
+	for _, r := range routes {
+		seen[strings.ToLower(r.Method)+" "+Path(r.Path)] = true
+		if _, ok := d.Operation(r.Method, r.Path); !ok {
+			missing = append(missing, r.Method+" "+r.Path)
+		}
+	}
+
+	var stale []string
+	for path, item := range d.Paths {
+		for method := range item {
+			if !seen[method+" "+path] {
+				stale = append(stale, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+	sort.Strings(stale)
+
+	var msgs []string
+	if len(missing) > 0 {
+		msgs = append(msgs, fmt.Sprintf("routes not described: %s", strings.Join(missing, ", ")))
+	}
+	if len(stale) > 0 {
+		msgs = append(msgs, fmt.Sprintf("operations without a route: %s", strings.Join(stale, ", ")))
+	}
+	if len(msgs) > 0 {
+		return errors.New(strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+// Path turns a route path like /v1/users/:user_id into the form of OpenAPI
+// like /v1/users/{user_id}.
+func Path(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// pathParams returns the names of the parameters of a route path.
+func pathParams(path string) []string {
+	var names []string
+	for _, s := range strings.Split(path, "/") {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			names = append(names, s[1:])
+		}
+	}
+	return names
+}
+
+// operationID derives a stable id for the operation from its route.
+func operationID(method, path string) string {
+	id := strings.ToLower(method)
+	for _, s := range strings.Split(path, "/") {
+		s = strings.TrimLeft(s, ":*.")
+		for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == '_' || r == '-' }) {
+			id += strings.ToUpper(part[:1]) + part[1:]
+		}
+	}
+	return id
+}
+
+// contentType returns the media type, JSON unless another one is set.
+func contentType(ct string) string {
+	if ct == "" {
+		return "application/json"
+	}
+	return ct
+}
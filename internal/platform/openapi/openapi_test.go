@@ -0,0 +1,173 @@
+package openapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+type widget struct {
+	Name   string   `json:"name" validate:"required,max=10"`
+	Count  int      `json:"count" validate:"omitempty,min=1,max=3"`
+	Color  string   `json:"color" validate:"omitempty,oneof=red blue"`
+	Tags   []string `json:"tags" validate:"max=2,dive,min=2"`
+	Parent *widget  `json:"parent,omitempty"`
+	Secret string   `json:"-"`
+}
+
+func TestSchema(t *testing.T) {
+	t.Log("Given the need to describe Go types with schemas.")
+	{
+		schemas := NewSchemas()
+		ref := schemas.Schema(widget{})
+		if ref.Ref != "#/components/schemas/widget" {
+			t.Fatalf("\t%s\tShould reference named structs : got %+v.", failed, ref)
+		}
+
+		s := schemas.Resolve(ref)
+		if len(s.Required) != 1 || s.Required[0] != "name" {
+			t.Fatalf("\t%s\tShould require the required fields : got %v.", failed, s.Required)
+		}
+		if _, ok := s.Properties["Secret"]; ok {
+			t.Fatalf("\t%s\tShould skip fields hidden from JSON.", failed)
+		}
+		if p := s.Properties["name"]; p.Type != "string" || p.MaxLength == nil || *p.MaxLength != 10 {
+			t.Fatalf("\t%s\tShould limit the length of strings : got %+v.", failed, p)
+		}
+		if p := s.Properties["count"]; p.Type != "integer" || *p.Minimum != 1 || *p.Maximum != 3 {
+			t.Fatalf("\t%s\tShould limit the value of numbers : got %+v.", failed, p)
+		}
+		if p := s.Properties["color"]; len(p.Enum) != 2 {
+			t.Fatalf("\t%s\tShould list the allowed values : got %+v.", failed, p)
+		}
+		if p := s.Properties["tags"]; *p.MaxItems != 2 || *p.Items.MinLength != 2 {
+			t.Fatalf("\t%s\tShould apply tags after dive to the items : got %+v.", failed, p)
+		}
+		if p := s.Properties["parent"]; p.Ref != ref.Ref {
+			t.Fatalf("\t%s\tShould reference recursive types : got %+v.", failed, p)
+		}
+		t.Logf("\t%s\tShould describe the fields with their constraints.", success)
+
+		patch := schemas.Patch(widget{})
+		if len(patch.Required) != 0 || !patch.Properties["name"].Nullable || *patch.Properties["name"].MaxLength != 10 {
+			t.Fatalf("\t%s\tShould make every field of patches optional : got %+v.", failed, patch)
+		}
+		if s.Properties["name"].Nullable {
+			t.Fatalf("\t%s\tShould leave the schema of the object alone.", failed)
+		}
+		t.Logf("\t%s\tShould describe merge patches of objects.", success)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	doc := New(Info{Title: "widgets", Version: "1"}, NewSchemas())
+	doc.Add(http.MethodGet, "/v1/widgets/:id", Endpoint{Response: widget{}})
+	doc.Add(http.MethodDelete, "/v1/widgets/:id", Endpoint{})
+
+	t.Log("Given the need to keep the document in line with the routes.")
+	{
+		routes := []web.Route{
+			{Method: http.MethodGet, Path: "/v1/widgets/:id"},
+			{Method: http.MethodPost, Path: "/v1/widgets"},
+		}
+		err := doc.Check(routes)
+		if err == nil {
+			t.Fatalf("\t%s\tShould fail for routes out of line.", failed)
+		}
+		for _, want := range []string{"POST /v1/widgets", "DELETE /v1/widgets/{id}"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Fatalf("\t%s\tShould name %s : got %q.", failed, want, err)
+			}
+		}
+		t.Logf("\t%s\tShould name the routes out of line.", success)
+
+		routes = append(routes[:1], web.Route{Method: http.MethodDelete, Path: "/v1/widgets/:id"})
+		if err := doc.Check(routes); err != nil {
+			t.Fatalf("\t%s\tShould accept described routes : %s.", failed, err)
+		}
+		t.Logf("\t%s\tShould accept described routes.", success)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	doc := New(Info{Title: "widgets", Version: "1"}, NewSchemas())
+	doc.Add(http.MethodPost, "/v1/widgets", Endpoint{
+		Query:   []Parameter{{Name: "dry_run", In: "query", Schema: &Schema{Type: "boolean"}}},
+		Request: widget{},
+	})
+
+	// Respond with the errors like mid.Errors does.
+	errs := func(after web.Handler) web.Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			if err := after(ctx, w, r, params); err != nil {
+				return web.ResponseError(ctx, w, err)
+			}
+			return nil
+		}
+	}
+
+	app := web.NewApp(make(chan os.Signal, 1), logger.New(os.Stdout, logger.Error), errs, Validate(doc))
+	app.Handle(http.MethodPost, "/v1/widgets", func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+		var wd widget
+		if err := json.NewDecoder(r.Body).Decode(&wd); err != nil {
+			return err
+		}
+		return web.Respond(ctx, w, wd, http.StatusOK)
+	})
+
+	tests := []struct {
+		query  string
+		body   string
+		status int
+		fields map[string]string
+	}{
+		{"", `{"name":"gear","tags":["ab"]}`, http.StatusOK, nil},
+		{"", `{"count":"2"}`, http.StatusBadRequest, map[string]string{"name": ReasonRequired, "count": ReasonWrongType}},
+		{"", `{"name":"gear","count":4,"color":"green"}`, http.StatusBadRequest, map[string]string{"count": ReasonTooLarge, "color": ReasonNotAllowed}},
+		{"", `{"name":"gear","tags":["a"],"size":1}`, http.StatusBadRequest, map[string]string{"tags[0]": ReasonTooShort, "size": ReasonUnknownField}},
+		{"", `{"name":"gear","parent":{"name":"a very long name"}}`, http.StatusBadRequest, map[string]string{"parent.name": ReasonTooLong}},
+		{"?dry_run=maybe", `{"name":"gear"}`, http.StatusBadRequest, map[string]string{"dry_run": ReasonWrongType}},
+	}
+
+	t.Log("Given the need to reject requests not matching the document.")
+	{
+		for i, tt := range tests {
+			r := httptest.NewRequest(http.MethodPost, "/v1/widgets"+tt.query, strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("\t%s\tShould respond to request %d with %d : got %d %s.", failed, i, tt.status, w.Code, w.Body)
+			}
+			if tt.status == http.StatusOK {
+				continue
+			}
+
+			var p web.Problem
+			if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+				t.Fatalf("\t%s\tShould be able to decode the problem : %s.", failed, err)
+			}
+			if len(p.Fields) != len(tt.fields) {
+				t.Fatalf("\t%s\tShould report %d fields of request %d : got %+v.", failed, len(tt.fields), i, p.Fields)
+			}
+			for _, f := range p.Fields {
+				if tt.fields[f.Field] != f.Error || f.Message == "" {
+					t.Fatalf("\t%s\tShould report %s of request %d : got %+v.", failed, f.Field, i, p.Fields)
+				}
+			}
+		}
+		t.Logf("\t%s\tShould pass valid requests and report the invalid fields.", success)
+	}
+}
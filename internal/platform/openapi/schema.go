@@ -0,0 +1,285 @@
+package openapi
+
+import (
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema describes a JSON value. Only the parts of JSON Schema the API uses
+// are supported.
+type Schema struct {
+	Ref         string             `json:"$ref,omitempty"`
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Nullable    bool               `json:"nullable,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	MinLength   *int               `json:"minLength,omitempty"`
+	MaxLength   *int               `json:"maxLength,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+	MinItems    *int               `json:"minItems,omitempty"`
+	MaxItems    *int               `json:"maxItems,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+
+	AdditionalProperties *Schema `json:"additionalProperties,omitempty"`
+}
+
+// refPrefix prefixes the names of schemas in references.
+const refPrefix = "#/components/schemas/"
+
+// TagFunc applies a validate tag with its parameter to a schema.
+type TagFunc func(s *Schema, param string)
+
+// Schemas generates the schemas of Go types. Named structs are added to the
+// components once and referenced everywhere else.
+type Schemas struct {
+	components map[string]*Schema
+	types      map[string]reflect.Type
+	tags       map[string]TagFunc
+}
+
+// NewSchemas constructs a generator knowing the validate tags of the
+// validator package.
+func NewSchemas() *Schemas {
+	s := Schemas{
+		components: make(map[string]*Schema),
+		types:      make(map[string]reflect.Type),
+		tags: map[string]TagFunc{
+			"email": format("email"),
+			"url":   format("uri"),
+			"uri":   format("uri"),
+			"uuid":  format("uuid"),
+			"oneof": func(s *Schema, param string) { s.Enum = strings.Fields(param) },
+		},
+	}
+	return &s
+}
+
+// Tag makes fields validated with the tag change their schemas with fn. It
+// is used for validations registered with web.RegisterValidation.
+func (s *Schemas) Tag(tag string, fn TagFunc) {
+	s.tags[tag] = fn
+}
+
+// Schema returns the schema of the type of v. A *Schema is returned as it
+// is.
+func (s *Schemas) Schema(v interface{}) *Schema {
+	if schema, ok := v.(*Schema); ok {
+		return schema
+	}
+	return s.schema(reflect.TypeOf(v))
+}
+
+// Patch returns the schema of JSON Merge Patch documents for the object
+// type of v. Every member is optional and may be null to remove it.
+func (s *Schemas) Patch(v interface{}) *Schema {
+	obj := s.Resolve(s.Schema(v))
+	if obj == nil || obj.Type != "object" {
+		return obj
+	}
+
+	patch := Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for name, p := range obj.Properties {
+		p := *p
+		p.Nullable = true
+		patch.Properties[name] = &p
+	}
+	return &patch
+}
+
+// Resolve returns the schema a reference points to. Other schemas are
+// returned as they are.
+func (s *Schemas) Resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = s.components[strings.TrimPrefix(schema.Ref, refPrefix)]
+	}
+	return schema
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func (s *Schemas) schema(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		schema := s.schema(t.Elem())
+		if schema.Ref != "" {
+			return schema
+		}
+		schema.Nullable = true
+		return schema
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name := s.name(t)
+		if _, ok := s.components[name]; !ok {
+			// Reserve the name first so recursive types terminate.
+			s.components[name] = &Schema{}
+			s.types[name] = t
+			*s.components[name] = *s.object(t)
+		}
+		return &Schema{Ref: refPrefix + name}
+	}
+	return &Schema{}
+}
+
+// name returns the name of the component of a named struct. Structs named
+// like one of another package which is already a component are prefixed
+// with their package.
+func (s *Schemas) name(t reflect.Type) string {
+	name := t.Name()
+	if other, ok := s.types[name]; ok && other != t {
+		name = path.Base(t.PkgPath()) + "." + name
+	}
+	return name
+}
+
+// object returns the schema of a struct from the json and validate tags of
+// its fields. Embedded structs add their fields.
+func (s *Schemas) object(t reflect.Type) *Schema {
+	schema := Schema{Type: "object", Properties: make(map[string]*Schema)}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		tag := f.Tag.Get("json")
+		name := strings.SplitN(tag, ",", 2)[0]
+		if name == "-" {
+			continue
+		}
+
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			embedded := s.object(f.Type)
+			for n, p := range embedded.Properties {
+				schema.Properties[n] = p
+			}
+			schema.Required = append(schema.Required, embedded.Required...)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := s.schema(f.Type)
+		if s.validate(prop, f.Type, f.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = prop
+	}
+
+	return &schema
+}
+
+// validate applies the validate tags of a field to its schema and reports
+// if the field is required. Tags after dive apply to the items.
+func (s *Schemas) validate(schema *Schema, t reflect.Type, tags string) bool {
+	if tags == "" {
+		return false
+	}
+
+	var required bool
+	parts := strings.SplitN(tags, ",dive", 2)
+	for _, tag := range strings.Split(parts[0], ",") {
+		name, param := tag, ""
+		if i := strings.Index(tag, "="); i >= 0 {
+			name, param = tag[:i], tag[i+1:]
+		}
+
+		switch name {
+		case "required":
+			required = true
+		case "min", "max", "len":
+			limit(schema, name, param)
+		default:
+			if fn, ok := s.tags[name]; ok && schema.Ref == "" {
+				fn(schema, param)
+			}
+		}
+	}
+
+	if len(parts) == 2 && schema.Items != nil {
+		s.validate(schema.Items, t.Elem(), strings.TrimPrefix(parts[1], ","))
+	}
+
+	return required
+}
+
+// limit applies a min, max or len tag to the length of strings, the number
+// of items of collections or the value of numbers.
+func limit(schema *Schema, name, param string) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	i := int(n)
+
+	switch schema.Type {
+	case "string":
+		switch name {
+		case "min":
+			schema.MinLength = &i
+		case "max":
+			schema.MaxLength = &i
+		default:
+			schema.MinLength, schema.MaxLength = &i, &i
+		}
+	case "array":
+		switch name {
+		case "min":
+			schema.MinItems = &i
+		case "max":
+			schema.MaxItems = &i
+		default:
+			schema.MinItems, schema.MaxItems = &i, &i
+		}
+	case "integer", "number":
+		switch name {
+		case "min":
+			schema.Minimum = &n
+		case "max":
+			schema.Maximum = &n
+		default:
+			schema.Minimum, schema.Maximum = &n, &n
+		}
+	}
+}
+
+// format returns the function setting the format of a schema.
+func format(f string) TagFunc {
+	return func(s *Schema, param string) {
+		s.Format = f
+	}
+}
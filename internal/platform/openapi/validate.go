@@ -0,0 +1,331 @@
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// Reasons a value does not match its schema, reported as the errors of
+// fields.
+const (
+	ReasonRequired     = "required"
+	ReasonWrongType    = "wrong_type"
+	ReasonTooShort     = "too_short"
+	ReasonTooLong      = "too_long"
+	ReasonTooSmall     = "too_small"
+	ReasonTooLarge     = "too_large"
+	ReasonTooFew       = "too_few"
+	ReasonTooMany      = "too_many"
+	ReasonNotAllowed   = "not_allowed"
+	ReasonFormat       = "invalid_format"
+	ReasonUnknownField = "unknown_field"
+)
+
+func init() {
+	web.RegisterMessages("en", map[string]string{
+		"reason." + ReasonRequired:     "{field} is a required field",
+		"reason." + ReasonWrongType:    "{field} has the wrong type",
+		"reason." + ReasonTooShort:     "{field} is too short",
+		"reason." + ReasonTooLong:      "{field} is too long",
+		"reason." + ReasonTooSmall:     "{field} is too small",
+		"reason." + ReasonTooLarge:     "{field} is too large",
+		"reason." + ReasonTooFew:       "{field} has too few items",
+		"reason." + ReasonTooMany:      "{field} has too many items",
+		"reason." + ReasonNotAllowed:   "{field} is not one of the allowed values",
+		"reason." + ReasonFormat:       "{field} is not in the expected format",
+		"reason." + ReasonUnknownField: "{field} is not a known field",
+	})
+	web.RegisterMessages("ru", map[string]string{
+		"reason." + ReasonRequired:     "{field} обязательное поле",
+		"reason." + ReasonWrongType:    "{field} имеет неверный тип",
+		"reason." + ReasonTooShort:     "{field} слишком короткое",
+		"reason." + ReasonTooLong:      "{field} слишком длинное",
+		"reason." + ReasonTooSmall:     "{field} слишком маленькое",
+		"reason." + ReasonTooLarge:     "{field} слишком большое",
+		"reason." + ReasonTooFew:       "{field} содержит слишком мало элементов",
+		"reason." + ReasonTooMany:      "{field} содержит слишком много элементов",
+		"reason." + ReasonNotAllowed:   "{field} не входит в список допустимых значений",
+		"reason." + ReasonFormat:       "{field} имеет неверный формат",
+		"reason." + ReasonUnknownField: "{field} неизвестное поле",
+	})
+}
+
+// Validate returns middleware rejecting requests which do not match the
+// operation of their route before they reach the handler. Parameters and
+// JSON bodies are checked, bodies of other media types are left to the
+// handler. Requests of routes the document does not
+// describe are passed on.
+func Validate(doc *Document) web.Middleware {
+
+	// This is the actual middleware function to be executed.
+	f := func(after web.Handler) web.Handler {
+
+		h := func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			v, ok := ctx.Value(web.KeyValues).(*web.Values)
+			if !ok {
+				return web.NewShutdownError("web value missing from context")
+			}
+
+			route := strings.SplitN(v.Route, " ", 2)
+			if len(route) != 2 {
+				return after(ctx, w, r, params)
+			}
+			op, ok := doc.Operation(route[0], route[1])
+			if !ok {
+				return after(ctx, w, r, params)
+			}
+
+			fields, err := doc.validateRequest(op, r, params)
+			if err != nil {
+				return err
+			}
+			if len(fields) > 0 {
+				return &web.Error{
+					Err:    errors.New("request does not match the specification"),
+					Status: http.StatusBadRequest,
+					Fields: fields,
+				}
+			}
+
+			return after(ctx, w, r, params)
+		}
+
+		return h
+	}
+
+	return f
+}
+
+// validateRequest checks the parameters and the JSON body of a request. The
+// body is left for the handler to read again.
+func (d *Document) validateRequest(op *Operation, r *http.Request, params map[string]string) ([]web.FieldError, error) {
+	var fields []web.FieldError
+
+	query := r.URL.Query()
+	for _, p := range op.Parameters {
+		var value string
+		switch p.In {
+		case "path":
+			value = params[p.Name]
+		case "query":
+			value = query.Get(p.Name)
+		case "header":
+			value = r.Header.Get(p.Name)
+		}
+		if value == "" {
+			if p.Required {
+				fields = append(fields, web.FieldError{Field: p.Name, Error: ReasonRequired})
+			}
+			continue
+		}
+		fields = append(fields, d.validateParam(p.Name, value, p.Schema)...)
+	}
+
+	body := op.RequestBody
+	if body == nil {
+		return fields, nil
+	}
+	mt, ok := body.Content[web.ContentTypeJSON]
+	if !ok {
+		return fields, nil
+	}
+
+	// Bodies of the other codecs are checked by the handler when decoding.
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if t, _, err := mime.ParseMediaType(ct); err != nil || t != web.ContentTypeJSON {
+			return fields, nil
+		}
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, web.NewRequestError(errors.Wrap(err, "reading body"), http.StatusBadRequest)
+	}
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(data))
+
+	var value interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&value); err != nil {
+		return nil, web.NewCodedError(err, http.StatusBadRequest, web.CodeMalformed)
+	}
+
+	return append(fields, d.validate("", value, mt.Schema)...), nil
+}
+
+// validateParam checks a parameter, which is always given as a string, by
+// parsing it as the type of its schema first.
+func (d *Document) validateParam(name, value string, schema *Schema) []web.FieldError {
+	schema = d.schemas.Resolve(schema)
+	if schema == nil {
+		return nil
+	}
+
+	var v interface{} = value
+	switch schema.Type {
+	case "integer", "number":
+		v = json.Number(value)
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return []web.FieldError{{Field: name, Error: ReasonWrongType}}
+		}
+		v = b
+	}
+
+	return d.validate(name, v, schema)
+}
+
+// validate checks a decoded JSON value against the schema, naming fields by
+// their path like scopes[0].
+func (d *Document) validate(field string, value interface{}, schema *Schema) []web.FieldError {
+	schema = d.schemas.Resolve(schema)
+	if schema == nil || value == nil {
+		return nil
+	}
+
+	wrong := []web.FieldError{{Field: name(field), Error: ReasonWrongType}}
+
+	switch schema.Type {
+	case "object":
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return wrong
+		}
+		var fields []web.FieldError
+		for _, req := range schema.Required {
+			if _, ok := m[req]; !ok {
+				fields = append(fields, web.FieldError{Field: join(field, req), Error: ReasonRequired})
+			}
+		}
+		for k, v := range m {
+			switch p, ok := schema.Properties[k]; {
+			case ok:
+				fields = append(fields, d.validate(join(field, k), v, p)...)
+			case schema.AdditionalProperties != nil:
+				fields = append(fields, d.validate(join(field, k), v, schema.AdditionalProperties)...)
+			case schema.Properties != nil:
+				fields = append(fields, web.FieldError{Field: join(field, k), Error: ReasonUnknownField})
+			}
+		}
+		return fields
+
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return wrong
+		}
+		if schema.MinItems != nil && len(items) < *schema.MinItems {
+			return []web.FieldError{{Field: name(field), Error: ReasonTooFew}}
+		}
+		if schema.MaxItems != nil && len(items) > *schema.MaxItems {
+			return []web.FieldError{{Field: name(field), Error: ReasonTooMany}}
+		}
+		var fields []web.FieldError
+		for i, v := range items {
+			fields = append(fields, d.validate(field+"["+strconv.Itoa(i)+"]", v, schema.Items)...)
+		}
+		return fields
+
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return wrong
+		}
+		return checkString(name(field), s, schema)
+
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			return wrong
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return wrong
+		}
+		if _, err := n.Int64(); schema.Type == "integer" && err != nil {
+			return wrong
+		}
+		if schema.Minimum != nil && f < *schema.Minimum {
+			return []web.FieldError{{Field: name(field), Error: ReasonTooSmall}}
+		}
+		if schema.Maximum != nil && f > *schema.Maximum {
+			return []web.FieldError{{Field: name(field), Error: ReasonTooLarge}}
+		}
+
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return wrong
+		}
+	}
+
+	return nil
+}
+
+// checkString checks the length, allowed values and format of a string.
+// Lengths are counted in characters like the validator does.
+func checkString(field, s string, schema *Schema) []web.FieldError {
+	n := utf8.RuneCountInString(s)
+	switch {
+	case schema.MinLength != nil && n < *schema.MinLength:
+		return []web.FieldError{{Field: field, Error: ReasonTooShort}}
+	case schema.MaxLength != nil && n > *schema.MaxLength:
+		return []web.FieldError{{Field: field, Error: ReasonTooLong}}
+	}
+
+	if len(schema.Enum) > 0 {
+		allowed := false
+		for _, e := range schema.Enum {
+			if s == e {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return []web.FieldError{{Field: field, Error: ReasonNotAllowed}}
+		}
+	}
+
+	var err error
+	switch schema.Format {
+	case "uuid":
+		_, err = uuid.Parse(s)
+	case "date-time":
+		_, err = time.Parse(time.RFC3339, s)
+	}
+	if err != nil {
+		return []web.FieldError{{Field: field, Error: ReasonFormat}}
+	}
+
+	return nil
+}
+
+// join appends the name of a property to the path of a field.
+func join(field, property string) string {
+	if field == "" {
+		return property
+	}
+	return field + "." + property
+}
+
+// name returns the name of the field, which is the body for the root.
+func name(field string) string {
+	if field == "" {
+		return "body"
+	}
+	return field
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// Postgres is a Store which keeps buckets in the rate_limit_buckets table so
+// the limits are shared by every instance of the service.
+type Postgres struct {
+	db *sqlx.DB
+}
+
+// NewPostgres constructs a Store backed by the provided database.
+func NewPostgres(db *sqlx.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// Take implements the Store interface. The bucket row is locked for the
+// duration of the transaction so concurrent requests for the same key are
+// serialized.
+func (p *Postgres) Take(ctx context.Context, key string, l Limit, now time.Time) (Result, error) {
+	ctx, span := trace.StartSpan(ctx, "platform.RateLimit.Postgres.Take")
+	defer span.End()
+
+	tx, err := p.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return Result{}, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const insert = `INSERT INTO rate_limit_buckets (key, tokens, updated_at)
+	VALUES ($1, $2, $3) ON CONFLICT (key) DO NOTHING;`
+
+	if _, err := tx.ExecContext(ctx, insert, key, l.Burst, now.UTC()); err != nil {
+		return Result{}, errors.Wrapf(err, "inserting bucket %q", key)
+	}
+
+	const sel = `SELECT tokens, updated_at FROM rate_limit_buckets
+	WHERE key = $1 FOR UPDATE;`
+
+	var b struct {
+		Tokens    float64   `db:"tokens"`
+		UpdatedAt time.Time `db:"updated_at"`
+	}
+	if err := tx.GetContext(ctx, &b, sel, key); err != nil {
+		return Result{}, errors.Wrapf(err, "selecting bucket %q", key)
+	}
+
+	tokens, res := take(b.Tokens, b.UpdatedAt, l, now.UTC())
+
+	const update = `UPDATE rate_limit_buckets SET tokens = $2, updated_at = $3
+	WHERE key = $1;`
+
+	if _, err := tx.ExecContext(ctx, update, key, tokens, now.UTC()); err != nil {
+		return Result{}, errors.Wrapf(err, "updating bucket %q", key)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Result{}, errors.Wrap(err, "committing transaction")
+	}
+
+	return res, nil
+}
+
+// Prune removes the buckets which were not updated since the provided time.
+func (p *Postgres) Prune(ctx context.Context, before time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "platform.RateLimit.Postgres.Prune")
+	defer span.End()
+
+	const q = `DELETE FROM rate_limit_buckets WHERE updated_at < $1;`
+
+	if _, err := p.db.ExecContext(ctx, q, before.UTC()); err != nil {
+		return errors.Wrap(err, "deleting buckets")
+	}
+
+	return nil
+}
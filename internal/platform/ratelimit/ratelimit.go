@@ -0,0 +1,144 @@
+// Package ratelimit implements token bucket rate limiting with an in-memory
+// and a Postgres backed store.
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit describes a token bucket. The bucket holds up to Burst tokens and is
+// refilled with Rate tokens every Period.
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// Result is the outcome of taking a token from a bucket.
+type Result struct {
+	Allowed bool
+
+	// Limit is the capacity of the bucket.
+	Limit int
+
+	// Remaining is the number of whole tokens left in the bucket.
+	Remaining int
+
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+
+	// RetryAfter is the time until the next token is available. It is only
+	// set when the request is not allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps the state of the buckets.
+type Store interface {
+	Take(ctx context.Context, key string, l Limit, now time.Time) (Result, error)
+}
+
+// perToken returns how long it takes to refill a single token.
+func (l Limit) perToken() time.Duration {
+	if l.Rate <= 0 {
+		return 0
+	}
+	return l.Period / time.Duration(l.Rate)
+}
+
+// take refills a bucket holding tokens which was last updated at the
+// provided time and takes a single token from it. It returns the tokens left
+// in the bucket and the result.
+func take(tokens float64, last time.Time, l Limit, now time.Time) (float64, Result) {
+	per := l.perToken()
+
+	// Refill the bucket for the time which passed since the last update.
+	if per > 0 && now.After(last) {
+		tokens += float64(now.Sub(last)) / float64(per)
+	}
+	if tokens > float64(l.Burst) {
+		tokens = float64(l.Burst)
+	}
+
+	res := Result{
+		Limit: l.Burst,
+	}
+
+	if tokens >= 1 {
+		tokens--
+		res.Allowed = true
+	} else if per > 0 {
+		res.RetryAfter = time.Duration((1 - tokens) * float64(per))
+	} else {
+		res.RetryAfter = l.Period
+	}
+
+	res.Remaining = int(math.Floor(tokens))
+	res.Reset = time.Duration((float64(l.Burst) - tokens) * float64(per))
+
+	return tokens, res
+}
+
+// =============================================================================
+
+// bucket is the state of a single token bucket.
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+// Memory is a Store which keeps buckets in the memory of the process. It is
+// only suitable for a single instance deployment.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+}
+
+// NewMemory constructs an empty in-memory Store.
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// sweepEvery is the number of takes after which buckets which were refilled
+// completely are dropped from memory.
+const sweepEvery = 1000
+
+// Take implements the Store interface.
+func (m *Memory) Take(ctx context.Context, key string, l Limit, now time.Time) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), last: now}
+		m.buckets[key] = b
+	}
+
+	var res Result
+	b.tokens, res = take(b.tokens, b.last, l, now)
+	b.last = now
+	b.full = now.Add(res.Reset)
+
+	m.takes++
+	if m.takes%sweepEvery == 0 {
+		m.sweep(now)
+	}
+
+	return res, nil
+}
+
+// sweep drops the buckets which were not used long enough to be full again.
+// Such a bucket is the same as a bucket which does not exist.
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if now.After(b.full) {
+			delete(m.buckets, key)
+		}
+	}
+}
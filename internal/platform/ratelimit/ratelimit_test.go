@@ -0,0 +1,96 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/igomonov88/users/internal/tests"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestMemory(t *testing.T) {
+	testStore(t, NewMemory())
+}
+
+func TestPostgres(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	store := NewPostgres(db)
+	testStore(t, store)
+
+	t.Log("Given the need to remove buckets which are not used anymore.")
+	{
+		ctx := context.Background()
+		if err := store.Prune(ctx, time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("\t%s\tShould be able to prune buckets: %s.", failed, err)
+		}
+
+		var n int
+		if err := db.GetContext(ctx, &n, `SELECT count(*) FROM rate_limit_buckets;`); err != nil {
+			t.Fatalf("\t%s\tShould be able to count buckets: %s.", failed, err)
+		}
+		if n != 0 {
+			t.Fatalf("\t%s\tShould prune the buckets not updated since the time, %d left.", failed, n)
+		}
+		t.Logf("\t%s\tShould prune the buckets not updated since the time.", success)
+	}
+}
+
+// testStore checks the store keeps a token bucket per key.
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+
+	t.Log("Given the need to limit requests with a token bucket.")
+	{
+		ctx := context.Background()
+		l := Limit{Rate: 1, Period: time.Second, Burst: 3}
+		now := time.Now()
+
+		for i := 0; i < l.Burst; i++ {
+			res, err := store.Take(ctx, "key", l, now)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to take a token: %s.", failed, err)
+			}
+			if !res.Allowed {
+				t.Fatalf("\t%s\tShould allow a burst of %d requests, denied request %d.", failed, l.Burst, i+1)
+			}
+			if res.Remaining != l.Burst-i-1 {
+				t.Fatalf("\t%s\tShould have %d remaining tokens, got %d.", failed, l.Burst-i-1, res.Remaining)
+			}
+		}
+		t.Logf("\t%s\tShould allow a burst of requests.", success)
+
+		res, err := store.Take(ctx, "key", l, now)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to take a token: %s.", failed, err)
+		}
+		if res.Allowed {
+			t.Fatalf("\t%s\tShould deny requests exceeding the burst.", failed)
+		}
+		if res.RetryAfter != time.Second {
+			t.Fatalf("\t%s\tShould retry after %v, got %v.", failed, time.Second, res.RetryAfter)
+		}
+		if res.Reset != 3*time.Second {
+			t.Fatalf("\t%s\tShould be full after %v, got %v.", failed, 3*time.Second, res.Reset)
+		}
+		t.Logf("\t%s\tShould deny requests exceeding the burst.", success)
+
+		res, err = store.Take(ctx, "other", l, now)
+		if err != nil || !res.Allowed {
+			t.Fatalf("\t%s\tShould keep a bucket per key: %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould keep a bucket per key.", success)
+
+		res, err = store.Take(ctx, "key", l, now.Add(1500*time.Millisecond))
+		if err != nil || !res.Allowed {
+			t.Fatalf("\t%s\tShould refill the bucket over time: %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould refill the bucket over time.", success)
+	}
+}
@@ -0,0 +1,173 @@
+package rpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/pkg/errors"
+)
+
+// File describes a proto file whose messages are Go structs with protobuf
+// tags instead of generated code. Registering it lets the reflection
+// service describe the services of the file to clients.
+type File struct {
+	Name     string
+	Package  string
+	Services []Service
+}
+
+// Service describes a service of a File.
+type Service struct {
+	Name    string
+	Methods []Method
+}
+
+// Method describes a method of a Service. The input and output are the
+// messages the method receives and sends, the name of the message is the
+// name of its Go type.
+type Method struct {
+	Name          string
+	Input         proto.Message
+	Output        proto.Message
+	ClientStreams bool
+	ServerStreams bool
+}
+
+// RegisterFile registers the descriptor of the file and the types of the
+// messages of its methods, including the messages they hold, with the proto
+// package. It is meant to be called once during initialization.
+func RegisterFile(f File) error {
+	fd := descriptor.FileDescriptorProto{
+		Name:    proto.String(f.Name),
+		Package: proto.String(f.Package),
+		Syntax:  proto.String("proto3"),
+	}
+
+	seen := make(map[reflect.Type]bool)
+	var add func(t reflect.Type)
+	add = func(t reflect.Type) {
+		if seen[t] {
+			return
+		}
+		seen[t] = true
+
+		msg, nested := message(f.Package, t)
+		fd.MessageType = append(fd.MessageType, msg)
+		proto.RegisterType(reflect.New(t).Interface().(proto.Message), f.Package+"."+t.Name())
+
+		for _, n := range nested {
+			add(n)
+		}
+	}
+
+	for _, s := range f.Services {
+		sd := descriptor.ServiceDescriptorProto{Name: proto.String(s.Name)}
+		for _, m := range s.Methods {
+			in, out := reflect.TypeOf(m.Input).Elem(), reflect.TypeOf(m.Output).Elem()
+			add(in)
+			add(out)
+
+			sd.Method = append(sd.Method, &descriptor.MethodDescriptorProto{
+				Name:            proto.String(m.Name),
+				InputType:       proto.String(typeName(f.Package, in)),
+				OutputType:      proto.String(typeName(f.Package, out)),
+				ClientStreaming: proto.Bool(m.ClientStreams),
+				ServerStreaming: proto.Bool(m.ServerStreams),
+			})
+		}
+		fd.Service = append(fd.Service, &sd)
+	}
+
+	data, err := proto.Marshal(&fd)
+	if err != nil {
+		return errors.Wrap(err, "marshaling file descriptor")
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return errors.Wrap(err, "compressing file descriptor")
+	}
+	if err := zw.Close(); err != nil {
+		return errors.Wrap(err, "compressing file descriptor")
+	}
+
+	proto.RegisterFile(f.Name, buf.Bytes())
+
+	return nil
+}
+
+// message describes the struct type of a message from its protobuf tags.
+// It returns the types of the messages its fields hold.
+func message(pkg string, t reflect.Type) (*descriptor.DescriptorProto, []reflect.Type) {
+	msg := descriptor.DescriptorProto{Name: proto.String(t.Name())}
+	var nested []reflect.Type
+
+	props := proto.GetProperties(t)
+	for i, p := range props.Prop {
+		if p.Tag == 0 {
+			continue
+		}
+
+		ft := t.Field(i).Type
+		label := descriptor.FieldDescriptorProto_LABEL_OPTIONAL
+		if p.Repeated {
+			label = descriptor.FieldDescriptorProto_LABEL_REPEATED
+			ft = ft.Elem()
+		}
+
+		field := descriptor.FieldDescriptorProto{
+			Name:   proto.String(p.OrigName),
+			Number: proto.Int32(int32(p.Tag)),
+			Label:  label.Enum(),
+		}
+		if p.JSONName != "" {
+			field.JsonName = proto.String(p.JSONName)
+		}
+
+		switch {
+		case ft.Kind() == reflect.Ptr && ft.Elem().Kind() == reflect.Struct:
+			field.Type = descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum()
+			field.TypeName = proto.String(typeName(pkg, ft.Elem()))
+			nested = append(nested, ft.Elem())
+		default:
+			field.Type = scalar(ft).Enum()
+		}
+
+		msg.Field = append(msg.Field, &field)
+	}
+
+	return &msg, nested
+}
+
+// scalar returns the proto type of a field of the Go type.
+func scalar(t reflect.Type) descriptor.FieldDescriptorProto_Type {
+	switch t.Kind() {
+	case reflect.Bool:
+		return descriptor.FieldDescriptorProto_TYPE_BOOL
+	case reflect.Int32:
+		return descriptor.FieldDescriptorProto_TYPE_INT32
+	case reflect.Int64, reflect.Int:
+		return descriptor.FieldDescriptorProto_TYPE_INT64
+	case reflect.Uint32:
+		return descriptor.FieldDescriptorProto_TYPE_UINT32
+	case reflect.Uint64:
+		return descriptor.FieldDescriptorProto_TYPE_UINT64
+	case reflect.Float32:
+		return descriptor.FieldDescriptorProto_TYPE_FLOAT
+	case reflect.Float64:
+		return descriptor.FieldDescriptorProto_TYPE_DOUBLE
+	case reflect.Slice:
+		return descriptor.FieldDescriptorProto_TYPE_BYTES
+	default:
+		return descriptor.FieldDescriptorProto_TYPE_STRING
+	}
+}
+
+// typeName returns the fully qualified name of the message of the type.
+func typeName(pkg string, t reflect.Type) string {
+	return "." + pkg + "." + t.Name()
+}
@@ -0,0 +1,230 @@
+// Package rpc is the counterpart of the web package for gRPC. Calls of
+// unary and streaming methods go through one chain of middleware, and the
+// context of a call carries the same web.Values HTTP handlers get, so the
+// helpers of the web package work for both.
+package rpc
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.opencensus.io/plugin/ocgrpc"
+	"go.opencensus.io/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// RequestIDKey is the metadata key request ids are accepted from and echoed
+// in, the gRPC form of web.RequestIDHeader.
+const RequestIDKey = "x-request-id"
+
+// A Handler handles a call of a method, the full name like
+// /users.v1.Users/CreateUser. The message of unary calls and the stream of
+// streaming calls are held by the handler the server built for the call.
+type Handler func(ctx context.Context, method string) error
+
+// Middleware is a function designed to run some code before and/or after
+// another Handler, like web.Middleware does for HTTP.
+type Middleware func(Handler) Handler
+
+// Server is a gRPC server serving the health checking and reflection
+// services next to the services registered with it.
+type Server struct {
+	*grpc.Server
+	health   *health.Server
+	shutdown chan os.Signal
+	log      *logger.Logger
+	mw       []Middleware
+	methods  map[string][]Middleware
+}
+
+// NewServer constructs a Server which runs the middleware for every call.
+func NewServer(shutdown chan os.Signal, log *logger.Logger, mw ...Middleware) *Server {
+	s := Server{
+		health:   health.NewServer(),
+		shutdown: shutdown,
+		log:      log,
+		mw:       mw,
+		methods:  make(map[string][]Middleware),
+	}
+
+	// The OpenCensus stats handler starts the initial span of calls, with
+	// the remote parent clients send in the metadata.
+	s.Server = grpc.NewServer(
+		grpc.StatsHandler(&ocgrpc.ServerHandler{}),
+		grpc.UnaryInterceptor(s.unary),
+		grpc.StreamInterceptor(s.stream),
+	)
+
+	healthpb.RegisterHealthServer(s.Server, s.health)
+	reflection.Register(s.Server)
+
+	return &s
+}
+
+// RegisterService registers a service and reports it as serving to health
+// checks.
+func (s *Server) RegisterService(sd *grpc.ServiceDesc, ss interface{}) {
+	s.Server.RegisterService(sd, ss)
+	s.health.SetServingStatus(sd.ServiceName, healthpb.HealthCheckResponse_SERVING)
+}
+
+// Use adds middleware for the calls of a method only, after the middleware
+// of the server, like the middleware of a route.
+func (s *Server) Use(method string, mw ...Middleware) {
+	s.methods[method] = append(s.methods[method], mw...)
+}
+
+// SignalShutdown is used to gracefully shutdown the app when an integrity
+// issue is identified.
+func (s *Server) SignalShutdown() {
+	s.shutdown <- syscall.SIGTERM
+}
+
+// Shutdown stops the server gracefully like http.Server.Shutdown does.
+// Health checks report every service as not serving, new calls are refused
+// and the pending ones are waited for until the context is done. Calls still
+// pending then are cancelled.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		return ctx.Err()
+	}
+}
+
+// unary runs the middleware for calls of unary methods.
+func (s *Server) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	var resp interface{}
+	h := func(ctx context.Context, method string) error {
+		var err error
+		resp, err = handler(ctx, req)
+		return err
+	}
+
+	if err := s.call(ctx, info.FullMethod, h); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// stream runs the middleware for calls of streaming methods.
+func (s *Server) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	h := func(ctx context.Context, method string) error {
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+
+	return s.call(ss.Context(), info.FullMethod, h)
+}
+
+// call sets the values of the call in the context and calls the handler
+// wrapped in the middleware.
+func (s *Server) call(ctx context.Context, method string, handler Handler) error {
+	ctx, span := trace.StartSpan(ctx, "internal.platform.rpc")
+	defer span.End()
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	// Set the context with the required values to
+	// process the call.
+	v := web.Values{
+		TraceID:   span.SpanContext().TraceID.String(),
+		RequestID: web.ValidRequestID(first(md, RequestIDKey)),
+		Route:     method,
+		Path:      method,
+		Locale:    web.MatchLocale(strings.Join(md.Get("accept-language"), ",")),
+		Now:       time.Now(),
+	}
+	ctx = context.WithValue(ctx, web.KeyValues, &v)
+
+	// Let clients quote the id when reporting problems.
+	grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, v.RequestID))
+
+	// First wrap method specific middleware around the handler, then the
+	// server's general middleware.
+	handler = wrapMiddleware(s.methods[method], handler)
+	handler = wrapMiddleware(s.mw, handler)
+
+	err := handler(ctx, method)
+	if web.IsShutdown(err) {
+		s.log.Error("rpc : shutdown requested", "trace_id", v.TraceID, "request_id", v.RequestID, "route", v.Route, "error", err)
+		s.SignalShutdown()
+		return status.Error(codes.Unavailable, "service is shutting down")
+	}
+
+	return err
+}
+
+// wrapMiddleware creates a new handler by wrapping middleware around a final
+// handler. The middlewares' Handlers will be executed by calls in the order
+// they are provided.
+func wrapMiddleware(mw []Middleware, handler Handler) Handler {
+	for i := len(mw) - 1; i >= 0; i-- {
+		if h := mw[i]; h != nil {
+			handler = h(handler)
+		}
+	}
+	return handler
+}
+
+// serverStream is a grpc.ServerStream with the context the middleware
+// built.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context of the call.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// ClientIP returns the ip address of the client which made the call. The
+// x-forwarded-for metadata is only taken into account when the call comes
+// from one of the trusted proxies, like web.ClientIP does.
+func ClientIP(ctx context.Context, trusted []*net.IPNet) string {
+	var addr string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr = p.Addr.String()
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	return web.ForwardedIP(addr, md.Get("x-forwarded-for"), trusted)
+}
+
+// UserAgent returns the user agent the client sent.
+func UserAgent(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return first(md, "user-agent")
+}
+
+// first returns the first value of the metadata key or an empty string.
+func first(md metadata.MD, key string) string {
+	if vals := md.Get(key); len(vals) > 0 {
+		return vals[0]
+	}
+	return ""
+}
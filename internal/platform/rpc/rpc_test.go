@@ -0,0 +1,239 @@
+package rpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// healthCheck is the full name of the method of health checks.
+const healthCheck = "/grpc.health.v1.Health/Check"
+
+// dial serves the server on an in-memory listener and connects to it.
+func dial(t *testing.T, s *Server) *grpc.ClientConn {
+	lis := bufconn.Listen(1 << 20)
+	go s.Serve(lis)
+
+	dialer := func(string, time.Duration) (net.Conn, error) {
+		return lis.Dial()
+	}
+	conn, err := grpc.Dial("bufnet", grpc.WithDialer(dialer), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestServer(t *testing.T) {
+	log := logger.New(ioutil.Discard, logger.Error)
+
+	var order []string
+	trail := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(ctx context.Context, method string) error {
+				order = append(order, name)
+				return next(ctx, method)
+			}
+		}
+	}
+
+	var seen web.Values
+	values := func(next Handler) Handler {
+		return func(ctx context.Context, method string) error {
+			if v, ok := ctx.Value(web.KeyValues).(*web.Values); ok {
+				seen = *v
+			}
+			return next(ctx, method)
+		}
+	}
+
+	s := NewServer(make(chan os.Signal, 1), log, trail("server"), values)
+	s.Use(healthCheck, trail("method"))
+	conn := dial(t, s)
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	t.Log("Given the need to serve calls like requests.")
+	{
+		ctx := metadata.AppendToOutgoingContext(context.Background(), RequestIDKey, "support-1234")
+
+		var header metadata.MD
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&header))
+		if err != nil {
+			t.Fatalf("\t%s\tShould answer health checks : %v.", failed, err)
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			t.Fatalf("\t%s\tShould report serving : got %v.", failed, resp.Status)
+		}
+		t.Logf("\t%s\tShould answer health checks.", success)
+
+		if len(order) != 2 || order[0] != "server" || order[1] != "method" {
+			t.Fatalf("\t%s\tShould run the middleware of the server first : got %v.", failed, order)
+		}
+		t.Logf("\t%s\tShould run the middleware of the server first.", success)
+
+		if seen.RequestID != "support-1234" || seen.Route != healthCheck {
+			t.Fatalf("\t%s\tShould set the values of the call : got %+v.", failed, seen)
+		}
+		if got := header.Get(RequestIDKey); len(got) != 1 || got[0] != "support-1234" {
+			t.Fatalf("\t%s\tShould echo the request id : got %v.", failed, got)
+		}
+		t.Logf("\t%s\tShould set the values of the call and echo the request id.", success)
+	}
+
+	t.Log("Given the need to stop gracefully.")
+	{
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		if err := s.Shutdown(ctx); err != nil {
+			t.Fatalf("\t%s\tShould stop without pending calls : %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould stop without pending calls.", success)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	log := logger.New(ioutil.Discard, logger.Error)
+
+	var fail error
+	errs := func(next Handler) Handler {
+		return func(ctx context.Context, method string) error {
+			if err := next(ctx, method); err != nil {
+				return Status(ctx, err)
+			}
+			if fail != nil {
+				return Status(ctx, fail)
+			}
+			return nil
+		}
+	}
+
+	s := NewServer(make(chan os.Signal, 1), log, errs)
+	conn := dial(t, s)
+	defer conn.Close()
+	defer s.Stop()
+	client := healthpb.NewHealthClient(conn)
+
+	tests := []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{"not found", web.NewRequestError(errors.New("user not found"), http.StatusNotFound), codes.NotFound},
+		{"conflict", web.NewRequestError(errors.New("email already exist"), http.StatusConflict), codes.AlreadyExists},
+		{"unauthorized", web.NewRequestError(errors.New("bad token"), http.StatusUnauthorized), codes.Unauthenticated},
+		{"internal", errors.New("connection refused"), codes.Internal},
+	}
+
+	t.Log("Given the need to report errors with the code of the call.")
+	{
+		for i, tt := range tests {
+			fail = tt.err
+
+			var trailer metadata.MD
+			_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.Trailer(&trailer))
+			st, _ := status.FromError(err)
+			if st.Code() != tt.code {
+				t.Fatalf("\t%s\tTest %d:\tShould fail %s with %v : got %v.", failed, i, tt.name, tt.code, st.Code())
+			}
+			if len(trailer.Get(ErrorCodeKey)) != 1 {
+				t.Fatalf("\t%s\tTest %d:\tShould send the code of the error : got %v.", failed, i, trailer)
+			}
+			if tt.code == codes.Internal && st.Message() == tt.err.Error() {
+				t.Fatalf("\t%s\tTest %d:\tShould not disclose internal errors : got %q.", failed, i, st.Message())
+			}
+			t.Logf("\t%s\tTest %d:\tShould fail %s with %v.", success, i, tt.name, tt.code)
+		}
+	}
+}
+
+type testRequest struct {
+	Name string `protobuf:"bytes,1,opt,name=name,proto3"`
+}
+
+func (m *testRequest) Reset()         { *m = testRequest{} }
+func (m *testRequest) String() string { return proto.CompactTextString(m) }
+func (*testRequest) ProtoMessage()    {}
+
+type testResponse struct {
+	Items []*testRequest `protobuf:"bytes,1,rep,name=items,proto3"`
+	Found bool           `protobuf:"varint,2,opt,name=found,proto3"`
+}
+
+func (m *testResponse) Reset()         { *m = testResponse{} }
+func (m *testResponse) String() string { return proto.CompactTextString(m) }
+func (*testResponse) ProtoMessage()    {}
+
+func TestRegisterFile(t *testing.T) {
+	f := File{
+		Name:    "rpc_test.proto",
+		Package: "rpc.test",
+		Services: []Service{
+			{
+				Name:    "Test",
+				Methods: []Method{{Name: "Find", Input: &testRequest{}, Output: &testResponse{}, ServerStreams: true}},
+			},
+		},
+	}
+
+	t.Log("Given the need to describe services to the reflection service.")
+	{
+		if err := RegisterFile(f); err != nil {
+			t.Fatalf("\t%s\tShould register the file : %v.", failed, err)
+		}
+
+		zr, err := gzip.NewReader(bytes.NewReader(proto.FileDescriptor(f.Name)))
+		if err != nil {
+			t.Fatalf("\t%s\tShould register a compressed descriptor : %v.", failed, err)
+		}
+		data, err := ioutil.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("\t%s\tShould register a compressed descriptor : %v.", failed, err)
+		}
+		var fd descriptor.FileDescriptorProto
+		if err := proto.Unmarshal(data, &fd); err != nil {
+			t.Fatalf("\t%s\tShould register a valid descriptor : %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould register a valid descriptor.", success)
+
+		m := fd.Service[0].Method[0]
+		if m.GetInputType() != ".rpc.test.testRequest" || m.GetOutputType() != ".rpc.test.testResponse" || !m.GetServerStreaming() {
+			t.Fatalf("\t%s\tShould describe the methods : got %v.", failed, m)
+		}
+		t.Logf("\t%s\tShould describe the methods.", success)
+
+		if len(fd.MessageType) != 2 || len(fd.MessageType[1].Field) != 2 {
+			t.Fatalf("\t%s\tShould describe each message once : got %v.", failed, fd.MessageType)
+		}
+		items := fd.MessageType[1].Field[0]
+		if items.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED || items.GetTypeName() != ".rpc.test.testRequest" {
+			t.Fatalf("\t%s\tShould describe fields holding messages : got %v.", failed, items)
+		}
+		t.Logf("\t%s\tShould describe each message once.", success)
+	}
+}
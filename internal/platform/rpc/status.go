@@ -0,0 +1,100 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/igomonov88/users/internal/platform/web"
+)
+
+// ErrorCodeKey is the trailer key the code of the error catalog is sent in,
+// the counterpart of the code of problem details.
+const ErrorCodeKey = "x-error-code"
+
+// statusCodes maps the status of web errors to the code of gRPC calls.
+var statusCodes = map[int]codes.Code{
+	http.StatusBadRequest:            codes.InvalidArgument,
+	http.StatusUnauthorized:          codes.Unauthenticated,
+	http.StatusForbidden:             codes.PermissionDenied,
+	http.StatusNotFound:              codes.NotFound,
+	http.StatusConflict:              codes.AlreadyExists,
+	http.StatusPreconditionFailed:    codes.FailedPrecondition,
+	http.StatusRequestEntityTooLarge: codes.InvalidArgument,
+	http.StatusUnprocessableEntity:   codes.InvalidArgument,
+	http.StatusTooManyRequests:       codes.ResourceExhausted,
+	http.StatusNotImplemented:        codes.Unimplemented,
+	http.StatusServiceUnavailable:    codes.Unavailable,
+	http.StatusGatewayTimeout:        codes.DeadlineExceeded,
+}
+
+// Code returns the gRPC code of calls failing with the HTTP status.
+func Code(statusCode int) codes.Code {
+	if c, ok := statusCodes[statusCode]; ok {
+		return c
+	}
+	if statusCode >= http.StatusInternalServerError {
+		return codes.Internal
+	}
+	return codes.Unknown
+}
+
+// Status converts an error returned by a handler into the status of the
+// call, the problem details of HTTP responses in gRPC form. The message is
+// the detail of the problem or its title, the errors of fields are sent as
+// a BadRequest and the code of the catalog as trailer. Errors which are a
+// status already are returned as they are.
+func Status(ctx context.Context, err error) error {
+	switch cause := errors.Cause(err); cause {
+	case context.Canceled:
+		return status.Error(codes.Canceled, cause.Error())
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, cause.Error())
+	}
+	if _, ok := status.FromError(errors.Cause(err)); ok {
+		return errors.Cause(err)
+	}
+
+	p := web.NewProblem(ctx, err)
+
+	msg := p.Detail
+	if msg == "" {
+		msg = p.Title
+	}
+	st := status.New(Code(p.Status), msg)
+
+	if len(p.Fields) > 0 {
+		br := errdetails.BadRequest{}
+		for _, f := range p.Fields {
+			br.FieldViolations = append(br.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       f.Field,
+				Description: f.Message,
+			})
+		}
+		if withDetails, err := st.WithDetails(&br); err == nil {
+			st = withDetails
+		}
+	}
+
+	grpc.SetTrailer(ctx, metadata.Pairs(ErrorCodeKey, p.Code))
+
+	return st.Err()
+}
+
+// StatusCode returns the HTTP status of the error returned by a handler,
+// so calls can be logged and counted like requests.
+func StatusCode(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	if webErr, ok := errors.Cause(err).(*web.Error); ok {
+		return webErr.Status
+	}
+	return http.StatusInternalServerError
+}
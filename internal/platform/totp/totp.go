@@ -0,0 +1,131 @@
+// Package totp implements time-based one-time passwords as described in
+// RFC 6238 and the otpauth:// key URI format used by authenticator apps.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// Defaults used by the authenticator apps. Changing them breaks enrolled
+// secrets as most apps ignore the parameters in the key URI.
+const (
+	Digits = 6
+	Period = 30 * time.Second
+	Skew   = 1
+)
+
+// secretSize is the length of a generated secret in bytes. RFC 4226
+// recommends 160 bits which matches the size of a HMAC-SHA1 key.
+const secretSize = 20
+
+// encoding is the base32 encoding of secrets used in key URIs.
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random secret encoded in base32.
+func GenerateSecret() (string, error) {
+	b := make([]byte, secretSize)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "reading random secret")
+	}
+	return encoding.EncodeToString(b), nil
+}
+
+// Step returns the time step counter for the provided time.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code returns the one-time password for the secret at the given time step
+// with the given number of digits.
+func Code(secret string, step int64, digits int) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return "", errors.Wrap(err, "decoding secret")
+	}
+
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation as described in RFC 4226 section 5.3.
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < digits; i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", digits, bin%mod), nil
+}
+
+// Validate checks the code against the secret at the provided time allowing
+// Skew time steps of clock drift in either direction. It returns the step
+// which matched so callers can reject the reuse of a code. Steps which are
+// not after the provided last step are never accepted.
+func Validate(secret, code string, t time.Time, last int64) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != Digits {
+		return 0, false
+	}
+
+	now := Step(t)
+	for step := now - Skew; step <= now+Skew; step++ {
+		if step <= last {
+			continue
+		}
+		want, err := Code(secret, step, Digits)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// URI returns the otpauth:// key URI for the secret which can be imported by
+// authenticator apps.
+func URI(issuer, account, secret string) string {
+	q := make(url.Values)
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(Digits))
+	q.Set("period", fmt.Sprint(int(Period/time.Second)))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: q.Encode(),
+	}
+
+	return u.String()
+}
+
+// QRCode returns a PNG image of the given size encoding the key URI.
+func QRCode(uri string, size int) ([]byte, error) {
+	png, err := qrcode.Encode(uri, qrcode.Medium, size)
+	if err != nil {
+		return nil, errors.Wrap(err, "encoding qr code")
+	}
+	return png, nil
+}
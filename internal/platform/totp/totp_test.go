@@ -0,0 +1,79 @@
+package totp_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/igomonov88/users/internal/platform/totp"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// rfcSecret is the base32 encoding of the SHA1 seed "12345678901234567890"
+// used by the test vectors in RFC 6238 appendix B.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCode(t *testing.T) {
+	t.Log("Given the need to generate one-time passwords.")
+	{
+		vectors := []struct {
+			unix int64
+			code string
+		}{
+			{59, "94287082"},
+			{1111111109, "07081804"},
+			{1111111111, "14050471"},
+			{1234567890, "89005924"},
+			{2000000000, "69279037"},
+			{20000000000, "65353130"},
+		}
+
+		for _, v := range vectors {
+			code, err := totp.Code(rfcSecret, totp.Step(time.Unix(v.unix, 0)), 8)
+			if err != nil {
+				t.Fatalf("\t%s\tShould be able to generate a code: %s.", failed, err)
+			}
+			if code != v.code {
+				t.Fatalf("\t%s\tShould generate %s at %d, got %s.", failed, v.code, v.unix, code)
+			}
+		}
+		t.Logf("\t%s\tShould match the RFC 6238 test vectors.", success)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Log("Given the need to validate one-time passwords.")
+	{
+		secret, err := totp.GenerateSecret()
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to generate a secret: %s.", failed, err)
+		}
+		t.Logf("\t%s\tShould be able to generate a secret.", success)
+
+		now := time.Now()
+		code, err := totp.Code(secret, totp.Step(now.Add(-totp.Period)), totp.Digits)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to generate a code: %s.", failed, err)
+		}
+
+		step, ok := totp.Validate(secret, code, now, 0)
+		if !ok {
+			t.Fatalf("\t%s\tShould accept a code from the previous time step.", failed)
+		}
+		t.Logf("\t%s\tShould accept a code from the previous time step.", success)
+
+		if _, ok := totp.Validate(secret, code, now, step); ok {
+			t.Fatalf("\t%s\tShould reject a code which was already used.", failed)
+		}
+		t.Logf("\t%s\tShould reject a code which was already used.", success)
+
+		if _, ok := totp.Validate(secret, code, now.Add(5*totp.Period), 0); ok {
+			t.Fatalf("\t%s\tShould reject a code outside of the allowed skew.", failed)
+		}
+		t.Logf("\t%s\tShould reject a code outside of the allowed skew.", success)
+	}
+}
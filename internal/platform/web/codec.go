@@ -0,0 +1,202 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/users/internal/platform/msgpack"
+)
+
+// Media types of the codecs every app knows.
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeProtobuf = "application/x-protobuf"
+	ContentTypeMsgPack  = "application/msgpack"
+)
+
+// ErrUnsupportedValue is returned by codecs for values they cannot
+// represent, like types without a protobuf message.
+var ErrUnsupportedValue = errors.New("value not supported by the codec")
+
+// Codec encodes and decodes the bodies of a media type.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// codecs are the registered codecs by their media type. Media types are
+// kept in the order of registration, which is the order of preference when
+// a client accepts several equally.
+var (
+	codecs     = make(map[string]Codec)
+	mediaTypes []string
+)
+
+func init() {
+	RegisterCodec(ContentTypeJSON, jsonCodec{})
+	RegisterCodec(ContentTypeProtobuf, protoCodec{})
+	RegisterCodec("application/protobuf", protoCodec{})
+	RegisterCodec(ContentTypeMsgPack, msgpackCodec{})
+	RegisterCodec("application/x-msgpack", msgpackCodec{})
+}
+
+// RegisterCodec makes requests and responses of the media type use the
+// codec. It is meant to be called during initialization.
+func RegisterCodec(mediaType string, c Codec) {
+	if _, ok := codecs[mediaType]; !ok {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	codecs[mediaType] = c
+}
+
+// codecFor returns the codec of the Content-Type of a request. Requests
+// without one are JSON.
+func codecFor(contentType string) (Codec, bool) {
+	if contentType == "" {
+		return codecs[ContentTypeJSON], true
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+	c, ok := codecs[mt]
+	return c, ok
+}
+
+// encode marshals the value with the codec the Accept header prefers,
+// falling back to the next one accepted when a codec cannot represent the
+// value. It returns the media type used and fails with 406 when no codec
+// accepted can encode the value.
+func encode(accept string, v interface{}) (string, []byte, error) {
+	for _, mt := range acceptable(accept) {
+		data, err := codecs[mt].Marshal(v)
+		if err == ErrUnsupportedValue {
+			continue
+		}
+		return mt, data, err
+	}
+	err := errors.New("none of the accepted media types can represent the response")
+	return "", nil, NewRequestError(err, http.StatusNotAcceptable)
+}
+
+// acceptable returns the registered media types the Accept header allows
+// ordered by their quality. Missing headers accept anything.
+func acceptable(accept string) []string {
+	if strings.TrimSpace(accept) == "" {
+		accept = "*/*"
+	}
+
+	type mediaRange struct {
+		typ string
+		q   float64
+	}
+	var ranges []mediaRange
+	for _, part := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		ranges = append(ranges, mediaRange{typ: mt, q: q})
+	}
+
+	// The most specific range matching a media type sets its quality.
+	quality := make(map[string]float64)
+	var types []string
+	for _, mt := range mediaTypes {
+		best, q := -1, 0.0
+		for _, r := range ranges {
+			var specificity int
+			switch {
+			case r.typ == mt:
+				specificity = 2
+			case strings.HasSuffix(r.typ, "/*") && strings.HasPrefix(mt, strings.TrimSuffix(r.typ, "*")):
+				specificity = 1
+			case r.typ == "*/*":
+				specificity = 0
+			default:
+				continue
+			}
+			if specificity > best {
+				best, q = specificity, r.q
+			}
+		}
+		if best >= 0 && q > 0 {
+			quality[mt] = q
+			types = append(types, mt)
+		}
+	}
+
+	sort.SliceStable(types, func(i, j int) bool {
+		return quality[types[i]] > quality[types[j]]
+	})
+	return types
+}
+
+// jsonCodec encodes bodies as JSON. Objects with unknown fields are
+// rejected.
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// protoCodec encodes bodies as Protocol Buffers. Only values which are a
+// proto.Message, or whose pointer is, are supported.
+type protoCodec struct{}
+
+func (protoCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		rv := reflect.ValueOf(v)
+		if !rv.IsValid() || rv.Kind() == reflect.Ptr {
+			return nil, ErrUnsupportedValue
+		}
+		p := reflect.New(rv.Type())
+		p.Elem().Set(rv)
+		if m, ok = p.Interface().(proto.Message); !ok {
+			return nil, ErrUnsupportedValue
+		}
+	}
+	return proto.Marshal(m)
+}
+
+func (protoCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return ErrUnsupportedValue
+	}
+	return proto.Unmarshal(data, m)
+}
+
+// msgpackCodec encodes bodies as MessagePack using the json tags of types.
+// Objects with unknown fields are rejected.
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	return msgpack.Unmarshal(data, v)
+}
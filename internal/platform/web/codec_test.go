@@ -0,0 +1,133 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/platform/msgpack"
+)
+
+type gadget struct {
+	Name string `json:"name" validate:"required" protobuf:"bytes,1,opt,name=name,proto3"`
+}
+
+func (m *gadget) Reset()         { *m = gadget{} }
+func (m *gadget) String() string { return proto.CompactTextString(m) }
+func (*gadget) ProtoMessage()    {}
+
+// widget has no protobuf message.
+type widget struct {
+	Name string `json:"name" validate:"required"`
+}
+
+func TestNegotiation(t *testing.T) {
+	// Respond with the errors like mid.Errors does.
+	respond := func(v interface{}) Handler {
+		return func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+			if err := Respond(ctx, w, v, http.StatusOK); err != nil {
+				return ResponseError(ctx, w, err)
+			}
+			return nil
+		}
+	}
+
+	app := NewApp(make(chan os.Signal, 1), logger.New(os.Stdout, logger.Error))
+	app.Handle(http.MethodGet, "/v1/gadget", respond(gadget{Name: "gear"}))
+	app.Handle(http.MethodGet, "/v1/widget", respond(widget{Name: "gear"}))
+
+	pb, _ := proto.Marshal(&gadget{Name: "gear"})
+	mp, _ := msgpack.Marshal(gadget{Name: "gear"})
+
+	tests := []struct {
+		path        string
+		accept      string
+		status      int
+		contentType string
+		body        []byte
+	}{
+		{"/v1/gadget", "", http.StatusOK, ContentTypeJSON, []byte(`{"name":"gear"}`)},
+		{"/v1/gadget", "*/*", http.StatusOK, ContentTypeJSON, []byte(`{"name":"gear"}`)},
+		{"/v1/gadget", ContentTypeProtobuf, http.StatusOK, ContentTypeProtobuf, pb},
+		{"/v1/gadget", "application/json;q=0.5, application/msgpack", http.StatusOK, ContentTypeMsgPack, mp},
+		{"/v1/gadget", "application/*;q=0.1, application/x-msgpack;q=0", http.StatusOK, ContentTypeJSON, []byte(`{"name":"gear"}`)},
+		{"/v1/widget", "application/x-protobuf, application/json;q=0.1", http.StatusOK, ContentTypeJSON, []byte(`{"name":"gear"}`)},
+		{"/v1/widget", ContentTypeProtobuf, http.StatusNotAcceptable, ContentTypeProblem, nil},
+		{"/v1/gadget", "text/html", http.StatusNotAcceptable, ContentTypeProblem, nil},
+	}
+
+	t.Log("Given the need to respond in the media type the client accepts.")
+	{
+		for i, tt := range tests {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if w.Code != tt.status || w.Header().Get("Content-Type") != tt.contentType {
+				t.Fatalf("\t%s\tShould respond to request %d with %d %s : got %d %s.", failed, i, tt.status, tt.contentType, w.Code, w.Header().Get("Content-Type"))
+			}
+			if tt.body != nil && !bytes.Equal(w.Body.Bytes(), tt.body) {
+				t.Fatalf("\t%s\tShould encode the body of request %d : got %q.", failed, i, w.Body)
+			}
+		}
+		t.Logf("\t%s\tShould pick the preferred codec able to encode the value.", success)
+	}
+}
+
+func TestDecodeCodecs(t *testing.T) {
+	pb, _ := proto.Marshal(&gadget{Name: "gear"})
+	empty, _ := proto.Marshal(&gadget{})
+	mp, _ := msgpack.Marshal(gadget{Name: "gear"})
+	js, _ := json.Marshal(gadget{Name: "gear"})
+
+	tests := []struct {
+		contentType string
+		body        []byte
+		val         interface{}
+		status      int
+	}{
+		{"", js, &gadget{}, 0},
+		{"application/json; charset=utf-8", js, &gadget{}, 0},
+		{ContentTypeProtobuf, pb, &gadget{}, 0},
+		{ContentTypeMsgPack, mp, &gadget{}, 0},
+		{ContentTypeProtobuf, empty, &gadget{}, http.StatusBadRequest},
+		{ContentTypeProtobuf, pb, &widget{}, http.StatusUnsupportedMediaType},
+		{ContentTypeMsgPack, []byte{0x81}, &gadget{}, http.StatusBadRequest},
+		{"text/plain", js, &gadget{}, http.StatusUnsupportedMediaType},
+	}
+
+	t.Log("Given the need to decode requests with the codec of their content type.")
+	{
+		for i, tt := range tests {
+			r := httptest.NewRequest(http.MethodPost, "/v1/gadget", bytes.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			err := Decode(r, tt.val)
+			if tt.status == 0 {
+				if err != nil {
+					t.Fatalf("\t%s\tShould decode request %d : %s.", failed, i, err)
+				}
+				if g := tt.val.(*gadget); g.Name != "gear" {
+					t.Fatalf("\t%s\tShould decode request %d : got %+v.", failed, i, g)
+				}
+				continue
+			}
+			if webErr, ok := err.(*Error); !ok || webErr.Status != tt.status {
+				t.Fatalf("\t%s\tShould reject request %d with %d : got %v.", failed, i, tt.status, err)
+			}
+		}
+		t.Logf("\t%s\tShould decode, validate and reject unsupported bodies.", success)
+	}
+}
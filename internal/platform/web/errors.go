@@ -1,31 +1,45 @@
 package web
 
-import "github.com/pkg/errors"
+import (
+	"github.com/pkg/errors"
+	validator "gopkg.in/go-playground/validator.v9"
+)
 
-// FieldError is used to indicate an error with a specific request field.
+// FieldError is used to indicate an error with a specific request field. The
+// error is a machine readable reason, the message is meant for people. The
+// message is localized when the response is built unless it is set.
 type FieldError struct {
-	Field string `json:"field"`
-	Error string `json:"error"`
-}
+	Field   string `json:"field"`
+	Error   string `json:"error"`
+	Message string `json:"message,omitempty"`
 
-// ErrorResponse is the form used for API responses from failures in the API.
-type ErrorResponse struct {
-	Error  string       `json:"error"`
-	Fields []FieldError `json:"fields, omitempty"`
+	verr validator.FieldError
 }
 
 // Error is used to pass an error during the request through the
-// application with web specific context.
+// application with web specific context. Code, title and detail default to
+// what the catalog has for the error, see RegisterErrorCode.
 type Error struct {
-	Err    error
-	Status int
-	Fields []FieldError
+	Err      error
+	Status   int
+	Code     string
+	Title    string
+	Detail   string
+	Instance string
+	Fields   []FieldError
 }
 
 // NewRequestError wraps a provided error with an HTTP status code. This
 // function should be used when handlers encounter expected errors.
 func NewRequestError(err error, status int) error {
-	return &Error{err, status, nil}
+	return &Error{Err: err, Status: status}
+}
+
+// NewCodedError wraps a provided error with an HTTP status code and the code
+// clients see. It is used where the catalog entry of the error does not fit,
+// like a generic not found error for something which is not a user.
+func NewCodedError(err error, status int, code ErrorCode) error {
+	return &Error{Err: err, Status: status, Code: code.Code, Title: code.Title}
 }
 
 // Error implements the error interface. It uses the default message of the
@@ -0,0 +1,97 @@
+package web
+
+import (
+	"context"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// DefaultLocale is used for clients accepting none of the supported locales
+// and for messages missing in the locale of a request.
+const DefaultLocale = "en"
+
+// locales are the supported locales, the default first. The matcher prefers
+// earlier ones for clients without a preference.
+var locales = []string{DefaultLocale, "ru"}
+
+var matcher = language.NewMatcher([]language.Tag{
+	language.English,
+	language.Russian,
+})
+
+// messages holds the message catalog by locale and key.
+var messages = make(map[string]map[string]string)
+
+// Locales returns the supported locales.
+func Locales() []string {
+	return append([]string(nil), locales...)
+}
+
+// SupportedLocale reports if the locale is one of the supported ones.
+func SupportedLocale(locale string) bool {
+	for _, l := range locales {
+		if l == locale {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchLocale returns the supported locale best matching the languages of an
+// Accept-Language header.
+func MatchLocale(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return DefaultLocale
+	}
+
+	_, i, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLocale
+	}
+	return locales[i]
+}
+
+// Locale returns the locale of the request being handled with the context.
+func Locale(ctx context.Context) string {
+	v, ok := ctx.Value(KeyValues).(*Values)
+	if !ok || v.Locale == "" {
+		return DefaultLocale
+	}
+	return v.Locale
+}
+
+// RegisterMessages adds messages of the locale to the catalog. Messages may
+// contain placeholders like {field} which are replaced when they are looked
+// up. It is meant to be called during initialization.
+func RegisterMessages(locale string, msgs map[string]string) {
+	m, ok := messages[locale]
+	if !ok {
+		m = make(map[string]string)
+		messages[locale] = m
+	}
+	for k, v := range msgs {
+		m[k] = v
+	}
+}
+
+// Message returns the message of the key in the locale with the placeholders
+// replaced by the arguments, which are pairs of names and values. Messages
+// missing in the locale are taken from the default locale. It reports false
+// if neither has the message.
+func Message(locale, key string, args ...string) (string, bool) {
+	msg, ok := messages[locale][key]
+	if !ok {
+		if msg, ok = messages[DefaultLocale][key]; !ok {
+			return "", false
+		}
+	}
+
+	var pairs []string
+	for i := 0; i+1 < len(args); i += 2 {
+		pairs = append(pairs, "{"+args[i]+"}", args[i+1])
+	}
+
+	return strings.NewReplacer(pairs...).Replace(msg), true
+}
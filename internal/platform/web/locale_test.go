@@ -0,0 +1,127 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/users/internal/platform/logger"
+)
+
+func TestMatchLocale(t *testing.T) {
+	tests := []struct {
+		header string
+		locale string
+	}{
+		{"", "en"},
+		{"ru-RU,ru;q=0.9,en;q=0.8", "ru"},
+		{"de-DE,en;q=0.5", "en"},
+		{"en-GB,ru;q=0.1", "en"},
+		{"fr", "en"},
+		{"not a language", "en"},
+	}
+
+	t.Log("Given the need to select the locale of a request.")
+	{
+		for _, tt := range tests {
+			if got := MatchLocale(tt.header); got != tt.locale {
+				t.Fatalf("\t%s\tShould select %q for %q : got %q.", failed, tt.locale, tt.header, got)
+			}
+		}
+		t.Logf("\t%s\tShould select the best supported locale.", success)
+	}
+}
+
+func TestLocalizedProblem(t *testing.T) {
+	errBroken := errors.New("gadget broken")
+	RegisterErrorCode(errBroken, ErrorCode{Code: "gadget.broken", Title: "Gadget broken"})
+	RegisterMessages("en", map[string]string{"reason.too_shiny": "{field} is too shiny"})
+
+	type request struct {
+		Name  string `json:"name" validate:"required"`
+		Count int    `json:"count" validate:"max=3"`
+		Tag   string `json:"tag" validate:"omitempty,hexcolor"`
+	}
+
+	app := NewApp(make(chan os.Signal, 1), logger.New(os.Stdout, logger.Error))
+	app.Handle(http.MethodPost, "/v1/gadgets/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+		var err error
+		switch params["id"] {
+		case "broken":
+			err = NewRequestError(errBroken, http.StatusConflict)
+		case "shiny":
+			err = &Error{
+				Err:    errors.New("shiny"),
+				Status: http.StatusBadRequest,
+				Fields: []FieldError{{Field: "color", Error: "too_shiny"}, {Field: "size", Error: "too_big"}},
+			}
+		default:
+			var req request
+			err = Decode(r, &req)
+		}
+		return ResponseError(ctx, w, err)
+	})
+
+	serve := func(id, body, acceptLanguage string) (Problem, http.Header) {
+		r := httptest.NewRequest(http.MethodPost, "/v1/gadgets/"+id, strings.NewReader(body))
+		r.Header.Set("Accept-Language", acceptLanguage)
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		var p Problem
+		if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+			t.Fatalf("\t%s\tShould be able to decode the problem : %s.", failed, err)
+		}
+		return p, w.Header()
+	}
+
+	t.Log("Given the need to localize problem details.")
+	{
+		p, h := serve("new", `{"count":5,"tag":"red"}`, "ru")
+		if h.Get("Content-Language") != "ru" || h.Get("Vary") != "Accept-Language" {
+			t.Fatalf("\t%s\tShould name the locale of the response : got %v.", failed, h)
+		}
+		if p.Title != "\u0417\u0430\u043f\u0440\u043e\u0441 \u043d\u0435 \u043f\u0440\u043e\u0448\u0451\u043b \u043f\u0440\u043e\u0432\u0435\u0440\u043a\u0443" {
+			t.Fatalf("\t%s\tShould translate the title : got %q.", failed, p.Title)
+		}
+		want := []string{
+			"name \u043e\u0431\u044f\u0437\u0430\u0442\u0435\u043b\u044c\u043d\u043e\u0435 \u043f\u043e\u043b\u0435",
+			"count \u0434\u043e\u043b\u0436\u043d\u043e \u0431\u044b\u0442\u044c \u043d\u0435 \u0431\u043e\u043b\u044c\u0448\u0435 3",
+			"tag must be a valid HEX color",
+		}
+		if len(p.Fields) != len(want) {
+			t.Fatalf("\t%s\tShould report %d fields : got %+v.", failed, len(want), p.Fields)
+		}
+		for i, f := range p.Fields {
+			if f.Message != want[i] {
+				t.Fatalf("\t%s\tShould translate the field errors : got %q, want %q.", failed, f.Message, want[i])
+			}
+		}
+		t.Logf("\t%s\tShould translate validation errors and fall back to English.", success)
+
+		p, h = serve("new", `{"name":"x","count":5}`, "de")
+		if h.Get("Content-Language") != "en" || p.Title != CodeValidation.Title || p.Fields[0].Message != "count must be 3 or less" {
+			t.Fatalf("\t%s\tShould respond in English to unsupported locales : got %+v.", failed, p)
+		}
+		t.Logf("\t%s\tShould respond in English to unsupported locales.", success)
+
+		p, _ = serve("broken", "", "ru")
+		if p.Title != "Gadget broken" {
+			t.Fatalf("\t%s\tShould keep the title of codes without a translation : got %q.", failed, p.Title)
+		}
+		t.Logf("\t%s\tShould keep the title of codes without a translation.", success)
+
+		p, _ = serve("shiny", "", "ru")
+		if p.Fields[0].Message != "color is too shiny" ||
+			p.Fields[1].Message != "size \u0438\u043c\u0435\u0435\u0442 \u043d\u0435\u0434\u043e\u043f\u0443\u0441\u0442\u0438\u043c\u043e\u0435 \u0437\u043d\u0430\u0447\u0435\u043d\u0438\u0435: too big" {
+			t.Fatalf("\t%s\tShould fall back for reasons without a translation : got %+v.", failed, p.Fields)
+		}
+		t.Logf("\t%s\tShould fall back for reasons without a translation.", success)
+	}
+}
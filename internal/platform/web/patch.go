@@ -0,0 +1,88 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+// ContentTypeMergePatch is the media type of JSON Merge Patch documents as
+// defined by RFC 7386.
+const ContentTypeMergePatch = "application/merge-patch+json"
+
+// DecodePatch applies the JSON Merge Patch in the body of an HTTP request to
+// val, which holds the current state of the resource. Members of the patch
+// replace the ones of the resource, null removes them and members left out
+// are kept. The result is decoded into val and checked for validation tags
+// like Decode does, so a patch cannot set unknown fields either.
+func DecodePatch(r *http.Request, val interface{}) error {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mt != ContentTypeMergePatch {
+		err := errors.Errorf("expected content type %s", ContentTypeMergePatch)
+		return NewRequestError(err, http.StatusUnsupportedMediaType)
+	}
+
+	var patch interface{}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		return NewCodedError(err, http.StatusBadRequest, CodeMalformed)
+	}
+	if _, ok := patch.(map[string]interface{}); !ok {
+		err := errors.New("patch must be a JSON object")
+		return NewCodedError(err, http.StatusBadRequest, CodeMalformed)
+	}
+
+	current, err := json.Marshal(val)
+	if err != nil {
+		return errors.Wrap(err, "encoding current state")
+	}
+	var target interface{}
+	if err := json.Unmarshal(current, &target); err != nil {
+		return errors.Wrap(err, "decoding current state")
+	}
+
+	merged, err := json.Marshal(MergePatch(target, patch))
+	if err != nil {
+		return errors.Wrap(err, "encoding patched state")
+	}
+
+	// Removed members must not keep their current values.
+	if v := reflect.ValueOf(val); v.Kind() == reflect.Ptr && !v.IsNil() {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(merged))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(val); err != nil {
+		return NewCodedError(err, http.StatusBadRequest, CodeMalformed)
+	}
+
+	return Check(val)
+}
+
+// MergePatch applies the patch to the target, both decoded JSON values, as
+// RFC 7386 describes. Objects are merged member by member, any other patch
+// replaces the target.
+func MergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = make(map[string]interface{})
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+			continue
+		}
+		t[k] = MergePatch(t[k], v)
+	}
+
+	return t
+}
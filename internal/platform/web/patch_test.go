@@ -0,0 +1,62 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestDecodePatch(t *testing.T) {
+	type resource struct {
+		Name   string            `json:"name" validate:"required"`
+		Email  string            `json:"email"`
+		Labels map[string]string `json:"labels,omitempty"`
+	}
+	current := resource{Name: "gear", Email: "gear@example.com", Labels: map[string]string{"a": "1", "b": "2"}}
+
+	tests := []struct {
+		contentType string
+		patch       string
+		status      int
+		want        resource
+	}{
+		{ContentTypeMergePatch, `{"email":"cog@example.com"}`, 0, resource{Name: "gear", Email: "cog@example.com", Labels: map[string]string{"a": "1", "b": "2"}}},
+		{ContentTypeMergePatch, `{"email":null,"labels":{"a":null,"c":"3"}}`, 0, resource{Name: "gear", Labels: map[string]string{"b": "2", "c": "3"}}},
+		{ContentTypeMergePatch + "; charset=utf-8", `{}`, 0, current},
+		{ContentTypeMergePatch, `{"name":null}`, http.StatusBadRequest, resource{}},
+		{ContentTypeMergePatch, `{"size":1}`, http.StatusBadRequest, resource{}},
+		{ContentTypeMergePatch, `["name"]`, http.StatusBadRequest, resource{}},
+		{"application/json", `{"email":"cog@example.com"}`, http.StatusUnsupportedMediaType, resource{}},
+	}
+
+	t.Log("Given the need to apply merge patches to resources.")
+	{
+		for i, tt := range tests {
+			r := httptest.NewRequest(http.MethodPatch, "/v2/gadgets/1", strings.NewReader(tt.patch))
+			r.Header.Set("Content-Type", tt.contentType)
+
+			res := current
+			res.Labels = map[string]string{"a": "1", "b": "2"}
+			err := DecodePatch(r, &res)
+
+			if tt.status != 0 {
+				webErr, ok := errors.Cause(err).(*Error)
+				if !ok || webErr.Status != tt.status {
+					t.Fatalf("\t%s\tShould reject patch %d with %d : got %v.", failed, i, tt.status, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("\t%s\tShould apply patch %d : %s.", failed, i, err)
+			}
+			if !reflect.DeepEqual(res, tt.want) {
+				t.Fatalf("\t%s\tShould apply patch %d : got %+v.", failed, i, res)
+			}
+		}
+		t.Logf("\t%s\tShould replace, remove and keep members and reject invalid patches.", success)
+	}
+}
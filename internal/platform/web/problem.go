@@ -0,0 +1,172 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// ContentTypeProblem is the media type of error responses as defined by
+// RFC 7807.
+const ContentTypeProblem = "application/problem+json"
+
+// ProblemTypePrefix prefixes the codes of errors to form the type of
+// problems. The type identifies the kind of problem like the code does.
+const ProblemTypePrefix = "urn:users:problem:"
+
+// Problem is the form used for API responses from failures in the API. It is
+// the problem details object of RFC 7807 extended by the code of the error,
+// the id of the request and the errors of fields.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Fields    []FieldError `json:"fields,omitempty"`
+}
+
+// ErrorCode is a machine readable kind of error clients can branch on, with
+// a short summary for people. Codes are stable, new ones are added but the
+// meaning of existing ones never changes.
+type ErrorCode struct {
+	Code  string
+	Title string
+}
+
+// Codes of errors which are not in the catalog.
+var (
+	CodeValidation = ErrorCode{"request.validation_failed", "Request validation failed"}
+	CodeMalformed  = ErrorCode{"request.malformed", "Malformed request"}
+	CodeInternal   = ErrorCode{"internal", "Internal Server Error"}
+)
+
+// statusCodes are the codes of errors with a status but without an entry
+// in the catalog.
+var statusCodes = map[int]ErrorCode{
+	http.StatusBadRequest:            {"request.invalid", "Bad Request"},
+	http.StatusUnauthorized:          {"auth.unauthenticated", "Unauthorized"},
+	http.StatusForbidden:             {"auth.forbidden", "Forbidden"},
+	http.StatusNotFound:              {"request.not_found", "Not Found"},
+	http.StatusMethodNotAllowed:      {"request.method_not_allowed", "Method Not Allowed"},
+	http.StatusNotAcceptable:         {"request.not_acceptable", "Not Acceptable"},
+	http.StatusConflict:              {"request.conflict", "Conflict"},
+	http.StatusRequestEntityTooLarge: {"request.too_large", "Request Entity Too Large"},
+	http.StatusUnsupportedMediaType:  {"request.unsupported_media_type", "Unsupported Media Type"},
+	http.StatusUnprocessableEntity:   {"request.unprocessable", "Unprocessable Entity"},
+	http.StatusTooManyRequests:       {"request.rate_limited", "Too Many Requests"},
+}
+
+// catalog maps errors to their codes.
+var catalog = make(map[error]ErrorCode)
+
+// RegisterErrorCode adds the error to the catalog of codes. Responses for
+// errors caused by it get the code unless the handler sets another one. It
+// is meant to be called during initialization.
+func RegisterErrorCode(err error, code ErrorCode) {
+	catalog[err] = code
+}
+
+// LookupErrorCode returns the code of the catalog for the error. Errors
+// which are an *Error are looked up themselves and by their cause.
+func LookupErrorCode(err error) (ErrorCode, bool) {
+	if code, ok := lookup(err); ok {
+		return code, true
+	}
+	if webErr, ok := errors.Cause(err).(*Error); ok {
+		return lookup(webErr.Err)
+	}
+	return ErrorCode{}, false
+}
+
+// lookup returns the code of the error or of its cause.
+func lookup(err error) (ErrorCode, bool) {
+	if code, ok := catalog[err]; ok {
+		return code, true
+	}
+	code, ok := catalog[errors.Cause(err)]
+	return code, ok
+}
+
+// NewProblem builds the problem details for the error. Errors which are not
+// an *Error are internal errors, their details are not disclosed.
+func NewProblem(ctx context.Context, err error) Problem {
+	webErr, ok := errors.Cause(err).(*Error)
+	if !ok {
+		webErr = &Error{Err: err, Status: http.StatusInternalServerError}
+	}
+
+	code := ErrorCode{Code: webErr.Code, Title: webErr.Title}
+	if code.Code == "" {
+		var found bool
+		code, found = LookupErrorCode(err)
+		switch {
+		case found:
+		case webErr.Status >= http.StatusInternalServerError:
+			code = CodeInternal
+		case len(webErr.Fields) > 0:
+			code = CodeValidation
+		default:
+			if code, found = statusCodes[webErr.Status]; !found {
+				code = ErrorCode{"request.failed", http.StatusText(webErr.Status)}
+			}
+		}
+	}
+	if code.Title == "" {
+		code.Title = http.StatusText(webErr.Status)
+	}
+
+	locale := Locale(ctx)
+	if title, ok := Message(locale, "code."+code.Code); ok {
+		code.Title = title
+	}
+
+	var fields []FieldError
+	for _, f := range webErr.Fields {
+		f.Message = fieldMessage(locale, f)
+		fields = append(fields, f)
+	}
+
+	p := Problem{
+		Type:     ProblemTypePrefix + code.Code,
+		Title:    code.Title,
+		Status:   webErr.Status,
+		Detail:   webErr.Detail,
+		Instance: webErr.Instance,
+		Code:     code.Code,
+		Fields:   fields,
+	}
+	if p.Status < http.StatusInternalServerError {
+		if p.Detail == "" {
+			p.Detail = webErr.Err.Error()
+		}
+	} else {
+		p.Detail = ""
+	}
+	if v, ok := ctx.Value(KeyValues).(*Values); ok {
+		p.RequestID = v.RequestID
+		if p.Instance == "" {
+			p.Instance = v.Path
+		}
+	}
+
+	return p
+}
+
+// RespondProblem sends the problem details to the client.
+func RespondProblem(ctx context.Context, w http.ResponseWriter, p Problem) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+
+	// Titles and messages are in the locale of the request.
+	w.Header().Set("Content-Language", Locale(ctx))
+	w.Header().Add("Vary", "Accept-Language")
+
+	return RespondRaw(ctx, w, data, ContentTypeProblem, p.Status)
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/users/internal/platform/logger"
+)
+
+func TestProblem(t *testing.T) {
+	errMissing := errors.New("widget not found")
+	RegisterErrorCode(errMissing, ErrorCode{Code: "widget.not_found", Title: "Widget not found"})
+
+	type request struct {
+		Name  string `json:"name" validate:"required"`
+		Count int    `json:"count" validate:"max=3"`
+	}
+
+	app := NewApp(make(chan os.Signal, 1), logger.New(os.Stdout, logger.Error))
+	app.Handle(http.MethodPost, "/v1/widgets/:id", func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+		var err error
+		switch params["id"] {
+		case "missing":
+			err = NewRequestError(errors.Wrap(errMissing, "retrieving widget"), http.StatusNotFound)
+		case "conflict":
+			err = NewRequestError(errors.New("already exists"), http.StatusConflict)
+		case "broken":
+			err = errors.New("connection refused")
+		default:
+			var req request
+			err = Decode(r, &req)
+		}
+		return ResponseError(ctx, w, err)
+	})
+
+	tests := []struct {
+		id     string
+		body   string
+		status int
+		code   string
+		detail string
+		fields int
+	}{
+		{"missing", "", http.StatusNotFound, "widget.not_found", "retrieving widget: widget not found", 0},
+		{"conflict", "", http.StatusConflict, "request.conflict", "already exists", 0},
+		{"broken", "", http.StatusInternalServerError, "internal", "", 0},
+		{"new", `{"count":5}`, http.StatusBadRequest, "request.validation_failed", "field validator error", 2},
+		{"new", `{"name":`, http.StatusBadRequest, "request.malformed", "unexpected EOF", 0},
+	}
+
+	t.Log("Given the need to respond with problem details.")
+	{
+		for _, tt := range tests {
+			r := httptest.NewRequest(http.MethodPost, "/v1/widgets/"+tt.id, strings.NewReader(tt.body))
+			r.Header.Set(RequestIDHeader, "req-1")
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if ct := w.Header().Get("Content-Type"); ct != ContentTypeProblem {
+				t.Fatalf("\t%s\tShould respond with %s : got %q.", failed, ContentTypeProblem, ct)
+			}
+
+			var p Problem
+			if err := json.NewDecoder(w.Body).Decode(&p); err != nil {
+				t.Fatalf("\t%s\tShould be able to decode the problem : %s.", failed, err)
+			}
+
+			if w.Code != tt.status || p.Status != tt.status || p.Code != tt.code || p.Detail != tt.detail {
+				t.Fatalf("\t%s\tShould describe the %s error : got %d %+v.", failed, tt.id, w.Code, p)
+			}
+			if p.Type != ProblemTypePrefix+tt.code || p.Title == "" {
+				t.Fatalf("\t%s\tShould set the type and title : got %+v.", failed, p)
+			}
+			if p.Instance != "/v1/widgets/"+tt.id || p.RequestID != "req-1" {
+				t.Fatalf("\t%s\tShould identify the occurrence : got %+v.", failed, p)
+			}
+			if len(p.Fields) != tt.fields {
+				t.Fatalf("\t%s\tShould report %d fields : got %+v.", failed, tt.fields, p.Fields)
+			}
+			for _, f := range p.Fields {
+				if f.Error == "" || f.Message == "" {
+					t.Fatalf("\t%s\tShould describe the field errors : got %+v.", failed, f)
+				}
+			}
+		}
+		t.Logf("\t%s\tShould respond with problem details.", success)
+	}
+}
@@ -1,12 +1,14 @@
 package web
 
 import (
-	"encoding/json"
 	"errors"
-	validator "gopkg.in/go-playground/validator.v9"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"reflect"
 	"strings"
+
+	validator "gopkg.in/go-playground/validator.v9"
 )
 
 var validate = validator.New()
@@ -23,17 +25,44 @@ func init() {
 	})
 }
 
-// Decode reads the body of an HTTP request looking for a JSON document. The
-// body is decoded into the provided value.
+// RegisterValidation adds a validation for string fields with the tag. It is
+// meant to be called during initialization.
+func RegisterValidation(tag string, fn func(string) bool) error {
+	return validate.RegisterValidation(tag, func(fl validator.FieldLevel) bool {
+		return fn(fl.Field().String())
+	})
+}
+
+// Decode reads the body of an HTTP request with the codec of its
+// Content-Type, JSON when it has none. The body is decoded into the
+// provided value. Bodies of other media types are rejected with 415.
 //
 // If the provided value is a struct then it is checked for validation tags.
 func Decode(r *http.Request, val interface{}) error {
-	decoder := json.NewDecoder(r.Body)
-	decoder.DisallowUnknownFields()
-	if err := decoder.Decode(val); err != nil {
+	codec, ok := codecFor(r.Header.Get("Content-Type"))
+	if !ok {
+		err := errors.New("unsupported content type " + r.Header.Get("Content-Type"))
+		return NewRequestError(err, http.StatusUnsupportedMediaType)
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
 		return NewRequestError(err, http.StatusBadRequest)
 	}
+	if err := codec.Unmarshal(data, val); err != nil {
+		if err == ErrUnsupportedValue {
+			err := errors.New("content type not supported for this request: " + r.Header.Get("Content-Type"))
+			return NewRequestError(err, http.StatusUnsupportedMediaType)
+		}
+		return NewCodedError(err, http.StatusBadRequest, CodeMalformed)
+	}
 
+	return Check(val)
+}
+
+// Check validates the tags of a decoded value, reporting the fields which
+// failed.
+func Check(val interface{}) error {
 	if err := validate.Struct(val); err != nil {
 		verrors, ok := err.(validator.ValidationErrors)
 		if !ok {
@@ -45,6 +74,8 @@ func Decode(r *http.Request, val interface{}) error {
 		for _, verror := range verrors {
 			field := FieldError{
 				Field: verror.Field(),
+				Error: verror.Tag(),
+				verr:  verror,
 			}
 			fields = append(fields, field)
 		}
@@ -57,3 +88,84 @@ func Decode(r *http.Request, val interface{}) error {
 
 	return nil
 }
+
+// ClientIP returns the ip address of the client which made the request. The
+// X-Forwarded-For header is only taken into account when the request comes
+// from one of the trusted proxies. In that case the header is walked from
+// right to left and the first address which is not a trusted proxy is the
+// client.
+func ClientIP(r *http.Request, trusted []*net.IPNet) string {
+	return ForwardedIP(r.RemoteAddr, r.Header["X-Forwarded-For"], trusted)
+}
+
+// ForwardedIP returns the ip address of the client connected from the
+// remote address like ClientIP does, given the values of the
+// X-Forwarded-For header. It serves protocols other than HTTP which carry
+// the header.
+func ForwardedIP(remoteAddr string, forwardedFor []string, trusted []*net.IPNet) string {
+	ip := remoteAddr
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		ip = host
+	}
+
+	if !isTrusted(ip, trusted) {
+		return ip
+	}
+
+	hops := strings.Split(strings.Join(forwardedFor, ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			break
+		}
+		ip = hop
+		if !isTrusted(hop, trusted) {
+			break
+		}
+	}
+
+	return ip
+}
+
+// ParseCIDRs parses a list of CIDR notations. A bare ip address is treated
+// as a single host network.
+func ParseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, errors.New("invalid ip address " + cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func isTrusted(ip string, trusted []*net.IPNet) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, n := range trusted {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header request ids are accepted from, echoed in and
+// propagated with.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestID is the longest request id accepted from clients.
+const maxRequestID = 128
+
+// RequestID returns the id of the request being handled with the context or
+// an empty string outside of requests.
+func RequestID(ctx context.Context) string {
+	v, ok := ctx.Value(KeyValues).(*Values)
+	if !ok {
+		return ""
+	}
+	return v.RequestID
+}
+
+// requestID returns the request id the client or a proxy in front of the
+// service sent.
+func requestID(r *http.Request) string {
+	return ValidRequestID(r.Header.Get(RequestIDHeader))
+}
+
+// ValidRequestID returns the request id received with a request. A new one
+// is generated when there is none or it could be used to forge log lines.
+func ValidRequestID(id string) string {
+	if id == "" || len(id) > maxRequestID {
+		return uuid.New().String()
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return uuid.New().String()
+		}
+	}
+	return id
+}
+
+// Transport is an http.RoundTripper which sends the id of the request being
+// handled with outgoing requests made with its context, so they can be
+// correlated in the logs of other services.
+type Transport struct {
+
+	// Base makes the requests. It defaults to http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+// RoundTrip implements the http.RoundTripper interface.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	if id := RequestID(r.Context()); id != "" && r.Header.Get(RequestIDHeader) == "" {
+		r = r.Clone(r.Context())
+		r.Header.Set(RequestIDHeader, id)
+	}
+
+	return base.RoundTrip(r)
+}
@@ -0,0 +1,73 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/igomonov88/users/internal/platform/logger"
+)
+
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestRequestID(t *testing.T) {
+	log := logger.New(os.Stdout, logger.Error)
+	app := NewApp(make(chan os.Signal, 1), log)
+
+	var seen, forwarded string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = r.Header.Get(RequestIDHeader)
+	}))
+	defer upstream.Close()
+	client := http.Client{Transport: &Transport{}}
+
+	app.Handle(http.MethodGet, "/v1/test", func(ctx context.Context, w http.ResponseWriter, r *http.Request, params map[string]string) error {
+		seen = RequestID(ctx)
+		req, err := http.NewRequest(http.MethodGet, upstream.URL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return Respond(ctx, w, nil, http.StatusNoContent)
+	})
+
+	t.Log("Given the need to correlate requests.")
+	{
+		r := httptest.NewRequest(http.MethodGet, "/v1/test", nil)
+		r.Header.Set(RequestIDHeader, "support-1234")
+		w := httptest.NewRecorder()
+		app.ServeHTTP(w, r)
+
+		if seen != "support-1234" || w.Header().Get(RequestIDHeader) != seen {
+			t.Fatalf("\t%s\tShould accept and echo the request id : got %q and %q.", failed, seen, w.Header().Get(RequestIDHeader))
+		}
+		t.Logf("\t%s\tShould accept and echo the request id.", success)
+
+		if forwarded != seen {
+			t.Fatalf("\t%s\tShould propagate the request id : got %q.", failed, forwarded)
+		}
+		t.Logf("\t%s\tShould propagate the request id.", success)
+
+		for _, id := range []string{"", "forged\nline", strings.Repeat("a", maxRequestID+1)} {
+			r := httptest.NewRequest(http.MethodGet, "/v1/test", nil)
+			r.Header.Set(RequestIDHeader, id)
+			w := httptest.NewRecorder()
+			app.ServeHTTP(w, r)
+
+			if seen == id || len(seen) != 36 || w.Header().Get(RequestIDHeader) != seen {
+				t.Fatalf("\t%s\tShould generate a request id instead of %q : got %q.", failed, id, seen)
+			}
+		}
+		t.Logf("\t%s\tShould generate a request id when there is no usable one.", success)
+	}
+}
@@ -0,0 +1,45 @@
+package web
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseCIDRs([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		remote string
+		xff    []string
+		want   string
+	}{
+		{"direct client", "203.0.113.7:1234", nil, "203.0.113.7"},
+		{"direct client with forged header", "203.0.113.7:1234", []string{"198.51.100.1"}, "203.0.113.7"},
+		{"client behind a trusted proxy", "10.0.0.2:1234", []string{"198.51.100.1"}, "198.51.100.1"},
+		{"client behind trusted proxies", "10.0.0.2:1234", []string{"198.51.100.1, 192.168.1.1", "10.1.2.3"}, "198.51.100.1"},
+		{"client spoofing through a trusted proxy", "10.0.0.2:1234", []string{"1.2.3.4, 198.51.100.1"}, "198.51.100.1"},
+		{"trusted proxy without header", "10.0.0.2:1234", nil, "10.0.0.2"},
+		{"request with a malformed hop", "10.0.0.2:1234", []string{"198.51.100.1, unknown"}, "10.0.0.2"},
+		{"request through trusted hops only", "10.0.0.2:1234", []string{"10.9.9.9"}, "10.9.9.9"},
+	}
+
+	t.Log("Given the need to know the ip address of clients.")
+	{
+		for i, tt := range tests {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			for _, v := range tt.xff {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+
+			if got := ClientIP(r, trusted); got != tt.want {
+				t.Fatalf("\t%s\tTest %d:\tShould find the ip of a %s : got %q, want %q.", failed, i, tt.name, got, tt.want)
+			}
+			t.Logf("\t%s\tTest %d:\tShould find the ip of a %s.", success, i, tt.name)
+		}
+	}
+}
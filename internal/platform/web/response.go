@@ -2,12 +2,12 @@ package web
 
 import (
 	"context"
-	"encoding/json"
-	"github.com/pkg/errors"
 	"net/http"
 )
 
-// Respond converts a Go value to JSON and sends it to the client.
+// Respond encodes a Go value with the codec the client accepts, JSON unless
+// it asks for another one, and sends it to the client. Clients accepting
+// none of the codecs able to encode the value get 406.
 func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statusCode int) error {
 
 	// Set the status code for the request logger middleware.
@@ -25,44 +25,57 @@ func Respond(ctx context.Context, w http.ResponseWriter, data interface{}, statu
 		return nil
 	}
 
-	jsonData, err := json.Marshal(data)
+	contentType, body, err := encode(v.Accept, data)
 	if err != nil {
 		return err
 	}
 	// Set the content_uploader type and headers once we know marshaling has succeeded.
-	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Add("Vary", "Accept")
 
 	// Write the status code to the response.
 	w.WriteHeader(statusCode)
 
-	if _, err := w.Write(jsonData); err != nil {
+	if _, err := w.Write(body); err != nil {
 		return err
 	}
 
 	return nil
 }
 
-// RespondError sends an error reponse back to the client.
-func ResponseError(ctx context.Context, w http.ResponseWriter, err error) error {
-
-	// If the error was of the type *Error, the handler has
-	// a specific status code and error to return.
-	if webErr, ok := errors.Cause(err).(*Error); ok {
-		er := ErrorResponse{
-			Error:  webErr.Error(),
-			Fields: webErr.Fields,
-		}
-		if err := Respond(ctx, w, er, webErr.Status); err != nil {
-			return err
-		}
+// RespondRaw sends already encoded data of the provided content type to the
+// client, for example an HTML page.
+func RespondRaw(ctx context.Context, w http.ResponseWriter, data []byte, contentType string, statusCode int) error {
+	v, ok := ctx.Value(KeyValues).(*Values)
+	if !ok {
+		return NewShutdownError("web value missing from context")
 	}
+	v.StatusCode = statusCode
 
-	// If not, the handler sent any arbitrary error value so use 500.
-	er := ErrorResponse{
-		Error: http.StatusText(http.StatusInternalServerError),
-	}
-	if err := Respond(ctx, w, er, http.StatusInternalServerError); err != nil {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(statusCode)
+
+	if _, err := w.Write(data); err != nil {
 		return err
 	}
+
+	return nil
+}
+
+// Redirect replies to the request with a redirect to url.
+func Redirect(ctx context.Context, w http.ResponseWriter, r *http.Request, url string, statusCode int) error {
+	v, ok := ctx.Value(KeyValues).(*Values)
+	if !ok {
+		return NewShutdownError("web value missing from context")
+	}
+	v.StatusCode = statusCode
+
+	http.Redirect(w, r, url, statusCode)
 	return nil
 }
+
+// ResponseError sends an error response back to the client as problem
+// details.
+func ResponseError(ctx context.Context, w http.ResponseWriter, err error) error {
+	return RespondProblem(ctx, w, NewProblem(ctx, err))
+}
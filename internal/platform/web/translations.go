@@ -0,0 +1,148 @@
+package web
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/ru"
+	ut "github.com/go-playground/universal-translator"
+	validator "gopkg.in/go-playground/validator.v9"
+	entranslations "gopkg.in/go-playground/validator.v9/translations/en"
+)
+
+// uni holds the translators of the validator messages of every supported
+// locale.
+var uni = ut.New(en.New(), en.New(), ru.New())
+
+// ruTranslations are the Russian messages of the validator tags the API
+// uses. Tags checking the length of strings and collections have a second
+// message for those.
+var ruTranslations = []struct {
+	tag     string
+	text    string
+	lenText string
+}{
+	{tag: "required", text: "{0} обязательное поле"},
+	{tag: "len", text: "{0} должно быть равно {1}", lenText: "длина {0} должна быть равна {1}"},
+	{tag: "min", text: "{0} должно быть не меньше {1}", lenText: "длина {0} должна быть не меньше {1}"},
+	{tag: "max", text: "{0} должно быть не больше {1}", lenText: "длина {0} должна быть не больше {1}"},
+	{tag: "oneof", text: "{0} должно быть одним из [{1}]"},
+	{tag: "email", text: "{0} должно быть корректным адресом электронной почты"},
+	{tag: "url", text: "{0} должно быть корректным URL"},
+	{tag: "uuid", text: "{0} должно быть корректным UUID"},
+}
+
+func init() {
+
+	// Let requests choose one of the supported locales.
+	if err := RegisterValidation("locale", SupportedLocale); err != nil {
+		panic(err)
+	}
+
+	trans, _ := uni.GetTranslator("en")
+	if err := entranslations.RegisterDefaultTranslations(validate, trans); err != nil {
+		panic(err)
+	}
+
+	trans, _ = uni.GetTranslator("ru")
+	for _, t := range ruTranslations {
+		if err := validate.RegisterTranslation(t.tag, trans, register(t.tag, t.text, t.lenText), translate(t.tag, t.lenText != "")); err != nil {
+			panic(err)
+		}
+	}
+
+	// Tags the validator has no translations for and the fallbacks of
+	// fields without a message of their own.
+	RegisterMessages("en", map[string]string{
+		"validation.required_without": "{field} is a required field",
+		"validation.locale":           "{field} must be one of the supported locales",
+		"validation.invalid":          "{field} is invalid",
+		"reason.invalid":              "{field} is invalid: {reason}",
+	})
+	RegisterMessages("ru", map[string]string{
+		"validation.required_without": "{field} обязательное поле",
+		"validation.locale":           "{field} должно быть одной из поддерживаемых локалей",
+		"validation.invalid":          "{field} имеет недопустимое значение",
+		"reason.invalid":              "{field} имеет недопустимое значение: {reason}",
+
+		"code." + CodeValidation.Code: "Запрос не прошёл проверку",
+		"code." + CodeMalformed.Code:  "Некорректный запрос",
+		"code." + CodeInternal.Code:   "Внутренняя ошибка сервера",
+
+		"code.request.invalid":                "Некорректный запрос",
+		"code.auth.unauthenticated":           "Требуется аутентификация",
+		"code.auth.forbidden":                 "Доступ запрещён",
+		"code.request.not_found":              "Не найдено",
+		"code.request.method_not_allowed":     "Метод не поддерживается",
+		"code.request.not_acceptable":         "Неприемлемый формат ответа",
+		"code.request.conflict":               "Конфликт",
+		"code.request.too_large":              "Слишком большой запрос",
+		"code.request.unsupported_media_type": "Неподдерживаемый тип данных",
+		"code.request.unprocessable":          "Невозможно обработать запрос",
+		"code.request.rate_limited":           "Слишком много запросов",
+	})
+}
+
+// register returns the function adding the messages of a tag to a
+// translator.
+func register(tag, text, lenText string) validator.RegisterTranslationsFunc {
+	return func(trans ut.Translator) error {
+		if err := trans.Add(tag, text, true); err != nil {
+			return err
+		}
+		if lenText == "" {
+			return nil
+		}
+		return trans.Add(tag+"-len", lenText, true)
+	}
+}
+
+// translate returns the function describing a failed validation of the tag.
+func translate(tag string, hasLen bool) validator.TranslationFunc {
+	return func(trans ut.Translator, fe validator.FieldError) string {
+		key := tag
+		if hasLen {
+			switch fe.Kind() {
+			case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+				key = tag + "-len"
+			}
+		}
+		msg, err := trans.T(key, fe.Field(), fe.Param())
+		if err != nil {
+			return fe.(error).Error()
+		}
+		return msg
+	}
+}
+
+// fieldMessage describes the error of the field in the locale. Failed
+// validations are looked up in the catalog, then with the translators of
+// the locale and the default locale. Reasons handlers set are looked up in
+// the catalog. Anything missing falls back to a generic message.
+func fieldMessage(locale string, f FieldError) string {
+	if f.Message != "" {
+		return f.Message
+	}
+
+	if f.verr != nil {
+		if msg, ok := Message(locale, "validation."+f.Error, "field", f.Field, "param", f.verr.Param()); ok {
+			return msg
+		}
+		for _, l := range []string{locale, DefaultLocale} {
+			if trans, found := uni.GetTranslator(l); found {
+				if msg := f.verr.Translate(trans); msg != f.verr.(error).Error() {
+					return msg
+				}
+			}
+		}
+		msg, _ := Message(locale, "validation.invalid", "field", f.Field)
+		return msg
+	}
+
+	if msg, ok := Message(locale, "reason."+f.Error, "field", f.Field); ok {
+		return msg
+	}
+	msg, _ := Message(locale, "reason.invalid", "field", f.Field, "reason", strings.Replace(f.Error, "_", " ", -1))
+	return msg
+}
@@ -3,11 +3,13 @@ package web
 import (
 	"context"
 	"github.com/dimfeld/httptreemux/v5"
+	"github.com/igomonov88/users/internal/platform/logger"
 	"go.opencensus.io/plugin/ochttp"
 	"go.opencensus.io/plugin/ochttp/propagation/tracecontext"
 	"go.opencensus.io/trace"
 	"net/http"
 	"os"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -21,8 +23,21 @@ const KeyValues ctxKey = 1
 // Values represent state for each request.
 type Values struct {
 	TraceID    string
+	RequestID  string
+	Route      string
+	Path       string
 	Now        time.Time
 	StatusCode int
+	Subject    string
+	Locale     string
+	Accept     string
+}
+
+// Route is a method and path a handler is mounted for. The path is in the
+// form it was registered with like /v1/users/:user_id.
+type Route struct {
+	Method string
+	Path   string
 }
 
 // A Handler is a type that handles an http request within our own little mini
@@ -35,13 +50,16 @@ type App struct {
 	*httptreemux.TreeMux
 	och      *ochttp.Handler
 	shutdown chan os.Signal
+	log      *logger.Logger
 	mw       []Middleware
+	routes   []Route
 }
 
-func NewApp(shutdown chan os.Signal, mw ...Middleware) *App {
+func NewApp(shutdown chan os.Signal, log *logger.Logger, mw ...Middleware) *App {
 	app := App{
 		TreeMux:  httptreemux.New(),
 		shutdown: shutdown,
+		log:      log,
 		mw:       mw,
 	}
 
@@ -83,13 +101,22 @@ func (a *App) Handle(verb, path string, handler Handler, mw ...Middleware) {
 		// Set the context with the required values to
 		// process the request.
 		v := Values{
-			TraceID: span.SpanContext().TraceID.String(),
-			Now:     time.Now(),
+			TraceID:   span.SpanContext().TraceID.String(),
+			RequestID: requestID(r),
+			Route:     verb + " " + path,
+			Path:      r.URL.Path,
+			Locale:    MatchLocale(r.Header.Get("Accept-Language")),
+			Accept:    strings.Join(r.Header["Accept"], ","),
+			Now:       time.Now(),
 		}
 		ctx = context.WithValue(ctx, KeyValues, &v)
 
+		// Let clients quote the id when reporting problems.
+		w.Header().Set(RequestIDHeader, v.RequestID)
+
 		// Call the wrapped handler functions.
 		if err := handler(ctx, w, r, params); err != nil {
+			a.log.Error("web : shutdown requested", "trace_id", v.TraceID, "request_id", v.RequestID, "route", v.Route, "error", err)
 			a.SignalShutDown()
 			return
 		}
@@ -97,6 +124,13 @@ func (a *App) Handle(verb, path string, handler Handler, mw ...Middleware) {
 
 	// Add this handler for the specified verb and route.
 	a.TreeMux.Handle(verb, path, h)
+	a.routes = append(a.routes, Route{Method: verb, Path: path})
+}
+
+// Routes returns the routes handlers were mounted for in the order they were
+// mounted.
+func (a *App) Routes() []Route {
+	return append([]Route(nil), a.routes...)
 }
 
 // ServeHTTP implements the http.Handler interface. It overrides the ServeHTTP
@@ -66,8 +66,246 @@ var migrations = []darwin.Migration{
 	{
 		Version:     6,
 		Description: "Apply trigger on update operations on users table",
-		Script:      `
+		Script: `
 		CREATE TRIGGER users_updated_at BEFORE UPDATE ON users 
 		FOR EACH ROW EXECUTE PROCEDURE updated_at_refresh()`,
 	},
+	{
+		Version:     7,
+		Description: "Add roles column to users table",
+		Script:      `ALTER TABLE users ADD COLUMN roles TEXT[] NOT NULL DEFAULT '{USER}';`,
+	},
+	{
+		Version:     8,
+		Description: "Add login_failures table",
+		Script: `
+		CREATE TABLE IF NOT EXISTS login_failures (
+			login_failure_id BIGSERIAL PRIMARY KEY,
+			email TEXT NOT NULL,
+			ip TEXT NOT NULL,
+			attempted_at TIMESTAMP NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX login_failures_email_idx ON login_failures(email, attempted_at);
+		CREATE INDEX login_failures_ip_idx ON login_failures(ip, attempted_at);`,
+	},
+	{
+		Version:     9,
+		Description: "Add rate_limit_buckets table",
+		Script: `
+		CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+			key TEXT PRIMARY KEY,
+			tokens DOUBLE PRECISION NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		);`,
+	},
+	{
+		Version:     10,
+		Description: "Add two-factor authentication tables",
+		Script: `
+		CREATE TABLE IF NOT EXISTS user_totp (
+			user_id UUID PRIMARY KEY REFERENCES users(user_id) ON DELETE CASCADE,
+			secret BYTEA NOT NULL,
+			last_step BIGINT NOT NULL DEFAULT 0,
+			confirmed_at TIMESTAMP DEFAULT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		);
+		CREATE TABLE IF NOT EXISTS user_recovery_codes (
+			recovery_code_id UUID PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			code_hash TEXT NOT NULL,
+			used_at TIMESTAMP DEFAULT NULL
+		);
+		CREATE INDEX user_recovery_codes_user_idx ON user_recovery_codes(user_id);
+		CREATE TABLE IF NOT EXISTS mfa_challenges (
+			challenge_id UUID PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			expires_at TIMESTAMP NOT NULL
+		);`,
+	},
+	{
+		Version:     11,
+		Description: "Add api_keys table",
+		Script: `
+		CREATE TABLE IF NOT EXISTS api_keys (
+			api_key_id UUID PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			name TEXT NOT NULL,
+			prefix TEXT NOT NULL,
+			secret_hash TEXT NOT NULL,
+			scopes TEXT[] NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			last_used_at TIMESTAMP DEFAULT NULL,
+			last_used_ip TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			revoked_at TIMESTAMP DEFAULT NULL
+		);
+		CREATE UNIQUE INDEX api_keys_prefix_idx ON api_keys(prefix);
+		CREATE INDEX api_keys_user_idx ON api_keys(user_id);`,
+	},
+	{
+		Version:     12,
+		Description: "Add oauth tables",
+		Script: `
+		CREATE TABLE IF NOT EXISTS oauth_clients (
+			client_id TEXT PRIMARY KEY,
+			secret_hash TEXT NOT NULL DEFAULT '',
+			name TEXT NOT NULL,
+			redirect_uris TEXT[] NOT NULL,
+			grant_types TEXT[] NOT NULL,
+			scopes TEXT[] NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		);
+		CREATE TABLE IF NOT EXISTS oauth_codes (
+			code_hash TEXT PRIMARY KEY,
+			client_id TEXT NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			redirect_uri TEXT NOT NULL,
+			scope TEXT NOT NULL,
+			nonce TEXT NOT NULL,
+			code_challenge TEXT NOT NULL,
+			auth_time TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS oauth_refresh_tokens (
+			token_hash TEXT PRIMARY KEY,
+			client_id TEXT NOT NULL REFERENCES oauth_clients(client_id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			scope TEXT NOT NULL,
+			auth_time TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			revoked_at TIMESTAMP DEFAULT NULL
+		);`,
+	},
+	{
+		Version:     13,
+		Description: "Add user identities",
+		Script: `
+		CREATE TABLE IF NOT EXISTS user_identities (
+			provider TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			email TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			last_login_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (provider, subject)
+		);
+		CREATE INDEX user_identities_user_idx ON user_identities(user_id);`,
+	},
+	{
+		Version:     14,
+		Description: "Add sessions",
+		Script: `
+		CREATE TABLE IF NOT EXISTS sessions (
+			session_id UUID PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			user_agent TEXT NOT NULL DEFAULT '',
+			ip TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			last_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			expires_at TIMESTAMP NOT NULL,
+			revoked_at TIMESTAMP DEFAULT NULL
+		);
+		CREATE INDEX sessions_user_idx ON sessions(user_id);`,
+	},
+	{
+		Version:     15,
+		Description: "Add avatar uploads",
+		Script: `
+		CREATE TABLE IF NOT EXISTS avatar_uploads (
+			upload_key TEXT PRIMARY KEY,
+			user_id UUID NOT NULL REFERENCES users(user_id) ON DELETE CASCADE,
+			content_type TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			expires_at TIMESTAMP NOT NULL
+		);
+		CREATE INDEX avatar_uploads_expires_idx ON avatar_uploads(expires_at);`,
+	},
+	{
+		Version:     16,
+		Description: "Add user name skeletons",
+		Script: `
+		ALTER TABLE users ADD COLUMN user_name_skeleton TEXT NOT NULL DEFAULT '';
+		CREATE INDEX users_user_name_skeleton_idx ON users(user_name_skeleton);`,
+	},
+	{
+		Version:     17,
+		Description: "Add user locales",
+		Script: `
+		ALTER TABLE users ADD COLUMN locale TEXT NOT NULL DEFAULT '';`,
+	},
+	{
+		Version:     18,
+		Description: "Add audit entries",
+		Script: `
+		CREATE TABLE IF NOT EXISTS audit_entries (
+			audit_entry_id UUID PRIMARY KEY,
+			user_id UUID NOT NULL,
+			actor_id UUID DEFAULT NULL,
+			action TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX audit_entries_user_idx ON audit_entries(user_id, created_at);`,
+	},
+	{
+		Version:     19,
+		Description: "Add webhooks",
+		Script: `
+		CREATE TABLE IF NOT EXISTS webhooks (
+			webhook_id UUID PRIMARY KEY,
+			url TEXT NOT NULL,
+			events TEXT[] NOT NULL,
+			secret BYTEA NOT NULL,
+			failures INT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			disabled_at TIMESTAMP DEFAULT NULL
+		);
+		CREATE TABLE IF NOT EXISTS webhook_deliveries (
+			delivery_id UUID PRIMARY KEY,
+			webhook_id UUID NOT NULL REFERENCES webhooks(webhook_id) ON DELETE CASCADE,
+			event TEXT NOT NULL,
+			payload BYTEA NOT NULL,
+			state TEXT NOT NULL,
+			attempts INT NOT NULL DEFAULT 0,
+			next_attempt_at TIMESTAMP NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+			delivered_at TIMESTAMP DEFAULT NULL
+		);
+		CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries(next_attempt_at) WHERE state = 'pending';
+		CREATE INDEX webhook_deliveries_webhook_idx ON webhook_deliveries(webhook_id, created_at);
+		CREATE TABLE IF NOT EXISTS webhook_attempts (
+			attempt_id UUID PRIMARY KEY,
+			delivery_id UUID NOT NULL REFERENCES webhook_deliveries(delivery_id) ON DELETE CASCADE,
+			status_code INT NOT NULL DEFAULT 0,
+			error TEXT NOT NULL DEFAULT '',
+			duration_ms INT NOT NULL DEFAULT 0,
+			created_at TIMESTAMP NOT NULL DEFAULT NOW()
+		);
+		CREATE INDEX webhook_attempts_delivery_idx ON webhook_attempts(delivery_id, created_at);`,
+	},
+	{
+		Version:     20,
+		Description: "Add failures to two-factor challenges",
+		Script: `
+		ALTER TABLE mfa_challenges ADD COLUMN failures INT NOT NULL DEFAULT 0;`,
+	},
+	{
+		Version:     21,
+		Description: "Add avatar hashes to users",
+		Script: `
+		ALTER TABLE users ADD COLUMN avatar_hash TEXT NOT NULL DEFAULT '';
+		UPDATE users SET avatar_hash = COALESCE(substring(avatar from '^(?:.*/)?avatars/([^/]+)/'), '');
+		CREATE INDEX users_avatar_hash_idx ON users(avatar_hash);`,
+	},
+	{
+		Version:     22,
+		Description: "Add request ids to webhook deliveries",
+		Script: `
+		ALTER TABLE webhook_deliveries ADD COLUMN request_id TEXT NOT NULL DEFAULT '';`,
+	},
+	{
+		Version:     23,
+		Description: "Add pending login attempts",
+		Script: `
+		ALTER TABLE login_failures ADD COLUMN pending BOOLEAN NOT NULL DEFAULT FALSE;`,
+	},
 }
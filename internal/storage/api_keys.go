@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/auth"
+)
+
+// apiKeyPrefix is prepended to every API key so they are easy to recognize,
+// for example by secret scanners.
+const apiKeyPrefix = "usr"
+
+// keyEncoding is used for the random parts of API keys.
+var keyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// CreateAPIKey creates a new API key for the user. It returns the stored key
+// and the full key value, which is never available again.
+func CreateAPIKey(ctx context.Context, db *sqlx.DB, userID, name string, scopes []string, now, expires time.Time) (*APIKey, string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.CreateAPIKey")
+	defer span.End()
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, "", ErrInvalidUserID
+	}
+
+	prefix, err := randomString(5)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := randomString(20)
+	if err != nil {
+		return nil, "", err
+	}
+
+	k := APIKey{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		Name:       name,
+		Prefix:     prefix,
+		SecretHash: hashSecret(secret),
+		Scopes:     scopes,
+		ExpiresAt:  expires.UTC(),
+		CreatedAt:  now.UTC(),
+	}
+
+	const q = `INSERT INTO api_keys (api_key_id, user_id, name, prefix,
+	secret_hash, scopes, expires_at, created_at) VALUES (:api_key_id, :user_id,
+	:name, :prefix, :secret_hash, :scopes, :expires_at, :created_at);`
+
+	if _, err := db.NamedExecContext(ctx, q, k); err != nil {
+		return nil, "", errors.Wrap(err, "inserting api key")
+	}
+
+	return &k, apiKeyPrefix + "_" + prefix + "_" + secret, nil
+}
+
+// ListAPIKeys gets the API keys of the user which are not revoked.
+func ListAPIKeys(ctx context.Context, db *sqlx.DB, userID string) ([]APIKey, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.ListAPIKeys")
+	defer span.End()
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, ErrInvalidUserID
+	}
+
+	const q = `SELECT * FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL
+	ORDER BY created_at;`
+
+	var keys []APIKey
+	if err := db.SelectContext(ctx, &keys, q, userID); err != nil {
+		return nil, errors.Wrapf(err, "selecting api keys %q", userID)
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey revokes the API key of the user. It returns ErrNotFound if
+// the user has no such key.
+func RevokeAPIKey(ctx context.Context, db *sqlx.DB, userID, keyID string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.RevokeAPIKey")
+	defer span.End()
+
+	if _, err := uuid.Parse(keyID); err != nil {
+		return ErrNotFound
+	}
+
+	const q = `UPDATE api_keys SET revoked_at = $3
+	WHERE api_key_id = $1 AND user_id = $2 AND revoked_at IS NULL;`
+
+	res, err := db.ExecContext(ctx, q, keyID, userID, now.UTC())
+	if err != nil {
+		return errors.Wrapf(err, "revoking api key %q", keyID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "revoking api key %q", keyID)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// AuthenticateAPIKey finds the API key and verifies its secret. On success it
+// records the use and returns Claims representing the owner of the key
+// limited to the scopes of the key.
+func AuthenticateAPIKey(ctx context.Context, db *sqlx.DB, key, ip string, now time.Time) (auth.Claims, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.AuthenticateAPIKey")
+	defer span.End()
+
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != apiKeyPrefix {
+		return auth.Claims{}, ErrAuthenticationFailure
+	}
+
+	const q = `SELECT * FROM api_keys WHERE prefix = $1;`
+
+	var k APIKey
+	if err := db.GetContext(ctx, &k, q, parts[1]); err != nil {
+		if err == sql.ErrNoRows {
+			return auth.Claims{}, ErrAuthenticationFailure
+		}
+
+		return auth.Claims{}, errors.Wrap(err, "selecting api key")
+	}
+
+	hash := hashSecret(parts[2])
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(k.SecretHash)) != 1 {
+		return auth.Claims{}, ErrAuthenticationFailure
+	}
+
+	if k.RevokedAt.Valid || !now.Before(k.ExpiresAt) {
+		return auth.Claims{}, ErrAuthenticationFailure
+	}
+
+	u, err := Retrieve(ctx, db, k.UserID)
+	if err != nil {
+		return auth.Claims{}, errors.Wrap(err, "retrieving api key owner")
+	}
+
+	const used = `UPDATE api_keys SET last_used_at = $2, last_used_ip = $3
+	WHERE api_key_id = $1;`
+
+	if _, err := db.ExecContext(ctx, used, k.ID, now.UTC(), ip); err != nil {
+		return auth.Claims{}, errors.Wrapf(err, "updating api key %q", k.ID)
+	}
+
+	claims := NewClaims(u, now)
+	claims.Scopes = k.Scopes
+	if claims.ExpiresAt > k.ExpiresAt.Unix() {
+		claims.ExpiresAt = k.ExpiresAt.Unix()
+	}
+
+	return claims, nil
+}
+
+// randomString returns n random bytes encoded in lower case base32.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "reading random bytes")
+	}
+	return strings.ToLower(keyEncoding.EncodeToString(b)), nil
+}
+
+// hashSecret returns the hex encoded SHA-256 of the secret. The secrets are
+// random and long so a slow password hash is not needed.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
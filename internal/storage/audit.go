@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// These are the actions audit entries are recorded for.
+const (
+	AuditUserCreated     = "user.created"
+	AuditUserUpdated     = "user.updated"
+	AuditUserDeleted     = "user.deleted"
+	AuditPasswordChanged = "user.password_changed"
+	AuditLogin           = "user.login"
+)
+
+// CreateAuditEntry records an action on the account of the user. The actor
+// is empty for anonymous requests.
+func CreateAuditEntry(ctx context.Context, db *sqlx.DB, userID, actorID, action string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.CreateAuditEntry")
+	defer span.End()
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return ErrInvalidUserID
+	}
+
+	e := AuditEntry{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		ActorID:   sql.NullString{String: actorID, Valid: actorID != ""},
+		Action:    action,
+		CreatedAt: now.UTC(),
+	}
+
+	const q = `INSERT INTO audit_entries (audit_entry_id, user_id, actor_id,
+	action, created_at) VALUES (:audit_entry_id, :user_id, :actor_id, :action,
+	:created_at);`
+
+	if _, err := db.NamedExecContext(ctx, q, e); err != nil {
+		return errors.Wrap(err, "inserting audit entry")
+	}
+
+	return nil
+}
+
+// ListAuditEntries gets the latest audit entries of each of the users, up to
+// the limit per user, the most recent first.
+func ListAuditEntries(ctx context.Context, db *sqlx.DB, userIDs []string, limit int) ([]AuditEntry, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.ListAuditEntries")
+	defer span.End()
+
+	for _, id := range userIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, ErrInvalidUserID
+		}
+	}
+
+	const q = `SELECT audit_entry_id, user_id, actor_id, action, created_at
+	FROM (
+		SELECT *, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY created_at DESC) AS n
+		FROM audit_entries WHERE user_id = ANY($1)
+	) AS e
+	WHERE n <= $2
+	ORDER BY user_id, created_at DESC;`
+
+	var entries []AuditEntry
+	if err := db.SelectContext(ctx, &entries, q, pq.Array(userIDs), limit); err != nil {
+		return nil, errors.Wrap(err, "selecting audit entries")
+	}
+
+	return entries, nil
+}
@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// CreateAvatarUpload records an upload the user is allowed to make until the
+// expiration time.
+func CreateAvatarUpload(ctx context.Context, db *sqlx.DB, userID, key, contentType string, now, expires time.Time) (*AvatarUpload, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.CreateAvatarUpload")
+	defer span.End()
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, ErrInvalidUserID
+	}
+
+	au := AvatarUpload{
+		Key:         key,
+		UserID:      userID,
+		ContentType: contentType,
+		CreatedAt:   now.UTC(),
+		ExpiresAt:   expires.UTC(),
+	}
+
+	const q = `INSERT INTO avatar_uploads (upload_key, user_id, content_type,
+	created_at, expires_at) VALUES (:upload_key, :user_id, :content_type,
+	:created_at, :expires_at);`
+
+	if _, err := db.NamedExecContext(ctx, q, au); err != nil {
+		return nil, errors.Wrap(err, "inserting avatar upload")
+	}
+
+	return &au, nil
+}
+
+// RetrieveAvatarUpload gets the upload with the key of the user. Uploads of
+// other users are not found.
+func RetrieveAvatarUpload(ctx context.Context, db *sqlx.DB, userID, key string) (*AvatarUpload, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.RetrieveAvatarUpload")
+	defer span.End()
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, ErrInvalidUserID
+	}
+
+	const q = `SELECT * FROM avatar_uploads WHERE upload_key = $1 AND user_id = $2;`
+
+	var au AvatarUpload
+	if err := db.GetContext(ctx, &au, q, key, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+
+		return nil, errors.Wrapf(err, "selecting avatar upload %q", key)
+	}
+
+	return &au, nil
+}
+
+// ListExpiredAvatarUploads gets the uploads which expired before the time.
+func ListExpiredAvatarUploads(ctx context.Context, db *sqlx.DB, before time.Time) ([]AvatarUpload, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.ListExpiredAvatarUploads")
+	defer span.End()
+
+	const q = `SELECT * FROM avatar_uploads WHERE expires_at < $1 ORDER BY expires_at;`
+
+	var uploads []AvatarUpload
+	if err := db.SelectContext(ctx, &uploads, q, before.UTC()); err != nil {
+		return nil, errors.Wrap(err, "selecting expired avatar uploads")
+	}
+
+	return uploads, nil
+}
+
+// DeleteAvatarUpload removes the record of the upload with the key.
+func DeleteAvatarUpload(ctx context.Context, db *sqlx.DB, key string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.DeleteAvatarUpload")
+	defer span.End()
+
+	const q = `DELETE FROM avatar_uploads WHERE upload_key = $1;`
+
+	if _, err := db.ExecContext(ctx, q, key); err != nil {
+		return errors.Wrapf(err, "deleting avatar upload %q", key)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// ErrIdentityAlreadyLinked is used when an external identity is linked to
+// another user already.
+var ErrIdentityAlreadyLinked = errors.New("Identity already linked")
+
+// RetrieveIdentity gets the identity of the provider with the subject from
+// the database.
+func RetrieveIdentity(ctx context.Context, db *sqlx.DB, provider, subject string) (*Identity, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.RetrieveIdentity")
+	defer span.End()
+
+	const q = `SELECT * FROM user_identities WHERE provider = $1 AND subject = $2;`
+
+	var i Identity
+	if err := db.GetContext(ctx, &i, q, provider, subject); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+
+		return nil, errors.Wrapf(err, "selecting identity %q", provider)
+	}
+
+	return &i, nil
+}
+
+// ListIdentities gets the identities linked to the user.
+func ListIdentities(ctx context.Context, db *sqlx.DB, userID string) ([]Identity, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.ListIdentities")
+	defer span.End()
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, ErrInvalidUserID
+	}
+
+	const q = `SELECT * FROM user_identities WHERE user_id = $1 ORDER BY created_at;`
+
+	var ids []Identity
+	if err := db.SelectContext(ctx, &ids, q, userID); err != nil {
+		return nil, errors.Wrapf(err, "selecting identities %q", userID)
+	}
+
+	return ids, nil
+}
+
+// LinkIdentity links the identity of the provider to the user. It returns
+// ErrIdentityAlreadyLinked if the identity belongs to another user.
+func LinkIdentity(ctx context.Context, db *sqlx.DB, provider, subject, userID, email string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.LinkIdentity")
+	defer span.End()
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return ErrInvalidUserID
+	}
+
+	const q = `INSERT INTO user_identities (provider, subject, user_id, email,
+	created_at, last_login_at) VALUES ($1, $2, $3, $4, $5, $5)
+	ON CONFLICT (provider, subject) DO NOTHING;`
+
+	res, err := db.ExecContext(ctx, q, provider, subject, userID, email, now.UTC())
+	if err != nil {
+		return errors.Wrapf(err, "inserting identity %q", provider)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "inserting identity %q", provider)
+	}
+	if n == 0 {
+		return ErrIdentityAlreadyLinked
+	}
+
+	return nil
+}
+
+// TouchIdentity records a login with the identity and updates the email the
+// provider knows the user by.
+func TouchIdentity(ctx context.Context, db *sqlx.DB, provider, subject, email string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.TouchIdentity")
+	defer span.End()
+
+	const q = `UPDATE user_identities SET email = $3, last_login_at = $4
+	WHERE provider = $1 AND subject = $2;`
+
+	if _, err := db.ExecContext(ctx, q, provider, subject, email, now.UTC()); err != nil {
+		return errors.Wrapf(err, "updating identity %q", provider)
+	}
+
+	return nil
+}
+
+// UnlinkIdentity removes the identity of the provider from the user. It
+// returns ErrNotFound if the user has no such identity.
+func UnlinkIdentity(ctx context.Context, db *sqlx.DB, userID, provider string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.UnlinkIdentity")
+	defer span.End()
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return ErrInvalidUserID
+	}
+
+	const q = `DELETE FROM user_identities WHERE user_id = $1 AND provider = $2;`
+
+	res, err := db.ExecContext(ctx, q, userID, provider)
+	if err != nil {
+		return errors.Wrapf(err, "deleting identity %q", provider)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "deleting identity %q", provider)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// LoginFailures represents the failed login attempts made for a single
+// account or from a single source ip within a window of time. Attempts which
+// are still being checked count as failures.
+type LoginFailures struct {
+	Count int       `db:"count"`
+	Last  time.Time `db:"last"`
+}
+
+// LockLoginAttempts runs fn while holding the locks of the email and the
+// ip, so the attempts for either are checked and recorded one at a time.
+func LockLoginAttempts(ctx context.Context, db *sqlx.DB, email, ip string, fn func() error) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.LockLoginAttempts")
+	defer span.End()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	// The locks are always taken in the same order, so attempts for the same
+	// ip can't deadlock.
+	const q = `SELECT pg_advisory_xact_lock(hashtext($1));`
+
+	for _, key := range []string{"login:email:" + email, "login:ip:" + ip} {
+		if _, err := tx.ExecContext(ctx, q, key); err != nil {
+			return errors.Wrapf(err, "locking login attempts %q", key)
+		}
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// RecordLoginAttempt stores a pending login attempt for the given email made
+// from the given ip. It counts like a failure until it is recorded as one or
+// the failures of the email are cleared. Attempts which are older than the
+// provided time are removed for both the email and the ip, so the table only
+// holds the sliding window which is still relevant.
+func RecordLoginAttempt(ctx context.Context, db *sqlx.DB, email, ip string, now, since time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.RecordLoginAttempt")
+	defer span.End()
+
+	if err := pruneLoginFailures(ctx, db, email, ip, since); err != nil {
+		return err
+	}
+
+	const q = `INSERT INTO login_failures (email, ip, attempted_at, pending)
+	VALUES ($1, $2, $3, TRUE);`
+
+	if _, err := db.ExecContext(ctx, q, email, ip, now.UTC()); err != nil {
+		return errors.Wrapf(err, "inserting login attempt %q", email)
+	}
+
+	return nil
+}
+
+// RecordLoginFailure stores a failed login attempt for the given email made
+// from the given ip. The latest pending attempt of the email and the ip is
+// the one which failed, a failure is inserted when there is none. Attempts
+// which are older than the provided time are removed like with
+// RecordLoginAttempt.
+func RecordLoginFailure(ctx context.Context, db *sqlx.DB, email, ip string, now, since time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.RecordLoginFailure")
+	defer span.End()
+
+	if err := pruneLoginFailures(ctx, db, email, ip, since); err != nil {
+		return err
+	}
+
+	const u = `UPDATE login_failures SET pending = FALSE
+	WHERE pending AND login_failure_id = (SELECT login_failure_id FROM
+	login_failures WHERE email = $1 AND ip = $2 AND pending
+	ORDER BY attempted_at DESC LIMIT 1);`
+
+	res, err := db.ExecContext(ctx, u, email, ip)
+	if err != nil {
+		return errors.Wrapf(err, "updating login attempt %q", email)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "updating login attempt %q", email)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	const q = `INSERT INTO login_failures (email, ip, attempted_at)
+	VALUES ($1, $2, $3);`
+
+	if _, err := db.ExecContext(ctx, q, email, ip, now.UTC()); err != nil {
+		return errors.Wrapf(err, "inserting login failure %q", email)
+	}
+
+	return nil
+}
+
+// pruneLoginFailures removes the attempts for the email and the ip which are
+// older than the provided time.
+func pruneLoginFailures(ctx context.Context, db *sqlx.DB, email, ip string, since time.Time) error {
+	const q = `DELETE FROM login_failures WHERE (email = $1 OR ip = $2)
+	AND attempted_at <= $3;`
+
+	if _, err := db.ExecContext(ctx, q, email, ip, since.UTC()); err != nil {
+		return errors.Wrapf(err, "pruning login failures %q", email)
+	}
+
+	return nil
+}
+
+// AccountLoginFailures returns the failed login attempts made for the given
+// email after the provided time. Pending attempts only count when they were
+// made after pendingSince.
+func AccountLoginFailures(ctx context.Context, db *sqlx.DB, email string, since, pendingSince time.Time) (LoginFailures, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.AccountLoginFailures")
+	defer span.End()
+
+	const q = `SELECT COUNT(*) AS count, COALESCE(MAX(attempted_at), 'epoch') AS last
+	FROM login_failures WHERE email = $1 AND attempted_at > $2
+	AND (NOT pending OR attempted_at > $3);`
+
+	var lf LoginFailures
+	if err := db.GetContext(ctx, &lf, q, email, since.UTC(), pendingSince.UTC()); err != nil {
+		return LoginFailures{}, errors.Wrapf(err, "selecting login failures %q", email)
+	}
+
+	return lf, nil
+}
+
+// IPLoginFailures returns the failed login attempts made from the given ip
+// after the provided time. Pending attempts only count when they were made
+// after pendingSince.
+func IPLoginFailures(ctx context.Context, db *sqlx.DB, ip string, since, pendingSince time.Time) (LoginFailures, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.IPLoginFailures")
+	defer span.End()
+
+	const q = `SELECT COUNT(*) AS count, COALESCE(MAX(attempted_at), 'epoch') AS last
+	FROM login_failures WHERE ip = $1 AND attempted_at > $2
+	AND (NOT pending OR attempted_at > $3);`
+
+	var lf LoginFailures
+	if err := db.GetContext(ctx, &lf, q, ip, since.UTC(), pendingSince.UTC()); err != nil {
+		return LoginFailures{}, errors.Wrapf(err, "selecting login failures %q", ip)
+	}
+
+	return lf, nil
+}
+
+// ClearLoginFailures removes all failed login attempts recorded for the
+// given email. It is used after a successful login and to unlock an account.
+func ClearLoginFailures(ctx context.Context, db *sqlx.DB, email string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.ClearLoginFailures")
+	defer span.End()
+
+	const q = `DELETE FROM login_failures WHERE email = $1;`
+
+	if _, err := db.ExecContext(ctx, q, email); err != nil {
+		return errors.Wrapf(err, "deleting login failures %q", email)
+	}
+
+	return nil
+}
@@ -0,0 +1,287 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+var (
+	// ErrTOTPAlreadyConfirmed is used when a user tries to enroll a new
+	// secret while a confirmed one exists.
+	ErrTOTPAlreadyConfirmed = errors.New("Two-factor authentication already enabled")
+
+	// ErrStepAlreadyUsed is used when a one-time password for a time step
+	// which is not after the last used one is redeemed.
+	ErrStepAlreadyUsed = errors.New("One-time password already used")
+)
+
+// SaveTOTP stores a new unconfirmed secret for the user. A previous
+// unconfirmed secret is replaced.
+func SaveTOTP(ctx context.Context, db *sqlx.DB, userID string, secret []byte, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.SaveTOTP")
+	defer span.End()
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return ErrInvalidUserID
+	}
+
+	const q = `INSERT INTO user_totp (user_id, secret, last_step, confirmed_at, created_at)
+	VALUES ($1, $2, 0, NULL, $3)
+	ON CONFLICT (user_id) DO UPDATE SET secret = $2, last_step = 0, created_at = $3
+	WHERE user_totp.confirmed_at IS NULL;`
+
+	res, err := db.ExecContext(ctx, q, userID, secret, now.UTC())
+	if err != nil {
+		return errors.Wrapf(err, "saving totp %q", userID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "saving totp %q", userID)
+	}
+	if n == 0 {
+		return ErrTOTPAlreadyConfirmed
+	}
+
+	return nil
+}
+
+// RetrieveTOTP gets the secret of the user from the database.
+func RetrieveTOTP(ctx context.Context, db *sqlx.DB, userID string) (*TOTP, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.RetrieveTOTP")
+	defer span.End()
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, ErrInvalidUserID
+	}
+
+	const q = `SELECT * FROM user_totp WHERE user_id = $1;`
+
+	var t TOTP
+	if err := db.GetContext(ctx, &t, q, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+
+		return nil, errors.Wrapf(err, "selecting totp %q", userID)
+	}
+
+	return &t, nil
+}
+
+// UseTOTPStep records the time step of a redeemed one-time password. It
+// returns ErrStepAlreadyUsed if the step is not after the last used one so
+// every code can be used only once, even by concurrent requests. The secret
+// is confirmed by the first redeemed step.
+func UseTOTPStep(ctx context.Context, db *sqlx.DB, userID string, step int64, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.UseTOTPStep")
+	defer span.End()
+
+	const q = `UPDATE user_totp SET last_step = $2,
+	confirmed_at = COALESCE(confirmed_at, $3)
+	WHERE user_id = $1 AND last_step < $2;`
+
+	res, err := db.ExecContext(ctx, q, userID, step, now.UTC())
+	if err != nil {
+		return errors.Wrapf(err, "updating totp step %q", userID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "updating totp step %q", userID)
+	}
+	if n == 0 {
+		return ErrStepAlreadyUsed
+	}
+
+	return nil
+}
+
+// DeleteTOTP removes the secret and the recovery codes of the user.
+func DeleteTOTP(ctx context.Context, db *sqlx.DB, userID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.DeleteTOTP")
+	defer span.End()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1;`, userID); err != nil {
+		return errors.Wrapf(err, "deleting recovery codes %q", userID)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_totp WHERE user_id = $1;`, userID); err != nil {
+		return errors.Wrapf(err, "deleting totp %q", userID)
+	}
+
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes replaces all recovery codes of the user with the
+// provided hashes.
+func ReplaceRecoveryCodes(ctx context.Context, db *sqlx.DB, userID string, hashes [][]byte) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.ReplaceRecoveryCodes")
+	defer span.End()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM user_recovery_codes WHERE user_id = $1;`, userID); err != nil {
+		return errors.Wrapf(err, "deleting recovery codes %q", userID)
+	}
+
+	const q = `INSERT INTO user_recovery_codes (recovery_code_id, user_id, code_hash)
+	VALUES ($1, $2, $3);`
+
+	for _, hash := range hashes {
+		if _, err := tx.ExecContext(ctx, q, uuid.New().String(), userID, string(hash)); err != nil {
+			return errors.Wrapf(err, "inserting recovery code %q", userID)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UnusedRecoveryCodes gets the recovery codes of the user which were not
+// used yet.
+func UnusedRecoveryCodes(ctx context.Context, db *sqlx.DB, userID string) ([]RecoveryCode, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.UnusedRecoveryCodes")
+	defer span.End()
+
+	const q = `SELECT * FROM user_recovery_codes WHERE user_id = $1 AND used_at IS NULL;`
+
+	var codes []RecoveryCode
+	if err := db.SelectContext(ctx, &codes, q, userID); err != nil {
+		return nil, errors.Wrapf(err, "selecting recovery codes %q", userID)
+	}
+
+	return codes, nil
+}
+
+// UseRecoveryCode marks the recovery code as used. It returns ErrNotFound
+// if the code was already used.
+func UseRecoveryCode(ctx context.Context, db *sqlx.DB, codeID string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.UseRecoveryCode")
+	defer span.End()
+
+	const q = `UPDATE user_recovery_codes SET used_at = $2
+	WHERE recovery_code_id = $1 AND used_at IS NULL;`
+
+	res, err := db.ExecContext(ctx, q, codeID, now.UTC())
+	if err != nil {
+		return errors.Wrapf(err, "using recovery code %q", codeID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "using recovery code %q", codeID)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CreateMFAChallenge stores a challenge which allows the user to finish
+// logging in with a second factor until the expiration time.
+func CreateMFAChallenge(ctx context.Context, db *sqlx.DB, userID string, expires time.Time) (string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.CreateMFAChallenge")
+	defer span.End()
+
+	const q = `INSERT INTO mfa_challenges (challenge_id, user_id, expires_at)
+	VALUES ($1, $2, $3);`
+
+	id := uuid.New().String()
+	if _, err := db.ExecContext(ctx, q, id, userID, expires.UTC()); err != nil {
+		return "", errors.Wrapf(err, "inserting challenge %q", userID)
+	}
+
+	return id, nil
+}
+
+// RetrieveMFAChallenge returns the id of the user the challenge was created
+// for. It returns ErrNotFound for unknown and expired challenges.
+func RetrieveMFAChallenge(ctx context.Context, db *sqlx.DB, challengeID string, now time.Time) (string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.RetrieveMFAChallenge")
+	defer span.End()
+
+	if _, err := uuid.Parse(challengeID); err != nil {
+		return "", ErrNotFound
+	}
+
+	const q = `SELECT user_id FROM mfa_challenges
+	WHERE challenge_id = $1 AND expires_at > $2;`
+
+	var userID string
+	if err := db.GetContext(ctx, &userID, q, challengeID, now.UTC()); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrNotFound
+		}
+
+		return "", errors.Wrapf(err, "selecting challenge %q", challengeID)
+	}
+
+	return userID, nil
+}
+
+// FailMFAChallenge counts a wrong answer to the challenge and removes it once
+// it was answered wrong the maximum number of times. Unknown challenges are
+// ignored.
+func FailMFAChallenge(ctx context.Context, db *sqlx.DB, challengeID string, max int) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.FailMFAChallenge")
+	defer span.End()
+
+	if _, err := uuid.Parse(challengeID); err != nil {
+		return nil
+	}
+
+	const q = `UPDATE mfa_challenges SET failures = failures + 1
+	WHERE challenge_id = $1 RETURNING failures;`
+
+	var failures int
+	if err := db.GetContext(ctx, &failures, q, challengeID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+
+		return errors.Wrapf(err, "updating challenge %q", challengeID)
+	}
+
+	if failures < max {
+		return nil
+	}
+
+	const d = `DELETE FROM mfa_challenges WHERE challenge_id = $1;`
+
+	if _, err := db.ExecContext(ctx, d, challengeID); err != nil {
+		return errors.Wrapf(err, "deleting challenge %q", challengeID)
+	}
+
+	return nil
+}
+
+// DeleteMFAChallenge removes the challenge and all expired challenges.
+func DeleteMFAChallenge(ctx context.Context, db *sqlx.DB, challengeID string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.DeleteMFAChallenge")
+	defer span.End()
+
+	const q = `DELETE FROM mfa_challenges WHERE challenge_id = $1 OR expires_at <= $2;`
+
+	if _, err := db.ExecContext(ctx, q, challengeID, now.UTC()); err != nil {
+		return errors.Wrapf(err, "deleting challenge %q", challengeID)
+	}
+
+	return nil
+}
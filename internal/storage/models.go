@@ -1,17 +1,168 @@
 package storage
 
 import (
+	"database/sql"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 // User represents someone with access to our system.
 type User struct {
-	ID           string    `db:"user_id"`
-	Name         string    `db:"user_name"`
-	Email        string    `db:"email"`
-	PasswordHash []byte    `db:"password_hash"`
-	Avatar       string    `db:"avatar"`
-	CreatedAt    time.Time `db:"created_at"`
-	UpdatedAt    time.Time `db:"updated_at"`
-	DeletedAt    time.Time `db:"deleted_at"`
+	ID           string         `db:"user_id"`
+	Name         string         `db:"user_name"`
+	NameSkeleton string         `db:"user_name_skeleton"`
+	Email        string         `db:"email"`
+	PasswordHash []byte         `db:"password_hash"`
+	Avatar       string         `db:"avatar"`
+	AvatarHash   string         `db:"avatar_hash"`
+	Locale       string         `db:"locale"`
+	Roles        pq.StringArray `db:"roles"`
+	CreatedAt    time.Time      `db:"created_at"`
+	UpdatedAt    time.Time      `db:"updated_at"`
+	DeletedAt    time.Time      `db:"deleted_at"`
+}
+
+// TOTP represents the time-based one-time password secret of a user. The
+// secret is stored encrypted.
+type TOTP struct {
+	UserID      string      `db:"user_id"`
+	Secret      []byte      `db:"secret"`
+	LastStep    int64       `db:"last_step"`
+	ConfirmedAt pq.NullTime `db:"confirmed_at"`
+	CreatedAt   time.Time   `db:"created_at"`
+}
+
+// RecoveryCode represents a hashed one-time recovery code of a user.
+type RecoveryCode struct {
+	ID       string      `db:"recovery_code_id"`
+	UserID   string      `db:"user_id"`
+	CodeHash []byte      `db:"code_hash"`
+	UsedAt   pq.NullTime `db:"used_at"`
+}
+
+// APIKey represents a named key a user created to call the API from scripts.
+// Only the hash of the secret is stored.
+type APIKey struct {
+	ID         string         `db:"api_key_id"`
+	UserID     string         `db:"user_id"`
+	Name       string         `db:"name"`
+	Prefix     string         `db:"prefix"`
+	SecretHash string         `db:"secret_hash"`
+	Scopes     pq.StringArray `db:"scopes"`
+	ExpiresAt  time.Time      `db:"expires_at"`
+	LastUsedAt pq.NullTime    `db:"last_used_at"`
+	LastUsedIP string         `db:"last_used_ip"`
+	CreatedAt  time.Time      `db:"created_at"`
+	RevokedAt  pq.NullTime    `db:"revoked_at"`
+}
+
+// OAuthClient represents an application which signs users in with this
+// service. Public clients have no secret.
+type OAuthClient struct {
+	ID           string         `db:"client_id"`
+	SecretHash   string         `db:"secret_hash"`
+	Name         string         `db:"name"`
+	RedirectURIs pq.StringArray `db:"redirect_uris"`
+	GrantTypes   pq.StringArray `db:"grant_types"`
+	Scopes       pq.StringArray `db:"scopes"`
+	CreatedAt    time.Time      `db:"created_at"`
+}
+
+// OAuthGrant represents what a user granted to a client. It is stored for
+// authorization codes and refresh tokens.
+type OAuthGrant struct {
+	ClientID      string    `db:"client_id"`
+	UserID        string    `db:"user_id"`
+	RedirectURI   string    `db:"redirect_uri"`
+	Scope         string    `db:"scope"`
+	Nonce         string    `db:"nonce"`
+	CodeChallenge string    `db:"code_challenge"`
+	AuthTime      time.Time `db:"auth_time"`
+	ExpiresAt     time.Time `db:"expires_at"`
+}
+
+// Identity links a user of an external identity provider to a local user.
+type Identity struct {
+	Provider    string    `db:"provider"`
+	Subject     string    `db:"subject"`
+	UserID      string    `db:"user_id"`
+	Email       string    `db:"email"`
+	CreatedAt   time.Time `db:"created_at"`
+	LastLoginAt time.Time `db:"last_login_at"`
+}
+
+// Session represents a login of a user on a device. Every issued token
+// belongs to a session and is rejected once the session is revoked.
+type Session struct {
+	ID         string      `db:"session_id"`
+	UserID     string      `db:"user_id"`
+	UserAgent  string      `db:"user_agent"`
+	IP         string      `db:"ip"`
+	CreatedAt  time.Time   `db:"created_at"`
+	LastSeenAt time.Time   `db:"last_seen_at"`
+	ExpiresAt  time.Time   `db:"expires_at"`
+	RevokedAt  pq.NullTime `db:"revoked_at"`
+}
+
+// AuditEntry represents something which happened to the account of a user.
+// The actor is the user who did it, it is not set for anonymous requests.
+// Entries outlive the users they are about.
+type AuditEntry struct {
+	ID        string         `db:"audit_entry_id"`
+	UserID    string         `db:"user_id"`
+	ActorID   sql.NullString `db:"actor_id"`
+	Action    string         `db:"action"`
+	CreatedAt time.Time      `db:"created_at"`
+}
+
+// Webhook represents a subscription of a URL to events. The secret payloads
+// are signed with is stored encrypted. Failures counts the failed attempts
+// since the last successful one, subscriptions are disabled once it gets
+// too high.
+type Webhook struct {
+	ID         string         `db:"webhook_id"`
+	URL        string         `db:"url"`
+	Events     pq.StringArray `db:"events"`
+	Secret     []byte         `db:"secret"`
+	Failures   int            `db:"failures"`
+	CreatedAt  time.Time      `db:"created_at"`
+	DisabledAt pq.NullTime    `db:"disabled_at"`
+}
+
+// WebhookDelivery represents an event queued for a webhook. Deliveries are
+// pending until an attempt succeeds or they run out of attempts and are
+// dead.
+type WebhookDelivery struct {
+	ID            string      `db:"delivery_id"`
+	WebhookID     string      `db:"webhook_id"`
+	Event         string      `db:"event"`
+	RequestID     string      `db:"request_id"`
+	Payload       []byte      `db:"payload"`
+	State         string      `db:"state"`
+	Attempts      int         `db:"attempts"`
+	NextAttemptAt time.Time   `db:"next_attempt_at"`
+	CreatedAt     time.Time   `db:"created_at"`
+	DeliveredAt   pq.NullTime `db:"delivered_at"`
+}
+
+// WebhookAttempt represents an attempt to deliver. The status code is zero
+// when no response was received.
+type WebhookAttempt struct {
+	ID         string    `db:"attempt_id"`
+	DeliveryID string    `db:"delivery_id"`
+	StatusCode int       `db:"status_code"`
+	Error      string    `db:"error"`
+	DurationMS int64     `db:"duration_ms"`
+	CreatedAt  time.Time `db:"created_at"`
+}
+
+// AvatarUpload represents an avatar a user was allowed to upload directly to
+// the blob store. It is removed once the upload is completed.
+type AvatarUpload struct {
+	Key         string    `db:"upload_key"`
+	UserID      string    `db:"user_id"`
+	ContentType string    `db:"content_type"`
+	CreatedAt   time.Time `db:"created_at"`
+	ExpiresAt   time.Time `db:"expires_at"`
 }
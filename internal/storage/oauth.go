@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// ErrTokenReused is used when a refresh token which was already rotated is
+// presented again.
+var ErrTokenReused = errors.New("Refresh token already used")
+
+// CreateOAuthClient registers a new client. When confidential is true a
+// secret is generated and returned, it is never available again.
+func CreateOAuthClient(ctx context.Context, db *sqlx.DB, name string, redirectURIs, grantTypes, scopes []string, confidential bool, now time.Time) (*OAuthClient, string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.CreateOAuthClient")
+	defer span.End()
+
+	id, err := randomString(10)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var secret string
+	c := OAuthClient{
+		ID:           id,
+		Name:         name,
+		RedirectURIs: redirectURIs,
+		GrantTypes:   grantTypes,
+		Scopes:       scopes,
+		CreatedAt:    now.UTC(),
+	}
+	if confidential {
+		if secret, err = randomString(32); err != nil {
+			return nil, "", err
+		}
+		c.SecretHash = hashSecret(secret)
+	}
+
+	const q = `INSERT INTO oauth_clients (client_id, secret_hash, name,
+	redirect_uris, grant_types, scopes, created_at) VALUES (:client_id,
+	:secret_hash, :name, :redirect_uris, :grant_types, :scopes, :created_at);`
+
+	if _, err := db.NamedExecContext(ctx, q, c); err != nil {
+		return nil, "", errors.Wrap(err, "inserting oauth client")
+	}
+
+	return &c, secret, nil
+}
+
+// RetrieveOAuthClient gets the specified client from the database.
+func RetrieveOAuthClient(ctx context.Context, db *sqlx.DB, clientID string) (*OAuthClient, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.RetrieveOAuthClient")
+	defer span.End()
+
+	const q = `SELECT * FROM oauth_clients WHERE client_id = $1;`
+
+	var c OAuthClient
+	if err := db.GetContext(ctx, &c, q, clientID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+
+		return nil, errors.Wrapf(err, "selecting oauth client %q", clientID)
+	}
+
+	return &c, nil
+}
+
+// VerifyOAuthClientSecret reports if the secret belongs to the confidential
+// client.
+func VerifyOAuthClientSecret(c *OAuthClient, secret string) bool {
+	if c.SecretHash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(c.SecretHash)) == 1
+}
+
+// DeleteOAuthClient removes the client with all its codes and tokens.
+func DeleteOAuthClient(ctx context.Context, db *sqlx.DB, clientID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.DeleteOAuthClient")
+	defer span.End()
+
+	const q = `DELETE FROM oauth_clients WHERE client_id = $1;`
+
+	if _, err := db.ExecContext(ctx, q, clientID); err != nil {
+		return errors.Wrapf(err, "deleting oauth client %q", clientID)
+	}
+
+	return nil
+}
+
+// CreateOAuthCode stores the grant under a new authorization code. It
+// returns the code, only its hash is stored.
+func CreateOAuthCode(ctx context.Context, db *sqlx.DB, g OAuthGrant) (string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.CreateOAuthCode")
+	defer span.End()
+
+	code, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	const q = `INSERT INTO oauth_codes (code_hash, client_id, user_id,
+	redirect_uri, scope, nonce, code_challenge, auth_time, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9);`
+
+	if _, err := db.ExecContext(ctx, q, hashSecret(code), g.ClientID, g.UserID,
+		g.RedirectURI, g.Scope, g.Nonce, g.CodeChallenge, g.AuthTime.UTC(), g.ExpiresAt.UTC()); err != nil {
+		return "", errors.Wrap(err, "inserting oauth code")
+	}
+
+	return code, nil
+}
+
+// RedeemOAuthCode removes the authorization code and returns its grant, so
+// a code can be redeemed only once. It returns ErrNotFound for unknown and
+// expired codes.
+func RedeemOAuthCode(ctx context.Context, db *sqlx.DB, code string, now time.Time) (*OAuthGrant, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.RedeemOAuthCode")
+	defer span.End()
+
+	const q = `DELETE FROM oauth_codes WHERE code_hash = $1 RETURNING client_id,
+	user_id, redirect_uri, scope, nonce, code_challenge, auth_time, expires_at;`
+
+	var g OAuthGrant
+	if err := db.GetContext(ctx, &g, q, hashSecret(code)); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+
+		return nil, errors.Wrap(err, "deleting oauth code")
+	}
+
+	if !now.Before(g.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+
+	return &g, nil
+}
+
+// CreateOAuthRefreshToken stores the grant under a new refresh token. It
+// returns the token, only its hash is stored.
+func CreateOAuthRefreshToken(ctx context.Context, db *sqlx.DB, g OAuthGrant) (string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.CreateOAuthRefreshToken")
+	defer span.End()
+
+	token, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	const q = `INSERT INTO oauth_refresh_tokens (token_hash, client_id, user_id,
+	scope, auth_time, expires_at) VALUES ($1, $2, $3, $4, $5, $6);`
+
+	if _, err := db.ExecContext(ctx, q, hashSecret(token), g.ClientID, g.UserID,
+		g.Scope, g.AuthTime.UTC(), g.ExpiresAt.UTC()); err != nil {
+		return "", errors.Wrap(err, "inserting oauth refresh token")
+	}
+
+	return token, nil
+}
+
+// RotateOAuthRefreshToken revokes the refresh token and returns its grant.
+// Presenting a token which was already revoked is a sign it was stolen, so
+// all refresh tokens of the user for the client are revoked and
+// ErrTokenReused is returned. It returns ErrNotFound for unknown and expired
+// tokens.
+func RotateOAuthRefreshToken(ctx context.Context, db *sqlx.DB, clientID, token string, now time.Time) (*OAuthGrant, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.RotateOAuthRefreshToken")
+	defer span.End()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const sel = `SELECT client_id, user_id, scope, auth_time, expires_at, revoked_at
+	FROM oauth_refresh_tokens WHERE token_hash = $1 AND client_id = $2 FOR UPDATE;`
+
+	var rt struct {
+		OAuthGrant
+		RevokedAt *time.Time `db:"revoked_at"`
+	}
+	if err := tx.GetContext(ctx, &rt, sel, hashSecret(token), clientID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+
+		return nil, errors.Wrap(err, "selecting oauth refresh token")
+	}
+
+	if rt.RevokedAt != nil {
+		const revokeAll = `UPDATE oauth_refresh_tokens SET revoked_at = $3
+		WHERE client_id = $1 AND user_id = $2 AND revoked_at IS NULL;`
+
+		if _, err := tx.ExecContext(ctx, revokeAll, rt.ClientID, rt.UserID, now.UTC()); err != nil {
+			return nil, errors.Wrap(err, "revoking oauth refresh tokens")
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, errors.Wrap(err, "committing transaction")
+		}
+		return nil, ErrTokenReused
+	}
+
+	if !now.Before(rt.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+
+	const revoke = `UPDATE oauth_refresh_tokens SET revoked_at = $2 WHERE token_hash = $1;`
+
+	if _, err := tx.ExecContext(ctx, revoke, hashSecret(token), now.UTC()); err != nil {
+		return nil, errors.Wrap(err, "revoking oauth refresh token")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, errors.Wrap(err, "committing transaction")
+	}
+
+	return &rt.OAuthGrant, nil
+}
@@ -0,0 +1,196 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// maxUserAgent is the longest user agent stored for a session.
+const maxUserAgent = 512
+
+// seenInterval is how often the last seen time of a session is updated, so
+// not every request writes to the database.
+const seenInterval = time.Minute
+
+// CreateSession stores a new session of the user which ends at the
+// expiration time.
+func CreateSession(ctx context.Context, db *sqlx.DB, userID, userAgent, ip string, now, expires time.Time) (*Session, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.CreateSession")
+	defer span.End()
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, ErrInvalidUserID
+	}
+
+	if len(userAgent) > maxUserAgent {
+		userAgent = userAgent[:maxUserAgent]
+	}
+
+	s := Session{
+		ID:         uuid.New().String(),
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now.UTC(),
+		LastSeenAt: now.UTC(),
+		ExpiresAt:  expires.UTC(),
+	}
+
+	const q = `INSERT INTO sessions (session_id, user_id, user_agent, ip,
+	created_at, last_seen_at, expires_at) VALUES (:session_id, :user_id,
+	:user_agent, :ip, :created_at, :last_seen_at, :expires_at);`
+
+	if _, err := db.NamedExecContext(ctx, q, s); err != nil {
+		return nil, errors.Wrap(err, "inserting session")
+	}
+
+	return &s, nil
+}
+
+// ListSessions gets the sessions of the user which are neither revoked nor
+// expired, the most recently used first.
+func ListSessions(ctx context.Context, db *sqlx.DB, userID string, now time.Time) ([]Session, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.ListSessions")
+	defer span.End()
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, ErrInvalidUserID
+	}
+
+	const q = `SELECT * FROM sessions
+	WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2
+	ORDER BY last_seen_at DESC;`
+
+	var sessions []Session
+	if err := db.SelectContext(ctx, &sessions, q, userID, now.UTC()); err != nil {
+		return nil, errors.Wrapf(err, "selecting sessions %q", userID)
+	}
+
+	return sessions, nil
+}
+
+// ListSessionsOfUsers gets the sessions of each of the users which are
+// neither revoked nor expired, the most recently used first.
+func ListSessionsOfUsers(ctx context.Context, db *sqlx.DB, userIDs []string, now time.Time) ([]Session, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.ListSessionsOfUsers")
+	defer span.End()
+
+	for _, id := range userIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, ErrInvalidUserID
+		}
+	}
+
+	const q = `SELECT * FROM sessions
+	WHERE user_id = ANY($1) AND revoked_at IS NULL AND expires_at > $2
+	ORDER BY user_id, last_seen_at DESC;`
+
+	var sessions []Session
+	if err := db.SelectContext(ctx, &sessions, q, pq.Array(userIDs), now.UTC()); err != nil {
+		return nil, errors.Wrap(err, "selecting sessions of users")
+	}
+
+	return sessions, nil
+}
+
+// TouchSession reports if the session is neither revoked nor expired. The
+// last seen time of an active session is updated at most once per minute.
+func TouchSession(ctx context.Context, db *sqlx.DB, sessionID string, now time.Time) (bool, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.TouchSession")
+	defer span.End()
+
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return false, nil
+	}
+
+	const q = `SELECT * FROM sessions WHERE session_id = $1;`
+
+	var s Session
+	if err := db.GetContext(ctx, &s, q, sessionID); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+
+		return false, errors.Wrapf(err, "selecting session %q", sessionID)
+	}
+
+	if s.RevokedAt.Valid || !now.Before(s.ExpiresAt) {
+		return false, nil
+	}
+
+	if now.Sub(s.LastSeenAt) >= seenInterval {
+		const seen = `UPDATE sessions SET last_seen_at = $2 WHERE session_id = $1;`
+
+		if _, err := db.ExecContext(ctx, seen, sessionID, now.UTC()); err != nil {
+			return false, errors.Wrapf(err, "updating session %q", sessionID)
+		}
+	}
+
+	return true, nil
+}
+
+// RevokeSession revokes the session of the user. It returns ErrNotFound if
+// the user has no such active session.
+func RevokeSession(ctx context.Context, db *sqlx.DB, userID, sessionID string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.RevokeSession")
+	defer span.End()
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return ErrInvalidUserID
+	}
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return ErrNotFound
+	}
+
+	const q = `UPDATE sessions SET revoked_at = $3
+	WHERE session_id = $1 AND user_id = $2 AND revoked_at IS NULL;`
+
+	res, err := db.ExecContext(ctx, q, sessionID, userID, now.UTC())
+	if err != nil {
+		return errors.Wrapf(err, "revoking session %q", sessionID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "revoking session %q", sessionID)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RevokeOtherSessions revokes all sessions of the user except the one to
+// keep, which may be empty to revoke all. It returns the number of revoked
+// sessions.
+func RevokeOtherSessions(ctx context.Context, db *sqlx.DB, userID, keepID string, now time.Time) (int, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.RevokeOtherSessions")
+	defer span.End()
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return 0, ErrInvalidUserID
+	}
+
+	const q = `UPDATE sessions SET revoked_at = $3
+	WHERE user_id = $1 AND session_id::text <> $2 AND revoked_at IS NULL;`
+
+	res, err := db.ExecContext(ctx, q, userID, keepID, now.UTC())
+	if err != nil {
+		return 0, errors.Wrapf(err, "revoking sessions %q", userID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrapf(err, "revoking sessions %q", userID)
+	}
+
+	return int(n), nil
+}
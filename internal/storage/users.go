@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -13,6 +14,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 
 	"github.com/igomonov88/users/internal/platform/auth"
+	"github.com/igomonov88/users/internal/username"
 )
 
 var (
@@ -67,8 +69,16 @@ func Authenticate(ctx context.Context, db *sqlx.DB, now time.Time, email, passwo
 
 	// If we are this far the request is valid. Create some claims for the user
 	// and generate their token.
+	return NewClaims(&u, now), nil
+}
+
+// NewClaims constructs the Claims representing the user which are valid for
+// the default duration from the provided time.
+func NewClaims(u *User, now time.Time) auth.Claims {
 	claims := auth.NewClaims(u.ID, now, claimsDuration)
-	return claims, nil
+	claims.Roles = u.Roles
+	claims.Locale = u.Locale
+	return claims
 }
 
 // Create user with provided info in database.
@@ -77,9 +87,10 @@ func Create(ctx context.Context, db *sqlx.DB, email, userName, avatar, password
 	defer span.End()
 
 	const q = `INSERT INTO users (
-	user_id, user_name, email, password_hash, avatar, created_at, updated_at, 
-	deleted_at) VALUES (:user_id, :user_name, :email, :password_hash, :avatar, 
-	:created_at, :updated_at, :deleted_at);`
+	user_id, user_name, user_name_skeleton, email, password_hash, avatar, 
+	avatar_hash, roles, created_at, updated_at, deleted_at) VALUES (:user_id, 
+	:user_name, :user_name_skeleton, :email, :password_hash, :avatar, 
+	:avatar_hash, :roles, :created_at, :updated_at, :deleted_at);`
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -89,9 +100,12 @@ func Create(ctx context.Context, db *sqlx.DB, email, userName, avatar, password
 	u := User{
 		ID:           uuid.New().String(),
 		Name:         userName,
+		NameSkeleton: username.Skeleton(userName),
 		Email:        email,
 		PasswordHash: hash,
 		Avatar:       avatar,
+		AvatarHash:   AvatarHash(avatar),
+		Roles:        []string{auth.RoleUser},
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Time{},
 		DeletedAt:    time.Time{},
@@ -132,7 +146,7 @@ func DeleteAvatar(ctx context.Context, db *sqlx.DB, userID string) error {
 		return ErrInvalidUserID
 	}
 
-	const q = `UPDATE users SET avatar = '' WHERE user_id = $1;`
+	const q = `UPDATE users SET avatar = '', avatar_hash = '' WHERE user_id = $1;`
 
 	if _, err := db.ExecContext(ctx, q, userID); err != nil {
 		return errors.Wrapf(err, "deleting avatar userID %q", userID)
@@ -173,6 +187,118 @@ func DoesUserNameExist(ctx context.Context, db *sqlx.DB, userName string) (bool,
 	return exist, err
 }
 
+// DoesUserNameSkeletonExist reports if a user other than the given one has a
+// name with the skeleton, so a new name would look like theirs. The user ID
+// may be empty.
+func DoesUserNameSkeletonExist(ctx context.Context, db *sqlx.DB, skeleton, userID string) (bool, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.DoesUserNameSkeletonExist")
+	defer span.End()
+
+	var exist bool
+	const q = `SELECT EXISTS(SELECT 1 FROM users WHERE user_name_skeleton = $1 
+	AND user_id::text <> $2);`
+
+	if err := db.GetContext(ctx, &exist, q, skeleton, userID); err != nil {
+		return exist, errors.Wrapf(err, "selecting user name skeleton exists %q", skeleton)
+	}
+
+	return exist, nil
+}
+
+// BackfillUserNameSkeletons saves the skeletons of the names of users which
+// were created before skeletons were kept. It returns how many were saved.
+func BackfillUserNameSkeletons(ctx context.Context, db *sqlx.DB) (int, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.BackfillUserNameSkeletons")
+	defer span.End()
+
+	const q = `SELECT * FROM users WHERE user_name_skeleton = '';`
+
+	var users []User
+	if err := db.SelectContext(ctx, &users, q); err != nil {
+		return 0, errors.Wrap(err, "selecting users without skeleton")
+	}
+
+	const u = `UPDATE users SET user_name_skeleton = $2 WHERE user_id = $1;`
+
+	for i, usr := range users {
+		if _, err := db.ExecContext(ctx, u, usr.ID, username.Skeleton(usr.Name)); err != nil {
+			return i, errors.Wrapf(err, "updating user name skeleton %q", usr.ID)
+		}
+	}
+
+	return len(users), nil
+}
+
+// ListAvatarHashes returns the hashes of the avatars users reference.
+func ListAvatarHashes(ctx context.Context, db *sqlx.DB) ([]string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.ListAvatarHashes")
+	defer span.End()
+
+	const q = `SELECT DISTINCT avatar_hash FROM users WHERE avatar_hash <> '';`
+
+	var hashes []string
+	if err := db.SelectContext(ctx, &hashes, q); err != nil {
+		return nil, errors.Wrap(err, "selecting avatar hashes")
+	}
+
+	return hashes, nil
+}
+
+// IsAvatarReferenced reports if any user references the avatar with the hash.
+func IsAvatarReferenced(ctx context.Context, db *sqlx.DB, hash string) (bool, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.IsAvatarReferenced")
+	defer span.End()
+
+	const q = `SELECT EXISTS(SELECT 1 FROM users WHERE avatar_hash = $1);`
+
+	var referenced bool
+	if err := db.GetContext(ctx, &referenced, q, hash); err != nil {
+		return false, errors.Wrapf(err, "checking references of avatar %q", hash)
+	}
+
+	return referenced, nil
+}
+
+// LockAvatar runs fn while holding the lock of the avatar with the hash. The
+// lock is held until fn returns, callers storing the objects of an avatar and
+// callers deleting them take it so neither happens halfway through the other.
+func LockAvatar(ctx context.Context, db *sqlx.DB, hash string, fn func() error) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.LockAvatar")
+	defer span.End()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const q = `SELECT pg_advisory_xact_lock(hashtext($1));`
+
+	if _, err := tx.ExecContext(ctx, q, "avatar:"+hash); err != nil {
+		return errors.Wrapf(err, "locking avatar %q", hash)
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AvatarHash returns the hash of the avatar the URL points to, the segment
+// after "avatars". It ignores where the objects are served from, so avatars
+// saved while the store had another base URL still count as referenced. URLs
+// not pointing to avatars have none.
+func AvatarHash(url string) string {
+	parts := strings.Split(url, "/")
+	for i := len(parts) - 3; i >= 0; i-- {
+		if parts[i] == "avatars" && parts[i+1] != "" {
+			return parts[i+1]
+		}
+	}
+	return ""
+}
+
 // Retrieve gets the specified user from the database.
 func Retrieve(ctx context.Context, db *sqlx.DB, userID string) (*User, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.user.Retrieve")
@@ -195,6 +321,28 @@ func Retrieve(ctx context.Context, db *sqlx.DB, userID string) (*User, error) {
 	return &u, nil
 }
 
+// RetrieveMany gets the users with the ids from the database. Users which do
+// not exist are left out.
+func RetrieveMany(ctx context.Context, db *sqlx.DB, userIDs []string) ([]User, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.RetrieveMany")
+	defer span.End()
+
+	for _, id := range userIDs {
+		if _, err := uuid.Parse(id); err != nil {
+			return nil, ErrInvalidUserID
+		}
+	}
+
+	const q = `SELECT * FROM users WHERE user_id = ANY($1);`
+
+	var users []User
+	if err := db.SelectContext(ctx, &users, q, pq.Array(userIDs)); err != nil {
+		return nil, errors.Wrap(err, "selecting users")
+	}
+
+	return users, nil
+}
+
 // RetrieveByEmail gets the specified user from the database by email.
 func RetrieveByEmail(ctx context.Context, db *sqlx.DB, email string) (*User, error) {
 	ctx, span := trace.StartSpan(ctx, "internal.user.RetrieveByEmail")
@@ -242,10 +390,11 @@ func Update(ctx context.Context, db *sqlx.DB, userID, userName, email string) er
 		return ErrInvalidUserID
 	}
 
-	const q = `UPDATE users SET user_name = $2, email = $3 WHERE 
-    user_id = $1;`
+	const q = `UPDATE users SET user_name = $2, user_name_skeleton = $3, 
+	email = $4 WHERE user_id = $1;`
 
-	if _, err := db.ExecContext(ctx, q, userID, userName, email); err != nil {
+	sk := username.Skeleton(userName)
+	if _, err := db.ExecContext(ctx, q, userID, userName, sk, email); err != nil {
 		return constraintError(err)
 	}
 
@@ -261,15 +410,60 @@ func UpdateAvatar(ctx context.Context, db *sqlx.DB, userID, avatar string) error
 		return ErrInvalidUserID
 	}
 
-	const q = `UPDATE users SET avatar = $2 WHERE user_id = $1;`
+	const q = `UPDATE users SET avatar = $2, avatar_hash = $3 WHERE user_id = $1;`
 
-	if _, err := db.ExecContext(ctx, q, userID, avatar); err != nil {
+	if _, err := db.ExecContext(ctx, q, userID, avatar, AvatarHash(avatar)); err != nil {
 		return errors.Wrapf(err, "updating avatar %q", userID)
 	}
 
 	return nil
 }
 
+// UpdateLocale replaces the locale a user chose in the database.
+func UpdateLocale(ctx context.Context, db *sqlx.DB, userID, locale string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.UpdateLocale")
+	defer span.End()
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return ErrInvalidUserID
+	}
+
+	const q = `UPDATE users SET locale = $2 WHERE user_id = $1;`
+
+	if _, err := db.ExecContext(ctx, q, userID, locale); err != nil {
+		return errors.Wrapf(err, "updating locale %q", userID)
+	}
+
+	return nil
+}
+
+// UpdatePassword replaces the password of a user in the database.
+func UpdatePassword(ctx context.Context, db *sqlx.DB, userID, password string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.UpdatePassword")
+	defer span.End()
+
+	if _, err := uuid.Parse(userID); err != nil {
+		return ErrInvalidUserID
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.Wrap(err, "generating password hash")
+	}
+
+	const q = `UPDATE users SET password_hash = $2 WHERE user_id = $1;`
+
+	res, err := db.ExecContext(ctx, q, userID, hash)
+	if err != nil {
+		return errors.Wrapf(err, "updating password %q", userID)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
 func constraintError(err error) error {
 	const UniqueViolationCode = "23505"
 	if err != nil {
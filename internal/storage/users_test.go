@@ -216,7 +216,7 @@ func TestAuthenticate (t *testing.T) {
 			}
 			t.Logf("\t%s\tShould be able to generate claims.", tests.Success)
 
-			want := auth.Claims{}
+			want := auth.Claims{Roles: []string{auth.RoleUser}}
 			want.Subject = cu.ID
 			want.ExpiresAt = time.Now().Add(time.Hour).Unix()
 			want.IssuedAt = time.Now().Unix()
@@ -0,0 +1,328 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+)
+
+// These are the states of webhook deliveries.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// CreateWebhook stores a new webhook. The secret must be encrypted already.
+func CreateWebhook(ctx context.Context, db *sqlx.DB, w Webhook) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.CreateWebhook")
+	defer span.End()
+
+	const q = `INSERT INTO webhooks (webhook_id, url, events, secret,
+	failures, created_at) VALUES (:webhook_id, :url, :events, :secret,
+	:failures, :created_at);`
+
+	if _, err := db.NamedExecContext(ctx, q, w); err != nil {
+		return errors.Wrap(err, "inserting webhook")
+	}
+
+	return nil
+}
+
+// ListWebhooks gets all webhooks, the oldest first.
+func ListWebhooks(ctx context.Context, db *sqlx.DB) ([]Webhook, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.ListWebhooks")
+	defer span.End()
+
+	const q = `SELECT * FROM webhooks ORDER BY created_at;`
+
+	var hooks []Webhook
+	if err := db.SelectContext(ctx, &hooks, q); err != nil {
+		return nil, errors.Wrap(err, "selecting webhooks")
+	}
+
+	return hooks, nil
+}
+
+// RetrieveWebhook gets the specified webhook from the database.
+func RetrieveWebhook(ctx context.Context, db *sqlx.DB, webhookID string) (*Webhook, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.RetrieveWebhook")
+	defer span.End()
+
+	if _, err := uuid.Parse(webhookID); err != nil {
+		return nil, ErrNotFound
+	}
+
+	const q = `SELECT * FROM webhooks WHERE webhook_id = $1;`
+
+	var w Webhook
+	if err := db.GetContext(ctx, &w, q, webhookID); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+
+		return nil, errors.Wrapf(err, "selecting webhook %q", webhookID)
+	}
+
+	return &w, nil
+}
+
+// DeleteWebhook removes the webhook with its deliveries. It returns
+// ErrNotFound if there is no such webhook.
+func DeleteWebhook(ctx context.Context, db *sqlx.DB, webhookID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.DeleteWebhook")
+	defer span.End()
+
+	if _, err := uuid.Parse(webhookID); err != nil {
+		return ErrNotFound
+	}
+
+	const q = `DELETE FROM webhooks WHERE webhook_id = $1;`
+
+	res, err := db.ExecContext(ctx, q, webhookID)
+	if err != nil {
+		return errors.Wrapf(err, "deleting webhook %q", webhookID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "deleting webhook %q", webhookID)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// EnableWebhook enables the webhook again after it was disabled for
+// failing and resets its failures. It returns ErrNotFound if there is no
+// such webhook.
+func EnableWebhook(ctx context.Context, db *sqlx.DB, webhookID string) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.EnableWebhook")
+	defer span.End()
+
+	if _, err := uuid.Parse(webhookID); err != nil {
+		return ErrNotFound
+	}
+
+	const q = `UPDATE webhooks SET disabled_at = NULL, failures = 0
+	WHERE webhook_id = $1;`
+
+	res, err := db.ExecContext(ctx, q, webhookID)
+	if err != nil {
+		return errors.Wrapf(err, "enabling webhook %q", webhookID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "enabling webhook %q", webhookID)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CreateDeliveries queues the payload of the event for every enabled
+// webhook subscribed to it. Webhooks subscribed to "*" get every event. The
+// id of the request the event happened in is kept to be sent along. It
+// returns the number of queued deliveries.
+func CreateDeliveries(ctx context.Context, db *sqlx.DB, event, requestID string, payload []byte, now time.Time) (int, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.CreateDeliveries")
+	defer span.End()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const sel = `SELECT webhook_id FROM webhooks
+	WHERE disabled_at IS NULL AND ($1 = ANY(events) OR '*' = ANY(events));`
+
+	var ids []string
+	if err := tx.SelectContext(ctx, &ids, sel, event); err != nil {
+		return 0, errors.Wrapf(err, "selecting webhooks of %q", event)
+	}
+
+	const q = `INSERT INTO webhook_deliveries (delivery_id, webhook_id, event,
+	request_id, payload, state, attempts, next_attempt_at, created_at) VALUES
+	(:delivery_id, :webhook_id, :event, :request_id, :payload, :state, :attempts,
+	:next_attempt_at, :created_at);`
+
+	for _, id := range ids {
+		d := WebhookDelivery{
+			ID:            uuid.New().String(),
+			WebhookID:     id,
+			Event:         event,
+			RequestID:     requestID,
+			Payload:       payload,
+			State:         DeliveryPending,
+			NextAttemptAt: now.UTC(),
+			CreatedAt:     now.UTC(),
+		}
+		if _, err := tx.NamedExecContext(ctx, q, d); err != nil {
+			return 0, errors.Wrapf(err, "inserting delivery of %q", event)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, errors.Wrap(err, "committing deliveries")
+	}
+
+	return len(ids), nil
+}
+
+// ClaimDeliveries gets up to limit pending deliveries of enabled webhooks
+// which are due at the time, the longest waiting first. Their next attempt
+// is moved to the lease time, so no one else claims them while they are
+// attempted and they are attempted again if the attempt is never recorded.
+func ClaimDeliveries(ctx context.Context, db *sqlx.DB, now, lease time.Time, limit int) ([]WebhookDelivery, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.ClaimDeliveries")
+	defer span.End()
+
+	const q = `UPDATE webhook_deliveries SET next_attempt_at = $2
+	WHERE delivery_id IN (
+		SELECT d.delivery_id FROM webhook_deliveries AS d
+		JOIN webhooks AS w ON w.webhook_id = d.webhook_id
+		WHERE d.state = 'pending' AND d.next_attempt_at <= $1 AND w.disabled_at IS NULL
+		ORDER BY d.next_attempt_at
+		LIMIT $3
+		FOR UPDATE OF d SKIP LOCKED
+	)
+	RETURNING *;`
+
+	var deliveries []WebhookDelivery
+	if err := db.SelectContext(ctx, &deliveries, q, now.UTC(), lease.UTC(), limit); err != nil {
+		return nil, errors.Wrap(err, "claiming deliveries")
+	}
+
+	return deliveries, nil
+}
+
+// RecordDeliveryAttempt stores the attempt with the outcome it had for the
+// delivery: its state, attempts and next attempt. Failed attempts count
+// towards the failures of the webhook, which is disabled once they reach
+// disableAfter. Successful attempts reset them.
+func RecordDeliveryAttempt(ctx context.Context, db *sqlx.DB, a WebhookAttempt, d WebhookDelivery, disableAfter int) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.RecordDeliveryAttempt")
+	defer span.End()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "beginning transaction")
+	}
+	defer tx.Rollback()
+
+	const qa = `INSERT INTO webhook_attempts (attempt_id, delivery_id,
+	status_code, error, duration_ms, created_at) VALUES (:attempt_id,
+	:delivery_id, :status_code, :error, :duration_ms, :created_at);`
+
+	if _, err := tx.NamedExecContext(ctx, qa, a); err != nil {
+		return errors.Wrapf(err, "inserting attempt of %q", d.ID)
+	}
+
+	const qd = `UPDATE webhook_deliveries SET state = :state,
+	attempts = :attempts, next_attempt_at = :next_attempt_at,
+	delivered_at = :delivered_at WHERE delivery_id = :delivery_id;`
+
+	if _, err := tx.NamedExecContext(ctx, qd, d); err != nil {
+		return errors.Wrapf(err, "updating delivery %q", d.ID)
+	}
+
+	if d.State == DeliveryDelivered {
+		const q = `UPDATE webhooks SET failures = 0 WHERE webhook_id = $1;`
+		if _, err := tx.ExecContext(ctx, q, d.WebhookID); err != nil {
+			return errors.Wrapf(err, "resetting failures of %q", d.WebhookID)
+		}
+	} else {
+		const q = `UPDATE webhooks SET failures = failures + 1,
+		disabled_at = CASE WHEN failures + 1 >= $2 AND disabled_at IS NULL THEN $3 ELSE disabled_at END
+		WHERE webhook_id = $1;`
+		if _, err := tx.ExecContext(ctx, q, d.WebhookID, disableAfter, a.CreatedAt.UTC()); err != nil {
+			return errors.Wrapf(err, "counting failures of %q", d.WebhookID)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListDeliveries gets the latest deliveries of the webhook up to the limit,
+// the most recent first.
+func ListDeliveries(ctx context.Context, db *sqlx.DB, webhookID string, limit int) ([]WebhookDelivery, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.ListDeliveries")
+	defer span.End()
+
+	if _, err := uuid.Parse(webhookID); err != nil {
+		return nil, ErrNotFound
+	}
+
+	const q = `SELECT * FROM webhook_deliveries WHERE webhook_id = $1
+	ORDER BY created_at DESC LIMIT $2;`
+
+	var deliveries []WebhookDelivery
+	if err := db.SelectContext(ctx, &deliveries, q, webhookID, limit); err != nil {
+		return nil, errors.Wrapf(err, "selecting deliveries of %q", webhookID)
+	}
+
+	return deliveries, nil
+}
+
+// ListDeliveryAttempts gets the attempts of the deliveries, the oldest
+// first.
+func ListDeliveryAttempts(ctx context.Context, db *sqlx.DB, deliveryIDs []string) ([]WebhookAttempt, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.user.ListDeliveryAttempts")
+	defer span.End()
+
+	const q = `SELECT * FROM webhook_attempts WHERE delivery_id = ANY($1)
+	ORDER BY created_at;`
+
+	var attempts []WebhookAttempt
+	if err := db.SelectContext(ctx, &attempts, q, pq.Array(deliveryIDs)); err != nil {
+		return nil, errors.Wrap(err, "selecting delivery attempts")
+	}
+
+	return attempts, nil
+}
+
+// Redeliver queues the delivery of the webhook again, whatever its state,
+// with all of its attempts. It returns ErrNotFound if the webhook has no
+// such delivery.
+func Redeliver(ctx context.Context, db *sqlx.DB, webhookID, deliveryID string, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.user.Redeliver")
+	defer span.End()
+
+	if _, err := uuid.Parse(webhookID); err != nil {
+		return ErrNotFound
+	}
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		return ErrNotFound
+	}
+
+	const q = `UPDATE webhook_deliveries SET state = 'pending', attempts = 0,
+	next_attempt_at = $3, delivered_at = NULL
+	WHERE delivery_id = $1 AND webhook_id = $2;`
+
+	res, err := db.ExecContext(ctx, q, deliveryID, webhookID, now.UTC())
+	if err != nil {
+		return errors.Wrapf(err, "redelivering delivery %q", deliveryID)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrapf(err, "redelivering delivery %q", deliveryID)
+	}
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
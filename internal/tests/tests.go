@@ -4,7 +4,6 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/rsa"
-	"log"
 	"os"
 	"testing"
 	"time"
@@ -14,6 +13,7 @@ import (
 
 	"github.com/igomonov88/users/internal/platform/auth"
 	"github.com/igomonov88/users/internal/platform/database/databasetest"
+	"github.com/igomonov88/users/internal/platform/logger"
 	"github.com/igomonov88/users/internal/platform/web"
 	"github.com/igomonov88/users/internal/schema"
 
@@ -90,7 +90,7 @@ func NewUnit(t *testing.T) (*sqlx.DB, func()) {
 // Test owns state for running and shutting down tests.
 type Test struct {
 	DB            *sqlx.DB
-	Log           *log.Logger
+	Log           *logger.Logger
 	Authenticator *auth.Authenticator
 
 	t       *testing.T
@@ -105,7 +105,7 @@ func NewIntegration(t *testing.T) *Test {
 	db, cleanup := NewUnit(t)
 
 	// Create the logger to use.
-	logger := log.New(os.Stdout, "TEST : ", log.LstdFlags|log.Lmicroseconds|log.Lshortfile)
+	log := logger.New(os.Stdout, logger.Debug).With("service", "test")
 
 	// Create RSA keys to enable authentication in our service.
 	key, err := rsa.GenerateKey(rand.Reader, 2048)
@@ -123,7 +123,7 @@ func NewIntegration(t *testing.T) *Test {
 
 	return &Test{
 		DB:            db,
-		Log:           logger,
+		Log:           log,
 		Authenticator: authenticator,
 		t:             t,
 		cleanup:       cleanup,
@@ -138,8 +138,9 @@ func (test *Test) Teardown() {
 //Context returns an app level context for testing.
 func Context() context.Context {
 	values := web.Values{
-		TraceID: uuid.New().String(),
-		Now:     time.Now(),
+		TraceID:   uuid.New().String(),
+		RequestID: uuid.New().String(),
+		Now:       time.Now(),
 	}
 
 	return context.WithValue(context.Background(), web.KeyValues, &values)
@@ -0,0 +1,119 @@
+package username
+
+// confusables maps characters to the prototype they can be confused with.
+// It is the part of the confusables.txt data of Unicode Technical Standard
+// #39 which maps to Latin letters, digits and punctuation. Fullwidth forms
+// are added in init.
+var confusables = map[rune]string{
+
+	// Latin, digits and punctuation.
+	'0':      "O",
+	'1':      "l",
+	'I':      "l",
+	'|':      "l",
+	'm':      "rn",
+	'\u0131': "i",  // LATIN SMALL LETTER DOTLESS I
+	'\u01c0': "l",  // LATIN LETTER DENTAL CLICK
+	'\u0251': "a",  // LATIN SMALL LETTER ALPHA
+	'\u0261': "g",  // LATIN SMALL LETTER SCRIPT G
+	'\u2113': "l",  // SCRIPT SMALL L
+	'\u02d7': "-",  // MODIFIER LETTER MINUS SIGN
+	'\u2010': "-",  // HYPHEN
+	'\u2011': "-",  // NON-BREAKING HYPHEN
+	'\u2012': "-",  // FIGURE DASH
+	'\u2013': "-",  // EN DASH
+	'\u2212': "-",  // MINUS SIGN
+	'\u2024': ".",  // ONE DOT LEADER
+	'\u0701': ".",  // SYRIAC SUPRALINEAR FULL STOP
+	'\u02cd': "_",  // MODIFIER LETTER LOW MACRON
+	'\u2017': "_",  // DOUBLE LOW LINE
+	'\u2160': "l",  // ROMAN NUMERAL ONE
+	'\u217c': "l",  // SMALL ROMAN NUMERAL FIFTY
+	'\u2170': "i",  // SMALL ROMAN NUMERAL ONE
+	'\u217d': "c",  // SMALL ROMAN NUMERAL ONE HUNDRED
+	'\u217e': "d",  // SMALL ROMAN NUMERAL FIVE HUNDRED
+	'\u217f': "rn", // SMALL ROMAN NUMERAL ONE THOUSAND
+
+	// Cyrillic.
+	'\u0430': "a", // SMALL LETTER A
+	'\u0435': "e", // SMALL LETTER IE
+	'\u043e': "o", // SMALL LETTER O
+	'\u0440': "p", // SMALL LETTER ER
+	'\u0441': "c", // SMALL LETTER ES
+	'\u0443': "y", // SMALL LETTER U
+	'\u0445': "x", // SMALL LETTER HA
+	'\u0456': "i", // SMALL LETTER BYELORUSSIAN-UKRAINIAN I
+	'\u0458': "j", // SMALL LETTER JE
+	'\u0455': "s", // SMALL LETTER DZE
+	'\u0501': "d", // SMALL LETTER KOMI DE
+	'\u04bb': "h", // SMALL LETTER SHHA
+	'\u051b': "q", // SMALL LETTER QA
+	'\u051d': "w", // SMALL LETTER WE
+	'\u04cf': "l", // SMALL LETTER PALOCHKA
+	'\u0475': "v", // SMALL LETTER IZHITSA
+	'\u0410': "A", // CAPITAL LETTER A
+	'\u0412': "B", // CAPITAL LETTER VE
+	'\u0415': "E", // CAPITAL LETTER IE
+	'\u041a': "K", // CAPITAL LETTER KA
+	'\u041c': "M", // CAPITAL LETTER EM
+	'\u041d': "H", // CAPITAL LETTER EN
+	'\u041e': "O", // CAPITAL LETTER O
+	'\u0420': "P", // CAPITAL LETTER ER
+	'\u0421': "C", // CAPITAL LETTER ES
+	'\u0422': "T", // CAPITAL LETTER TE
+	'\u0425': "X", // CAPITAL LETTER HA
+	'\u0406': "l", // CAPITAL LETTER BYELORUSSIAN-UKRAINIAN I
+	'\u0408': "J", // CAPITAL LETTER JE
+	'\u0405': "S", // CAPITAL LETTER DZE
+	'\u04c0': "l", // LETTER PALOCHKA
+	'\u0417': "3", // CAPITAL LETTER ZE
+
+	// Greek.
+	'\u03b1': "a", // SMALL LETTER ALPHA
+	'\u03bf': "o", // SMALL LETTER OMICRON
+	'\u03bd': "v", // SMALL LETTER NU
+	'\u03c1': "p", // SMALL LETTER RHO
+	'\u03b9': "i", // SMALL LETTER IOTA
+	'\u03c5': "u", // SMALL LETTER UPSILON
+	'\u0391': "A", // CAPITAL LETTER ALPHA
+	'\u0392': "B", // CAPITAL LETTER BETA
+	'\u0395': "E", // CAPITAL LETTER EPSILON
+	'\u0396': "Z", // CAPITAL LETTER ZETA
+	'\u0397': "H", // CAPITAL LETTER ETA
+	'\u0399': "l", // CAPITAL LETTER IOTA
+	'\u039a': "K", // CAPITAL LETTER KAPPA
+	'\u039c': "M", // CAPITAL LETTER MU
+	'\u039d': "N", // CAPITAL LETTER NU
+	'\u039f': "O", // CAPITAL LETTER OMICRON
+	'\u03a1': "P", // CAPITAL LETTER RHO
+	'\u03a4': "T", // CAPITAL LETTER TAU
+	'\u03a5': "Y", // CAPITAL LETTER UPSILON
+	'\u03a7': "X", // CAPITAL LETTER CHI
+}
+
+// ignorable are default ignorable code points. They are invisible, so they
+// are removed from skeletons.
+var ignorable = map[rune]bool{
+	'\u00ad': true, // SOFT HYPHEN
+	'\u034f': true, // COMBINING GRAPHEME JOINER
+	'\u180e': true, // MONGOLIAN VOWEL SEPARATOR
+	'\u200b': true, // ZERO WIDTH SPACE
+	'\u200c': true, // ZERO WIDTH NON-JOINER
+	'\u200d': true, // ZERO WIDTH JOINER
+	'\u2060': true, // WORD JOINER
+	'\ufeff': true, // ZERO WIDTH NO-BREAK SPACE
+}
+
+func init() {
+
+	// Fullwidth forms look like the ASCII characters they stand for, which
+	// may have a prototype of their own.
+	for r := rune(0xff01); r <= 0xff5e; r++ {
+		ascii := r - 0xff01 + '!'
+		p, ok := confusables[ascii]
+		if !ok {
+			p = string(ascii)
+		}
+		confusables[r] = p
+	}
+}
@@ -0,0 +1,212 @@
+// Package username implements the policy user names have to follow. Names
+// are checked for their length and characters, against reserved words and
+// deny lists, and are compared by their skeletons as defined by Unicode
+// Technical Standard #39 so lookalikes of other names can be detected.
+package username
+
+import (
+	"bufio"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Reasons a name is rejected for. They are reported as the error of the
+// field holding the name. Confusable and taken names are found by comparing
+// skeletons with the names of other users.
+const (
+	ReasonRequired   = "required"
+	ReasonTooShort   = "too_short"
+	ReasonTooLong    = "too_long"
+	ReasonCharacters = "invalid_characters"
+	ReasonReserved   = "reserved"
+	ReasonProfane    = "profane"
+	ReasonDenied     = "denied"
+	ReasonConfusable = "confusable"
+	ReasonTaken      = "taken"
+)
+
+// Violation is returned for names which break the policy.
+type Violation struct {
+	Reason string
+}
+
+// Error implements the error interface.
+func (v *Violation) Error() string {
+	return "user name rejected : " + v.Reason
+}
+
+// Config is the required properties to use the policy.
+type Config struct {
+
+	// MinLength and MaxLength bound the length of names in characters.
+	MinLength int
+	MaxLength int
+
+	// Allowed is the character class names are made of, in the syntax of
+	// regular expressions without the brackets, for example `a-z0-9_`.
+	Allowed string
+
+	// Reserved are names nobody can take, like the ones of staff roles.
+	Reserved []string
+
+	// ProfanityFile and DenylistFile are optional files with one entry per
+	// line. Names containing a profane word are rejected, as well as names
+	// which are on the deny list. Empty lines and lines starting with # are
+	// skipped.
+	ProfanityFile string
+	DenylistFile  string
+}
+
+// Policy checks user names.
+type Policy struct {
+	cfg       Config
+	allowed   *regexp.Regexp
+	reserved  map[string]bool
+	denied    map[string]bool
+	profanity []string
+}
+
+// New constructs a Policy for use, reading the files of the config.
+func New(cfg Config) (*Policy, error) {
+	allowed, err := regexp.Compile(`^[` + cfg.Allowed + `]+$`)
+	if err != nil {
+		return nil, errors.Wrap(err, "compiling allowed characters")
+	}
+
+	p := Policy{
+		cfg:      cfg,
+		allowed:  allowed,
+		reserved: make(map[string]bool),
+		denied:   make(map[string]bool),
+	}
+
+	for _, name := range cfg.Reserved {
+		p.reserved[Skeleton(name)] = true
+	}
+
+	if cfg.DenylistFile != "" {
+		names, err := readList(cfg.DenylistFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading deny list")
+		}
+		for _, name := range names {
+			p.denied[Skeleton(name)] = true
+		}
+	}
+
+	if cfg.ProfanityFile != "" {
+		words, err := readList(cfg.ProfanityFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading profanity list")
+		}
+		for _, word := range words {
+			p.profanity = append(p.profanity, compact(Skeleton(word)))
+		}
+	}
+
+	return &p, nil
+}
+
+// Check reports if the name follows the policy. Whether it can be confused
+// with the name of another user is up to the caller to check by comparing
+// skeletons.
+func (p *Policy) Check(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return &Violation{Reason: ReasonRequired}
+	}
+
+	name = norm.NFC.String(name)
+	switch n := utf8.RuneCountInString(name); {
+	case n < p.cfg.MinLength:
+		return &Violation{Reason: ReasonTooShort}
+	case p.cfg.MaxLength > 0 && n > p.cfg.MaxLength:
+		return &Violation{Reason: ReasonTooLong}
+	}
+
+	if !p.allowed.MatchString(name) {
+		return &Violation{Reason: ReasonCharacters}
+	}
+
+	sk := Skeleton(name)
+	if p.reserved[sk] {
+		return &Violation{Reason: ReasonReserved}
+	}
+	if p.denied[sk] {
+		return &Violation{Reason: ReasonDenied}
+	}
+
+	// Separators are ignored, so words can not be split up with them.
+	c := compact(sk)
+	for _, word := range p.profanity {
+		if word != "" && strings.Contains(c, word) {
+			return &Violation{Reason: ReasonProfane}
+		}
+	}
+
+	return nil
+}
+
+// Skeleton returns the skeleton of the name. Names which look alike have the
+// same skeleton. It follows UTS #39: the name is decomposed, each character
+// is replaced by its prototype and the result is decomposed again. Unlike
+// the standard, skeletons are also case-insensitive. Since I looks like l,
+// that makes i and l alike as well.
+func Skeleton(name string) string {
+	sk := skeleton(strings.ToLower(skeleton(name)))
+	return strings.Replace(sk, "i", "l", -1)
+}
+
+// skeleton maps the characters of the name to their prototypes.
+func skeleton(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		if ignorable[r] {
+			continue
+		}
+		if p, ok := confusables[r]; ok {
+			b.WriteString(p)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return norm.NFD.String(b.String())
+}
+
+// compact removes separators and spaces from a skeleton.
+func compact(sk string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == '.' || r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, sk)
+}
+
+// readList reads the entries of a list file.
+func readList(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []string
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entries = append(entries, line)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
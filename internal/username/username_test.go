@@ -0,0 +1,102 @@
+package username
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+func TestPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "username")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	profanity := filepath.Join(dir, "profanity.txt")
+	denylist := filepath.Join(dir, "denylist.txt")
+	if err := ioutil.WriteFile(profanity, []byte("# words\nbadword\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(denylist, []byte("\nimpostor\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := New(Config{
+		MinLength:     3,
+		MaxLength:     16,
+		Allowed:       `\p{L}\p{N}_.-`,
+		Reserved:      []string{"admin", "support"},
+		ProfanityFile: profanity,
+		DenylistFile:  denylist,
+	})
+	if err != nil {
+		t.Fatalf("\t%s\tShould be able to construct a policy : %s.", failed, err)
+	}
+
+	tests := []struct {
+		name   string
+		reason string
+	}{
+		{"gopher", ""},
+		{"go_pher.42", ""},
+		{"   ", ReasonRequired},
+		{"go", ReasonTooShort},
+		{"a_very_long_user_name", ReasonTooLong},
+		{"go pher", ReasonCharacters},
+		{"gopher!", ReasonCharacters},
+		{"Admin", ReasonReserved},
+		{"\u0430dmin", ReasonReserved},
+		{"SUPP0RT", ReasonReserved},
+		{"Impostor", ReasonDenied},
+		{"the_bad.word", ReasonProfane},
+	}
+
+	t.Log("Given the need to enforce the user name policy.")
+	{
+		for _, tt := range tests {
+			err := p.Check(tt.name)
+			var reason string
+			if v, ok := err.(*Violation); ok {
+				reason = v.Reason
+			} else if err != nil {
+				t.Fatalf("\t%s\tShould only return violations : %q got %v.", failed, tt.name, err)
+			}
+			if reason != tt.reason {
+				t.Fatalf("\t%s\tShould check %q : got %q, want %q.", failed, tt.name, reason, tt.reason)
+			}
+		}
+		t.Logf("\t%s\tShould reject names which break the policy.", success)
+	}
+
+	t.Log("Given the need to detect lookalike names.")
+	{
+		alike := [][2]string{
+			{"paypal", "p\u0430yp\u0430l"},
+			{"gopher", "GOPHER"},
+			{"bill", "bi1l"},
+			{"modern", "rnodern"},
+			{"gopher", "go\u200bpher"},
+			{"gopher", "\uff47opher"},
+			{"jos\u00e9", "jose\u0301"},
+		}
+		for _, a := range alike {
+			if Skeleton(a[0]) != Skeleton(a[1]) {
+				t.Fatalf("\t%s\tShould find %q and %q alike : %q %q.", failed, a[0], a[1], Skeleton(a[0]), Skeleton(a[1]))
+			}
+		}
+		t.Logf("\t%s\tShould give lookalike names the same skeleton.", success)
+
+		if Skeleton("gopher") == Skeleton("gofer") {
+			t.Fatalf("\t%s\tShould tell different names apart.", failed)
+		}
+		t.Logf("\t%s\tShould tell different names apart.", success)
+	}
+}
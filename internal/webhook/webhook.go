@@ -0,0 +1,385 @@
+// Package webhook notifies subscribed URLs of events on users. Events are
+// queued in the database for every subscribed webhook and delivered in the
+// background as signed requests, failed attempts are retried with
+// exponential backoff.
+//
+// Every request carries the time it was signed at in the Webhook-Timestamp
+// header and the signature in the Webhook-Signature header. The signature is
+// "v1=" followed by the hex encoded HMAC-SHA256 of the timestamp, a dot and
+// the body, keyed with the secret of the webhook. Receivers check it with
+// Verify.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"go.opencensus.io/trace"
+
+	"github.com/igomonov88/users/internal/platform/encryption"
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+)
+
+// Headers of the requests of deliveries.
+const (
+	HeaderEvent     = "Webhook-Event"
+	HeaderDelivery  = "Webhook-Delivery"
+	HeaderTimestamp = "Webhook-Timestamp"
+	HeaderSignature = "Webhook-Signature"
+)
+
+// signaturePrefix is the version of the signature scheme.
+const signaturePrefix = "v1="
+
+// AllEvents subscribes a webhook to every event.
+const AllEvents = "*"
+
+// Events are the events webhooks can subscribe to. They are the actions
+// audit entries are recorded for.
+var Events = []string{
+	storage.AuditUserCreated,
+	storage.AuditUserUpdated,
+	storage.AuditUserDeleted,
+	storage.AuditPasswordChanged,
+	storage.AuditLogin,
+}
+
+var (
+	// ErrUnknownEvent is returned when a webhook subscribes to an event
+	// which does not exist.
+	ErrUnknownEvent = errors.New("unknown webhook event")
+
+	// ErrInvalidSignature is returned by Verify for requests which are not
+	// signed with the secret or which are too old.
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// maxError is the longest error of an attempt which is stored.
+const maxError = 512
+
+// Config is the required properties to use webhooks.
+type Config struct {
+
+	// Timeout bounds an attempt to deliver.
+	Timeout time.Duration
+
+	// MaxAttempts is how often a delivery is attempted before it is dead.
+	MaxAttempts int
+
+	// MinBackoff is the wait after the first failed attempt. It doubles
+	// with every further failed attempt up to MaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// DisableAfter is the number of failed attempts in a row after which a
+	// webhook is disabled.
+	DisableAfter int
+
+	// BatchSize is how many deliveries are attempted at once.
+	BatchSize int
+}
+
+// Payload is the body of the requests of deliveries. The id identifies the
+// event, it is the same for every webhook the event is delivered to.
+type Payload struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      Data      `json:"data"`
+}
+
+// Data describes what happened in an event. The actor is empty for
+// anonymous requests.
+type Data struct {
+	UserID  string `json:"user_id"`
+	ActorID string `json:"actor_id,omitempty"`
+}
+
+// Webhooks manages webhooks and delivers events to them. Secrets are
+// encrypted before they are stored.
+type Webhooks struct {
+	cfg    Config
+	db     *sqlx.DB
+	enc    *encryption.Encrypter
+	client *http.Client
+	log    *logger.Logger
+}
+
+// New constructs Webhooks for use. Deliveries are sent with the client,
+// redirects are not followed.
+func New(cfg Config, db *sqlx.DB, enc *encryption.Encrypter, client *http.Client, log *logger.Logger) *Webhooks {
+	c := *client
+	c.Timeout = cfg.Timeout
+	c.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &Webhooks{
+		cfg:    cfg,
+		db:     db,
+		enc:    enc,
+		client: &c,
+		log:    log,
+	}
+}
+
+// Create subscribes the URL to the events. A secret is generated when none
+// is provided. It returns the webhook and its secret, the secret is never
+// available again.
+func (w *Webhooks) Create(ctx context.Context, url string, events []string, secret string, now time.Time) (*storage.Webhook, string, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.webhook.Create")
+	defer span.End()
+
+	for _, e := range events {
+		if !known(e) {
+			return nil, "", errors.Wrapf(ErrUnknownEvent, "event %q", e)
+		}
+	}
+
+	if secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, "", errors.Wrap(err, "generating secret")
+		}
+		secret = hex.EncodeToString(b)
+	}
+
+	hook := storage.Webhook{
+		ID:        uuid.New().String(),
+		URL:       url,
+		Events:    events,
+		CreatedAt: now.UTC(),
+	}
+
+	sealed, err := w.enc.Seal([]byte(secret), []byte(hook.ID))
+	if err != nil {
+		return nil, "", errors.Wrap(err, "encrypting secret")
+	}
+	hook.Secret = sealed
+
+	if err := storage.CreateWebhook(ctx, w.db, hook); err != nil {
+		return nil, "", err
+	}
+
+	return &hook, secret, nil
+}
+
+// Publish queues the event for every webhook subscribed to it. The deliveries
+// carry the id of the request being handled with the context, so receivers
+// can correlate them with the logs of the service.
+func (w *Webhooks) Publish(ctx context.Context, event string, data Data, now time.Time) error {
+	ctx, span := trace.StartSpan(ctx, "internal.webhook.Publish")
+	defer span.End()
+
+	p := Payload{
+		ID:        uuid.New().String(),
+		Event:     event,
+		CreatedAt: now.UTC(),
+		Data:      data,
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		return errors.Wrapf(err, "encoding payload of %q", event)
+	}
+
+	if _, err := storage.CreateDeliveries(ctx, w.db, event, web.RequestID(ctx), body, now); err != nil {
+		return errors.Wrapf(err, "queueing %q", event)
+	}
+
+	return nil
+}
+
+// Deliver attempts the deliveries which are due at the time, up to the
+// size of a batch at once. It returns the number of attempts.
+func (w *Webhooks) Deliver(ctx context.Context, now time.Time) (int, error) {
+	ctx, span := trace.StartSpan(ctx, "internal.webhook.Deliver")
+	defer span.End()
+
+	// Claimed deliveries are attempted again after the lease if an attempt
+	// is never recorded, for example when the service stops.
+	lease := now.Add(2 * w.cfg.Timeout)
+	deliveries, err := storage.ClaimDeliveries(ctx, w.db, now, lease, w.cfg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	if len(deliveries) == 0 {
+		return 0, nil
+	}
+
+	hooks, err := storage.ListWebhooks(ctx, w.db)
+	if err != nil {
+		return 0, err
+	}
+	byID := make(map[string]storage.Webhook, len(hooks))
+	for _, h := range hooks {
+		byID[h.ID] = h
+	}
+
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		hook, ok := byID[d.WebhookID]
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(hook storage.Webhook, d storage.WebhookDelivery) {
+			defer wg.Done()
+			if err := w.attempt(ctx, hook, d, now); err != nil {
+				w.log.Error("webhook : Deliver", "delivery_id", d.ID, "error", err)
+			}
+		}(hook, d)
+	}
+	wg.Wait()
+
+	return len(deliveries), nil
+}
+
+// attempt sends the delivery to the webhook at the time and records the
+// outcome.
+func (w *Webhooks) attempt(ctx context.Context, hook storage.Webhook, d storage.WebhookDelivery, now time.Time) error {
+	secret, err := w.enc.Open(hook.Secret, []byte(hook.ID))
+	if err != nil {
+		return errors.Wrapf(err, "decrypting secret of %q", hook.ID)
+	}
+
+	start := time.Now()
+	a := w.send(ctx, hook.URL, secret, d, now)
+	a.DurationMS = int64(time.Since(start) / time.Millisecond)
+
+	d.Attempts++
+	switch {
+	case a.Error == "":
+		d.State = storage.DeliveryDelivered
+		d.DeliveredAt.Time, d.DeliveredAt.Valid = a.CreatedAt, true
+	case d.Attempts >= w.cfg.MaxAttempts:
+		d.State = storage.DeliveryDead
+	default:
+		d.NextAttemptAt = a.CreatedAt.Add(w.backoff(d.Attempts))
+	}
+
+	return storage.RecordDeliveryAttempt(ctx, w.db, a, d, w.cfg.DisableAfter)
+}
+
+// send makes the request of the delivery to the URL, signed with the secret
+// at the time. Attempts fail unless the response has a 2xx status.
+func (w *Webhooks) send(ctx context.Context, url string, secret []byte, d storage.WebhookDelivery, now time.Time) storage.WebhookAttempt {
+	a := storage.WebhookAttempt{
+		ID:         uuid.New().String(),
+		DeliveryID: d.ID,
+		CreatedAt:  now.UTC(),
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(d.Payload))
+	if err != nil {
+		a.Error = truncate(err.Error())
+		return a
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, d.Event)
+	req.Header.Set(HeaderDelivery, d.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(secret, now, d.Payload))
+	if d.RequestID != "" {
+		req.Header.Set(web.RequestIDHeader, d.RequestID)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		a.Error = truncate(err.Error())
+		return a
+	}
+	defer resp.Body.Close()
+
+	// Drain a bit of the body so the connection can be reused.
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4<<10))
+
+	a.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		a.Error = "unexpected status " + resp.Status
+	}
+
+	return a
+}
+
+// backoff returns how long to wait after the number of failed attempts.
+func (w *Webhooks) backoff(attempts int) time.Duration {
+	d := w.cfg.MinBackoff
+	for i := 1; i < attempts && d < w.cfg.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > w.cfg.MaxBackoff {
+		d = w.cfg.MaxBackoff
+	}
+	return d
+}
+
+// Sign returns the signature of the body sent at the time.
+func Sign(secret []byte, now time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(strconv.FormatInt(now.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature of a request of a delivery with the secret
+// and that it was signed no longer than the tolerance before the time, so
+// captured requests can't be replayed later.
+func Verify(secret []byte, header http.Header, body []byte, now time.Time, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(header.Get(HeaderTimestamp), 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	signed := time.Unix(ts, 0)
+	if now.Sub(signed) > tolerance || signed.Sub(now) > tolerance {
+		return ErrInvalidSignature
+	}
+
+	if !hmac.Equal([]byte(header.Get(HeaderSignature)), []byte(Sign(secret, signed, body))) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+// known reports if webhooks can subscribe to the event.
+func known(event string) bool {
+	if event == AllEvents {
+		return true
+	}
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate shortens errors of attempts to the length which is stored.
+func truncate(s string) string {
+	if len(s) > maxError {
+		return strings.TrimSpace(s[:maxError])
+	}
+	return s
+}
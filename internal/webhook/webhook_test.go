@@ -0,0 +1,277 @@
+package webhook_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/igomonov88/users/internal/platform/encryption"
+	"github.com/igomonov88/users/internal/platform/logger"
+	"github.com/igomonov88/users/internal/platform/web"
+	"github.com/igomonov88/users/internal/storage"
+	"github.com/igomonov88/users/internal/tests"
+	"github.com/igomonov88/users/internal/webhook"
+)
+
+// Success and failure markers.
+const (
+	success = "\u2713"
+	failed  = "\u2717"
+)
+
+// tolerance is how old requests receivers accept. Deliveries are attempted
+// at times up to a few hours ahead.
+const tolerance = 24 * time.Hour
+
+// receiver is a webhook receiver which verifies the signature of every
+// request it gets and responds with its status.
+type receiver struct {
+	*httptest.Server
+	secret []byte
+
+	mu       sync.Mutex
+	status   int
+	payloads []webhook.Payload
+	ids      []string
+	invalid  int
+}
+
+// newReceiver starts a receiver checking signatures with the secret.
+func newReceiver(secret string, status int) *receiver {
+	rc := receiver{secret: []byte(secret), status: status}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+
+		if err := webhook.Verify(rc.secret, r.Header, body, time.Now(), tolerance); err != nil {
+			rc.invalid++
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var p webhook.Payload
+		json.Unmarshal(body, &p)
+		rc.payloads = append(rc.payloads, p)
+		rc.ids = append(rc.ids, r.Header.Get(web.RequestIDHeader))
+		w.WriteHeader(rc.status)
+	}))
+	return &rc
+}
+
+// respond changes the status the receiver responds with.
+func (rc *receiver) respond(status int) {
+	rc.mu.Lock()
+	rc.status = status
+	rc.mu.Unlock()
+}
+
+// received returns the number of requests with a valid and an invalid
+// signature.
+func (rc *receiver) received() (int, int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.payloads), rc.invalid
+}
+
+func TestVerify(t *testing.T) {
+	secret := []byte("0123456789abcdef")
+	body := []byte(`{"event":"user.created"}`)
+	now := time.Now()
+
+	rc := newReceiver(string(secret), http.StatusNoContent)
+	defer rc.Close()
+
+	post := func(sig string, signed time.Time, body []byte) int {
+		req, _ := http.NewRequest(http.MethodPost, rc.URL, bytes.NewReader(body))
+		req.Header.Set(webhook.HeaderTimestamp, strconv.FormatInt(signed.Unix(), 10))
+		req.Header.Set(webhook.HeaderSignature, sig)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name   string
+		sig    string
+		signed time.Time
+		body   []byte
+		status int
+	}{
+		{"signed request", webhook.Sign(secret, now, body), now, body, http.StatusNoContent},
+		{"changed body", webhook.Sign(secret, now, body), now, []byte(`{"event":"user.deleted"}`), http.StatusUnauthorized},
+		{"request signed with another secret", webhook.Sign([]byte("fedcba9876543210"), now, body), now, body, http.StatusUnauthorized},
+		{"replayed request", webhook.Sign(secret, now.Add(-2*tolerance), body), now.Add(-2 * tolerance), body, http.StatusUnauthorized},
+		{"unsigned request", "", now, body, http.StatusUnauthorized},
+	}
+
+	t.Log("Given the need to verify the signature of deliveries.")
+	{
+		for i, tt := range tests {
+			if status := post(tt.sig, tt.signed, tt.body); status != tt.status {
+				t.Fatalf("\t%s\tTest %d:\tShould respond to a %s with %d : got %d.", failed, i, tt.name, tt.status, status)
+			}
+			t.Logf("\t%s\tTest %d:\tShould respond to a %s with %d.", success, i, tt.name, tt.status)
+		}
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	db, teardown := tests.NewUnit(t)
+	defer teardown()
+
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Second)
+
+	enc, err := encryption.New("ZGV2ZWxvcG1lbnQta2V5LWRvLW5vdC11c2UtaW4tcHI=")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := webhook.Config{
+		Timeout:      5 * time.Second,
+		MaxAttempts:  3,
+		MinBackoff:   time.Minute,
+		MaxBackoff:   90 * time.Second,
+		DisableAfter: 4,
+		BatchSize:    10,
+	}
+	hooks := webhook.New(cfg, db, enc, http.DefaultClient, logger.New(ioutil.Discard, logger.Error))
+
+	ok := newReceiver("ok-receiver-secret", http.StatusNoContent)
+	defer ok.Close()
+	broken := newReceiver("broken-receiver-secret", http.StatusInternalServerError)
+	defer broken.Close()
+
+	deliver := func(at time.Time, want int) {
+		t.Helper()
+		n, err := hooks.Deliver(ctx, at)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to deliver : %s.", failed, err)
+		}
+		if n != want {
+			t.Fatalf("\t%s\tShould attempt %d deliveries at %s : got %d.", failed, want, at.Sub(now), n)
+		}
+	}
+
+	latest := func(webhookID string) storage.WebhookDelivery {
+		t.Helper()
+		ds, err := storage.ListDeliveries(ctx, db, webhookID, 10)
+		if err != nil || len(ds) == 0 {
+			t.Fatalf("\t%s\tShould be able to list the deliveries of %q : %v.", failed, webhookID, err)
+		}
+		return ds[0]
+	}
+
+	t.Log("Given the need to deliver events to webhooks.")
+	{
+		if _, _, err := hooks.Create(ctx, ok.URL, []string{"user.unknown"}, "", now); errors.Cause(err) != webhook.ErrUnknownEvent {
+			t.Fatalf("\t%s\tShould reject unknown events : got %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould reject unknown events.", success)
+
+		a, _, err := hooks.Create(ctx, ok.URL, []string{storage.AuditUserCreated}, "ok-receiver-secret", now)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create a webhook : %s.", failed, err)
+		}
+		b, _, err := hooks.Create(ctx, broken.URL, []string{webhook.AllEvents}, "broken-receiver-secret", now)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to create a webhook : %s.", failed, err)
+		}
+		if _, _, err := hooks.Create(ctx, ok.URL, []string{storage.AuditUserDeleted}, "ok-receiver-secret", now); err != nil {
+			t.Fatalf("\t%s\tShould be able to create a webhook : %s.", failed, err)
+		}
+
+		data := webhook.Data{UserID: "45b5fbd3-755f-4379-8f07-a58d4a30fa2f"}
+		published := tests.Context()
+		if err := hooks.Publish(published, storage.AuditUserCreated, data, now); err != nil {
+			t.Fatalf("\t%s\tShould be able to publish an event : %s.", failed, err)
+		}
+
+		deliver(now, 2)
+		if n, invalid := ok.received(); n != 1 || invalid != 0 || ok.payloads[0].Event != storage.AuditUserCreated || ok.payloads[0].Data != data {
+			t.Fatalf("\t%s\tShould deliver the signed event to the subscribed webhooks only : got %d valid, %d invalid %+v.", failed, n, invalid, ok.payloads)
+		}
+		if d := latest(a.ID); d.State != storage.DeliveryDelivered || !d.DeliveredAt.Valid {
+			t.Fatalf("\t%s\tShould mark the delivery delivered : got %+v.", failed, d)
+		}
+		t.Logf("\t%s\tShould deliver the signed event to the subscribed webhooks only.", success)
+
+		if id := web.RequestID(published); ok.ids[0] != id {
+			t.Fatalf("\t%s\tShould send the id of the request the event happened in : got %q, want %q.", failed, ok.ids[0], id)
+		}
+		t.Logf("\t%s\tShould send the id of the request the event happened in.", success)
+
+		d := latest(b.ID)
+		if d.State != storage.DeliveryPending || d.Attempts != 1 || !d.NextAttemptAt.Equal(now.Add(time.Minute)) {
+			t.Fatalf("\t%s\tShould retry a failed delivery after the backoff : got %+v.", failed, d)
+		}
+		deliver(now.Add(30*time.Second), 0)
+		deliver(now.Add(time.Minute), 1)
+		if d := latest(b.ID); d.Attempts != 2 || !d.NextAttemptAt.Equal(now.Add(time.Minute+90*time.Second)) {
+			t.Fatalf("\t%s\tShould double the backoff up to the maximum : got %+v.", failed, d)
+		}
+		t.Logf("\t%s\tShould retry a failed delivery with exponential backoff.", success)
+
+		deliver(now.Add(time.Minute+90*time.Second), 1)
+		if d := latest(b.ID); d.State != storage.DeliveryDead || d.Attempts != 3 {
+			t.Fatalf("\t%s\tShould give up after the maximum attempts : got %+v.", failed, d)
+		}
+		deliver(now.Add(time.Hour), 0)
+
+		attempts, err := storage.ListDeliveryAttempts(ctx, db, []string{d.ID})
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to list the attempts : %s.", failed, err)
+		}
+		if len(attempts) != 3 || attempts[0].StatusCode != http.StatusInternalServerError || attempts[0].Error == "" {
+			t.Fatalf("\t%s\tShould record every attempt : got %+v.", failed, attempts)
+		}
+		t.Logf("\t%s\tShould move a delivery to the dead letters after the maximum attempts.", success)
+
+		later := now.Add(2 * time.Hour)
+		if err := storage.Redeliver(ctx, db, b.ID, d.ID, later); err != nil {
+			t.Fatalf("\t%s\tShould be able to redeliver : %s.", failed, err)
+		}
+		deliver(later, 1)
+		hook, err := storage.RetrieveWebhook(ctx, db, b.ID)
+		if err != nil {
+			t.Fatalf("\t%s\tShould be able to retrieve the webhook : %s.", failed, err)
+		}
+		if hook.Failures != 4 || !hook.DisabledAt.Valid {
+			t.Fatalf("\t%s\tShould disable a webhook after repeated failures : got %+v.", failed, hook)
+		}
+		deliver(later.Add(time.Hour), 0)
+		t.Logf("\t%s\tShould disable a webhook after repeated failures.", success)
+
+		if err := storage.Redeliver(ctx, db, a.ID, d.ID, later); err != storage.ErrNotFound {
+			t.Fatalf("\t%s\tShould not redeliver deliveries of other webhooks : got %v.", failed, err)
+		}
+		t.Logf("\t%s\tShould not redeliver deliveries of other webhooks.", success)
+
+		broken.respond(http.StatusOK)
+		if err := storage.EnableWebhook(ctx, db, b.ID); err != nil {
+			t.Fatalf("\t%s\tShould be able to enable the webhook : %s.", failed, err)
+		}
+		deliver(later.Add(time.Hour), 1)
+		if d := latest(b.ID); d.State != storage.DeliveryDelivered {
+			t.Fatalf("\t%s\tShould deliver once the webhook is enabled : got %+v.", failed, d)
+		}
+		if hook, _ := storage.RetrieveWebhook(ctx, db, b.ID); hook.Failures != 0 || hook.DisabledAt.Valid {
+			t.Fatalf("\t%s\tShould reset the failures of the webhook : got %+v.", failed, hook)
+		}
+		t.Logf("\t%s\tShould redeliver once the webhook is enabled.", success)
+	}
+}